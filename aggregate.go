@@ -0,0 +1,474 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// numericFieldTypes is the field types that fieldStats tool can aggregate.
+var numericFieldTypes = map[string]bool{
+	"NUMBER": true,
+	"CALC":   true,
+}
+
+// statsPercentiles is the percentiles that fieldStats tool reports.
+var statsPercentiles = []int{10, 25, 50, 75, 90}
+
+// lookupField finds the field in the app, including the fields in tables.
+// The table is the code of the table that contains the field, or an empty string if the field is not in a table.
+func lookupField(fields map[string]formField, code string) (f formField, table string, ok bool) {
+	if f, ok := fields[code]; ok {
+		return f, "", true
+	}
+	for table, t := range fields {
+		if f, ok := t.Fields[code]; ok {
+			return f, table, true
+		}
+	}
+	return formField{}, "", false
+}
+
+// fieldValues calls fn with the values of the field in the record. A field in a table has a value for each row.
+func fieldValues(record map[string]any, code, table string, fn func(value any)) {
+	if table == "" {
+		field, _ := record[code].(map[string]any)
+		fn(field["value"])
+		return
+	}
+
+	field, _ := record[table].(map[string]any)
+	rows, _ := field["value"].([]any)
+	for _, row := range rows {
+		row, _ := row.(map[string]any)
+		cells, _ := row["value"].(map[string]any)
+		cell, _ := cells[code].(map[string]any)
+		fn(cell["value"])
+	}
+}
+
+// statsNumber formats a statistic in 15 significant digits, to hide the rounding errors of the floating point numbers such as 0.30000000000000004.
+func statsNumber(v float64) json.Number {
+	return json.Number(strconv.FormatFloat(v, 'g', 15, 64))
+}
+
+// percentile returns the p-th percentile of the sorted values, interpolating between the closest ranks in the same way as PERCENTILE.INC of Excel.
+func percentile(sorted []float64, p int) float64 {
+	pos := float64(len(sorted)-1) * float64(p) / 100
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(pos-float64(lower))
+}
+
+type FieldStatsParams struct {
+	AppID string `json:"appID" required:"true" description:"The app ID to aggregate records in."`
+	Field string `json:"field" required:"true" description:"The field code of the number or calculated field to aggregate. A field in a table is aggregated over all rows."`
+	Query string `json:"query" description:"The query to filter records. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed. Default is all records."`
+}
+
+func (h *KintoneHandlers) FieldStats(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req FieldStatsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" || req.Field == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Arguments 'appID' and 'field' are required",
+		}
+	}
+
+	if err := h.checkPermissions(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	f, table, ok := lookupField(fields, req.Field)
+	if !ok {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Unknown field code: %s. Please check the field codes by using 'readAppInfo' tool.", req.Field),
+		}
+	}
+	if !h.fieldFilter(req.AppID).allowed(table, req.Field) {
+		return nil, fieldDeniedError(req.AppID, req.Field)
+	}
+	if !numericFieldTypes[f.Type] {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Field %s is a %s field. Only number and calculated fields can be aggregated.", req.Field, f.Type),
+		}
+	}
+
+	fetch := req.Field
+	if table != "" {
+		fetch = table
+	}
+
+	var values []float64
+	records, empty := 0, 0
+	sum := 0.0
+	query := h.recordDefaults(req.AppID).mergeQuery(req.Query)
+	err = h.eachRecordByCursor(ctx, req.AppID, query, []string{fetch}, func(record map[string]any) error {
+		fieldValues(record, req.Field, table, func(value any) {
+			s, _ := value.(string)
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+				// Empty values, and the errors of calculated fields such as "#N/A!".
+				empty++
+				return
+			}
+			values = append(values, v)
+			sum += v
+		})
+
+		records++
+		if records%cursorSize == 0 {
+			ReportProgress(ctx, float64(records), 0, fmt.Sprintf("Aggregated %d records", records))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := JsonMap{
+		"appID":   req.AppID,
+		"field":   req.Field,
+		"records": records,
+		"count":   len(values),
+		"empty":   empty,
+	}
+	if table != "" {
+		result["table"] = table
+	}
+	if len(values) > 0 {
+		slices.Sort(values)
+		percentiles := make(JsonMap, len(statsPercentiles))
+		for _, p := range statsPercentiles {
+			percentiles[fmt.Sprintf("p%d", p)] = statsNumber(percentile(values, p))
+		}
+		result["sum"] = statsNumber(sum)
+		result["min"] = statsNumber(values[0])
+		result["max"] = statsNumber(values[len(values)-1])
+		result["average"] = statsNumber(sum / float64(len(values)))
+		result["percentiles"] = percentiles
+	}
+	return JSONContent(result)
+}
+
+// dateFieldTypes is the field types that chartData tool can bucket records by.
+var dateFieldTypes = map[string]bool{
+	"DATE":         true,
+	"DATETIME":     true,
+	"CREATED_TIME": true,
+	"UPDATED_TIME": true,
+}
+
+const (
+	// maxChartBuckets is the maximum number of buckets in a response of chartData tool, including the empty buckets between the first and the last.
+	maxChartBuckets = 1000
+
+	// maxChartGroups is the maximum number of groups in a response of chartData tool. The smaller groups are merged into chartOthersGroup.
+	maxChartGroups = 20
+
+	// chartOthersGroup is the name of the group that the smaller groups are merged into.
+	chartOthersGroup = "(others)"
+)
+
+// chartBucket returns the first day of the bucket that contains the date or time value, in the timezone of the value.
+func chartBucket(value, interval string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, value); err != nil {
+			return time.Time{}, false
+		}
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case "week":
+		// Weeks start on Monday, as ISO 8601.
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), true
+	case "month":
+		return day.AddDate(0, 0, 1-day.Day()), true
+	default:
+		return day, true
+	}
+}
+
+// nextChartBucket returns the first day of the bucket after the bucket that starts at t.
+func nextChartBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// chartBucketLabel formats the bucket such as "2025-01" for months, or "2025-01-06" for the days and the weeks.
+func chartBucketLabel(t time.Time, interval string) string {
+	if interval == "month" {
+		return t.Format("2006-01")
+	}
+	return t.Format(time.DateOnly)
+}
+
+// chartGroups returns the groups of the value of the field. A field that has multiple values, such as check boxes, puts the record into all of its groups.
+func chartGroups(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case map[string]any:
+		return []string{entityLabel(v)}
+	case []any:
+		if len(v) == 0 {
+			return []string{""}
+		}
+		groups := make([]string, 0, len(v))
+		for _, x := range v {
+			groups = append(groups, chartGroups(x)...)
+		}
+		return groups
+	default:
+		return []string{""}
+	}
+}
+
+type ChartDataParams struct {
+	AppID     string `json:"appID" required:"true" description:"The app ID to aggregate records in."`
+	DateField string `json:"dateField" required:"true" description:"The field code of the date or datetime field to bucket records by, such as the created time."`
+	Interval  string `json:"interval" enum:"day,week,month" description:"The size of the buckets. Weeks start on Monday. Default is 'month'."`
+	GroupBy   string `json:"groupBy" description:"The field code to split each bucket into groups, such as a drop-down or a status. Default is no grouping."`
+	SumField  string `json:"sumField" description:"The field code of the number or calculated field to sum in each bucket. Default is to count records."`
+	Query     string `json:"query" description:"The query to filter records. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed. Default is all records."`
+}
+
+func (h *KintoneHandlers) ChartData(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req ChartDataParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" || req.DateField == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Arguments 'appID' and 'dateField' are required",
+		}
+	}
+	if req.Interval == "" {
+		req.Interval = "month"
+	}
+	if req.Interval != "day" && req.Interval != "week" && req.Interval != "month" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Interval must be 'day', 'week', or 'month'",
+		}
+	}
+
+	if err := h.checkPermissions(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	filter := h.fieldFilter(req.AppID)
+	for _, code := range []string{req.DateField, req.GroupBy, req.SumField} {
+		if code == "" {
+			continue
+		}
+		f, ok := fields[code]
+		if !ok {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Unknown field code: %s. Please check the field codes by using 'readAppInfo' tool. Fields in tables can't be used.", code),
+			}
+		}
+		if !filter.allowed("", code) {
+			return nil, fieldDeniedError(req.AppID, code)
+		}
+		if f.Type == "SUBTABLE" || f.Type == "FILE" {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Field %s is a %s field, which can't be used to aggregate records.", code, f.Type),
+			}
+		}
+	}
+	if t := fields[req.DateField].Type; !dateFieldTypes[t] {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Field %s is a %s field. Only date, datetime, created time, and updated time fields can be used as 'dateField'.", req.DateField, t),
+		}
+	}
+	if req.SumField != "" && !numericFieldTypes[fields[req.SumField].Type] {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Field %s is a %s field. Only number and calculated fields can be aggregated.", req.SumField, fields[req.SumField].Type),
+		}
+	}
+
+	codes := []string{req.DateField}
+	for _, code := range []string{req.GroupBy, req.SumField} {
+		if code != "" && !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+
+	// totals[group][bucket] is the count or the sum of the records.
+	totals := make(map[string]map[time.Time]float64)
+	var first, last time.Time
+	records, undated := 0, 0
+	query := h.recordDefaults(req.AppID).mergeQuery(req.Query)
+	err = h.eachRecordByCursor(ctx, req.AppID, query, codes, func(record map[string]any) error {
+		records++
+		if records%cursorSize == 0 {
+			ReportProgress(ctx, float64(records), 0, fmt.Sprintf("Aggregated %d records", records))
+		}
+
+		date, _ := record[req.DateField].(map[string]any)
+		s, _ := date["value"].(string)
+		bucket, ok := chartBucket(s, req.Interval)
+		if !ok {
+			undated++
+			return nil
+		}
+		if first.IsZero() || bucket.Before(first) {
+			first = bucket
+		}
+		if last.IsZero() || bucket.After(last) {
+			last = bucket
+		}
+
+		value := 1.0
+		if req.SumField != "" {
+			field, _ := record[req.SumField].(map[string]any)
+			s, _ := field["value"].(string)
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+				return nil
+			}
+			value = v
+		}
+
+		groups := []string{""}
+		if req.GroupBy != "" {
+			field, _ := record[req.GroupBy].(map[string]any)
+			groups = chartGroups(field["value"])
+		}
+		for _, g := range groups {
+			if totals[g] == nil {
+				totals[g] = make(map[time.Time]float64)
+			}
+			totals[g][bucket] += value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []time.Time
+	if !first.IsZero() {
+		for t := first; !t.After(last); t = nextChartBucket(t, req.Interval) {
+			if len(buckets) >= maxChartBuckets {
+				return nil, jsonrpc2.Error{
+					Code:    jsonrpc2.InvalidParamsCode,
+					Message: fmt.Sprintf("Too many buckets: the records span more than %d %ss. Please use a larger interval or narrow down the records by the query.", maxChartBuckets, req.Interval),
+				}
+			}
+			buckets = append(buckets, t)
+		}
+	}
+	labels := make([]string, len(buckets))
+	for i, t := range buckets {
+		labels[i] = chartBucketLabel(t, req.Interval)
+	}
+
+	series := func(totals map[time.Time]float64) ([]json.Number, float64) {
+		values := make([]json.Number, len(buckets))
+		sum := 0.0
+		for i, t := range buckets {
+			values[i] = statsNumber(totals[t])
+			sum += totals[t]
+		}
+		return values, sum
+	}
+
+	result := JsonMap{
+		"appID":     req.AppID,
+		"dateField": req.DateField,
+		"interval":  req.Interval,
+		"records":   records,
+		"undated":   undated,
+		"buckets":   labels,
+	}
+	if req.SumField != "" {
+		result["sumField"] = req.SumField
+	}
+
+	if req.GroupBy == "" {
+		values, total := series(totals[""])
+		result["values"] = values
+		result["total"] = statsNumber(total)
+		return JSONContent(result)
+	}
+
+	type group struct {
+		name   string
+		values []json.Number
+		total  float64
+	}
+	groups := make([]group, 0, len(totals))
+	for name, t := range totals {
+		values, total := series(t)
+		groups = append(groups, group{name, values, total})
+	}
+	slices.SortFunc(groups, func(a, b group) int {
+		if c := cmp.Compare(b.total, a.total); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+
+	if len(groups) > maxChartGroups {
+		others := make(map[time.Time]float64)
+		for _, g := range groups[maxChartGroups-1:] {
+			for t, v := range totals[g.name] {
+				others[t] += v
+			}
+		}
+		result["otherGroups"] = len(groups) - maxChartGroups + 1
+		values, total := series(others)
+		groups = append(groups[:maxChartGroups-1], group{chartOthersGroup, values, total})
+	}
+
+	list := make([]JsonMap, len(groups))
+	for i, g := range groups {
+		list[i] = JsonMap{"group": g.name, "values": g.values, "total": statsNumber(g.total)}
+	}
+	result["groupBy"] = req.GroupBy
+	result["series"] = list
+	return JSONContent(result)
+}
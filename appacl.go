@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// aclEntity is the target of a permission or a notification in the settings of the app.
+type aclEntity struct {
+	Type string `json:"type" required:"true" enum:"USER,GROUP,ORGANIZATION,CREATOR,FIELD_ENTITY" description:"The type of the entity. CREATOR is the creator of the app or the record, and FIELD_ENTITY is the entities in a user, group, or organization field of the record."`
+	Code string `json:"code,omitempty" description:"The code of the user, the group, or the organization, or the field code for FIELD_ENTITY. Use 'everyone' with GROUP for all users. Not needed for CREATOR."`
+}
+
+type AppACLRight struct {
+	Entity           aclEntity `json:"entity" required:"true"`
+	IncludeSubs      bool      `json:"includeSubs" description:"If true, the permission also applies to the child organizations. Only for ORGANIZATION."`
+	AppEditable      bool      `json:"appEditable" description:"Manage the app."`
+	RecordViewable   bool      `json:"recordViewable" description:"View records."`
+	RecordAddable    bool      `json:"recordAddable" description:"Add records."`
+	RecordEditable   bool      `json:"recordEditable" description:"Edit records."`
+	RecordDeletable  bool      `json:"recordDeletable" description:"Delete records."`
+	RecordImportable bool      `json:"recordImportable" description:"Import records from files."`
+	RecordExportable bool      `json:"recordExportable" description:"Export records to files."`
+}
+
+type RecordACLRight struct {
+	FilterCond string            `json:"filterCond" description:"The query to select the records that the permissions apply to, such as 'status in (\"Done\")'. Empty for all records."`
+	Entities   []RecordACLEntity `json:"entities" required:"true" description:"The permissions of the records in the order of priority."`
+}
+
+type RecordACLEntity struct {
+	Entity      aclEntity `json:"entity" required:"true"`
+	IncludeSubs bool      `json:"includeSubs" description:"If true, the permission also applies to the child organizations. Only for ORGANIZATION."`
+	Viewable    bool      `json:"viewable" description:"View the records."`
+	Editable    bool      `json:"editable" description:"Edit the records."`
+	Deletable   bool      `json:"deletable" description:"Delete the records."`
+}
+
+type FieldACLRight struct {
+	Code     string           `json:"code" required:"true" description:"The field code."`
+	Entities []FieldACLEntity `json:"entities" required:"true" description:"The permissions of the field in the order of priority."`
+}
+
+type FieldACLEntity struct {
+	Entity        aclEntity `json:"entity" required:"true"`
+	IncludeSubs   bool      `json:"includeSubs" description:"If true, the permission also applies to the child organizations. Only for ORGANIZATION."`
+	Accessibility string    `json:"accessibility" required:"true" enum:"READ,WRITE,NONE" description:"READ to view the field, WRITE to view and edit it, and NONE to hide it."`
+}
+
+type UpdateAppACLParams struct {
+	AppID             string        `json:"appID" required:"true" description:"The app ID to change the permissions."`
+	Rights            []AppACLRight `json:"rights" required:"true" description:"All permissions of the app in the order of priority. They replace the current permissions, so include the current ones to keep."`
+	ConfirmationToken string        `json:"confirmationToken" description:"The confirmation token that is returned by the previous call of this tool. The first call returns the current permissions and the changes with a token, and nothing is changed."`
+}
+
+type UpdateRecordACLParams struct {
+	AppID             string           `json:"appID" required:"true" description:"The app ID to change the permissions."`
+	Rights            []RecordACLRight `json:"rights" required:"true" description:"All record permissions of the app in the order of priority. They replace the current permissions, so include the current ones to keep."`
+	ConfirmationToken string           `json:"confirmationToken" description:"The confirmation token that is returned by the previous call of this tool. The first call returns the current permissions and the changes with a token, and nothing is changed."`
+}
+
+type UpdateFieldACLParams struct {
+	AppID             string          `json:"appID" required:"true" description:"The app ID to change the permissions."`
+	Rights            []FieldACLRight `json:"rights" required:"true" description:"All field permissions of the app. They replace the current permissions, so include the current ones to keep. The fields that are not included can be viewed and edited by everyone who can view the records."`
+	ConfirmationToken string          `json:"confirmationToken" description:"The confirmation token that is returned by the previous call of this tool. The first call returns the current permissions and the changes with a token, and nothing is changed."`
+}
+
+func (h *KintoneHandlers) UpdateAppACL(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req UpdateAppACLParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	return h.updatePreviewSettings(ctx, previewSettingsUpdate{
+		Tool:              "updateAppAcl",
+		Path:              "/k/v1/preview/app/acl.json",
+		AppID:             req.AppID,
+		What:              "permissions",
+		Key:               "rights",
+		Items:             req.Rights,
+		ConfirmationToken: req.ConfirmationToken,
+	})
+}
+
+func (h *KintoneHandlers) UpdateRecordACL(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req UpdateRecordACLParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	return h.updatePreviewSettings(ctx, previewSettingsUpdate{
+		Tool:              "updateRecordAcl",
+		Path:              "/k/v1/preview/record/acl.json",
+		AppID:             req.AppID,
+		What:              "permissions",
+		Key:               "rights",
+		Items:             req.Rights,
+		ConfirmationToken: req.ConfirmationToken,
+	})
+}
+
+func (h *KintoneHandlers) UpdateFieldACL(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req UpdateFieldACLParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	return h.updatePreviewSettings(ctx, previewSettingsUpdate{
+		Tool:              "updateFieldAcl",
+		Path:              "/k/v1/preview/field/acl.json",
+		AppID:             req.AppID,
+		What:              "permissions",
+		Key:               "rights",
+		Items:             req.Rights,
+		ConfirmationToken: req.ConfirmationToken,
+	})
+}
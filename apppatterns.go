@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// appNamesRefreshInterval is how often the app names are fetched again to match name patterns.
+const appNamesRefreshInterval = 10 * time.Minute
+
+// AppPatterns is a list of patterns to select apps, which is used for KINTONE_ALLOW_APPS and KINTONE_DENY_APPS.
+// Each pattern is an app ID such as "12", a range such as "100-199", a wildcard such as "1*", or a glob of app names such as "name:Sales*".
+type AppPatterns struct {
+	ids       []string
+	ranges    [][2]uint64
+	idGlobs   []string
+	nameGlobs []string
+
+	mu       sync.RWMutex
+	names    map[string]string
+	resolved bool
+}
+
+// ParseAppPatterns parses the patterns.
+func ParseAppPatterns(patterns []string) (*AppPatterns, error) {
+	var p AppPatterns
+	for _, s := range patterns {
+		if s == "" {
+			continue
+		}
+		if glob, ok := strings.CutPrefix(s, "name:"); ok {
+			if _, err := path.Match(glob, ""); err != nil || glob == "" {
+				return nil, fmt.Errorf("invalid app name pattern: %s", s)
+			}
+			p.nameGlobs = append(p.nameGlobs, glob)
+		} else if lo, hi, ok := strings.Cut(s, "-"); ok {
+			l, err1 := strconv.ParseUint(strings.TrimSpace(lo), 10, 64)
+			h, err2 := strconv.ParseUint(strings.TrimSpace(hi), 10, 64)
+			if err1 != nil || err2 != nil || l > h {
+				return nil, fmt.Errorf("invalid app ID range: %s", s)
+			}
+			p.ranges = append(p.ranges, [2]uint64{l, h})
+		} else if strings.ContainsAny(s, "*?") {
+			if strings.Trim(s, "0123456789*?") != "" {
+				return nil, fmt.Errorf("invalid app ID pattern: %s", s)
+			}
+			p.idGlobs = append(p.idGlobs, s)
+		} else {
+			if _, err := strconv.ParseUint(s, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid app ID: %s", s)
+			}
+			p.ids = append(p.ids, s)
+		}
+	}
+	return &p, nil
+}
+
+// IsEmpty reports whether no patterns are set.
+func (p *AppPatterns) IsEmpty() bool {
+	return p == nil || len(p.ids)+len(p.ranges)+len(p.idGlobs)+len(p.nameGlobs) == 0
+}
+
+// IsExact reports whether all patterns are exact app IDs.
+func (p *AppPatterns) IsExact() bool {
+	return p == nil || len(p.ranges)+len(p.idGlobs)+len(p.nameGlobs) == 0
+}
+
+// IDs returns the app IDs that are listed as exact IDs.
+func (p *AppPatterns) IDs() []string {
+	if p == nil {
+		return nil
+	}
+	return p.ids
+}
+
+// String returns the patterns in the format of KINTONE_ALLOW_APPS, such as "1, 100-199, name:Sales*".
+func (p *AppPatterns) String() string {
+	if p == nil {
+		return ""
+	}
+	patterns := slices.Clone(p.ids)
+	for _, r := range p.ranges {
+		patterns = append(patterns, fmt.Sprintf("%d-%d", r[0], r[1]))
+	}
+	patterns = append(patterns, p.idGlobs...)
+	for _, g := range p.nameGlobs {
+		patterns = append(patterns, "name:"+g)
+	}
+	return strings.Join(patterns, ", ")
+}
+
+// HasNamePatterns reports whether the patterns include globs of app names.
+func (p *AppPatterns) HasNamePatterns() bool {
+	return p != nil && len(p.nameGlobs) > 0
+}
+
+// Resolved reports whether the app names have been fetched to match name patterns.
+func (p *AppPatterns) Resolved() bool {
+	if !p.HasNamePatterns() {
+		return true
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.resolved
+}
+
+// setNames sets the names of apps to match name patterns.
+func (p *AppPatterns) setNames(names map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.names = names
+	p.resolved = true
+}
+
+// Match reports whether the app matches any of the patterns.
+func (p *AppPatterns) Match(id string) bool {
+	if p == nil {
+		return false
+	}
+
+	if slices.Contains(p.ids, id) {
+		return true
+	}
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		for _, r := range p.ranges {
+			if r[0] <= n && n <= r[1] {
+				return true
+			}
+		}
+	}
+	for _, glob := range p.idGlobs {
+		if ok, _ := path.Match(glob, id); ok {
+			return true
+		}
+	}
+
+	if len(p.nameGlobs) > 0 {
+		p.mu.RLock()
+		name, ok := p.names[id]
+		p.mu.RUnlock()
+		if ok {
+			for _, glob := range p.nameGlobs {
+				if ok, _ := path.Match(glob, name); ok {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// refreshAppNames fetches the names of all apps to match name patterns in KINTONE_ALLOW_APPS and KINTONE_DENY_APPS of the policy.
+func (h *KintoneHandlers) refreshAppNames(ctx context.Context, policy *Policy) error {
+	apps, err := h.fetchAllApps(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]string, len(apps))
+	for _, app := range apps {
+		names[app.AppID] = app.Name
+	}
+	for _, p := range []*AppPatterns{policy.Allow, policy.Deny} {
+		if p.HasNamePatterns() {
+			p.setNames(names)
+		}
+	}
+	return nil
+}
+
+// WatchAppNames resolves the name patterns, and refreshes them periodically until ctx is canceled.
+// The patterns are refreshed only while they are used, because they can be added by reloading the settings.
+func (h *KintoneHandlers) WatchAppNames(ctx context.Context) {
+	if p := h.policy(); p.Allow.HasNamePatterns() || p.Deny.HasNamePatterns() {
+		if err := h.refreshAppNames(ctx, p); err != nil {
+			serverLog.Warn("Failed to fetch app names for KINTONE_ALLOW_APPS or KINTONE_DENY_APPS", "error", err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(appNamesRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p := h.policy()
+				if !p.Allow.HasNamePatterns() && !p.Deny.HasNamePatterns() {
+					continue
+				}
+				if err := h.refreshAppNames(ctx, p); err != nil {
+					serverLog.Warn("Failed to refresh app names for KINTONE_ALLOW_APPS or KINTONE_DENY_APPS", "error", err)
+				}
+			}
+		}
+	}()
+}
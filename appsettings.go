@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+const (
+	// deployPollInterval is the interval to check the status of deploying app settings.
+	deployPollInterval = time.Second
+
+	// deployTimeout is how long deployAppSettings tool waits for the deployment to finish.
+	deployTimeout = time.Minute
+)
+
+// previewSettingsUpdate is a change of the settings in the preview environment of an app, such as the permissions or the notifications.
+// The changes are applied to the app only by deployAppSettings tool.
+type previewSettingsUpdate struct {
+	Tool  string
+	Path  string // The path of the API, such as "/k/v1/preview/app/acl.json".
+	AppID string
+	What  string // The name of the settings in the messages, such as "permissions".
+
+	// Key is the key of the list in the settings, such as "rights", and Items is the new list that replaces the current one.
+	Key   string
+	Items any
+
+	// Options is the other settings to change, such as "notifyToCommenter". The settings that are not included are not changed.
+	Options JsonMap
+
+	ConfirmationToken string
+}
+
+// updatePreviewSettings changes the settings in the preview environment of the app.
+// The first call only returns the current and the new settings and the difference with a confirmation token, and the settings are changed when called again with the token.
+func (h *KintoneHandlers) updatePreviewSettings(ctx context.Context, u previewSettingsUpdate) ([]Content, error) {
+	if u.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required",
+		}
+	}
+	if err := h.checkOperation(u.AppID, "manage"); err != nil {
+		return nil, err
+	}
+
+	var current map[string]any
+	if err := h.FetchHTTPWithJSON(ctx, "GET", u.Path, Query{"app": u.AppID}, nil, &current); err != nil {
+		return nil, err
+	}
+	revision := fmt.Sprint(current["revision"])
+
+	// The new settings are compared in the same form as the current settings.
+	signature, _ := json.Marshal(JsonMap{"items": u.Items, "options": u.Options})
+	var proposed struct {
+		Items   []any          `json:"items"`
+		Options map[string]any `json:"options"`
+	}
+	json.Unmarshal(signature, &proposed)
+
+	currentItems, _ := current[u.Key].([]any)
+	added, removed := diffSettings(currentItems, proposed.Items)
+	changes := JsonMap{"added": added, "removed": removed}
+	for k, v := range proposed.Options {
+		if !reflect.DeepEqual(current[k], v) {
+			changes[k] = JsonMap{"from": current[k], "to": v}
+		}
+	}
+
+	// The revision is a part of the target, so that the token becomes invalid if someone changes the settings after the preview.
+	target := fmt.Sprintf("%s\x00%s\x00%s\x00%s", u.Tool, u.AppID, revision, signature)
+	if u.ConfirmationToken == "" {
+		token, expires := h.confirmations.issue(target)
+		return JSONContent(JsonMap{
+			"confirmationRequired": true,
+			"confirmationToken":    token,
+			"expiresAt":            expires.Format(time.RFC3339),
+			"appID":                u.AppID,
+			"current":              current,
+			"changes":              changes,
+			"message":              fmt.Sprintf("The %s are not changed yet. Please show the changes to the user, and call %s again with the same arguments and this confirmationToken only if the user approves them.", u.What, u.Tool),
+		})
+	}
+	if !h.confirmations.consume(u.ConfirmationToken, target) {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("The confirmation token is invalid or expired, or the settings of the app have been changed after the token was issued. Please call %s again without 'confirmationToken' to get a new one.", u.Tool),
+		}
+	}
+
+	body := JsonMap{"app": u.AppID, u.Key: u.Items, "revision": revision}
+	for k, v := range u.Options {
+		body[k] = v
+	}
+	var res struct {
+		Revision string `json:"revision"`
+	}
+	if err := h.FetchHTTPWithJSON(ctx, "PUT", u.Path, nil, body, &res); err != nil {
+		return nil, err
+	}
+
+	return JSONContent(JsonMap{
+		"success":  true,
+		"appID":    u.AppID,
+		"revision": res.Revision,
+		"changes":  changes,
+		"deployed": false,
+		"message":  fmt.Sprintf("The %s are changed in the preview environment, but not applied to the app yet. Call deployAppSettings tool with the app ID to apply them.", u.What),
+	})
+}
+
+// diffSettings returns the items that are only in the new settings and only in the current settings.
+// The items are compared as JSON without null values, because kintone returns null for the omitted values such as the code of CREATOR.
+func diffSettings(current, proposed []any) (added, removed []any) {
+	key := func(v any) string {
+		bs, _ := json.Marshal(withoutNulls(v))
+		return string(bs)
+	}
+	count := make(map[string]int)
+	for _, v := range current {
+		count[key(v)]++
+	}
+	added, removed = []any{}, []any{}
+	for _, v := range proposed {
+		if k := key(v); count[k] > 0 {
+			count[k]--
+		} else {
+			added = append(added, v)
+		}
+	}
+	for _, v := range current {
+		if k := key(v); count[k] > 0 {
+			count[k]--
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
+
+// withoutNulls returns a copy of the JSON value without the null values in the objects.
+func withoutNulls(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			if x != nil {
+				m[k] = withoutNulls(x)
+			}
+		}
+		return m
+	case []any:
+		l := make([]any, len(v))
+		for i, x := range v {
+			l[i] = withoutNulls(x)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+type DeployAppSettingsParams struct {
+	AppID  string `json:"appID" required:"true" description:"The app ID to deploy the settings."`
+	Revert bool   `json:"revert" description:"If true, the changes in the preview environment are discarded instead of being applied. Default is false."`
+}
+
+func (h *KintoneHandlers) DeployAppSettings(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req DeployAppSettingsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required",
+		}
+	}
+	if err := h.checkOperation(req.AppID, "manage"); err != nil {
+		return nil, err
+	}
+
+	err := h.FetchHTTPWithJSON(ctx, "POST", "/k/v1/preview/app/deploy.json", nil, JsonMap{
+		"apps":   []JsonMap{{"app": req.AppID}},
+		"revert": req.Revert,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The deployment runs in the background of kintone, so the status is checked until it finishes.
+	ctx, cancel := context.WithTimeout(ctx, deployTimeout)
+	defer cancel()
+	ticker := time.NewTicker(deployPollInterval)
+	defer ticker.Stop()
+	for {
+		var res struct {
+			Apps []struct {
+				App    string `json:"app"`
+				Status string `json:"status"`
+			} `json:"apps"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/preview/app/deploy.json", nil, JsonMap{"apps": []string{req.AppID}}, &res); err != nil {
+			return nil, err
+		}
+		status := "PROCESSING"
+		if len(res.Apps) > 0 {
+			status = res.Apps[0].Status
+		}
+
+		switch status {
+		case "SUCCESS":
+			h.invalidateCache(ctx, req.AppID)
+			return JSONContent(JsonMap{"success": true, "appID": req.AppID, "status": status, "reverted": req.Revert})
+		case "PROCESSING":
+		default:
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to deploy the settings of app ID %s: the status is %s.", req.AppID, status),
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return JSONContent(JsonMap{
+				"success": false,
+				"appID":   req.AppID,
+				"status":  status,
+				"message": "The deployment is still in progress. Please check the settings of the app later.",
+			})
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// maxAppStatisticsApps is the maximum number of apps that appStatistics tool reads at once.
+const maxAppStatisticsApps = 100
+
+type AppStatisticsParams struct {
+	AppIDs []string `json:"appIDs" required:"true" description:"The app IDs to get the statistics. Up to 100 apps. Use 'listApps' tool to get the app IDs."`
+}
+
+// appStatistics is the statistics of an app in the result of appStatistics tool.
+type appStatistics struct {
+	AppID             string         `json:"appID"`
+	Name              string         `json:"name,omitempty"`
+	Records           *int           `json:"records,omitempty"`
+	LastUpdatedAt     string         `json:"lastRecordUpdatedAt,omitempty"`
+	AppModifiedAt     string         `json:"appModifiedAt,omitempty"`
+	Views             *int           `json:"views,omitempty"`
+	Fields            map[string]int `json:"fields,omitempty"`
+	ProcessManagement *bool          `json:"processManagement,omitempty"`
+	Error             string         `json:"error,omitempty"`
+}
+
+func (h *KintoneHandlers) AppStatistics(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req AppStatisticsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.AppIDs) == 0 {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appIDs' is required",
+		}
+	}
+	if len(req.AppIDs) > maxAppStatisticsApps {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Too many apps: %d. Up to %d apps can be read at once.", len(req.AppIDs), maxAppStatisticsApps),
+		}
+	}
+
+	// An app that can't be read is reported in the result, so that the statistics of the other apps are still returned.
+	stats := make([]appStatistics, len(req.AppIDs))
+	err := h.forEachConcurrently(ctx, len(req.AppIDs), func(ctx context.Context, i int) error {
+		id := req.AppIDs[i]
+		s, err := h.appStatistics(ctx, id)
+		if err != nil {
+			s = appStatistics{AppID: id, Error: importErrorMessage(err)}
+		}
+		stats[i] = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return JSONContent(JsonMap{"apps": stats})
+}
+
+// appStatistics collects the statistics of the app.
+func (h *KintoneHandlers) appStatistics(ctx context.Context, appID string) (appStatistics, error) {
+	if err := h.checkPermissions(appID); err != nil {
+		return appStatistics{}, err
+	}
+
+	app, err := h.fetchAppDetail(ctx, appID, appDetailOptions{Views: true})
+	if err != nil {
+		return appStatistics{}, err
+	}
+
+	views := len(app.Views)
+	process := app.ProcessManagement.Enable
+	s := appStatistics{
+		AppID:             appID,
+		Name:              app.Name,
+		AppModifiedAt:     app.ModifiedAt,
+		Views:             &views,
+		Fields:            make(map[string]int),
+		ProcessManagement: &process,
+	}
+
+	var updatedField string
+	for code, prop := range app.Properties {
+		prop, _ := prop.(map[string]any)
+		typ, _ := prop["type"].(string)
+		s.Fields[typ]++
+		if typ == "UPDATED_TIME" {
+			updatedField = code
+		}
+	}
+
+	// The total count and the latest update are read by a single request.
+	query := "order by $id desc limit 1"
+	fields := []string{"$id"}
+	if updatedField != "" {
+		query = fmt.Sprintf("order by %s desc limit 1", updatedField)
+		fields = []string{updatedField}
+	}
+	var latest map[string]any
+	res, err := h.fetchRecords(ctx, "GET", "/k/v1/records.json", nil, JsonMap{
+		"app":        appID,
+		"query":      query,
+		"fields":     fields,
+		"totalCount": true,
+	}, func(record map[string]any) error {
+		latest = record
+		return nil
+	})
+	if err != nil {
+		return appStatistics{}, err
+	}
+	if total, err := strconv.Atoi(fmt.Sprint(res["totalCount"])); err == nil {
+		s.Records = &total
+	}
+	if updated, ok := latest[updatedField].(map[string]any); ok && updatedField != "" {
+		if t, err := time.Parse(time.RFC3339, fmt.Sprint(updated["value"])); err == nil {
+			s.LastUpdatedAt = t.In(h.timezone(ctx)).Format(time.RFC3339)
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// appToolNamePattern is the format of AppConfiguration.ToolName, which is used as a part of tool names.
+var appToolNamePattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// appTool is a tool that is generated from the fields of an app, such as createCustomerRecord.
+type appTool struct {
+	Name      string
+	AppID     string
+	Operation string // "create" or "update"
+}
+
+// appTools returns the tools that are generated for the apps that have toolName in the configuration file.
+func (c Configuration) appTools() []appTool {
+	var tools []appTool
+	for _, id := range slices.Sorted(maps.Keys(c.Apps)) {
+		if name := c.Apps[id].ToolName; name != "" && id != "*" {
+			tools = append(tools,
+				appTool{Name: "create" + name + "Record", AppID: id, Operation: "create"},
+				appTool{Name: "update" + name + "Record", AppID: id, Operation: "update"},
+			)
+		}
+	}
+	return tools
+}
+
+// findAppTool returns the generated tool that has the name.
+func (h *KintoneHandlers) findAppTool(name string) (appTool, bool) {
+	for _, t := range h.policy().Config.appTools() {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return appTool{}, false
+}
+
+// formField is a field definition in the response of /k/v1/app/form/fields.json.
+type formField struct {
+	Type     string `json:"type"`
+	Code     string `json:"code"`
+	Label    string `json:"label"`
+	Required bool   `json:"required"`
+	Options  map[string]struct {
+		Index string `json:"index"`
+	} `json:"options"`
+	Fields map[string]formField `json:"fields"`
+}
+
+// formFields returns the field definitions of the app.
+func (h *KintoneHandlers) formFields(ctx context.Context, appID string) (map[string]formField, error) {
+	var res struct {
+		Properties map[string]formField `json:"properties"`
+	}
+	if err := h.fetchAppSchema(ctx, "/k/v1/app/form/fields.json", appID, &res); err != nil {
+		return nil, err
+	}
+	return res.Properties, nil
+}
+
+// writableFieldTypes is the types of fields that can be set by creating or updating records.
+var writableFieldTypes = []string{
+	"SINGLE_LINE_TEXT", "MULTI_LINE_TEXT", "RICH_TEXT", "LINK", "NUMBER",
+	"DATE", "TIME", "DATETIME",
+	"DROP_DOWN", "RADIO_BUTTON", "CHECK_BOX", "MULTI_SELECT",
+	"USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT",
+	"FILE", "SUBTABLE",
+}
+
+// optionNames returns the options of a selection field in the order of the form.
+func (f formField) optionNames() []string {
+	names := slices.Collect(maps.Keys(f.Options))
+	slices.SortFunc(names, func(a, b string) int {
+		x, _ := strconv.Atoi(f.Options[a].Index)
+		y, _ := strconv.Atoi(f.Options[b].Index)
+		return x - y
+	})
+	return names
+}
+
+// schema returns the JSON schema for the value of the field in the arguments of generated tools.
+func (f formField) schema(filter fieldFilter) JsonMap {
+	s := JsonMap{"description": f.Label}
+
+	switch f.Type {
+	case "NUMBER":
+		s["type"] = "number"
+	case "DATE":
+		s["type"] = "string"
+		s["format"] = "date"
+	case "TIME":
+		s["type"] = "string"
+		s["description"] = f.Label + " (HH:MM)"
+	case "DATETIME":
+		s["type"] = "string"
+		s["format"] = "date-time"
+	case "DROP_DOWN", "RADIO_BUTTON":
+		s["type"] = "string"
+		s["enum"] = f.optionNames()
+	case "CHECK_BOX", "MULTI_SELECT":
+		s["type"] = "array"
+		s["items"] = JsonMap{"type": "string", "enum": f.optionNames()}
+	case "USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT":
+		s["type"] = "array"
+		s["items"] = JsonMap{"type": "string"}
+		s["description"] = f.Label + " (the codes of users, organizations, or groups)"
+	case "FILE":
+		s["type"] = "array"
+		s["items"] = JsonMap{"type": "string"}
+		s["description"] = f.Label + " (the file keys returned by 'uploadAttachmentFile' tool)"
+	case "SUBTABLE":
+		props := JsonMap{}
+		for _, code := range slices.Sorted(maps.Keys(f.Fields)) {
+			sub := f.Fields[code]
+			if slices.Contains(writableFieldTypes, sub.Type) && filter.allowed(f.Code, code) {
+				props[code] = sub.schema(filter)
+			}
+		}
+		s["type"] = "array"
+		s["items"] = JsonMap{"type": "object", "properties": props}
+		s["description"] = f.Label + " (the rows of the table)"
+	default:
+		s["type"] = "string"
+	}
+
+	return s
+}
+
+// recordValue converts the value in the arguments of generated tools or in the simple format into the value of kintone records.
+// Users and files can be either codes or objects as kintone returns, and rows of tables can have the row ID as "$id".
+// The values that are not lists for the fields of lists are kept as is, and converted by coerceRecord later.
+func (f formField) recordValue(v any) any {
+	list, ok := v.([]any)
+	if !ok && v != nil && slices.Contains([]string{"USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT", "FILE", "SUBTABLE"}, f.Type) {
+		return v
+	}
+
+	switch f.Type {
+	case "USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT":
+		entities := make([]any, 0, len(list))
+		for _, code := range list {
+			if _, ok := code.(map[string]any); ok {
+				entities = append(entities, code)
+			} else {
+				entities = append(entities, JsonMap{"code": code})
+			}
+		}
+		return entities
+	case "FILE":
+		files := make([]any, 0, len(list))
+		for _, key := range list {
+			if _, ok := key.(map[string]any); ok {
+				files = append(files, key)
+			} else {
+				files = append(files, JsonMap{"fileKey": key})
+			}
+		}
+		return files
+	case "SUBTABLE":
+		rows := make([]JsonMap, 0, len(list))
+		for _, row := range list {
+			cells := JsonMap{}
+			var id any
+			if row, ok := row.(map[string]any); ok {
+				for code, v := range row {
+					if code == rowIDKey {
+						id = v
+						continue
+					}
+					cells[code] = JsonMap{"value": f.Fields[code].recordValue(v)}
+				}
+			}
+			if id != nil {
+				rows = append(rows, JsonMap{"id": id, "value": cells})
+			} else {
+				rows = append(rows, JsonMap{"value": cells})
+			}
+		}
+		return rows
+	default:
+		// kintone accepts numbers as strings, which keeps the precision of large numbers.
+		if n, ok := v.(json.Number); ok {
+			return n.String()
+		}
+		return v
+	}
+}
+
+// appToolInfo builds the tool definition from the fields of the app.
+func (h *KintoneHandlers) appToolInfo(ctx context.Context, t appTool) (ToolInfo, error) {
+	fields, err := h.formFields(ctx, t.AppID)
+	if err != nil {
+		return ToolInfo{}, err
+	}
+	filter := h.fieldFilter(t.AppID)
+
+	props := JsonMap{}
+	var required []string
+	for _, code := range slices.Sorted(maps.Keys(fields)) {
+		f := fields[code]
+		if !slices.Contains(writableFieldTypes, f.Type) || !filter.allowed("", code) {
+			continue
+		}
+		props[code] = f.schema(filter)
+		if f.Required && t.Operation == "create" {
+			required = append(required, code)
+		}
+	}
+
+	info := ToolInfo{Name: t.Name, InputSchema: JsonMap{"type": "object", "properties": props}}
+	if t.Operation == "create" {
+		info.Description = fmt.Sprintf("Create a new record in the kintone app %s. The arguments are the values of the fields.", t.AppID)
+		info.Annotations = JsonMap{
+			"title":           fmt.Sprintf("Create a record in kintone app %s", t.AppID),
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		}
+	} else {
+		info.Description = fmt.Sprintf("Update the specified record in the kintone app %s. The arguments are the record ID and the values of the fields to change.", t.AppID)
+		info.Annotations = JsonMap{
+			"title":           fmt.Sprintf("Update a record in kintone app %s", t.AppID),
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		}
+		props["recordID"] = JsonMap{"description": "The record ID to update.", "type": "string"}
+		required = append(required, "recordID")
+	}
+	if len(required) > 0 {
+		info.InputSchema["required"] = required
+	}
+
+	return info, nil
+}
+
+// CallAppTool converts the arguments of a generated tool into a record, and creates or updates it.
+func (h *KintoneHandlers) CallAppTool(ctx context.Context, t appTool, params json.RawMessage) ([]Content, error) {
+	var args map[string]any
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.UseNumber()
+	if err := dec.Decode(&args); err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Failed to parse parameters: %v", err),
+		}
+	}
+
+	fields, err := h.formFields(ctx, t.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordID, _ := args["recordID"].(string)
+	if t.Operation == "update" {
+		delete(args, "recordID")
+		if recordID == "" {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: "Argument 'recordID' is required",
+			}
+		}
+	}
+	delete(args, "profile")
+
+	record := JsonMap{}
+	for code, v := range args {
+		f, ok := fields[code]
+		if !ok || !slices.Contains(writableFieldTypes, f.Type) {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Unknown field code: %s. Please check the input schema of the tool.", code),
+			}
+		}
+		record[code] = JsonMap{"value": f.recordValue(v)}
+	}
+
+	req := JsonMap{"appID": t.AppID, "record": record}
+	if t.Operation == "create" {
+		bs, _ := json.Marshal(req)
+		return h.CreateRecord(ctx, bs)
+	}
+	req["recordID"] = recordID
+	bs, _ := json.Marshal(req)
+	return h.UpdateRecord(ctx, bs)
+}
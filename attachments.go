@@ -0,0 +1,476 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+type kintoneField struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kintoneFileValue struct {
+	FileKey     string `json:"fileKey"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        string `json:"size"`
+}
+
+type recordAttachment struct {
+	RecordID  string
+	FieldCode string
+	kintoneFileValue
+}
+
+// collectAttachments returns attachment files in the record, including files in tables.
+// table is the code of the table that contains the record, or an empty string for the top level.
+func collectAttachments(recordID string, record map[string]kintoneField, filter fieldFilter, table string) []recordAttachment {
+	var files []recordAttachment
+
+	for _, code := range slices.Sorted(maps.Keys(record)) {
+		if !filter.allowed(table, code) {
+			continue
+		}
+		field := record[code]
+		switch field.Type {
+		case "FILE":
+			var values []kintoneFileValue
+			if err := json.Unmarshal(field.Value, &values); err != nil {
+				continue
+			}
+			for _, v := range values {
+				files = append(files, recordAttachment{RecordID: recordID, FieldCode: code, kintoneFileValue: v})
+			}
+		case "SUBTABLE":
+			var rows []struct {
+				Value map[string]kintoneField `json:"value"`
+			}
+			if err := json.Unmarshal(field.Value, &rows); err != nil {
+				continue
+			}
+			for _, row := range rows {
+				files = append(files, collectAttachments(recordID, row.Value, filter, code)...)
+			}
+		}
+	}
+
+	return files
+}
+
+const maxDownloadRetries = 3
+
+// downloadFile starts downloading the file from kintone.
+// The body of the returned response resumes the download automatically if the connection is interrupted.
+func (h *KintoneHandlers) downloadFile(ctx context.Context, fileKey string) (*http.Response, error) {
+	res, err := h.SendHTTP(ctx, "GET", "/k/v1/file.json", Query{"fileKey": fileKey}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = &resumableBody{
+		ctx:     ctx,
+		h:       h,
+		fileKey: fileKey,
+		body:    res.Body,
+	}
+	return res, nil
+}
+
+// resumableBody is an io.ReadCloser that re-requests the rest of the file when reading is failed.
+// It uses Range request if the server supports, otherwise it downloads the file again from the start and skips the bytes already read.
+type resumableBody struct {
+	ctx     context.Context
+	h       *KintoneHandlers
+	fileKey string
+	body    io.ReadCloser
+	offset  int64
+	retries int
+}
+
+func (r *resumableBody) Read(p []byte) (int, error) {
+	for {
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if err == nil || errors.Is(err, io.EOF) {
+			return n, err
+		}
+		if n > 0 {
+			// Return the data read so far. The error will be reported again by the next Read.
+			return n, nil
+		}
+
+		if r.retries >= maxDownloadRetries || r.ctx.Err() != nil {
+			return 0, err
+		}
+		r.retries++
+		serverLog.WarnContext(r.ctx, "Download interrupted, retrying", "fileKey", r.fileKey, "offset", r.offset, "retry", r.retries, "maxRetries", maxDownloadRetries, "error", err)
+
+		select {
+		case <-r.ctx.Done():
+			return 0, err
+		case <-time.After(time.Duration(r.retries) * time.Second):
+		}
+
+		if err := r.reopen(); err != nil {
+			r.body = errorBody{err}
+		}
+	}
+}
+
+// reopen replaces the body with a new response that starts from the current offset.
+func (r *resumableBody) reopen() error {
+	r.body.Close()
+
+	auth, err := r.h.auth(r.ctx)
+	if err != nil {
+		return err
+	}
+
+	endpoint := auth.URL.JoinPath("/k/v1/file.json")
+	endpoint.RawQuery = Query{"fileKey": r.fileKey}.Encode()
+	req, err := http.NewRequestWithContext(r.ctx, "GET", endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+
+	res, err := r.h.SendRequest(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusPartialContent || !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", r.offset)) {
+		// The server ignored the Range header, so skip the bytes already read.
+		if _, err := io.CopyN(io.Discard, res.Body, r.offset); err != nil {
+			res.Body.Close()
+			return err
+		}
+	}
+
+	r.body = res.Body
+	return nil
+}
+
+func (r *resumableBody) Close() error {
+	return r.body.Close()
+}
+
+// errorBody is an io.ReadCloser that always fails with err.
+type errorBody struct {
+	err error
+}
+
+func (b errorBody) Read([]byte) (int, error) {
+	return 0, b.err
+}
+
+func (b errorBody) Close() error {
+	return nil
+}
+
+type attachmentManifestEntry struct {
+	RecordID    string `json:"recordID"`
+	FieldCode   string `json:"fieldCode"`
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	Path        string `json:"path"`
+}
+
+// zipEntryName makes an unique entry name in the zip archive.
+func zipEntryName(used map[string]bool, dir, name string) string {
+	name = sanitizeFileName(name)
+	if name == "" {
+		name = "file"
+	}
+
+	p := path.Join(dir, name)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; used[p]; i++ {
+		p = path.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+	used[p] = true
+
+	return p
+}
+
+type DownloadRecordAttachmentsParams struct {
+	AppID  string   `json:"appID" required:"true" description:"The app ID to download attachment files from."`
+	Query  string   `json:"query" description:"The query to filter records. Query format is the same as kintone's query format. Default is all records."`
+	Fields []string `json:"fields" description:"The field codes of the attachment fields to download. Default is all attachment fields."`
+	Limit  *int     `json:"limit" description:"The maximum number of records to read. Default is 100, maximum is 500."`
+	Bundle bool     `json:"bundle" description:"If true, all files are bundled into a single zip file with a manifest.json. Default is false."`
+}
+
+func (h *KintoneHandlers) DownloadRecordAttachments(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req DownloadRecordAttachmentsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required",
+		}
+	}
+
+	if req.Limit == nil {
+		limit := 100
+		req.Limit = &limit
+	} else if *req.Limit < 1 || *req.Limit > 500 {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Limit must be between 1 and 500",
+		}
+	}
+
+	if err := h.checkPermissions(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+
+	dir, err := h.downloadDirectory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq := JsonMap{
+		"app":   req.AppID,
+		"query": req.Query,
+		"limit": *req.Limit,
+	}
+	if len(req.Fields) > 0 {
+		httpReq["fields"] = append(req.Fields, "$id")
+	}
+
+	acl, err := h.fieldACL(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	var records struct {
+		Records []json.RawMessage `json:"records"`
+	}
+	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/records.json", nil, httpReq, &records); err != nil {
+		return nil, err
+	}
+
+	filter := h.fieldFilter(req.AppID)
+	var files []recordAttachment
+	for _, raw := range records.Records {
+		raw, err := acl.filterRawRecord(raw)
+		if err != nil {
+			return nil, err
+		}
+		var record map[string]kintoneField
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, err
+		}
+
+		var id string
+		json.Unmarshal(record["$id"].Value, &id)
+		delete(record, "$id")
+		files = append(files, collectAttachments(id, record, filter, "")...)
+	}
+
+	if len(files) == 0 {
+		return JSONContent(JsonMap{
+			"success": true,
+			"files":   []attachmentManifestEntry{},
+		})
+	}
+
+	if req.Bundle {
+		return h.downloadAttachmentsAsZip(ctx, dir, req.AppID, files)
+	}
+
+	manifest := make([]attachmentManifestEntry, len(files))
+	var done atomic.Int64
+	err = h.forEachConcurrently(ctx, len(files), func(ctx context.Context, i int) error {
+		f := files[i]
+
+		httpRes, err := h.downloadFile(ctx, f.FileKey)
+		if err != nil {
+			return err
+		}
+		saved, err := saveDownloadedFile(dir, httpRes.Body, f.Name, false)
+		httpRes.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		manifest[i] = attachmentManifestEntry{
+			RecordID:    f.RecordID,
+			FieldCode:   f.FieldCode,
+			FileName:    f.Name,
+			ContentType: f.ContentType,
+			Size:        saved.Size,
+			SHA256:      saved.SHA256,
+			Path:        saved.Path,
+		}
+		ReportProgress(ctx, float64(done.Add(1)), float64(len(files)), fmt.Sprintf("Downloaded %s", f.Name))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ReportProgress(ctx, float64(len(files)), float64(len(files)), "Finished downloading")
+
+	return JSONContent(JsonMap{
+		"success": true,
+		"files":   manifest,
+	})
+}
+
+func (h *KintoneHandlers) downloadAttachmentsAsZip(ctx context.Context, dir, appID string, files []recordAttachment) ([]Content, error) {
+	tmpFile, err := os.CreateTemp(dir, ".kintone-download-*")
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to create zip file: %v", err),
+			Data:    JsonMap{"directory": dir},
+		}
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+	defer tmpFile.Close()
+
+	zw := zip.NewWriter(tmpFile)
+	used := make(map[string]bool)
+	manifest := make([]attachmentManifestEntry, 0, len(files))
+
+	// The files are downloaded in parallel into temporary files, and then added to the zip file in order.
+	parts := make([]*os.File, len(files))
+	defer func() {
+		for _, p := range parts {
+			if p != nil {
+				p.Close()
+				os.Remove(p.Name())
+			}
+		}
+	}()
+
+	var done atomic.Int64
+	err = h.forEachConcurrently(ctx, len(files), func(ctx context.Context, i int) error {
+		f := files[i]
+
+		httpRes, err := h.downloadFile(ctx, f.FileKey)
+		if err != nil {
+			return err
+		}
+		defer httpRes.Body.Close()
+
+		part, err := os.CreateTemp(dir, ".kintone-download-*")
+		if err != nil {
+			return jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to create file for attachment: %v", err),
+				Data:    JsonMap{"directory": dir},
+			}
+		}
+		parts[i] = part
+
+		if _, err := io.Copy(part, httpRes.Body); err != nil {
+			return jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to download attachment file: %s: %v", f.Name, err),
+			}
+		}
+		ReportProgress(ctx, float64(done.Add(1)), float64(len(files)), fmt.Sprintf("Downloaded %s", f.Name))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range files {
+		name := zipEntryName(used, path.Join(f.RecordID, f.FieldCode), f.Name)
+
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to write zip file: %v", err),
+			}
+		}
+
+		hash := sha256.New()
+		_, err = parts[i].Seek(0, io.SeekStart)
+		var size int64
+		if err == nil {
+			size, err = io.Copy(io.MultiWriter(w, hash), parts[i])
+		}
+		if err != nil {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to write zip file: %s: %v", f.Name, err),
+			}
+		}
+
+		manifest = append(manifest, attachmentManifestEntry{
+			RecordID:    f.RecordID,
+			FieldCode:   f.FieldCode,
+			FileName:    f.Name,
+			ContentType: f.ContentType,
+			Size:        size,
+			SHA256:      hex.EncodeToString(hash.Sum(nil)),
+			Path:        name,
+		})
+	}
+
+	ReportProgress(ctx, float64(len(files)), float64(len(files)), "Finished downloading")
+
+	w, err := zw.Create("manifest.json")
+	if err == nil {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(JsonMap{"appID": appID, "files": manifest})
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = tmpFile.Close()
+	}
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to write zip file: %v", err),
+		}
+	}
+
+	outPath := getDownloadFilePath(dir, fmt.Sprintf("kintone-app%s-attachments.zip", appID))
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to save zip file: %s: %v", outPath, err),
+		}
+	}
+
+	return JSONContent(JsonMap{
+		"success": true,
+		"zipPath": outPath,
+		"files":   manifest,
+	})
+}
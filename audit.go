@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry is a line of the audit log, which records an operation that modifies kintone data.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestID,omitempty"`
+	Tool      string    `json:"tool"`
+	AppID     string    `json:"appID,omitempty"`
+	RecordID  string    `json:"recordID,omitempty"`
+	Profile   string    `json:"profile,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	Digest    string    `json:"digest"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// AuditLog is an append-only JSONL file to record write operations.
+// The file is rotated when it exceeds maxSize, and up to maxFiles old files are kept as path.1, path.2, and so on.
+type AuditLog struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+// OpenAuditLog opens the audit log file to append.
+// If maxSize is 0, the file is never rotated.
+func OpenAuditLog(path string, maxSize int64, maxFiles int) (*AuditLog, error) {
+	l := &AuditLog{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *AuditLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file into path.1, shifts the old files, and opens a new file.
+func (l *AuditLog) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+
+	if l.maxFiles <= 0 {
+		os.Remove(l.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+		for i := l.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		}
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	}
+
+	return l.open()
+}
+
+// Write appends the entry to the file.
+func (l *AuditLog) Write(e AuditEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// Close closes the file.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// auditToolCall records the result of the tool call that modifies kintone data.
+func (h *KintoneHandlers) auditToolCall(ctx context.Context, params ToolsCallRequest, content []Content, err error) {
+	if h.AuditLog == nil || !h.isWriteCall(params) {
+		return
+	}
+
+	var args struct {
+		AppID    string `json:"appID"`
+		RecordID string `json:"recordID"`
+	}
+	json.Unmarshal(params.Arguments, &args)
+	var res struct {
+		RecordID             string `json:"recordID"`
+		ConfirmationRequired bool   `json:"confirmationRequired"`
+	}
+	if len(content) > 0 {
+		json.Unmarshal([]byte(content[0].Text), &res)
+	}
+	if args.RecordID == "" {
+		args.RecordID = res.RecordID
+	}
+	if t, ok := h.findAppTool(params.Name); ok {
+		args.AppID = t.AppID
+	}
+	if params.Name == "kintoneRequest" {
+		var req KintoneRequestParams
+		json.Unmarshal(params.Arguments, &req)
+		if ids := requestAppIDs(req.Path, req.Body); len(ids) > 0 {
+			args.AppID = ids[0]
+		}
+	}
+
+	sum := sha256.Sum256(params.Arguments)
+	entry := AuditEntry{
+		Time:      time.Now(),
+		RequestID: requestIDFromContext(ctx),
+		Tool:      params.Name,
+		Profile:   profileFromContext(ctx),
+		AppID:     args.AppID,
+		RecordID:  args.RecordID,
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Outcome:   "success",
+	}
+	if auth, err := h.auth(ctx); err == nil {
+		entry.User = auth.User
+	}
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		if s.clientInfo.Name != "" {
+			entry.Client = s.clientInfo.Name + "/" + s.clientInfo.Version
+		}
+		s.mu.Unlock()
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.Error = err.Error()
+	} else if res.ConfirmationRequired {
+		entry.Outcome = "confirmation-required"
+	}
+
+	if err := h.AuditLog.Write(entry); err != nil {
+		serverLog.ErrorContext(ctx, "Failed to write audit log", "error", err)
+	}
+}
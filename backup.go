@@ -0,0 +1,521 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// backupVersion is the version of the layout of backups, to reject backups that this version can't restore.
+const backupVersion = 1
+
+// The entries in a backup.
+const (
+	backupManifestName    = "manifest.json"
+	backupRecordsName     = "records.jsonl"
+	backupAttachmentsName = "attachments"
+)
+
+// backupManifest describes a backup of an app.
+type backupManifest struct {
+	Version     int                         `json:"version"`
+	AppID       string                      `json:"appID"`
+	Query       string                      `json:"query,omitempty"`
+	CreatedAt   time.Time                   `json:"createdAt"`
+	Records     int                         `json:"records"`
+	Fields      map[string]formField        `json:"fields"`
+	Attachments map[string]backupAttachment `json:"attachments"`
+}
+
+// backupAttachment is an attachment file in a backup.
+// The key in backupManifest.Attachments is the file key at the time of the backup, which is used in records.jsonl.
+type backupAttachment struct {
+	Path        string `json:"path"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+// backupArchive is the destination of a backup, which is a zip file or a directory.
+type backupArchive interface {
+	Create(name string) (io.Writer, error)
+	Close() error
+}
+
+// dirArchive writes the entries of a backup as files in a directory.
+type dirArchive struct {
+	root string
+	f    *os.File
+}
+
+func (d *dirArchive) Create(name string) (io.Writer, error) {
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+
+	p := filepath.Join(d.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	d.f = f
+	return f, nil
+}
+
+func (d *dirArchive) Close() error {
+	if d.f == nil {
+		return nil
+	}
+	err := d.f.Close()
+	d.f = nil
+	return err
+}
+
+// forEachFileValue calls fn for each item of the file fields in the record, including the fields in tables.
+func forEachFileValue(record map[string]any, fn func(file map[string]any) error) error {
+	for _, v := range record {
+		field, _ := v.(map[string]any)
+		switch field["type"] {
+		case "FILE":
+			files, _ := field["value"].([]any)
+			for _, file := range files {
+				if file, ok := file.(map[string]any); ok {
+					if err := fn(file); err != nil {
+						return err
+					}
+				}
+			}
+		case "SUBTABLE":
+			rows, _ := field["value"].([]any)
+			for _, row := range rows {
+				row, _ := row.(map[string]any)
+				if cells, ok := row["value"].(map[string]any); ok {
+					if err := forEachFileValue(cells, fn); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type BackupAppDataParams struct {
+	AppID           string `json:"appID" required:"true" description:"The app ID to back up."`
+	Query           string `json:"query" description:"The query to filter records to back up. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed. Default is all records."`
+	Directory       bool   `json:"directory" description:"If true, the backup is saved as a directory instead of a zip file. Default is false."`
+	SkipAttachments bool   `json:"skipAttachments" description:"If true, the attachment files are not included in the backup. Default is false."`
+}
+
+func (h *KintoneHandlers) BackupAppData(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req BackupAppDataParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required",
+		}
+	}
+
+	if err := h.checkPermissions(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := h.downloadDirectory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := backupManifest{
+		Version:     backupVersion,
+		AppID:       req.AppID,
+		Query:       req.Query,
+		CreatedAt:   time.Now().UTC(),
+		Fields:      fields,
+		Attachments: make(map[string]backupAttachment),
+	}
+
+	// The records are written into a temporary file first, because the entries of a zip file can't be written in parallel with the attachments.
+	records, err := os.CreateTemp("", "kintone-backup-*.jsonl")
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to create a temporary file: %v", err),
+		}
+	}
+	defer os.Remove(records.Name())
+	defer records.Close()
+
+	bw := bufio.NewWriter(records)
+	enc := json.NewEncoder(bw)
+	err = h.eachRecordByCursor(ctx, req.AppID, h.recordDefaults(req.AppID).mergeQuery(req.Query), nil, func(record map[string]any) error {
+		manifest.Records++
+		if manifest.Records%cursorSize == 0 {
+			ReportProgress(ctx, float64(manifest.Records), 0, fmt.Sprintf("Read %d records", manifest.Records))
+		}
+
+		if !req.SkipAttachments {
+			forEachFileValue(record, func(file map[string]any) error {
+				key, _ := file["fileKey"].(string)
+				name, _ := file["name"].(string)
+				contentType, _ := file["contentType"].(string)
+				size, _ := strconv.ParseInt(fmt.Sprint(file["size"]), 10, 64)
+				manifest.Attachments[key] = backupAttachment{
+					Path:        path.Join(backupAttachmentsName, sanitizeFileName(key), sanitizeFileName(name)),
+					Name:        name,
+					ContentType: contentType,
+					Size:        size,
+				}
+				return nil
+			})
+		}
+
+		return enc.Encode(record)
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		outPath string
+		archive backupArchive
+		zipFile *os.File
+	)
+	name := fmt.Sprintf("app%s-backup-%s", req.AppID, manifest.CreatedAt.Format("20060102-150405"))
+	if req.Directory {
+		saveFileMu.Lock()
+		outPath = getDownloadFilePath(dir, name)
+		err = os.Mkdir(outPath, 0755)
+		saveFileMu.Unlock()
+		archive = &dirArchive{root: outPath}
+	} else if zipFile, err = os.CreateTemp(dir, ".kintone-backup-*"); err == nil {
+		defer os.Remove(zipFile.Name())
+		defer zipFile.Close()
+		archive = zip.NewWriter(zipFile)
+	}
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to create the backup: %v", err),
+			Data:    JsonMap{"directory": dir},
+		}
+	}
+
+	if err := h.writeBackup(ctx, archive, manifest, records); err != nil {
+		archive.Close()
+		if req.Directory {
+			os.RemoveAll(outPath)
+		}
+		return nil, err
+	}
+	err = archive.Close()
+	if zipFile != nil && err == nil {
+		err = zipFile.Close()
+	}
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to save the backup: %v", err),
+		}
+	}
+
+	if !req.Directory {
+		saveFileMu.Lock()
+		outPath = getDownloadFilePath(dir, name+".zip")
+		err := os.Rename(zipFile.Name(), outPath)
+		saveFileMu.Unlock()
+		if err != nil {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to save the backup: %s: %v", outPath, err),
+			}
+		}
+	}
+
+	return JSONContent(JsonMap{
+		"success":     true,
+		"path":        outPath,
+		"records":     manifest.Records,
+		"attachments": len(manifest.Attachments),
+	})
+}
+
+// writeBackup writes the records, the attachment files, and the manifest into the archive.
+func (h *KintoneHandlers) writeBackup(ctx context.Context, archive backupArchive, manifest backupManifest, records *os.File) error {
+	writeError := func(err error) error {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to write the backup: %v", err),
+		}
+	}
+
+	if _, err := records.Seek(0, io.SeekStart); err != nil {
+		return writeError(err)
+	}
+	w, err := archive.Create(backupRecordsName)
+	if err != nil {
+		return writeError(err)
+	}
+	if _, err := io.Copy(w, records); err != nil {
+		return writeError(err)
+	}
+
+	keys := slices.Sorted(maps.Keys(manifest.Attachments))
+	for i, key := range keys {
+		a := manifest.Attachments[key]
+		ReportProgress(ctx, float64(i), float64(len(keys)), fmt.Sprintf("Downloading %s", a.Name))
+
+		res, err := h.downloadFile(ctx, key)
+		if err != nil {
+			return err
+		}
+		w, err := archive.Create(a.Path)
+		if err == nil {
+			_, err = io.Copy(w, res.Body)
+		}
+		res.Body.Close()
+		if err != nil {
+			return writeError(err)
+		}
+	}
+
+	w, err = archive.Create(backupManifestName)
+	if err != nil {
+		return writeError(err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return writeError(err)
+	}
+
+	return nil
+}
+
+// unrestorableFieldTypes is the field types that kintone doesn't accept when creating records.
+var unrestorableFieldTypes = []string{"__ID__", "__REVISION__", "RECORD_NUMBER", "STATUS", "STATUS_ASSIGNEE", "CATEGORY", "CALC"}
+
+type RestoreAppDataParams struct {
+	AppID string `json:"appID" required:"true" description:"The app ID to restore records into. It can be different from the app of the backup if the app has the same fields. The records are created as new records, so restoring into the same app duplicates the records that still exist."`
+	Path  string `json:"path" required:"true" description:"The path of the backup that 'backupAppData' tool created, which is a zip file or a directory."`
+
+	OverrideRecordLimit bool `json:"overrideRecordLimit" description:"If true, the records are changed even if the number exceeds the limit of the server settings. Only use it if the user explicitly asks to change that many records. Default is false."`
+}
+
+func (h *KintoneHandlers) RestoreAppData(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req RestoreAppDataParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" || req.Path == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Arguments 'appID' and 'path' are required",
+		}
+	}
+
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkPathAccess(ctx, req.Path); err != nil {
+		return nil, err
+	}
+
+	openError := func(err error) error {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Failed to open the backup: %v", err),
+		}
+	}
+
+	var backup fs.FS
+	if info, err := os.Stat(req.Path); err != nil {
+		return nil, openError(err)
+	} else if info.IsDir() {
+		backup = os.DirFS(req.Path)
+	} else {
+		zr, err := zip.OpenReader(req.Path)
+		if err != nil {
+			return nil, openError(err)
+		}
+		defer zr.Close()
+		backup = zr
+	}
+
+	var manifest backupManifest
+	if bs, err := fs.ReadFile(backup, backupManifestName); err != nil {
+		return nil, openError(err)
+	} else if err := json.Unmarshal(bs, &manifest); err != nil {
+		return nil, openError(err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, openError(fmt.Errorf("unsupported version of backup: %d", manifest.Version))
+	}
+
+	if err := h.checkAffectedRecords(ctx, "restoreAppData", req.AppID, manifest.Records, req.OverrideRecordLimit); err != nil {
+		return nil, err
+	}
+
+	records, err := backup.Open(backupRecordsName)
+	if err != nil {
+		return nil, openError(err)
+	}
+	defer records.Close()
+
+	var (
+		batch     []importRow
+		errs      []importError
+		created   int
+		uploaded  int
+		filter    = h.fieldFilter(req.AppID)
+		restoreTo = func(batch []importRow) {
+			ids, batchErrs := h.createImportBatch(ctx, req.AppID, batch)
+			created += len(ids)
+			errs = append(errs, batchErrs...)
+			ReportProgress(ctx, float64(created+len(errs)), float64(manifest.Records), fmt.Sprintf("Restored %d of %d records", created, manifest.Records))
+		}
+	)
+
+	sc := bufio.NewScanner(records)
+	sc.Buffer(nil, 64*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		var record map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &record); err != nil {
+			errs = append(errs, importError{Row: n, Error: fmt.Sprintf("invalid record: %v", err)})
+			continue
+		}
+
+		// Upload the attachments again, because the file keys in the backup can't be used for new records.
+		err := forEachFileValue(record, func(file map[string]any) error {
+			key, _ := file["fileKey"].(string)
+			a, ok := manifest.Attachments[key]
+			if !ok {
+				return fmt.Errorf("attachment %s is not in the backup", key)
+			}
+			f, err := backup.Open(a.Path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			newKey, err := h.uploadFile(ctx, a.Name, f, a.Size)
+			if err != nil {
+				return fmt.Errorf("failed to upload %s: %w", a.Name, err)
+			}
+			uploaded++
+			clear(file)
+			file["fileKey"] = newKey
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, importError{Row: n, Error: err.Error()})
+			continue
+		}
+
+		restorable := restorableRecord(record)
+		if err := filter.checkRecord(req.AppID, map[string]any(restorable)); err != nil {
+			errs = append(errs, importError{Row: n, Error: importErrorMessage(err)})
+			continue
+		}
+
+		batch = append(batch, importRow{Row: n, Record: restorable})
+		if len(batch) == importBatchSize {
+			restoreTo(batch)
+			batch = nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, openError(err)
+	}
+	if len(batch) > 0 {
+		restoreTo(batch)
+	}
+
+	result := JsonMap{
+		"success":     len(errs) == 0,
+		"created":     created,
+		"failed":      len(errs),
+		"attachments": uploaded,
+	}
+	slices.SortStableFunc(errs, func(a, b importError) int { return a.Row - b.Row })
+	if len(errs) > maxImportErrors {
+		result["errors"] = errs[:maxImportErrors]
+		result["note"] = fmt.Sprintf("Only the first %d errors are shown. The row is the line number in %s of the backup.", maxImportErrors, backupRecordsName)
+	} else if len(errs) > 0 {
+		result["errors"] = errs
+		result["note"] = fmt.Sprintf("The row is the line number in %s of the backup.", backupRecordsName)
+	}
+	return JSONContent(result)
+}
+
+// restorableRecord converts a record in a backup into a record to create.
+// The fields that kintone sets automatically are removed, and the users, organizations, and groups are specified only by the codes.
+// The creator, the modifier, and the times of them are kept, because kintone accepts them for new records.
+func restorableRecord(record map[string]any) RecordParam {
+	result := make(RecordParam, len(record))
+	for code, v := range record {
+		field, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		fieldType, _ := field["type"].(string)
+		if slices.Contains(unrestorableFieldTypes, fieldType) {
+			continue
+		}
+
+		value := field["value"]
+		switch fieldType {
+		case "SUBTABLE":
+			rows, _ := value.([]any)
+			newRows := make([]any, 0, len(rows))
+			for _, row := range rows {
+				row, _ := row.(map[string]any)
+				cells, _ := row["value"].(map[string]any)
+				newRows = append(newRows, JsonMap{"value": restorableRecord(cells)})
+			}
+			value = newRows
+		case "CREATOR", "MODIFIER":
+			entity, _ := value.(map[string]any)
+			value = JsonMap{"code": entity["code"]}
+		case "USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT":
+			entities, _ := value.([]any)
+			codes := make([]any, 0, len(entities))
+			for _, e := range entities {
+				e, _ := e.(map[string]any)
+				codes = append(codes, JsonMap{"code": e["code"]})
+			}
+			value = codes
+		}
+		result[code] = JsonMap{"value": value}
+	}
+	return result
+}
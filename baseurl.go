@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// kintoneDomains is the list of domains of the cloud version of kintone.
+var kintoneDomains = []string{".cybozu.com", ".kintone.com", ".cybozu.cn"}
+
+// kintoneSubdomain splits the host such as "example.cybozu.com" into the subdomain "example" and the domain ".cybozu.com".
+// The host for Secure Access such as "example.s.cybozu.com" is also accepted. ok is false if the host is not a domain of kintone.
+func kintoneSubdomain(host string) (sub, domain string, ok bool) {
+	host = strings.ToLower(host)
+	for _, d := range kintoneDomains {
+		s, found := strings.CutSuffix(host, d)
+		s = strings.TrimSuffix(s, ".s")
+		if found && s != "" && !strings.Contains(s, ".") {
+			return s, d, true
+		}
+	}
+	return "", "", false
+}
+
+// parseBaseURL parses the base URL of kintone, such as "https://example.cybozu.com".
+// The common mistakes, such as missing the scheme or copying the URL of an app from the browser, are reported with the corrected URL.
+// If customDomain is false, the host has to be a domain of kintone.
+func parseBaseURL(s string, customDomain bool) (*url.URL, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("the URL is empty")
+	}
+
+	if !strings.Contains(s, "://") {
+		// Such as "example.cybozu.com" or "example".
+		fixed, err := url.Parse("https://" + s)
+		if err != nil || fixed.Host == "" {
+			return nil, fmt.Errorf("%q is not a URL. Please write it like \"https://example.cybozu.com\"", s)
+		}
+		return nil, fmt.Errorf("%q doesn't have the scheme. Did you mean %q?", s, suggestBaseURL(fixed))
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q doesn't have the host. Please write it like \"https://example.cybozu.com\"", s)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("%q is not an HTTP or HTTPS URL", s)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%q must not contain the credentials. Please use KINTONE_USERNAME and KINTONE_PASSWORD instead", u.Redacted())
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if path == "/k" || strings.HasPrefix(path, "/k/") || u.RawQuery != "" || u.Fragment != "" || (path != "" && !customDomain) {
+		// Such as "https://example.cybozu.com/k/#/portal" or "https://example.cybozu.com/k/123/", that is copied from the browser.
+		return nil, fmt.Errorf("%q has an unnecessary path. Did you mean %q?", s, suggestBaseURL(u))
+	}
+
+	if !customDomain {
+		if _, _, ok := kintoneSubdomain(u.Hostname()); !ok {
+			if isBareSubdomain(u.Hostname()) {
+				return nil, fmt.Errorf("%q is not a domain of kintone. Did you mean %q?", s, suggestBaseURL(u))
+			}
+			return nil, fmt.Errorf("%q is not a domain of kintone, such as \"https://example.cybozu.com\". Set KINTONE_CUSTOM_DOMAIN to use other domains, such as a reverse proxy", s)
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("%q must use https. Did you mean %q?", s, suggestBaseURL(u))
+		}
+	}
+
+	normalized := &url.URL{Scheme: u.Scheme, Host: strings.ToLower(u.Host), Path: path}
+	return normalized, nil
+}
+
+// suggestBaseURL returns the base URL that the user probably meant.
+// A bare subdomain such as "example" is completed as "example.cybozu.com", and the path is removed.
+func suggestBaseURL(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if isBareSubdomain(u.Hostname()) {
+		host = strings.ToLower(u.Hostname()) + kintoneDomains[0]
+	}
+	scheme := u.Scheme
+	if _, _, ok := kintoneSubdomain(host); ok || scheme == "" {
+		scheme = "https"
+	}
+	return scheme + "://" + host
+}
+
+// isBareSubdomain reports whether the host is probably only the subdomain of kintone, such as "example".
+func isBareSubdomain(host string) bool {
+	return !strings.Contains(host, ".") && host != "localhost" && net.ParseIP(host) == nil
+}
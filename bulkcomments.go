@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+const (
+	// bulkCommentConfirmThreshold is the number of records that createRecordComments tool can comment on without confirmation.
+	bulkCommentConfirmThreshold = 10
+
+	// maxBulkComments is the maximum number of records that createRecordComments tool can comment on at once.
+	maxBulkComments = 500
+
+	// bulkCommentPreviews is the number of comments that the preview of createRecordComments tool shows.
+	bulkCommentPreviews = 3
+)
+
+// commentPlaceholderPattern matches the placeholders in the comment text, such as "{{title}}".
+var commentPlaceholderPattern = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// mentionTypes maps the types of the mention targets to the kinds of the entities in the User API.
+var mentionTypes = map[string]string{
+	"USER":         "users",
+	"GROUP":        "groups",
+	"ORGANIZATION": "organizations",
+}
+
+// commentFieldText formats a value of a record in the simple format for a comment.
+func commentFieldText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any:
+		return entityLabel(v)
+	case []any:
+		ss := make([]string, 0, len(v))
+		for _, x := range v {
+			if row, ok := x.(map[string]any); ok && entityLabel(row) == "" {
+				// The rows of tables are not entities.
+				bs, _ := json.Marshal(row)
+				ss = append(ss, string(bs))
+			} else {
+				ss = append(ss, commentFieldText(x))
+			}
+		}
+		return strings.Join(ss, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type CreateRecordCommentsParams struct {
+	AppID   string `json:"appID" required:"true" description:"The app ID to create comments in."`
+	Query   string `json:"query" required:"true" description:"The query to select the records to comment on. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed."`
+	Comment struct {
+		Text     string `json:"text" required:"true" description:"The text of the comment. Placeholders such as {{title}} are replaced with the values of the fields of each record."`
+		Mentions []struct {
+			Code string `json:"code" required:"true" description:"The code or the display name of the mention target, or a placeholder of a user, organization, or group field such as {{assignee}} to mention the entities in the field of each record."`
+			Type string `json:"type" enum:"USER,GROUP,ORGANIZATION" description:"The type of the mention target. Default is 'USER'. Ignored for placeholders."`
+		} `json:"mentions" description:"The mention targets of the comments."`
+	} `json:"comment" required:"true"`
+	ConfirmationToken   string `json:"confirmationToken" description:"The confirmation token that is returned by the previous call of this tool. Only required if the query matches more than 10 records."`
+	OverrideRecordLimit bool   `json:"overrideRecordLimit" description:"If true, the records are changed even if the number exceeds the limit of the server settings. Only use it if the user explicitly asks to change that many records. Default is false."`
+	EvaluatePermissions bool   `json:"evaluatePermissions" description:"If true, the permissions of the records are evaluated in kintone before posting, and the records that the credentials can't view are skipped and reported in 'skipped' instead of failing. Default is false."`
+}
+
+// bulkComment is a comment to post on a record by createRecordComments tool.
+type bulkComment struct {
+	RecordID string    `json:"recordID"`
+	Text     string    `json:"text"`
+	Mentions []JsonMap `json:"mentions,omitempty"`
+}
+
+func (h *KintoneHandlers) CreateRecordComments(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req CreateRecordCommentsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" || strings.TrimSpace(req.Query) == "" || req.Comment.Text == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Arguments 'appID', 'query', and 'comment.text' are required",
+		}
+	}
+
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	filter := h.fieldFilter(req.AppID)
+
+	// The fields that the placeholders in the text and the mentions refer.
+	codes := []string{"$id"}
+	addField := func(code string) error {
+		if code == "$id" {
+			return nil
+		}
+		if _, ok := fields[code]; !ok {
+			return jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Unknown field code: %s. Please check the field codes by using 'readAppInfo' tool.", code),
+			}
+		}
+		if !filter.allowed("", code) {
+			return fieldDeniedError(req.AppID, code)
+		}
+		codes = append(codes, code)
+		return nil
+	}
+	for _, m := range commentPlaceholderPattern.FindAllStringSubmatch(req.Comment.Text, -1) {
+		if err := addField(m[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	directory := h.newDirectoryLookup(ctx)
+	var mentions []JsonMap
+	mentionFields := make(map[string]string)
+	for _, m := range req.Comment.Mentions {
+		if sub := commentPlaceholderPattern.FindStringSubmatch(m.Code); sub != nil && sub[0] == strings.TrimSpace(m.Code) {
+			if err := addField(sub[1]); err != nil {
+				return nil, err
+			}
+			if _, ok := entityKinds[fields[sub[1]].Type]; !ok {
+				return nil, jsonrpc2.Error{
+					Code:    jsonrpc2.InvalidParamsCode,
+					Message: fmt.Sprintf("Field %s is a %s field, which can't be used to mention. Use a user, organization, or group field.", sub[1], fields[sub[1]].Type),
+				}
+			}
+			for t, kind := range mentionTypes {
+				if kind == entityKinds[fields[sub[1]].Type] {
+					mentionFields[sub[1]] = t
+				}
+			}
+			continue
+		}
+
+		if m.Code == "" {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: "Mention code is required",
+			}
+		}
+		if m.Type == "" {
+			m.Type = "USER"
+		}
+		kind, ok := mentionTypes[m.Type]
+		if !ok {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: "Mention type must be 'USER', 'GROUP', or 'ORGANIZATION'",
+			}
+		}
+		code := m.Code
+		if c, ok := directory.lookupCode(kind, m.Code); ok {
+			code = c
+		}
+		mentions = append(mentions, JsonMap{"code": code, "type": m.Type})
+	}
+
+	var comments []bulkComment
+	query := h.recordDefaults(req.AppID).mergeQuery(req.Query)
+	err = h.eachRecordByCursor(ctx, req.AppID, query, codes, func(record map[string]any) error {
+		if len(comments) >= maxBulkComments {
+			return jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("The query matches more than %d records. Please narrow down the records by the query.", maxBulkComments),
+			}
+		}
+
+		simple := simplifyRecord(record)
+		c := bulkComment{
+			RecordID: commentFieldText(simple["$id"]),
+			Text: commentPlaceholderPattern.ReplaceAllStringFunc(req.Comment.Text, func(s string) string {
+				return commentFieldText(simple[commentPlaceholderPattern.FindStringSubmatch(s)[1]])
+			}),
+			Mentions: slices.Clone(mentions),
+		}
+		for code, typ := range mentionFields {
+			field, _ := record[code].(map[string]any)
+			entities, ok := field["value"].([]any)
+			if !ok {
+				// CREATOR and MODIFIER have a single entity.
+				entities = []any{field["value"]}
+			}
+			for _, e := range entities {
+				if e, ok := e.(map[string]any); ok && e["code"] != nil && e["code"] != "" {
+					c.Mentions = append(c.Mentions, JsonMap{"code": e["code"], "type": typ})
+				}
+			}
+		}
+		comments = append(comments, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The records that can't be commented on are removed before the confirmation, so that the preview and the limit count only the records to post.
+	var skipped []JsonMap
+	if req.EvaluatePermissions && len(comments) > 0 {
+		ids := make([]string, len(comments))
+		for i, c := range comments {
+			ids[i] = c.RecordID
+		}
+		allowed, s, err := h.partitionViewable(ctx, req.AppID, ids)
+		if err != nil {
+			return nil, err
+		}
+		skipped = s
+		comments = slices.DeleteFunc(comments, func(c bulkComment) bool { return !allowed[c.RecordID] })
+	}
+
+	if err := h.checkAffectedRecords(ctx, "createRecordComments", req.AppID, len(comments), req.OverrideRecordLimit); err != nil {
+		return nil, err
+	}
+
+	signature, _ := json.Marshal(req.Comment)
+	target := fmt.Sprintf("comments\x00%s\x00%s\x00%s", req.AppID, query, signature)
+	if len(comments) > bulkCommentConfirmThreshold {
+		if req.ConfirmationToken == "" {
+			token, expires := h.confirmations.issue(target)
+			res := JsonMap{
+				"confirmationRequired": true,
+				"confirmationToken":    token,
+				"expiresAt":            expires.Format(time.RFC3339),
+				"records":              len(comments),
+				"preview":              comments[:bulkCommentPreviews],
+				"message":              fmt.Sprintf("No comments are posted yet, because the query matches %d records. Please make sure that these are the records to comment on, and call createRecordComments again with the same arguments and this confirmationToken.", len(comments)),
+			}
+			if len(skipped) > 0 {
+				res["skipped"] = skipped
+			}
+			return JSONContent(res)
+		}
+		if !h.confirmations.consume(req.ConfirmationToken, target) {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: "The confirmation token is invalid or expired. Please call createRecordComments again without 'confirmationToken' to get a new one.",
+			}
+		}
+	}
+
+	results := make([]JsonMap, 0, len(comments))
+	succeeded := 0
+	for i, c := range comments {
+		comment := JsonMap{"text": c.Text}
+		if len(c.Mentions) > 0 {
+			comment["mentions"] = c.Mentions
+		}
+		var res struct {
+			ID string `json:"id"`
+		}
+		err := h.FetchHTTPWithJSON(ctx, "POST", "/k/v1/record/comment.json", nil, JsonMap{
+			"app":     req.AppID,
+			"record":  c.RecordID,
+			"comment": comment,
+		}, &res)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			results = append(results, JsonMap{"recordID": c.RecordID, "success": false, "error": importErrorMessage(err)})
+		} else {
+			succeeded++
+			results = append(results, JsonMap{"recordID": c.RecordID, "success": true, "commentID": res.ID})
+		}
+		ReportProgress(ctx, float64(i+1), float64(len(comments)), fmt.Sprintf("Posted comments on %d of %d records", i+1, len(comments)))
+	}
+
+	res := JsonMap{
+		"success":   succeeded == len(comments),
+		"records":   len(comments),
+		"succeeded": succeeded,
+		"failed":    len(comments) - succeeded,
+		"results":   results,
+	}
+	if len(skipped) > 0 {
+		res["skipped"] = skipped
+	}
+	return JSONContent(res)
+}
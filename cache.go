@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ttlCache is a simple in-memory cache that expires entries after a certain time.
+// The zero value is ready to use.
+type ttlCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	group   singleflight.Group
+}
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+func (c *ttlCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *ttlCache) Set(key string, value any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[key] = cacheEntry{value: value, expires: time.Now().Add(ttl)}
+}
+
+// DeletePrefix removes all entries whose keys start with the prefix.
+func (c *ttlCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cached returns the cached value for the key, or calls fetch and caches the result if not cached.
+// Errors are not cached, and nothing is cached if ttl is 0.
+//
+// Concurrent calls for the same key share a single call of fetch.
+// fetch receives a context that is not canceled even if the caller gives up, because the other callers may still wait for the result.
+func cached[T any](ctx context.Context, c *ttlCache, key string, ttl time.Duration, fetch func(ctx context.Context) (T, error)) (T, error) {
+	if v, ok := c.Get(key); ok {
+		if t, ok := v.(T); ok {
+			countUsage(ctx, func(s *usageStats) { s.countCache(true) })
+			return t, nil
+		}
+	}
+	countUsage(ctx, func(s *usageStats) { s.countCache(false) })
+
+	ch := c.group.DoChan(key, func() (any, error) {
+		v, err := fetch(context.WithoutCancel(ctx))
+		if err == nil && ttl > 0 {
+			c.Set(key, v, ttl)
+		}
+		return v, err
+	})
+
+	var zero T
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// envFlag is a command line flag that sets an environment variable.
+// The flags have higher priority than the environment variables and the configuration file.
+type envFlag struct {
+	Name  string
+	Env   string
+	Bool  bool
+	Usage string
+}
+
+// envFlags is the command line flags for the settings.
+var envFlags = []envFlag{
+	{Name: "config", Env: "KINTONE_CONFIG_FILE", Usage: "Path to the configuration file in JSON or YAML."},
+
+	{Name: "base-url", Env: "KINTONE_BASE_URL", Usage: "The base URL of your kintone, such as \"https://example.cybozu.com\"."},
+	{Name: "custom-domain", Env: "KINTONE_CUSTOM_DOMAIN", Bool: true, Usage: "Allow the base URL that is not a domain of kintone, such as a reverse proxy."},
+	{Name: "username", Env: "KINTONE_USERNAME", Usage: "Your username for kintone."},
+	{Name: "password", Env: "KINTONE_PASSWORD", Usage: "Your password for kintone."},
+	{Name: "password-file", Env: "KINTONE_PASSWORD_FILE", Usage: "The path to a file that contains the password."},
+	{Name: "api-token", Env: "KINTONE_API_TOKEN", Usage: "Comma separated API tokens for kintone."},
+	{Name: "api-token-file", Env: "KINTONE_API_TOKEN_FILE", Usage: "The path to a file that contains the API tokens."},
+	{Name: "keyring", Env: "KINTONE_KEYRING", Bool: true, Usage: "Read the password and the API token from the OS keychain if they are not set."},
+	{Name: "keyring-service", Env: "KINTONE_KEYRING_SERVICE", Usage: "The service name to look up secrets in the OS keychain."},
+	{Name: "basic-auth-username", Env: "KINTONE_BASIC_AUTH_USERNAME", Usage: "The username for the Basic authentication of cybozu.com."},
+	{Name: "basic-auth-password", Env: "KINTONE_BASIC_AUTH_PASSWORD", Usage: "The password for the Basic authentication of cybozu.com."},
+	{Name: "client-cert-file", Env: "KINTONE_CLIENT_CERT_FILE", Usage: "The client certificate file for cybozu.com Secure Access."},
+	{Name: "client-cert-password", Env: "KINTONE_CLIENT_CERT_PASSWORD", Usage: "The password of the client certificate file."},
+	{Name: "ca-file", Env: "KINTONE_CA_FILE", Usage: "A PEM file of additional CA certificates."},
+	{Name: "tls-min-version", Env: "KINTONE_TLS_MIN_VERSION", Usage: "The minimum TLS version to connect to kintone, such as \"1.3\"."},
+	{Name: "tls-insecure-skip-verify", Env: "KINTONE_TLS_INSECURE_SKIP_VERIFY", Bool: true, Usage: "Skip verifying the certificate of kintone. Use it only in test environments."},
+	{Name: "gzip-requests", Env: "KINTONE_GZIP_REQUESTS", Bool: true, Usage: "Compress large JSON request bodies with gzip. Use it only if kintone or your proxy accepts compressed requests."},
+	{Name: "proxy", Env: "KINTONE_PROXY", Usage: "The proxy URL to access kintone, such as \"http://proxy.example.com:8080\". \"direct\" ignores HTTPS_PROXY."},
+	{Name: "connect-timeout", Env: "KINTONE_CONNECT_TIMEOUT", Usage: "The time limit to connect to kintone, such as \"10s\"."},
+	{Name: "timeout", Env: "KINTONE_TIMEOUT", Usage: "The time limit of a tool call, such as \"2m\". \"0\" disables the limit."},
+	{Name: "tool-timeouts", Env: "KINTONE_TOOL_TIMEOUTS", Usage: "A comma-separated list of time limits for each tool, such as \"downloadRecordAttachments=1h\"."},
+	{Name: "timezone", Env: "KINTONE_TIMEZONE", Usage: "The timezone of date and time values, such as \"Asia/Tokyo\". In default, the timezone of the kintone user or the server."},
+	{Name: "cache-ttl", Env: "KINTONE_CACHE_TTL", Usage: "How long the app list and the app schemas are cached, such as \"5m\". \"0\" disables caching."},
+	{Name: "retry-count", Env: "KINTONE_RETRY_COUNT", Usage: "The maximum number of retries of requests that failed by transient problems."},
+	{Name: "retry-backoff", Env: "KINTONE_RETRY_BACKOFF", Usage: "The wait before the first retry, such as \"1s\". It doubles for each retry."},
+	{Name: "retry-jitter", Env: "KINTONE_RETRY_JITTER", Usage: "The ratio of random variation of the wait between retries, from 0 to 1."},
+	{Name: "rate-limit", Env: "KINTONE_RATE_LIMIT", Usage: "The number of requests per second to each kintone domain. \"0\" disables the limit."},
+	{Name: "max-concurrent-requests", Env: "KINTONE_MAX_CONCURRENT_REQUESTS", Usage: "The number of requests in progress to each kintone domain. \"0\" disables the limit."},
+	{Name: "concurrency", Env: "KINTONE_CONCURRENCY", Usage: "The number of sub-requests that a tool runs in parallel, such as downloading multiple files."},
+	{Name: "session-credentials", Env: "KINTONE_SESSION_CREDENTIALS", Bool: true, Usage: "Allow clients to provide their own kintone credentials for each session."},
+	{Name: "field-acl", Env: "KINTONE_FIELD_ACL", Bool: true, Usage: "Hide the fields that the user can't view by the field permissions of kintone, even if the API token can read them."},
+	{Name: "mock", Env: "KINTONE_MOCK", Bool: true, Usage: "Serve the tools by an in-memory fake of kintone instead of a real domain, for demos and tests."},
+	{Name: "mock-fixture", Env: "KINTONE_MOCK_FIXTURE", Usage: "The path to a JSON file of the initial data of KINTONE_MOCK. In default, the demo data is used."},
+	{Name: "record-fixtures", Env: "KINTONE_RECORD_FIXTURES", Usage: "The directory to record the responses of kintone as fixtures for KINTONE_REPLAY_FIXTURES."},
+	{Name: "replay-fixtures", Env: "KINTONE_REPLAY_FIXTURES", Usage: "The directory of the fixtures to respond instead of kintone, that are recorded by KINTONE_RECORD_FIXTURES."},
+
+	{Name: "allow-apps", Env: "KINTONE_ALLOW_APPS", Usage: "A comma-separated list of app IDs or name patterns to allow access."},
+	{Name: "deny-apps", Env: "KINTONE_DENY_APPS", Usage: "A comma-separated list of app IDs or name patterns to deny access."},
+	{Name: "read-only", Env: "KINTONE_READ_ONLY", Bool: true, Usage: "Disable all tools that modify kintone data."},
+	{Name: "confirm-delete", Env: "KINTONE_CONFIRM_DELETE", Bool: true, Usage: "Require confirmation to delete records."},
+	{Name: "max-affected-records", Env: "KINTONE_MAX_AFFECTED_RECORDS", Usage: "The maximum number of records that a write tool can change at once, such as posting comments by a query. \"0\" disables the limit."},
+	{Name: "disabled-tools", Env: "KINTONE_DISABLED_TOOLS", Usage: "A comma-separated list of tool names to disable."},
+	{Name: "request-endpoints", Env: "KINTONE_REQUEST_ENDPOINTS", Usage: "A comma-separated list of kintone API endpoints that kintoneRequest tool can call, such as \"GET /k/v1/app/acl.json\"."},
+
+	{Name: "allowed-paths", Env: "KINTONE_ALLOWED_PATHS", Usage: "A comma-separated list of directories that the server can read files from and write files to."},
+	{Name: "download-dir", Env: "KINTONE_DOWNLOAD_DIR", Usage: "The directory to save downloaded files."},
+	{Name: "max-upload-size", Env: "KINTONE_MAX_UPLOAD_SIZE", Usage: "The maximum size of a file to upload, such as \"100MB\"."},
+	{Name: "max-response-size", Env: "KINTONE_MAX_RESPONSE_SIZE", Usage: "The maximum size of records in a result of readRecords, such as \"100KB\". \"0\" disables the limit."},
+
+	{Name: "audit-log", Env: "KINTONE_AUDIT_LOG", Usage: "The path to a file to record all operations that modify kintone data."},
+	{Name: "audit-log-max-size", Env: "KINTONE_AUDIT_LOG_MAX_SIZE", Usage: "The size to rotate the audit log, such as \"100MB\"."},
+	{Name: "audit-log-max-files", Env: "KINTONE_AUDIT_LOG_MAX_FILES", Usage: "The number of rotated audit log files to keep."},
+	{Name: "log-level", Env: "KINTONE_LOG_LEVEL", Usage: "The minimum level of log messages to send to the client, such as \"info\"."},
+	{Name: "server-log", Env: "KINTONE_SERVER_LOG", Usage: "The path to a file to write the log of the server. Use stderr if empty."},
+	{Name: "server-log-format", Env: "KINTONE_SERVER_LOG_FORMAT", Usage: "The format of the server log, \"json\" or \"text\"."},
+	{Name: "server-log-level", Env: "KINTONE_SERVER_LOG_LEVEL", Usage: "The minimum level of the server log: debug, info, warn, or error."},
+	{Name: "debug", Env: "KINTONE_DEBUG", Bool: true, Usage: "Write the requests and responses of kintone to the server log, with credentials removed."},
+
+	{Name: "instructions", Env: "KINTONE_INSTRUCTIONS", Usage: "Additional instructions for the AI."},
+	{Name: "instructions-mode", Env: "KINTONE_INSTRUCTIONS_MODE", Usage: "\"append\" to add the instructions to the built-in instructions, or \"replace\" to use them instead."},
+	{Name: "instructions-apps", Env: "KINTONE_INSTRUCTIONS_APPS", Bool: true, Usage: "Include the apps in KINTONE_ALLOW_APPS in the instructions."},
+	{Name: "lang", Env: "KINTONE_LANG", Usage: "The language of the tool descriptions, the instructions, and the error messages. \"en\" or \"ja\"."},
+
+	{Name: "http", Env: "KINTONE_MCP_HTTP_ADDR", Usage: "Listen address for the Streamable HTTP transport, such as \":8080\". Use stdio if empty."},
+	{Name: "listen", Env: "KINTONE_MCP_LISTEN", Usage: "Listen address for newline-delimited JSON-RPC, such as \"unix:///run/kintone.sock\" or \"tcp://127.0.0.1:9000\". Use stdio if empty."},
+	{Name: "auth-tokens", Env: "KINTONE_MCP_AUTH_TOKENS", Usage: "A comma-separated list of tokens that clients have to send to the HTTP transport."},
+	{Name: "tls-cert", Env: "KINTONE_MCP_TLS_CERT", Usage: "The certificate file to enable TLS on the HTTP transport or the TCP listener."},
+	{Name: "tls-key", Env: "KINTONE_MCP_TLS_KEY", Usage: "The private key file to enable TLS on the HTTP transport or the TCP listener."},
+	{Name: "webhook-addr", Env: "KINTONE_WEBHOOK_ADDR", Usage: "Listen address to receive the webhooks of kintone, such as \":8081\". The webhooks are forwarded to the clients as notifications."},
+	{Name: "webhook-secret", Env: "KINTONE_WEBHOOK_SECRET", Usage: "The secret that the webhooks have to provide by the \"secret\" query parameter."},
+}
+
+// envValue is a flag.Value that sets the environment variable when the flag is given.
+type envValue struct {
+	env    string
+	isBool bool
+}
+
+func (v envValue) String() string {
+	return ""
+}
+
+func (v envValue) Set(s string) error {
+	return os.Setenv(v.env, s)
+}
+
+func (v envValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// command is a subcommand of the CLI.
+type command struct {
+	Name  string
+	Args  string // Args is the usage of the positional arguments. The command takes no arguments if empty.
+	Usage string
+	Run   func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{Name: "serve", Usage: "Start the MCP server. This is the default command.", Run: runServe},
+	{Name: "check", Usage: "Validate the settings, and check the connection to kintone and the apps, and exit.", Run: runCheck},
+	{Name: "doctor", Usage: "The same as check.", Run: runCheck},
+	{Name: "tools", Usage: "Print the list of the available tools in JSON, and exit.", Run: runTools},
+	{Name: "call", Args: "<tool> [arguments]", Usage: "Call a tool with the arguments in JSON, print the result, and exit. Use - as the arguments to read them from stdin.", Run: runCall},
+	{Name: "healthcheck", Usage: "Check that kintone is reachable with the credentials, and exit with status 1 if not. For HEALTHCHECK of Docker.", Run: runHealthcheck},
+}
+
+// parseCommandLine parses the arguments, and returns the command to run and its positional arguments.
+// The flags are applied to the environment variables.
+// The errors are reported to stderr with the usage, so the caller only has to exit.
+func parseCommandLine(args []string) (command, []string, error) {
+	cmd := commands[0]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		found := false
+		for _, c := range commands {
+			if c.Name == args[0] {
+				cmd, found = c, true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+			printUsage(os.Stderr, cmd)
+			return command{}, nil, fmt.Errorf("unknown command: %s", args[0])
+		}
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("mcp-server-kintone "+cmd.Name, flag.ContinueOnError)
+	version := fs.Bool("version", false, "Print the version and exit.")
+	healthcheck := fs.Bool("healthcheck", false, "The same as healthcheck command.")
+	for _, f := range envFlags {
+		fs.Var(envValue{env: f.Env, isBool: f.Bool}, f.Name, fmt.Sprintf("%s The same as %s.", f.Usage, f.Env))
+	}
+	fs.Usage = func() {
+		printUsage(fs.Output(), cmd)
+		fs.PrintDefaults()
+	}
+
+	// The flags can be placed after the positional arguments, such as "call readRecords '{}' --mock".
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return command{}, nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if cmd.Args == "" && len(positional) > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument: %s\n", positional[0])
+		fs.Usage()
+		return command{}, nil, fmt.Errorf("unexpected argument: %s", positional[0])
+	}
+
+	if *version {
+		cmd = command{Name: "version", Run: runVersion}
+	} else if *healthcheck {
+		cmd = command{Name: "healthcheck", Run: runHealthcheck}
+	}
+	return cmd, positional, nil
+}
+
+func printUsage(w io.Writer, cmd command) {
+	fmt.Fprintf(w, "Usage: mcp-server-kintone [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-13s%s\n", c.Name, c.Usage)
+	}
+	if cmd.Args != "" {
+		fmt.Fprintf(w, "\nUsage of %s: mcp-server-kintone %s %s [flags]\n", cmd.Name, cmd.Name, cmd.Args)
+	}
+	fmt.Fprintf(w, "\nFlags of %s:\n", cmd.Name)
+}
+
+func runVersion(ctx context.Context, args []string) error {
+	fmt.Printf("mcp-server-kintone %s (%s)\n", Version, Commit)
+	return nil
+}
+
+// runCheck loads the settings and runs the self-test for each kintone environment.
+func runCheck(ctx context.Context, args []string) error {
+	handlers, err := NewKintoneHandlersFromEnv()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Settings: OK")
+
+	profiles := handlers.profileNames()
+	if handlers.URL == nil {
+		fmt.Println("Connection (default): skipped because KINTONE_BASE_URL is not set and the clients provide credentials")
+		profiles = profiles[1:]
+	}
+
+	failed := false
+	for _, name := range profiles {
+		pctx := ctx
+		if name != defaultProfile {
+			pctx = withProfile(ctx, name)
+		}
+
+		fmt.Printf("\nConnection (%s):\n", name)
+		for _, r := range handlers.runSelfTest(pctx) {
+			fmt.Printf("  [%s] %s: %s\n", r.Status, r.Name, r.Detail)
+			if r.Status == "error" {
+				failed = true
+			}
+		}
+	}
+	if failed {
+		return fmt.Errorf("failed to connect to kintone")
+	}
+	return nil
+}
+
+// runTools prints the result of tools/list with the current settings.
+func runTools(ctx context.Context, args []string) error {
+	handlers, err := NewKintoneHandlersFromEnv()
+	if err != nil {
+		return err
+	}
+
+	tools, err := handlers.ToolsList(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tools)
+}
+
+// runCall calls a tool once without the MCP handshake, and prints the result. It is for debugging the tools.
+func runCall(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("usage: mcp-server-kintone call <tool> [arguments]")
+	}
+
+	arguments := []byte("{}")
+	if len(args) == 2 {
+		arguments = []byte(args[1])
+		if args[1] == "-" {
+			var err error
+			if arguments, err = io.ReadAll(os.Stdin); err != nil {
+				return fmt.Errorf("failed to read the arguments from stdin: %w", err)
+			}
+		}
+	}
+	if !json.Valid(arguments) {
+		return fmt.Errorf("the arguments are not valid JSON: %s", arguments)
+	}
+
+	handlers, err := NewKintoneHandlersFromEnv()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := handlers.ToolsCall(ctx, ToolsCallRequest{Name: args[0], Arguments: arguments})
+	if err != nil {
+		return err
+	}
+
+	// The texts are printed as is to be readable. The results with images or files are printed in JSON instead.
+	if slices.ContainsFunc(result.Content, func(c Content) bool { return c.Type != "text" }) {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range result.Content {
+			fmt.Println(c.Text)
+		}
+	}
+
+	if result.IsError {
+		return fmt.Errorf("%s returned an error", args[0])
+	}
+	return nil
+}
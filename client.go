@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// httpClient returns the HTTP client to send requests to kintone.
+func (h *KintoneHandlers) httpClient() *http.Client {
+	if h.client != nil {
+		return h.client
+	}
+	return defaultHTTPClient()
+}
+
+// loadClientCertificate loads a client certificate for cybozu.com Secure Access.
+// The file can be either a PKCS#12 file (.pfx or .p12) that is issued by cybozu.com, or a PEM file that contains both of the certificate and the private key.
+func loadClientCertificate(file, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		return tls.X509KeyPair(data, data)
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 file: %w", err)
+	}
+
+	chain := [][]byte{cert.Raw}
+	for _, c := range caCerts {
+		chain = append(chain, c.Raw)
+	}
+	return tls.Certificate{
+		Certificate: chain,
+		PrivateKey:  key,
+		Leaf:        cert,
+	}, nil
+}
+
+// secureAccessURL converts the URL such as https://example.cybozu.com into https://example.s.cybozu.com, which is the endpoint for Secure Access.
+// Other URLs are returned as is.
+func secureAccessURL(u *url.URL) *url.URL {
+	sub, domain, ok := kintoneSubdomain(u.Hostname())
+	if !ok {
+		return u
+	}
+
+	converted := *u
+	converted.Host = sub + ".s" + domain
+	if port := u.Port(); port != "" {
+		converted.Host += ":" + port
+	}
+	return &converted
+}
+
+// tlsVersions maps the values of KINTONE_TLS_MIN_VERSION to TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// loadCertPool loads the CA certificates in the PEM file, in addition to the system's certificates.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// defaultMaxConnsPerHost is the number of connections to keep for each kintone domain if the concurrent requests are not limited.
+// kintone rejects more than 100 concurrent requests per domain.
+const defaultMaxConnsPerHost = 100
+
+// httpClientOptions is the settings of the HTTP client to access kintone.
+type httpClientOptions struct {
+	// TLS is the TLS configuration, such as the client certificate and the CA certificates.
+	TLS *tls.Config
+
+	// ConnectTimeout limits both of connecting and the TLS handshake. The time limit of the whole request is given by the context.
+	ConnectTimeout time.Duration
+
+	// Proxy decides the proxy for each request. The environment variables such as HTTPS_PROXY are used if nil.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// MaxConnsPerHost is the number of requests in progress to each domain, to keep the same number of idle connections.
+	MaxConnsPerHost int
+}
+
+// newHTTPClient creates an HTTP client to access kintone.
+// The client is shared by all sessions and profiles, so that the connections are reused.
+func newHTTPClient(opts httpClientOptions) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.TLS
+	transport.DialContext = (&net.Dialer{Timeout: opts.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = opts.ConnectTimeout
+
+	transport.Proxy = http.ProxyFromEnvironment
+	if opts.Proxy != nil {
+		transport.Proxy = opts.Proxy
+	}
+
+	// Keep as many idle connections as the concurrent requests, so that the worker pool doesn't have to reconnect.
+	perHost := opts.MaxConnsPerHost
+	if perHost <= 0 {
+		perHost = defaultMaxConnsPerHost
+	}
+	transport.MaxIdleConnsPerHost = perHost
+	transport.MaxIdleConns = max(transport.MaxIdleConns, perHost)
+	transport.IdleConnTimeout = 90 * time.Second
+
+	// A custom TLS configuration disables HTTP/2 unless it is forced.
+	transport.ForceAttemptHTTP2 = true
+
+	// Keep the transparent compression of the responses, which makes large pages of records much faster on slow links.
+	transport.DisableCompression = false
+
+	return &http.Client{Transport: transport}
+}
+
+// newHookHTTPClient creates an HTTP client to call the hooks, with the same proxy and TLS settings as the client to access kintone.
+// The client certificate of Secure Access is not sent, because it is only for cybozu.com.
+// Redirects are not followed, so that the requests are sent only to the URLs in the configuration file.
+func newHookHTTPClient(tlsConfig *tls.Config, connectTimeout time.Duration, proxy func(*http.Request) (*url.URL, error)) *http.Client {
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.Certificates = nil
+	}
+	client := newHTTPClient(httpClientOptions{TLS: tlsConfig, ConnectTimeout: connectTimeout, Proxy: proxy})
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client
+}
+
+// hookHTTPClient returns the HTTP client to call the hooks.
+func (h *KintoneHandlers) hookHTTPClient() *http.Client {
+	if h.hookClient != nil {
+		return h.hookClient
+	}
+	return defaultHookHTTPClient()
+}
+
+// defaultHookHTTPClient is the client for the hooks of the handlers that are not created by NewKintoneHandlersFromEnv.
+var defaultHookHTTPClient = sync.OnceValue(func() *http.Client {
+	return newHookHTTPClient(nil, defaultConnectTimeout, nil)
+})
+
+// defaultHTTPClient is the client for the handlers that are not created by NewKintoneHandlersFromEnv.
+var defaultHTTPClient = sync.OnceValue(func() *http.Client {
+	return newHTTPClient(httpClientOptions{ConnectTimeout: defaultConnectTimeout})
+})
+
+// parseProxy parses KINTONE_PROXY. "direct" disables the proxy even if HTTPS_PROXY is set.
+// It returns nil if s is empty, to use the environment variables.
+func parseProxy(s string) (func(*http.Request) (*url.URL, error), error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return nil, nil
+	case "direct", "none":
+		return func(*http.Request) (*url.URL, error) { return nil, nil }, nil
+	}
+
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported scheme: %s: use http, https, or socks5", s)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("host is missing: %s", s)
+	}
+	return http.ProxyURL(u), nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The layouts of dates and times that are converted into the format of kintone.
+var (
+	coercibleDateLayouts = []string{
+		"2006/01/02",
+		"2006/1/2",
+		"2006-1-2",
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+	}
+	coercibleTimeLayouts = []string{
+		"15:04:05",
+		"15:04:05.999999999",
+		"15:4",
+	}
+	coercibleDateTimeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04Z07:00",
+	}
+
+	// localDateTimeLayouts is the layouts of date and time without offset, that are interpreted in the timezone of KINTONE_TIMEZONE.
+	localDateTimeLayouts = []string{
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006/01/02 15:04:05",
+		"2006/01/02 15:04",
+		"2006-01-02",
+		"2006/01/02",
+	}
+)
+
+const (
+	kintoneDateLayout     = "2006-01-02"
+	kintoneTimeLayout     = "15:04"
+	kintoneDateTimeLayout = "2006-01-02T15:04:05Z"
+)
+
+// coerceRecord converts the obvious mistakes of types in a record to write, such as a number for a text field or a string for a checkbox.
+// The display names of users, organizations, and groups are also replaced with their codes.
+// The record has to be in the format of kintone, and is modified in place.
+// It returns the descriptions of the conversions, to tell the model what was changed.
+// If the field definitions are not available, the record is sent as is and kintone reports the errors.
+func (h *KintoneHandlers) coerceRecord(ctx context.Context, appID string, record RecordParam) []string {
+	fields, err := h.formFields(ctx, appID)
+	if err != nil {
+		serverLog.DebugContext(ctx, "Skipped type coercion because the fields are not available", "appID", appID, "error", err)
+		return nil
+	}
+	coerced := coerceFields(fields, record, "", h.timezone(ctx))
+	return append(coerced, h.newDirectoryLookup(ctx).resolveCodes(fields, record, "")...)
+}
+
+func coerceFields(fields map[string]formField, record map[string]any, prefix string, loc *time.Location) []string {
+	var coerced []string
+	for _, code := range slices.Sorted(maps.Keys(record)) {
+		f, ok := fields[code]
+		if !ok {
+			continue
+		}
+		field, ok := record[code].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		path := prefix + code
+		if f.Type == "SUBTABLE" {
+			rows, _ := field["value"].([]any)
+			for i, row := range rows {
+				row, _ := row.(map[string]any)
+				if cells, ok := row["value"].(map[string]any); ok {
+					coerced = append(coerced, coerceFields(f.Fields, cells, fmt.Sprintf("%s[%d].", path, i), loc)...)
+				}
+			}
+			continue
+		}
+
+		if v, note := f.coerceValue(field["value"], loc); note != "" {
+			field["value"] = v
+			coerced = append(coerced, fmt.Sprintf("%s: %s", path, note))
+		}
+	}
+	return coerced
+}
+
+// coerceValue converts the value into the type of the field.
+// The date and time without offset are interpreted in loc.
+// It returns the converted value and the description of the conversion, or an empty description if nothing was changed.
+func (f formField) coerceValue(v any, loc *time.Location) (any, string) {
+	switch f.Type {
+	case "SINGLE_LINE_TEXT", "MULTI_LINE_TEXT", "RICH_TEXT", "LINK":
+		switch x := v.(type) {
+		case float64:
+			s := strconv.FormatFloat(x, 'f', -1, 64)
+			return s, fmt.Sprintf("converted number %s to string", s)
+		case json.Number:
+			return x.String(), fmt.Sprintf("converted number %s to string", x)
+		case bool:
+			s := strconv.FormatBool(x)
+			return s, fmt.Sprintf("converted boolean %s to string", s)
+		}
+
+	case "NUMBER":
+		// kintone accepts both numbers and strings, but not the separators of thousands.
+		if s, ok := v.(string); ok {
+			n := strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+			if _, err := strconv.ParseFloat(n, 64); err == nil && n != s {
+				return n, fmt.Sprintf("converted %q to %q", s, n)
+			}
+		}
+
+	case "DATE":
+		return coerceTimeString(v, coercibleDateLayouts, kintoneDateLayout, false)
+	case "TIME":
+		return coerceTimeString(v, coercibleTimeLayouts, kintoneTimeLayout, false)
+	case "DATETIME":
+		if v, note := coerceTimeString(v, coercibleDateTimeLayouts, kintoneDateTimeLayout, true); note != "" || isRFC3339(v) {
+			return v, note
+		}
+		return coerceLocalDateTime(v, loc)
+
+	case "CHECK_BOX", "MULTI_SELECT":
+		if s, ok := v.(string); ok {
+			return []any{s}, "wrapped the single value in an array"
+		}
+
+	case "DROP_DOWN", "RADIO_BUTTON":
+		if list, ok := v.([]any); ok && len(list) == 1 {
+			if s, ok := list[0].(string); ok {
+				return s, "unwrapped the single value from the array"
+			}
+		}
+
+	case "USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT":
+		return coerceObjectList(v, "code")
+	case "FILE":
+		return coerceObjectList(v, "fileKey")
+	}
+
+	return v, ""
+}
+
+// coerceTimeString converts a date or time string in the layouts into the layout of kintone.
+// If utc is true, the time is converted into UTC.
+func coerceTimeString(v any, layouts []string, layout string, utc bool) (any, string) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return v, ""
+	}
+	for _, l := range append([]string{layout}, layouts...) {
+		t, err := time.Parse(l, s)
+		if err != nil {
+			continue
+		}
+		// kintone accepts the time with an offset as is.
+		if utc && t.Format(time.RFC3339) == s {
+			return v, ""
+		}
+		if utc {
+			t = t.UTC()
+		}
+		converted := t.Format(layout)
+		if converted == s {
+			return v, ""
+		}
+		return converted, fmt.Sprintf("converted %q to %q", s, converted)
+	}
+	return v, ""
+}
+
+// isRFC3339 reports whether v is a string of date and time with offset.
+func isRFC3339(v any) bool {
+	s, _ := v.(string)
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
+
+// coerceLocalDateTime converts a date and time without offset into UTC, interpreting it in loc.
+func coerceLocalDateTime(v any, loc *time.Location) (any, string) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return v, ""
+	}
+	for _, l := range localDateTimeLayouts {
+		t, err := time.ParseInLocation(l, s, loc)
+		if err != nil {
+			continue
+		}
+		converted := t.UTC().Format(kintoneDateTimeLayout)
+		return converted, fmt.Sprintf("converted %q in %s to %q", s, loc, converted)
+	}
+	return v, ""
+}
+
+// coerceObjectList converts a single value or a list of strings into a list of objects such as [{"code": "user1"}].
+func coerceObjectList(v any, key string) (any, string) {
+	var list []any
+	note := ""
+	switch x := v.(type) {
+	case []any:
+		list = x
+	case string, map[string]any:
+		list = []any{x}
+		note = "wrapped the single value in an array"
+	default:
+		return v, ""
+	}
+
+	result := make([]any, len(list))
+	for i, item := range list {
+		if s, ok := item.(string); ok {
+			result[i] = map[string]any{key: s}
+			if note == "" {
+				note = fmt.Sprintf("converted strings to objects with %q", key)
+			}
+		} else {
+			result[i] = item
+		}
+	}
+	if note == "" {
+		return v, ""
+	}
+	return result, note
+}
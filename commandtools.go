@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// commandToolNamePattern is the format of the names of the command tools.
+var commandToolNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// maxCommandOutputSize is the maximum size of the standard output of a command tool.
+const maxCommandOutputSize = 10 << 20
+
+// CommandTool is a tool that is implemented by an external command, such as a script of the operator.
+// The command receives the arguments as JSON from the standard input, and writes the result to the standard output.
+type CommandTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Command is the program and its arguments, such as ["python3", "/opt/tools/report.py"].
+	Command []string `json:"command"`
+
+	// InputSchema is the JSON schema of the arguments. In default, the tool takes an object with any properties.
+	InputSchema map[string]any `json:"inputSchema"`
+
+	// Env is the environment variables that are passed to the command in addition to the ones of the server.
+	Env map[string]string `json:"env"`
+
+	// ReadOnly tells that the command doesn't modify anything, so that the tool can be used in read-only mode.
+	ReadOnly bool `json:"readOnly"`
+}
+
+// validateCommandTools checks the command tools in the configuration file.
+func (c Configuration) validateCommandTools() error {
+	names := make(map[string]bool)
+	for _, t := range c.appTools() {
+		names[t.Name] = true
+	}
+	for i, t := range c.Tools {
+		if !commandToolNamePattern.MatchString(t.Name) {
+			return fmt.Errorf("invalid value for \"tools[%d].name\": the name must start with a letter and contain only letters, digits, '_', and '-'", i)
+		}
+		if _, ok := findTool(t.Name); ok || names[t.Name] {
+			return fmt.Errorf("invalid value for \"tools[%d].name\": %q is already used by another tool", i, t.Name)
+		}
+		names[t.Name] = true
+		if len(t.Command) == 0 || t.Command[0] == "" {
+			return fmt.Errorf("invalid value for \"tools[%d].command\": the command must not be empty", i)
+		}
+		if typ, ok := t.InputSchema["type"]; ok && typ != "object" {
+			return fmt.Errorf("invalid value for \"tools[%d].inputSchema.type\": the type must be \"object\"", i)
+		}
+	}
+	return nil
+}
+
+// findCommandTool returns the command tool that has the name.
+func (c Configuration) findCommandTool(name string) (CommandTool, bool) {
+	for _, t := range c.Tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return CommandTool{}, false
+}
+
+// findCommandTool returns the command tool in the current settings that has the name.
+func (h *KintoneHandlers) findCommandTool(name string) (CommandTool, bool) {
+	return h.policy().Config.findCommandTool(name)
+}
+
+// info builds the tool definition of the command tool.
+func (t CommandTool) info() ToolInfo {
+	schema := JsonMap(t.InputSchema)
+	if schema == nil {
+		schema = JsonMap{"type": "object"}
+	} else if _, ok := schema["type"]; !ok {
+		schema = JsonMap(maps.Clone(t.InputSchema))
+		schema["type"] = "object"
+	}
+
+	return ToolInfo{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: schema,
+		Annotations: JsonMap{
+			"title":           t.Name,
+			"readOnlyHint":    t.ReadOnly,
+			"destructiveHint": !t.ReadOnly,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+	}
+}
+
+// commandEnv returns the environment variables for an external command, such as a command tool or a hook.
+// The variables of this server are removed, so that the credentials of kintone are not leaked to the command.
+func commandEnv(extra map[string]string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "KINTONE_") {
+			env = append(env, kv)
+		}
+	}
+	for k, v := range extra {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+// limitedBuffer is a buffer that fails when the content exceeds the limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("the output exceeds %s", formatSize(int64(b.limit)))
+	}
+	return b.Buffer.Write(p)
+}
+
+// runCommand runs the command with the input in the standard input, and returns the standard output.
+// The error includes the standard error output, so that the caller can tell why it failed.
+func runCommand(ctx context.Context, command []string, env []string, input []byte) ([]byte, error) {
+	stdout := &limitedBuffer{limit: maxCommandOutputSize}
+	stderr := &limitedBuffer{limit: maxCommandOutputSize}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = env
+	// The children of the command may keep the output open after the command is killed, such as "sh -c 'sleep 10'".
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 1000 {
+			msg = msg[:1000] + "..."
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return bytes.TrimSpace(stdout.Bytes()), nil
+}
+
+// CallCommandTool runs the command of the tool with the arguments in the standard input.
+// The standard output is returned as a text, which is indented if it is JSON.
+func (h *KintoneHandlers) CallCommandTool(ctx context.Context, t CommandTool, params json.RawMessage) ([]Content, error) {
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+
+	serverLog.DebugContext(ctx, "Running a command tool", "tool", t.Name, "command", t.Command)
+	out, err := runCommand(ctx, t.Command, commandEnv(t.Env), params)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	} else if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Tool %s failed: %v", t.Name, err),
+		}
+	}
+
+	var indented bytes.Buffer
+	if len(out) > 0 && json.Indent(&indented, out, "", "  ") == nil {
+		out = indented.Bytes()
+	}
+	return []Content{{Type: "text", Text: string(out)}}, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// maxCompletionValues is the maximum number of values in a completion response, defined in the MCP specification.
+const maxCompletionValues = 100
+
+type CompletionRequest struct {
+	Ref struct {
+		Type string `json:"type"`
+		Name string `json:"name,omitempty"`
+		URI  string `json:"uri,omitempty"`
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+	Context struct {
+		Arguments map[string]string `json:"arguments"`
+	} `json:"context"`
+}
+
+type CompletionResult struct {
+	Completion struct {
+		Values  []string `json:"values"`
+		Total   int      `json:"total"`
+		HasMore bool     `json:"hasMore"`
+	} `json:"completion"`
+}
+
+type appSummary struct {
+	AppID string
+	Name  string
+}
+
+// listPermittedApps returns all apps that the server can access.
+func (h *KintoneHandlers) listPermittedApps(ctx context.Context) ([]appSummary, error) {
+	all, err := h.fetchAllApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []appSummary
+	for _, app := range all {
+		if h.checkPermissions(app.AppID) == nil {
+			apps = append(apps, app)
+		}
+	}
+	return apps, nil
+}
+
+// fetchAllApps returns all apps in kintone, regardless of the permissions.
+// The list is cached for KINTONE_CACHE_TTL.
+func (h *KintoneHandlers) fetchAllApps(ctx context.Context) ([]appSummary, error) {
+	return cached(ctx, &h.cache, h.cacheScope(ctx)+"apps", h.CacheTTL, func(ctx context.Context) ([]appSummary, error) {
+		return h.fetchAppList(ctx)
+	})
+}
+
+func (h *KintoneHandlers) fetchAppList(ctx context.Context) ([]appSummary, error) {
+	const limit = 100
+
+	var apps []appSummary
+	for offset := 0; offset < 10000; offset += limit {
+		var httpRes struct {
+			Apps []KintoneAppDetail `json:"apps"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apps.json", nil, JsonMap{"offset": offset, "limit": limit}, &httpRes); err != nil {
+			return nil, err
+		}
+		for _, app := range httpRes.Apps {
+			apps = append(apps, appSummary{AppID: app.AppID, Name: app.Name})
+		}
+		if len(httpRes.Apps) < limit {
+			break
+		}
+	}
+	return apps, nil
+}
+
+// listFieldCodes returns all field codes in the app that can be passed to the client, including fields in tables.
+func (h *KintoneHandlers) listFieldCodes(ctx context.Context, appID string) ([]string, error) {
+	tables, err := h.fieldTables(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := h.fieldFilter(appID)
+	var codes []string
+	for code, table := range tables {
+		if filter.allowed(table, code) {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+	return codes, nil
+}
+
+// listStatusActions returns the names of process management actions in the app.
+func (h *KintoneHandlers) listStatusActions(ctx context.Context, appID string) ([]string, error) {
+	var process struct {
+		Actions []struct {
+			Name string `json:"name"`
+		} `json:"actions"`
+	}
+	if err := h.fetchAppSchema(ctx, "/k/v1/app/status.json", appID, &process); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(process.Actions))
+	for _, a := range process.Actions {
+		names = append(names, a.Name)
+	}
+	return names, nil
+}
+
+// Complete handles completion/complete requests.
+// The candidates are decided by the argument name, so that it works for any resource templates or prompts.
+func (h *KintoneHandlers) Complete(ctx context.Context, params CompletionRequest) (CompletionResult, error) {
+	var candidates []string
+	value := strings.ToLower(params.Argument.Value)
+	appID := params.Context.Arguments["appID"]
+
+	switch params.Argument.Name {
+	case "appID":
+		apps, err := h.listPermittedApps(ctx)
+		if err != nil {
+			return CompletionResult{}, err
+		}
+		for _, app := range apps {
+			if strings.HasPrefix(app.AppID, value) || strings.Contains(strings.ToLower(app.Name), value) {
+				candidates = append(candidates, app.AppID)
+			}
+		}
+	case "fieldCode", "field", "fields":
+		if appID == "" || h.checkPermissions(appID) != nil {
+			break
+		}
+		codes, err := h.listFieldCodes(ctx, appID)
+		if err != nil {
+			return CompletionResult{}, err
+		}
+		for _, code := range codes {
+			if strings.HasPrefix(strings.ToLower(code), value) {
+				candidates = append(candidates, code)
+			}
+		}
+	case "action":
+		if appID == "" || h.checkPermissions(appID) != nil {
+			break
+		}
+		actions, err := h.listStatusActions(ctx, appID)
+		if err != nil {
+			return CompletionResult{}, err
+		}
+		for _, action := range actions {
+			if strings.HasPrefix(strings.ToLower(action), value) {
+				candidates = append(candidates, action)
+			}
+		}
+	}
+
+	var result CompletionResult
+	result.Completion.Total = len(candidates)
+	if len(candidates) > maxCompletionValues {
+		candidates = candidates[:maxCompletionValues]
+		result.Completion.HasMore = true
+	}
+	if candidates == nil {
+		candidates = []string{}
+	}
+	result.Completion.Values = candidates
+
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// gzipMinRequestSize is the minimum size of a request body to compress, because compression makes small bodies larger.
+const gzipMinRequestSize = 1024
+
+// gzipRequestBody compresses the JSON body of the request if KINTONE_GZIP_REQUESTS is enabled.
+// The responses don't need this, because net/http requests gzip and decompresses the responses transparently.
+func (h *KintoneHandlers) gzipRequestBody(req *http.Request) error {
+	if !h.GzipRequests || req.GetBody == nil || req.ContentLength < gzipMinRequestSize {
+		return nil
+	}
+	if req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	bs := buf.Bytes()
+	req.Body = io.NopCloser(bytes.NewReader(bs))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(bs)), nil
+	}
+	req.ContentLength = int64(len(bs))
+	req.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
@@ -0,0 +1,674 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/macrat/go-jsonrpc2"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration is the content of the configuration file that is specified by KINTONE_CONFIG_FILE or the --config flag.
+// The file can be written in JSON or YAML.
+type Configuration struct {
+	Kintone      KintoneConfiguration      `json:"kintone"`
+	Access       AccessConfiguration       `json:"access"`
+	Limits       LimitsConfiguration       `json:"limits"`
+	Files        FilesConfiguration        `json:"files"`
+	Instructions InstructionsConfiguration `json:"instructions"`
+	Server       ServerConfiguration       `json:"server"`
+	Logging      LoggingConfiguration      `json:"logging"`
+
+	// Apps is the settings for each app.
+	// The key is an app ID, or "*" for the apps that are not listed.
+	// If Apps has some entries but no "*", the apps that are not listed are inaccessible.
+	Apps map[string]AppConfiguration `json:"apps"`
+
+	// Masking is the rules to hide personal information in records and comments.
+	Masking []MaskingRule `json:"masking"`
+
+	// Profiles is the other kintone environments that tools can select by the profile argument.
+	// The key is the name of the profile. "default" is reserved for the environment of KINTONE_BASE_URL.
+	Profiles map[string]ProfileConfiguration `json:"profiles"`
+
+	// Tools is the tools that are implemented by external commands.
+	Tools []CommandTool `json:"tools"`
+
+	// Hooks is the external commands or HTTP endpoints that are called around tool calls.
+	Hooks HooksConfiguration `json:"hooks"`
+}
+
+// KintoneConfiguration is the settings to connect to kintone.
+type KintoneConfiguration struct {
+	BaseURL            string              `json:"baseURL"`
+	CustomDomain       bool                `json:"customDomain"`
+	Username           string              `json:"username"`
+	Password           string              `json:"password"`
+	PasswordFile       string              `json:"passwordFile"`
+	APITokens          []string            `json:"apiTokens"`
+	APITokensFile      string              `json:"apiTokensFile"`
+	AppTokens          map[string][]string `json:"appTokens"`
+	Keyring            bool                `json:"keyring"`
+	KeyringService     string              `json:"keyringService"`
+	BasicAuth          BasicAuthSettings   `json:"basicAuth"`
+	ClientCertFile     string              `json:"clientCertFile"`
+	ClientCertPassword string              `json:"clientCertPassword"`
+	CAFile             string              `json:"caFile"`
+	TLSMinVersion      string              `json:"tlsMinVersion"`
+	InsecureSkipVerify bool                `json:"insecureSkipVerify"`
+	SessionCredentials bool                `json:"sessionCredentials"`
+	FieldACL           bool                `json:"fieldACL"`
+	GzipRequests       bool                `json:"gzipRequests"`
+	Mock               bool                `json:"mock"`
+	MockFixture        string              `json:"mockFixture"`
+	Proxy              string              `json:"proxy"`
+	ConnectTimeout     string              `json:"connectTimeout"`
+	Timeout            string              `json:"timeout"`
+	ToolTimeouts       map[string]string   `json:"toolTimeouts"`
+	CacheTTL           string              `json:"cacheTTL"`
+	Timezone           string              `json:"timezone"`
+	Retry              RetryConfiguration  `json:"retry"`
+
+	// RateLimit is the number of requests per second to each kintone domain.
+	RateLimit *float64 `json:"rateLimit"`
+
+	// MaxConcurrentRequests is the number of requests in progress to each kintone domain.
+	MaxConcurrentRequests *int `json:"maxConcurrentRequests"`
+
+	// Concurrency is the number of sub-requests that a tool runs in parallel.
+	Concurrency *int `json:"concurrency"`
+}
+
+// RetryConfiguration is the settings to retry requests to kintone that failed by transient problems.
+type RetryConfiguration struct {
+	Count   *int     `json:"count"`
+	Backoff string   `json:"backoff"`
+	Jitter  *float64 `json:"jitter"`
+}
+
+type BasicAuthSettings struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AccessConfiguration is the settings to restrict apps and tools.
+type AccessConfiguration struct {
+	AllowApps        []string `json:"allowApps"`
+	DenyApps         []string `json:"denyApps"`
+	ReadOnly         bool     `json:"readOnly"`
+	DisabledTools    []string `json:"disabledTools"`
+	ConfirmDelete    bool     `json:"confirmDelete"`
+	RequestEndpoints []string `json:"requestEndpoints"`
+}
+
+type LimitsConfiguration struct {
+	MaxUploadSize      string `json:"maxUploadSize"`
+	MaxResponseSize    string `json:"maxResponseSize"`
+	MaxAffectedRecords *int   `json:"maxAffectedRecords"`
+}
+
+type FilesConfiguration struct {
+	AllowedPaths      []string `json:"allowedPaths"`
+	DownloadDirectory string   `json:"downloadDirectory"`
+}
+
+type InstructionsConfiguration struct {
+	Text string `json:"text"`
+	Mode string `json:"mode"`
+	Apps bool   `json:"apps"`
+}
+
+// ServerConfiguration is the settings of the transports to serve MCP.
+type ServerConfiguration struct {
+	HTTPAddr   string   `json:"httpAddr"`
+	Listen     string   `json:"listen"`
+	AuthTokens []string `json:"authTokens"`
+	TLSCert    string   `json:"tlsCert"`
+	TLSKey     string   `json:"tlsKey"`
+	Lang       string   `json:"lang"`
+
+	WebhookAddr   string `json:"webhookAddr"`
+	WebhookSecret string `json:"webhookSecret"`
+}
+
+type LoggingConfiguration struct {
+	Level           string `json:"level"`
+	ServerLog       string `json:"serverLog"`
+	ServerLogFormat string `json:"serverLogFormat"`
+	ServerLogLevel  string `json:"serverLogLevel"`
+	Debug           bool   `json:"debug"`
+	AuditLog        string `json:"auditLog"`
+	AuditLogSize    string `json:"auditLogMaxSize"`
+	AuditLogFiles   *int   `json:"auditLogMaxFiles"`
+}
+
+// environ returns the environment variables that are equivalent to the settings.
+func (c Configuration) environ() map[string]string {
+	env := make(map[string]string)
+	str := func(key, value string) {
+		if value != "" {
+			env[key] = value
+		}
+	}
+	list := func(key string, values []string) {
+		if len(values) > 0 {
+			env[key] = strings.Join(values, ",")
+		}
+	}
+	flag := func(key string, value bool) {
+		if value {
+			env[key] = "1"
+		}
+	}
+
+	k := c.Kintone
+	str("KINTONE_BASE_URL", k.BaseURL)
+	flag("KINTONE_CUSTOM_DOMAIN", k.CustomDomain)
+	str("KINTONE_USERNAME", k.Username)
+	str("KINTONE_PASSWORD", k.Password)
+	str("KINTONE_PASSWORD_FILE", k.PasswordFile)
+	list("KINTONE_API_TOKEN", k.APITokens)
+	str("KINTONE_API_TOKEN_FILE", k.APITokensFile)
+	for id, tokens := range k.AppTokens {
+		list(appTokenEnvPrefix+id, tokens)
+	}
+	flag("KINTONE_KEYRING", k.Keyring)
+	str("KINTONE_KEYRING_SERVICE", k.KeyringService)
+	str("KINTONE_BASIC_AUTH_USERNAME", k.BasicAuth.Username)
+	str("KINTONE_BASIC_AUTH_PASSWORD", k.BasicAuth.Password)
+	str("KINTONE_CLIENT_CERT_FILE", k.ClientCertFile)
+	str("KINTONE_CLIENT_CERT_PASSWORD", k.ClientCertPassword)
+	str("KINTONE_CA_FILE", k.CAFile)
+	str("KINTONE_TLS_MIN_VERSION", k.TLSMinVersion)
+	flag("KINTONE_TLS_INSECURE_SKIP_VERIFY", k.InsecureSkipVerify)
+	flag("KINTONE_SESSION_CREDENTIALS", k.SessionCredentials)
+	flag("KINTONE_FIELD_ACL", k.FieldACL)
+	flag("KINTONE_GZIP_REQUESTS", k.GzipRequests)
+	flag("KINTONE_MOCK", k.Mock)
+	str("KINTONE_MOCK_FIXTURE", k.MockFixture)
+	str("KINTONE_PROXY", k.Proxy)
+	str("KINTONE_CONNECT_TIMEOUT", k.ConnectTimeout)
+	str("KINTONE_TIMEOUT", k.Timeout)
+	var toolTimeouts []string
+	for _, name := range slices.Sorted(maps.Keys(k.ToolTimeouts)) {
+		toolTimeouts = append(toolTimeouts, name+"="+k.ToolTimeouts[name])
+	}
+	list("KINTONE_TOOL_TIMEOUTS", toolTimeouts)
+	str("KINTONE_CACHE_TTL", k.CacheTTL)
+	str("KINTONE_TIMEZONE", k.Timezone)
+	if k.Retry.Count != nil {
+		env["KINTONE_RETRY_COUNT"] = strconv.Itoa(*k.Retry.Count)
+	}
+	str("KINTONE_RETRY_BACKOFF", k.Retry.Backoff)
+	if k.Retry.Jitter != nil {
+		env["KINTONE_RETRY_JITTER"] = strconv.FormatFloat(*k.Retry.Jitter, 'f', -1, 64)
+	}
+	if k.RateLimit != nil {
+		env["KINTONE_RATE_LIMIT"] = strconv.FormatFloat(*k.RateLimit, 'f', -1, 64)
+	}
+	if k.MaxConcurrentRequests != nil {
+		env["KINTONE_MAX_CONCURRENT_REQUESTS"] = strconv.Itoa(*k.MaxConcurrentRequests)
+	}
+	if k.Concurrency != nil {
+		env["KINTONE_CONCURRENCY"] = strconv.Itoa(*k.Concurrency)
+	}
+
+	list("KINTONE_ALLOW_APPS", c.Access.AllowApps)
+	list("KINTONE_DENY_APPS", c.Access.DenyApps)
+	flag("KINTONE_READ_ONLY", c.Access.ReadOnly)
+	list("KINTONE_DISABLED_TOOLS", c.Access.DisabledTools)
+	flag("KINTONE_CONFIRM_DELETE", c.Access.ConfirmDelete)
+	list("KINTONE_REQUEST_ENDPOINTS", c.Access.RequestEndpoints)
+
+	str("KINTONE_MAX_UPLOAD_SIZE", c.Limits.MaxUploadSize)
+	str("KINTONE_MAX_RESPONSE_SIZE", c.Limits.MaxResponseSize)
+	if c.Limits.MaxAffectedRecords != nil {
+		env["KINTONE_MAX_AFFECTED_RECORDS"] = strconv.Itoa(*c.Limits.MaxAffectedRecords)
+	}
+
+	list("KINTONE_ALLOWED_PATHS", c.Files.AllowedPaths)
+	str("KINTONE_DOWNLOAD_DIR", c.Files.DownloadDirectory)
+
+	str("KINTONE_INSTRUCTIONS", c.Instructions.Text)
+	str("KINTONE_INSTRUCTIONS_MODE", c.Instructions.Mode)
+	flag("KINTONE_INSTRUCTIONS_APPS", c.Instructions.Apps)
+
+	str("KINTONE_MCP_HTTP_ADDR", c.Server.HTTPAddr)
+	str("KINTONE_MCP_LISTEN", c.Server.Listen)
+	list("KINTONE_MCP_AUTH_TOKENS", c.Server.AuthTokens)
+	str("KINTONE_MCP_TLS_CERT", c.Server.TLSCert)
+	str("KINTONE_MCP_TLS_KEY", c.Server.TLSKey)
+	str("KINTONE_LANG", c.Server.Lang)
+	str("KINTONE_WEBHOOK_ADDR", c.Server.WebhookAddr)
+	str("KINTONE_WEBHOOK_SECRET", c.Server.WebhookSecret)
+
+	str("KINTONE_LOG_LEVEL", c.Logging.Level)
+	str("KINTONE_SERVER_LOG", c.Logging.ServerLog)
+	str("KINTONE_SERVER_LOG_FORMAT", c.Logging.ServerLogFormat)
+	flag("KINTONE_DEBUG", c.Logging.Debug)
+	str("KINTONE_SERVER_LOG_LEVEL", c.Logging.ServerLogLevel)
+	str("KINTONE_AUDIT_LOG", c.Logging.AuditLog)
+	str("KINTONE_AUDIT_LOG_MAX_SIZE", c.Logging.AuditLogSize)
+	if c.Logging.AuditLogFiles != nil {
+		env["KINTONE_AUDIT_LOG_MAX_FILES"] = strconv.Itoa(*c.Logging.AuditLogFiles)
+	}
+
+	return env
+}
+
+// configSettings is the settings of the configuration file, keyed by the names of the equivalent environment variables.
+// They are read by lookupSetting instead of setting the environment variables,
+// so that the secrets in the file are not inherited by child processes.
+var configSettings struct {
+	sync.RWMutex
+	values map[string]string
+}
+
+// Apply makes the settings visible to Getenv and the similar functions, replacing the settings that are applied before.
+// Environment variables have a higher priority than the configuration file.
+func (c Configuration) Apply() {
+	values := c.environ()
+
+	configSettings.Lock()
+	defer configSettings.Unlock()
+	configSettings.values = values
+}
+
+// lookupSetting returns the environment variable, or the setting in the configuration file if the variable is not set.
+func lookupSetting(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+
+	configSettings.RLock()
+	defer configSettings.RUnlock()
+	return configSettings.values[key]
+}
+
+// settingKeys returns the names of the environment variables and the settings in the configuration file that start with prefix.
+func settingKeys(prefix string) []string {
+	var keys []string
+	for _, kv := range os.Environ() {
+		if key, _, _ := strings.Cut(kv, "="); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	configSettings.RLock()
+	defer configSettings.RUnlock()
+	for key := range configSettings.values {
+		if strings.HasPrefix(key, prefix) && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// AppConfiguration is the settings for an app.
+type AppConfiguration struct {
+	Permissions Permissions `json:"permissions"`
+
+	// AllowFields is the list of field codes that can be passed to the client. All fields are allowed if empty.
+	// Fields in a table are allowed if the table is allowed.
+	AllowFields []string `json:"allowFields"`
+
+	// DenyFields is the list of field codes that must never be passed to the client. It has a higher priority than AllowFields.
+	DenyFields []string `json:"denyFields"`
+
+	// Defaults is the default arguments of readRecords for the app.
+	Defaults RecordDefaults `json:"defaults"`
+
+	// ToolName enables the tools that are generated from the fields of the app, such as createCustomerRecord for "Customer".
+	ToolName string `json:"toolName"`
+
+	// Templates is the presets of records for createRecordFromTemplate tool. The key is the name of the template.
+	Templates map[string]RecordTemplate `json:"templates"`
+
+	// MaxAffectedRecords is the maximum number of records that a write tool can change at once in the app.
+	// KINTONE_MAX_AFFECTED_RECORDS is used if it is 0.
+	MaxAffectedRecords int `json:"maxAffectedRecords"`
+}
+
+// Permissions is the set of operations that are allowed for an app.
+type Permissions struct {
+	Read   bool `json:"read"`
+	Write  bool `json:"write"`
+	Delete bool `json:"delete"`
+
+	// Manage allows changing the settings of the app, such as the permissions. It is not granted without a configuration file.
+	Manage bool `json:"manage"`
+}
+
+// fullPermissions is the permissions for apps when no configuration file is given.
+var fullPermissions = Permissions{Read: true, Write: true, Delete: true}
+
+// UnmarshalJSON parses the permissions. Read is true unless it is explicitly set to false.
+func (p *Permissions) UnmarshalJSON(data []byte) error {
+	type plain Permissions
+	v := plain{Read: true}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	*p = Permissions(v)
+	return nil
+}
+
+// allows reports whether the operation, which is "read", "write", "delete", or "manage", is allowed.
+func (p Permissions) allows(operation string) bool {
+	switch operation {
+	case "read":
+		return p.Read
+	case "write":
+		return p.Write
+	case "delete":
+		return p.Delete
+	case "manage":
+		return p.Manage
+	}
+	return false
+}
+
+// LoadConfiguration reads the configuration file in JSON or YAML.
+// The format is decided by the extension of the file.
+func LoadConfiguration(path string) (Configuration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	var raw any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return Configuration{}, fmt.Errorf("%s: %w", path, err)
+		}
+		raw = normalizeYAML(raw)
+	default:
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return Configuration{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := checkConfigValue(raw, reflect.TypeFor[Configuration](), ""); err != nil {
+		return Configuration{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return Configuration{}, fmt.Errorf("%s: %w", path, err)
+	}
+	var c Configuration
+	if err := json.Unmarshal(normalized, &c); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return Configuration{}, fmt.Errorf("%s: invalid value for %q: %s is expected but got %s", path, typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+		return Configuration{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for id, app := range c.Apps {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil && id != "*" {
+			return Configuration{}, fmt.Errorf("%s: invalid app ID in \"apps\": %q", path, id)
+		}
+		if app.Defaults.Limit < 0 || app.Defaults.Limit > 500 {
+			return Configuration{}, fmt.Errorf("%s: invalid value for \"apps.%s.defaults.limit\": the limit must be between 1 and 500", path, id)
+		}
+		if app.MaxAffectedRecords < 0 {
+			return Configuration{}, fmt.Errorf("%s: invalid value for \"apps.%s.maxAffectedRecords\": the number must not be negative", path, id)
+		}
+		if app.ToolName != "" && (id == "*" || !appToolNamePattern.MatchString(app.ToolName)) {
+			return Configuration{}, fmt.Errorf("%s: invalid value for \"apps.%s.toolName\": the name must start with an uppercase letter and contain only letters and digits, and can't be used for \"*\"", path, id)
+		}
+		for name, t := range app.Templates {
+			if id == "*" {
+				return Configuration{}, fmt.Errorf("%s: invalid key \"apps.*.templates\": templates can't be used for \"*\"", path)
+			}
+			if slices.Contains(t.Inputs, "") {
+				return Configuration{}, fmt.Errorf("%s: invalid value for \"apps.%s.templates.%s.inputs\": the field code must not be empty", path, id, name)
+			}
+		}
+	}
+	toolNames := make(map[string]string)
+	for id, app := range c.Apps {
+		if app.ToolName == "" {
+			continue
+		}
+		if other, ok := toolNames[app.ToolName]; ok {
+			return Configuration{}, fmt.Errorf("%s: duplicated toolName %q in \"apps.%s\" and \"apps.%s\"", path, app.ToolName, other, id)
+		}
+		toolNames[app.ToolName] = id
+	}
+	for id := range c.Kintone.AppTokens {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return Configuration{}, fmt.Errorf("%s: invalid app ID in \"kintone.appTokens\": %q", path, id)
+		}
+	}
+
+	if err := c.validateCommandTools(); err != nil {
+		return Configuration{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.validateHooks(); err != nil {
+		return Configuration{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i := range c.Masking {
+		if err := c.Masking[i].compile(); err != nil {
+			return Configuration{}, fmt.Errorf("%s: \"masking[%d]\": %w", path, i, err)
+		}
+	}
+
+	return c, nil
+}
+
+// normalizeYAML converts maps that are decoded from YAML into map[string]any, so that they can be handled as same as JSON.
+func normalizeYAML(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			v[k] = normalizeYAML(x)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(x)
+		}
+		return m
+	case []any:
+		for i, x := range v {
+			v[i] = normalizeYAML(x)
+		}
+	}
+	return v
+}
+
+// checkConfigValue reports an error with the path of the key if the configuration has a key that is not defined in t, or a value of a wrong type.
+func checkConfigValue(v any, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if v == nil {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]any)
+		if !ok && path == "" {
+			return errors.New("the configuration must be an object")
+		} else if !ok {
+			return fmt.Errorf("invalid value for %q: an object is expected", path)
+		}
+		fields := make(map[string]reflect.Type)
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); f.IsExported() && name != "" && name != "-" {
+				fields[name] = f.Type
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			ft, ok := fields[key]
+			if !ok {
+				return fmt.Errorf("unknown key %q", joinConfigPath(path, key))
+			}
+			if err := checkConfigValue(m[key], ft, joinConfigPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("invalid value for %q: an object is expected", path)
+		}
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			if err := checkConfigValue(m[key], t.Elem(), joinConfigPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		s, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("invalid value for %q: a list is expected", path)
+		}
+		for i, x := range s {
+			if err := checkConfigValue(x, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("invalid value for %q: a string is expected", path)
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("invalid value for %q: true or false is expected", path)
+		}
+	case reflect.Int:
+		switch n := v.(type) {
+		case int:
+		case float64:
+			if n != float64(int(n)) {
+				return fmt.Errorf("invalid value for %q: an integer is expected", path)
+			}
+		default:
+			return fmt.Errorf("invalid value for %q: an integer is expected", path)
+		}
+	case reflect.Float64:
+		switch v.(type) {
+		case int, float64:
+		default:
+			return fmt.Errorf("invalid value for %q: a number is expected", path)
+		}
+	}
+
+	return nil
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// appConfiguration returns the settings for the app.
+// The second return value is false if the app is not listed in the configuration file.
+func (c Configuration) appConfiguration(id string) (AppConfiguration, bool) {
+	if len(c.Apps) == 0 {
+		return AppConfiguration{Permissions: fullPermissions}, true
+	}
+	if app, ok := c.Apps[id]; ok {
+		return app, true
+	}
+	if app, ok := c.Apps["*"]; ok {
+		return app, true
+	}
+	return AppConfiguration{}, false
+}
+
+// permissionsFor returns the permissions for the app.
+// The second return value is false if the app is not listed in the configuration file.
+func (c Configuration) permissionsFor(id string) (Permissions, bool) {
+	app, ok := c.appConfiguration(id)
+	return app.Permissions, ok
+}
+
+// checkOperation checks if the operation, which is "read", "write", "delete", or "manage", is allowed for the app.
+func (h *KintoneHandlers) checkOperation(id, operation string) error {
+	if err := h.checkAllowed(id); err != nil {
+		return err
+	}
+
+	p, listed := h.policy().Config.permissionsFor(id)
+	if !listed {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("App ID %s is inaccessible because it is not listed in the configuration file (KINTONE_CONFIG_FILE). Please check the MCP server settings.", id),
+		}
+	}
+	if !p.allows(operation) {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("The %s permission for app ID %s is not granted in the configuration file (KINTONE_CONFIG_FILE). Please check the MCP server settings.", operation, id),
+		}
+	}
+
+	return nil
+}
+
+// appScopedTools maps the tools that modify an app into the operation that the tools need.
+var appScopedTools = map[string]string{
+	"createRecord":                    "write",
+	"createRecordFromTemplate":        "write",
+	"importRecords":                   "write",
+	"restoreAppData":                  "write",
+	"updateRecord":                    "write",
+	"deleteRecord":                    "delete",
+	"createRecordComment":             "write",
+	"createRecordComments":            "write",
+	"updateProcessManagementAssignee": "write",
+	"executeProcessManagementAction":  "write",
+	"updateAppAcl":                    "manage",
+	"updateRecordAcl":                 "manage",
+	"updateFieldAcl":                  "manage",
+	"updateGeneralNotifications":      "manage",
+	"updatePerRecordNotifications":    "manage",
+	"deployAppSettings":               "manage",
+}
+
+// appsWithPermission returns the IDs of the apps that the operation is allowed.
+// The second return value is true if the operation is allowed for the apps that are not listed in the configuration file, too.
+func (h *KintoneHandlers) appsWithPermission(operation string) ([]string, bool) {
+	config := h.policy().Config
+	if p, listed := config.permissionsFor("*"); listed && p.allows(operation) {
+		return nil, true
+	}
+
+	var ids []string
+	for id, app := range config.Apps {
+		if id != "*" && app.Permissions.allows(operation) && h.checkAllowed(id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		x, _ := strconv.ParseUint(a, 10, 64)
+		y, _ := strconv.ParseUint(b, 10, 64)
+		return cmp.Compare(x, y)
+	})
+	return ids, false
+}
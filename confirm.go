@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// confirmationTTL is how long a confirmation token for deletion is valid.
+const confirmationTTL = 5 * time.Minute
+
+// confirmationStore keeps the tokens to confirm destructive operations, which are issued with a preview of the operation.
+type confirmationStore struct {
+	mu     sync.Mutex
+	tokens map[string]pendingConfirmation
+}
+
+type pendingConfirmation struct {
+	target  string
+	expires time.Time
+}
+
+// issue creates a new token for the target, such as the app ID and the record ID to delete.
+func (s *confirmationStore) issue(target string) (string, time.Time) {
+	var b [16]byte
+	rand.Read(b[:])
+	token := hex.EncodeToString(b[:])
+	expires := time.Now().Add(confirmationTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokens == nil {
+		s.tokens = make(map[string]pendingConfirmation)
+	}
+	now := time.Now()
+	for t, c := range s.tokens {
+		if now.After(c.expires) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = pendingConfirmation{target: target, expires: expires}
+
+	return token, expires
+}
+
+// consume reports whether the token is valid for the target. A token can be used only once.
+func (s *confirmationStore) consume(token, target string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.tokens[token]
+	if !ok || c.target != target {
+		return false
+	}
+	delete(s.tokens, token)
+	return time.Now().Before(c.expires)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// KintoneCredentials is a set of credentials that the client provides for the session.
+// It is used to host one server for multiple users, each with their own kintone account.
+type KintoneCredentials struct {
+	BaseURL  string `json:"baseURL"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	APIToken string `json:"apiToken"`
+}
+
+// IsZero reports whether no credentials are provided.
+func (c KintoneCredentials) IsZero() bool {
+	return c == KintoneCredentials{}
+}
+
+// credentialsFromHeader reads credentials from the X-Kintone-* headers of the request.
+func credentialsFromHeader(h http.Header) KintoneCredentials {
+	return KintoneCredentials{
+		BaseURL:  h.Get("X-Kintone-Base-URL"),
+		Username: h.Get("X-Kintone-Username"),
+		Password: h.Get("X-Kintone-Password"),
+		APIToken: h.Get("X-Kintone-API-Token"),
+	}
+}
+
+// kintoneAuth is the resolved credentials to send requests to kintone.
+type kintoneAuth struct {
+	URL       *url.URL
+	User      string
+	Auth      string
+	Token     string
+	BasicAuth string
+}
+
+// redactedText is the text to replace secrets with.
+const redactedText = "[REDACTED]"
+
+// credentialHeaderPattern matches headers that carry credentials, in case kintone or a proxy echoes the request back.
+var credentialHeaderPattern = regexp.MustCompile(`(?i)((?:x-cybozu-authorization|x-cybozu-api-token|proxy-authorization|authorization)["']?\s*[:=]\s*["']?)(?:(?:basic|bearer)\s+)?[^\s"',;]+`)
+
+// secrets returns the secret values in the credentials, including decoded passwords.
+func (a kintoneAuth) secrets() []string {
+	var secrets []string
+	for _, encoded := range []string{a.Auth, a.BasicAuth} {
+		if encoded == "" {
+			continue
+		}
+		secrets = append(secrets, encoded)
+		if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+			if _, password, ok := strings.Cut(string(decoded), ":"); ok && password != "" {
+				secrets = append(secrets, password)
+			}
+		}
+	}
+	for _, t := range strings.Split(a.Token, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			secrets = append(secrets, t)
+		}
+	}
+
+	for _, s := range secrets {
+		if escaped := url.QueryEscape(s); escaped != s {
+			secrets = append(secrets, escaped)
+		}
+	}
+
+	// Replace longer secrets first, so that a secret that contains another one is fully redacted.
+	slices.SortFunc(secrets, func(x, y string) int { return len(y) - len(x) })
+	return secrets
+}
+
+// redact replaces the credentials in s with a placeholder.
+func (a kintoneAuth) redact(s string) string {
+	s = credentialHeaderPattern.ReplaceAllString(s, "${1}"+redactedText)
+	for _, secret := range a.secrets() {
+		s = strings.ReplaceAll(s, secret, redactedText)
+	}
+	return s
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "%s:%s", username, password))
+}
+
+// resolveCredentials validates the credentials that the client provided, and converts them into kintoneAuth.
+func (h *KintoneHandlers) resolveCredentials(c KintoneCredentials) (*kintoneAuth, error) {
+	if !h.SessionCredentials {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Per-session credentials are not allowed. Please set KINTONE_SESSION_CREDENTIALS to enable it.",
+		}
+	}
+
+	// A username without a password is not authenticated, so it can't tell whose field permissions are applied.
+	if c.Username != "" && c.Password == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "The username can't be used without the password. To apply the field permissions of the user, bind the bearer token to the user in KINTONE_MCP_AUTH_TOKENS.",
+		}
+	}
+
+	if (c.Username == "" || c.Password == "") && c.APIToken == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Either username/password or apiToken must be provided",
+		}
+	}
+
+	auth := &kintoneAuth{
+		URL:       h.URL,
+		Token:     c.APIToken,
+		BasicAuth: h.BasicAuth,
+	}
+	if c.Username != "" && c.Password != "" {
+		auth.User = c.Username
+		auth.Auth = basicAuth(c.Username, c.Password)
+	}
+
+	if c.BaseURL != "" {
+		// Allowing to change the base URL when the server has one could be used to send requests to arbitrary hosts.
+		if h.URL != nil {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: "The base URL can't be changed because KINTONE_BASE_URL is set on the server",
+			}
+		}
+		u, err := parseBaseURL(c.BaseURL, h.CustomDomain)
+		if err != nil {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Invalid base URL: %v", err),
+			}
+		}
+		if u.Scheme != "https" {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Invalid base URL: %s: the base URL must be an https:// URL", c.BaseURL),
+			}
+		}
+		if h.SecureAccess {
+			u = secureAccessURL(u)
+		}
+		auth.URL = u
+		// The Basic authentication of the server is for KINTONE_BASE_URL, so don't send it to other domains.
+		auth.BasicAuth = ""
+	}
+
+	if auth.URL == nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "baseURL must be provided",
+		}
+	}
+
+	return auth, nil
+}
+
+// auth returns the credentials to access kintone for the request.
+// The credentials of the profile are used if the request selects one.
+// Otherwise, the credentials of the session are used if provided, or the credentials from the environment variables are used.
+func (h *KintoneHandlers) auth(ctx context.Context) (kintoneAuth, error) {
+	if auth, ok, err := h.profileAuth(ctx); ok {
+		return auth, err
+	}
+
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		auth := s.auth
+		s.mu.Unlock()
+		if auth != nil {
+			return *auth, nil
+		}
+	}
+
+	if p := h.policy(); h.URL == nil || (h.Auth == "" && p.Token == "" && len(p.AppTokens) == 0) {
+		return kintoneAuth{}, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidRequestCode,
+			Message: "kintone credentials are not provided. Please provide them via the initialize request or the X-Kintone-* headers.",
+		}
+	}
+
+	return kintoneAuth{URL: h.URL, User: h.Username, Auth: h.Auth, Token: h.apiTokensFor(appIDFromContext(ctx)), BasicAuth: h.BasicAuth}, nil
+}
+
+// cacheScope returns a prefix of cache keys, to avoid sharing cached data between users with different credentials.
+func (h *KintoneHandlers) cacheScope(ctx context.Context) string {
+	if name := profileFromContext(ctx); name != "" {
+		return "profile:" + name + ":"
+	}
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		auth := s.auth
+		s.mu.Unlock()
+		if auth != nil {
+			sum := sha256.Sum256([]byte(auth.URL.String() + "\x00" + auth.Auth + "\x00" + auth.Token + "\x00" + auth.BasicAuth))
+			return "session:" + hex.EncodeToString(sum[:16]) + ":"
+		}
+	}
+	return ""
+}
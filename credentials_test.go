@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+const (
+	testUsername      = "alice"
+	testPassword      = "p@ss:w0rd/s3cret"
+	testAPIToken      = "tokenABCDEF0123456789"
+	testOtherAPIToken = "tokenZYXWVU9876543210"
+	testProxyUsername = "proxy"
+	testProxyPassword = "proxy-pa55word"
+
+	testSessionUsername = "bob"
+	testSessionPassword = "s3ssion+pass&word"
+	testSessionAPIToken = "tokenSESSION000111222"
+)
+
+// testSecrets is the values that must not appear in the outputs, including the encoded forms.
+var testSecrets = []string{
+	testPassword,
+	url.QueryEscape(testPassword),
+	basicAuth(testUsername, testPassword),
+	testAPIToken,
+	testOtherAPIToken,
+	testProxyPassword,
+	basicAuth(testProxyUsername, testProxyPassword),
+	testSessionPassword,
+	url.QueryEscape(testSessionPassword),
+	basicAuth(testSessionUsername, testSessionPassword),
+	testSessionAPIToken,
+}
+
+// assertNoSecrets fails the test if s contains any of testSecrets.
+func assertNoSecrets(t *testing.T, where, s string) {
+	t.Helper()
+	for _, secret := range testSecrets {
+		if strings.Contains(s, secret) {
+			t.Errorf("%s contains the secret %q: %s", where, secret, s)
+		}
+	}
+	if !strings.Contains(s, redactedText) {
+		t.Errorf("%s doesn't contain %s, so the echoed credentials are not tested: %s", where, redactedText, s)
+	}
+}
+
+// echoCredentialsHandler responds errors that contain the credentials of the request, like the error page of a proxy that dumps the request.
+// /k/v1/app.json responds in the JSON format of kintone, and the others respond in plain text.
+func echoCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	dump := fmt.Sprintf("X-Cybozu-Authorization: %s\nX-Cybozu-API-Token: %s\nAuthorization: %s",
+		r.Header.Get("X-Cybozu-Authorization"), r.Header.Get("X-Cybozu-API-Token"), r.Header.Get("Authorization"))
+	if decoded, err := base64.StdEncoding.DecodeString(r.Header.Get("X-Cybozu-Authorization")); err == nil {
+		dump += "\nuser=" + string(decoded)
+		if _, password, ok := strings.Cut(string(decoded), ":"); ok {
+			dump += "\nquery=password%3D" + url.QueryEscape(password)
+		}
+	}
+	body, _ := io.ReadAll(r.Body)
+	dump += "\nbody=" + string(body)
+	dump += "\ntokens=" + strings.ReplaceAll(r.Header.Get("X-Cybozu-API-Token"), ",", " and ")
+
+	if r.URL.Path == "/k/v1/app.json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(JsonMap{"code": "CB_VA01", "id": "test", "message": dump, "errors": JsonMap{"app": JsonMap{"messages": []string{dump}}}})
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	io.WriteString(w, dump)
+}
+
+// newEchoCredentialsHandlers creates the handlers with all kinds of credentials, that send requests to echoCredentialsHandler.
+func newEchoCredentialsHandlers(t *testing.T) *KintoneHandlers {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(echoCredentialsHandler))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("KINTONE_BASE_URL", srv.URL)
+	t.Setenv("KINTONE_CUSTOM_DOMAIN", "true")
+	t.Setenv("KINTONE_USERNAME", testUsername)
+	t.Setenv("KINTONE_PASSWORD", testPassword)
+	t.Setenv("KINTONE_API_TOKEN", testAPIToken+","+testOtherAPIToken)
+	t.Setenv("KINTONE_BASIC_AUTH_USERNAME", testProxyUsername)
+	t.Setenv("KINTONE_BASIC_AUTH_PASSWORD", testProxyPassword)
+	t.Setenv("KINTONE_RETRY_COUNT", "0")
+
+	h, err := NewKintoneHandlersFromEnv()
+	if err != nil {
+		t.Fatalf("failed to create the handlers: %v", err)
+	}
+	return h
+}
+
+// captureServerLog writes the server log into the buffer until the test ends.
+func captureServerLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	orig := serverLog
+	serverLog = slog.New(newServerLogHandler(&buf, "json", slog.LevelDebug))
+	t.Cleanup(func() { serverLog = orig })
+	return &buf
+}
+
+// assertErrorHasNoSecrets checks the error message, the tool result, and the data of the JSON-RPC error.
+func assertErrorHasNoSecrets(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assertNoSecrets(t, "the error message", err.Error())
+
+	var apiErr *KintoneAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected KintoneAPIError but got %T: %v", err, err)
+	}
+	assertNoSecrets(t, "the tool result", apiErr.ToolResult().Content[0].Text)
+
+	var rpcErr jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected the error to be converted into jsonrpc2.Error")
+	}
+	bs, _ := json.Marshal(rpcErr)
+	assertNoSecrets(t, "the JSON-RPC error", string(bs))
+}
+
+func TestRedact(t *testing.T) {
+	auth := kintoneAuth{
+		Auth:      basicAuth(testUsername, testPassword),
+		Token:     testAPIToken + ", " + testOtherAPIToken,
+		BasicAuth: basicAuth(testProxyUsername, testProxyPassword),
+	}
+
+	tests := []string{
+		"X-Cybozu-Authorization: " + auth.Auth,
+		`{"x-cybozu-api-token": "` + testAPIToken + `"}`,
+		"Authorization: Basic " + auth.BasicAuth,
+		"proxy-authorization=Basic " + auth.BasicAuth,
+		"password is " + testPassword,
+		"password=" + url.QueryEscape(testPassword),
+		"tokens: " + testAPIToken + " " + testOtherAPIToken,
+		"the proxy password is " + testProxyPassword,
+	}
+	for _, input := range tests {
+		assertNoSecrets(t, fmt.Sprintf("redact(%q)", input), auth.redact(input))
+	}
+
+	if got := auth.redact("nothing secret here"); got != "nothing secret here" {
+		t.Errorf("redact changed the text without secrets: %q", got)
+	}
+}
+
+func TestSendHTTPRedactsPlainTextError(t *testing.T) {
+	log := captureServerLog(t)
+	h := newEchoCredentialsHandlers(t)
+
+	_, err := h.SendHTTP(context.Background(), "GET", "/k/v1/records.json", Query{"app": "1"}, strings.NewReader(testPassword), "text/plain")
+	assertErrorHasNoSecrets(t, err)
+
+	for _, s := range testSecrets {
+		if strings.Contains(log.String(), s) {
+			t.Errorf("the server log contains the secret %q: %s", s, log.String())
+		}
+	}
+}
+
+func TestSendHTTPRedactsKintoneError(t *testing.T) {
+	h := newEchoCredentialsHandlers(t)
+
+	_, err := h.SendHTTP(context.Background(), "GET", "/k/v1/app.json", Query{"id": "1"}, nil, "")
+	assertErrorHasNoSecrets(t, err)
+
+	var apiErr *KintoneAPIError
+	errors.As(err, &apiErr)
+	if apiErr.Code != "CB_VA01" {
+		t.Errorf("expected the error of kintone to be parsed but got %+v", apiErr)
+	}
+	for _, f := range apiErr.FieldErrors {
+		assertNoSecrets(t, "the field error", strings.Join(f.Messages, " "))
+	}
+}
+
+func TestToolsCallRedactsError(t *testing.T) {
+	h := newEchoCredentialsHandlers(t)
+
+	bs, _ := json.Marshal(JsonMap{"appID": "1"})
+	res, err := h.ToolsCall(context.Background(), ToolsCallRequest{Name: "readRecords", Arguments: bs})
+	if err != nil {
+		t.Fatalf("expected the error to be reported as a tool result but got %v", err)
+	}
+	if !res.IsError {
+		t.Fatal("expected an error result")
+	}
+	assertNoSecrets(t, "the tool result", res.Content[0].Text)
+}
+
+// roundTripFunc is an http.RoundTripper by a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSendHTTPRedactsTransportError(t *testing.T) {
+	log := captureServerLog(t)
+	h := newEchoCredentialsHandlers(t)
+	h.client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, fmt.Errorf("proxy refused the request with X-Cybozu-Authorization: %s, token %s, and password %s",
+			req.Header.Get("X-Cybozu-Authorization"), req.Header.Get("X-Cybozu-API-Token"), testProxyPassword)
+	})}
+
+	_, err := h.SendHTTP(context.Background(), "GET", "/k/v1/records.json", Query{"app": "1"}, nil, "")
+	assertErrorHasNoSecrets(t, err)
+
+	for _, s := range testSecrets {
+		if strings.Contains(log.String(), s) {
+			t.Errorf("the server log contains the secret %q: %s", s, log.String())
+		}
+	}
+}
+
+func TestSessionCredentialsRedacted(t *testing.T) {
+	h := newEchoCredentialsHandlers(t)
+	h.SessionCredentials = true
+
+	auth, err := h.resolveCredentials(KintoneCredentials{Username: testSessionUsername, Password: testSessionPassword, APIToken: testSessionAPIToken})
+	if err != nil {
+		t.Fatalf("failed to resolve the credentials: %v", err)
+	}
+	// The credentials of the session are used instead of the environment variables.
+	s := &Session{w: io.Discard, auth: auth}
+	ctx := s.context(context.Background())
+
+	_, err = h.SendHTTP(ctx, "GET", "/k/v1/records.json", Query{"app": "1"}, nil, "")
+	assertErrorHasNoSecrets(t, err)
+
+	_, err = h.SendHTTP(ctx, "GET", "/k/v1/app.json", Query{"id": "1"}, nil, "")
+	assertErrorHasNoSecrets(t, err)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceBodyLimit is the maximum number of bytes of request and response bodies in the debug trace.
+const traceBodyLimit = 4096
+
+// traceHeaders returns the headers for the debug trace, with the values of credential headers replaced.
+func traceHeaders(header http.Header) map[string]string {
+	m := make(map[string]string, len(header))
+	for key, values := range header {
+		if sensitiveLogKey.MatchString(key) {
+			m[key] = redactedText
+		} else {
+			m[key] = redactLog(strings.Join(values, ", "))
+		}
+	}
+	return m
+}
+
+// isTextContent reports whether the body of the content type is readable in the log.
+func isTextContent(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "text/")
+}
+
+// traceBody formats the first traceBodyLimit bytes of a body for the debug trace.
+func traceBody(contentType string, head []byte, size int64) string {
+	if size == 0 {
+		return ""
+	}
+	if !isTextContent(contentType) {
+		return fmt.Sprintf("(%d bytes of %s)", size, contentType)
+	}
+	if size > int64(len(head)) {
+		return fmt.Sprintf("%s... (%d more bytes)", head, size-int64(len(head)))
+	}
+	return string(head)
+}
+
+// traceRequest writes the request to the server log if KINTONE_DEBUG is enabled.
+// It has to be called before the body is compressed.
+func (h *KintoneHandlers) traceRequest(req *http.Request) {
+	if !h.Debug {
+		return
+	}
+
+	var body string
+	if req.GetBody != nil {
+		if r, err := req.GetBody(); err == nil {
+			head, _ := io.ReadAll(io.LimitReader(r, traceBodyLimit))
+			size := int64(len(head))
+			if n, err := io.Copy(io.Discard, r); err == nil {
+				size += n
+			}
+			r.Close()
+			body = traceBody(req.Header.Get("Content-Type"), head, size)
+		}
+	}
+
+	serverLog.DebugContext(req.Context(), "kintone request trace",
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"headers", traceHeaders(req.Header),
+		"body", body,
+	)
+}
+
+// traceResponse makes the response to be written to the server log when its body is closed, if KINTONE_DEBUG is enabled.
+// The body is recorded while the caller reads it, so that the response is still streamed.
+func (h *KintoneHandlers) traceResponse(req *http.Request, res *http.Response, start time.Time) {
+	if !h.Debug {
+		return
+	}
+	res.Body = &traceBodyReader{
+		ReadCloser: res.Body,
+		req:        req,
+		res:        res,
+		start:      start,
+	}
+}
+
+type traceBodyReader struct {
+	io.ReadCloser
+
+	req   *http.Request
+	res   *http.Response
+	start time.Time
+	head  bytes.Buffer
+	size  int64
+	once  sync.Once
+}
+
+func (r *traceBodyReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if rest := traceBodyLimit - r.head.Len(); rest > 0 {
+		r.head.Write(p[:min(n, rest)])
+	}
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *traceBodyReader) Close() error {
+	r.once.Do(func() {
+		contentType := r.res.Header.Get("Content-Type")
+		serverLog.DebugContext(r.req.Context(), "kintone response trace",
+			"method", r.req.Method,
+			"url", r.req.URL.Redacted(),
+			"status", r.res.StatusCode,
+			"headers", traceHeaders(r.res.Header),
+			"body", traceBody(contentType, r.head.Bytes(), r.size),
+			"durationMs", time.Since(r.start).Milliseconds(),
+		)
+	})
+	return r.ReadCloser.Close()
+}
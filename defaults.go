@@ -0,0 +1,92 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RecordDefaults is the default arguments of readRecords for an app, to avoid reading unnecessary fields or records.
+type RecordDefaults struct {
+	// Fields is the field codes to read if the caller doesn't specify fields.
+	Fields []string `json:"fields"`
+
+	// Query is a condition that is always combined with the condition of the caller by "and".
+	Query string `json:"query"`
+
+	// OrderBy is the sort order if the caller doesn't specify one, such as "updated_time desc".
+	OrderBy string `json:"orderBy"`
+
+	// Limit is the number of records to read if the caller doesn't specify one.
+	Limit int `json:"limit"`
+}
+
+// recordDefaults returns the default arguments of readRecords for the app.
+func (h *KintoneHandlers) recordDefaults(appID string) RecordDefaults {
+	app, _ := h.policy().Config.appConfiguration(appID)
+	return app.Defaults
+}
+
+// mergeQuery combines the default condition and sort order with the query of the caller.
+func (d RecordDefaults) mergeQuery(query string) string {
+	cond, rest := splitQuery(query)
+
+	if d.Query != "" {
+		if cond == "" {
+			cond = d.Query
+		} else {
+			cond = "(" + d.Query + ") and (" + cond + ")"
+		}
+	}
+	if d.OrderBy != "" && !orderByPattern.MatchString(rest) {
+		rest = strings.TrimSpace("order by " + d.OrderBy + " " + rest)
+	}
+
+	return strings.TrimSpace(cond + " " + rest)
+}
+
+var (
+	queryOptionPattern = regexp.MustCompile(`(?i)^(?:order\s+by|limit|offset)\b`)
+	orderByPattern     = regexp.MustCompile(`(?i)\border\s+by\b`)
+	queryLimitPattern  = regexp.MustCompile(`(?i)\blimit\s+(\d+)`)
+	queryOffsetPattern = regexp.MustCompile(`(?i)\boffset\s+(\d+)`)
+)
+
+// splitQuery splits the kintone query into the condition and the options such as "order by", "limit", and "offset".
+func splitQuery(query string) (cond, options string) {
+	inString, escaped := false, false
+	for i, r := range query {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			inString = true
+		case i == 0 || strings.ContainsRune(" \t\r\n)", rune(query[i-1])):
+			if queryOptionPattern.MatchString(query[i:]) {
+				return strings.TrimSpace(query[:i]), strings.TrimSpace(query[i:])
+			}
+		}
+	}
+	return strings.TrimSpace(query), ""
+}
+
+// takeQueryPaging removes "limit" and "offset" from the options of the query, and returns them, or -1 if they are not in the query.
+func takeQueryPaging(query string) (rest string, limit, offset int) {
+	cond, options := splitQuery(query)
+	limit, offset = -1, -1
+	if m := queryLimitPattern.FindStringSubmatch(options); m != nil {
+		limit, _ = strconv.Atoi(m[1])
+		options = queryLimitPattern.ReplaceAllString(options, "")
+	}
+	if m := queryOffsetPattern.FindStringSubmatch(options); m != nil {
+		offset, _ = strconv.Atoi(m[1])
+		options = queryOffsetPattern.ReplaceAllString(options, "")
+	}
+	return strings.TrimSpace(cond + " " + strings.TrimSpace(options)), limit, offset
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// directoryPageSize is the maximum number of entities in a page of the User API.
+const directoryPageSize = 100
+
+// entityKinds maps the field types whose values are users, organizations, or groups, to the kind of the entities in the User API.
+var entityKinds = map[string]string{
+	"USER_SELECT":         "users",
+	"STATUS_ASSIGNEE":     "users",
+	"CREATOR":             "users",
+	"MODIFIER":            "users",
+	"ORGANIZATION_SELECT": "organizations",
+	"GROUP_SELECT":        "groups",
+}
+
+// directory returns the display names of all users, organizations, or groups by their codes.
+// The User API of cybozu.com accepts only password authentication, so it fails with API tokens.
+func (h *KintoneHandlers) directory(ctx context.Context, kind string) (map[string]string, error) {
+	auth, err := h.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if auth.User == "" {
+		return nil, errors.New("the User API requires password authentication")
+	}
+
+	return cached(ctx, &h.cache, h.cacheScope(ctx)+"directory:"+kind, h.CacheTTL, func(ctx context.Context) (map[string]string, error) {
+		names := make(map[string]string)
+		for offset := 0; ; offset += directoryPageSize {
+			var res map[string][]struct {
+				Code string `json:"code"`
+				Name string `json:"name"`
+			}
+			query := Query{"offset": strconv.Itoa(offset), "size": strconv.Itoa(directoryPageSize)}
+			if err := h.FetchHTTPWithJSON(ctx, "GET", "/v1/"+kind+".json", query, nil, &res); err != nil {
+				return nil, err
+			}
+			for _, e := range res[kind] {
+				names[e.Code] = e.Name
+			}
+			if len(res[kind]) < directoryPageSize {
+				return names, nil
+			}
+		}
+	})
+}
+
+// directoryLookup fetches the directories on demand, so that records without entities don't send any requests.
+// The failures are logged and remembered, and the values are left as is.
+type directoryLookup struct {
+	h      *KintoneHandlers
+	ctx    context.Context
+	loaded map[string]map[string]string
+}
+
+func (h *KintoneHandlers) newDirectoryLookup(ctx context.Context) *directoryLookup {
+	return &directoryLookup{h: h, ctx: ctx, loaded: make(map[string]map[string]string)}
+}
+
+func (d *directoryLookup) names(kind string) map[string]string {
+	if names, ok := d.loaded[kind]; ok {
+		return names
+	}
+	names, err := d.h.directory(d.ctx, kind)
+	if err != nil {
+		serverLog.DebugContext(d.ctx, "Failed to fetch the directory of the User API", "kind", kind, "error", err)
+	}
+	d.loaded[kind] = names
+	return names
+}
+
+// resolveNames fills in the display names of users, organizations, and groups in the record that has only their codes.
+// The record has to be in the format of kintone.
+func (d *directoryLookup) resolveNames(record map[string]any) {
+	for _, v := range record {
+		field, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		fieldType, _ := field["type"].(string)
+		if fieldType == "SUBTABLE" {
+			rows, _ := field["value"].([]any)
+			for _, row := range rows {
+				row, _ := row.(map[string]any)
+				if cells, ok := row["value"].(map[string]any); ok {
+					d.resolveNames(cells)
+				}
+			}
+			continue
+		}
+
+		kind, ok := entityKinds[fieldType]
+		if !ok {
+			continue
+		}
+		entities, ok := field["value"].([]any)
+		if !ok {
+			// CREATOR and MODIFIER have a single entity.
+			entities = []any{field["value"]}
+		}
+		for _, e := range entities {
+			e, ok := e.(map[string]any)
+			if !ok {
+				continue
+			}
+			code, _ := e["code"].(string)
+			if name, _ := e["name"].(string); code == "" || name != "" {
+				continue
+			}
+			if name, ok := d.names(kind)[code]; ok {
+				e["name"] = name
+			}
+		}
+	}
+}
+
+// resolveCodes replaces the display names of users, organizations, and groups in the record to write with their codes.
+// The entity is resolved if it has only a name, or if its code is not found but a name is matched exactly one entity.
+// It returns the descriptions of the replacements, in the same way as coerceRecord.
+func (d *directoryLookup) resolveCodes(fields map[string]formField, record map[string]any, prefix string) []string {
+	var resolved []string
+	for _, code := range slices.Sorted(maps.Keys(record)) {
+		f, ok := fields[code]
+		if !ok {
+			continue
+		}
+		field, ok := record[code].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		path := prefix + code
+		if f.Type == "SUBTABLE" {
+			rows, _ := field["value"].([]any)
+			for i, row := range rows {
+				row, _ := row.(map[string]any)
+				if cells, ok := row["value"].(map[string]any); ok {
+					resolved = append(resolved, d.resolveCodes(f.Fields, cells, fmt.Sprintf("%s[%d].", path, i))...)
+				}
+			}
+			continue
+		}
+
+		kind, ok := entityKinds[f.Type]
+		if !ok {
+			continue
+		}
+		entities, _ := field["value"].([]any)
+		for _, e := range entities {
+			e, ok := e.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			given, _ := e["code"].(string)
+			if given == "" {
+				given, _ = e["name"].(string)
+			}
+			if given == "" {
+				continue
+			}
+
+			if c, ok := d.lookupCode(kind, given); ok && c != e["code"] {
+				e["code"] = c
+				delete(e, "name")
+				resolved = append(resolved, fmt.Sprintf("%s: resolved name %q to code %q", path, given, c))
+			}
+		}
+	}
+	return resolved
+}
+
+// lookupCode returns the code of the entity that has the given code, or the given display name if only one entity has it.
+func (d *directoryLookup) lookupCode(kind, given string) (string, bool) {
+	names := d.names(kind)
+	if _, ok := names[given]; ok {
+		return given, true
+	}
+
+	var matched []string
+	for c, name := range names {
+		if name == given {
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) != 1 {
+		return "", false
+	}
+	return matched[0], true
+}
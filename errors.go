@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// KintoneAPIError is an error that occurred while communicating with kintone server.
+//
+// In tools/call, this error is reported as a tool result with isError flag, so that the model can see the reason and retry.
+// In other methods, this error is reported as a JSON-RPC error.
+type KintoneAPIError struct {
+	Status     int            `json:"status,omitempty"`
+	StatusText string         `json:"-"`
+	Code       string         `json:"code,omitempty"`
+	ID         string         `json:"id,omitempty"`
+	Message    string         `json:"message"`
+	Errors     map[string]any `json:"-"`
+	Body       string         `json:"-"`
+
+	// FieldErrors is the errors of each field that kintone reported in "errors", such as validation errors of a record.
+	FieldErrors []KintoneFieldError `json:"fieldErrors,omitempty"`
+
+	// Hint is the guidance to solve the error, such as the tool to call to find the valid values.
+	Hint string `json:"hint,omitempty"`
+
+	// Retryable is true if the same request may succeed by retrying, such as a timeout.
+	Retryable bool `json:"retryable,omitempty"`
+
+	// RequestID is the ID of the tool call, that is also written in the server log and the audit log.
+	RequestID string `json:"requestID,omitempty"`
+
+	// TraceID is the OpenTelemetry trace ID of the tool call, to find the trace of the error.
+	TraceID string `json:"traceID,omitempty"`
+}
+
+// kintoneErrorHints is the guidance for the common error codes of kintone, so that the model can fix the request by itself.
+// The message from kintone tells what is wrong, such as the field code, so the hints only tell how to fix it.
+var kintoneErrorHints = map[string]string{
+	"CB_VA01":   "Some values in the request are invalid. The fieldErrors property shows the problem of each field. Fix only those fields and retry.",
+	"CB_IJ01":   "The request is not a valid JSON. Check the format of the parameters.",
+	"CB_NO02":   "The user doesn't have the permission for this operation in kintone. Don't retry, and ask the user to check the permission settings of the app.",
+	"CB_WA01":   "The password authentication failed. Ask the user to check the username and password settings of the server.",
+	"GAIA_AP01": "The app doesn't exist or has been deleted. Call listApps to find the valid app IDs.",
+	"GAIA_RE01": "The record doesn't exist or has been deleted. Call readRecords to find the valid record IDs.",
+	"GAIA_FC01": "The field code doesn't exist in the app. Call readAppInfo to list the valid field codes, and note that field codes are case-sensitive.",
+	"GAIA_IQ11": "The field code in the query doesn't exist in the app. Call readAppInfo to list the valid field codes, and note that field codes are case-sensitive.",
+	"GAIA_IQ03": "The query has a syntax error. Check the query syntax of kintone, such as quoting string values with double quotes.",
+	"GAIA_CO02": "The record has been updated by someone else since it was read. Read the record again and retry with the latest values.",
+	"GAIA_NO01": "The API token doesn't have the permission for this operation. Ask the user to add the permission to the API token, or use another tool.",
+	"GAIA_DA02": "kintone is busy processing another request for the same data. Wait a moment and retry.",
+}
+
+// newKintoneAPIError parses the error response body from kintone server.
+func newKintoneAPIError(status int, statusText string, body []byte) *KintoneAPIError {
+	e := &KintoneAPIError{
+		Status:     status,
+		StatusText: statusText,
+		Body:       string(body),
+	}
+
+	var parsed struct {
+		Code    string         `json:"code"`
+		ID      string         `json:"id"`
+		Message string         `json:"message"`
+		Errors  map[string]any `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Message != "" {
+		e.Code = parsed.Code
+		e.ID = parsed.ID
+		e.Message = parsed.Message
+		e.Errors = parsed.Errors
+		e.FieldErrors = parseFieldErrors(parsed.Errors)
+		e.Hint = kintoneErrorHints[parsed.Code]
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+		if e.Message == "" {
+			e.Message = statusText
+		}
+	}
+
+	return e
+}
+
+func (e *KintoneAPIError) Error() string {
+	if e.Status == 0 {
+		return e.Message
+	}
+
+	var msg string
+	switch {
+	case e.Code != "":
+		msg = fmt.Sprintf("kintone server returned an error: %s\n%s: %s", e.StatusText, e.Code, e.Message)
+	case e.Body != "":
+		msg = fmt.Sprintf("kintone server returned an error: %s\n%s", e.StatusText, e.Body)
+	default:
+		msg = fmt.Sprintf("kintone server returned an error: %s\n%s", e.StatusText, e.Message)
+	}
+	for _, f := range e.FieldErrors {
+		msg += fmt.Sprintf("\n- %s: %s", f.describe(), strings.Join(f.Messages, " "))
+	}
+	if e.Hint != "" {
+		msg += "\n" + e.Hint
+	}
+	return msg
+}
+
+// KintoneFieldError is an error of a field in the request.
+type KintoneFieldError struct {
+	// Path is the key of "errors" in the response of kintone, such as "record.name.value" or "records[1].table.value[0].value.price.value".
+	Path string `json:"path"`
+
+	// FieldCode is the code of the field. If the field is in a table, it is the code of the field in the table.
+	FieldCode string `json:"fieldCode,omitempty"`
+
+	// Table and Row are the code of the table and the index of the row, if the field is in a table.
+	Table string `json:"table,omitempty"`
+	Row   *int   `json:"row,omitempty"`
+
+	// Record is the index of the record in the request that has multiple records.
+	Record *int `json:"record,omitempty"`
+
+	Messages []string `json:"messages"`
+}
+
+// fieldErrorPath matches the keys of "errors" in kintone responses, such as "record.name.value", "records[1].name.value", or "record.table.value[0].value.price.value".
+var fieldErrorPath = regexp.MustCompile(`^(?:record|records\[(\d+)\])\.([^.\[\]]+)(?:\.value\[(\d+)\]\.value\.([^.\[\]]+))?`)
+
+// parseFieldErrors converts "errors" in a kintone response into the errors of each field.
+// The keys that are not fields, such as "app", are kept with only the path.
+func parseFieldErrors(errs map[string]any) []KintoneFieldError {
+	var result []KintoneFieldError
+	for _, path := range slices.Sorted(maps.Keys(errs)) {
+		f := KintoneFieldError{Path: path}
+
+		if detail, ok := errs[path].(map[string]any); ok {
+			messages, _ := detail["messages"].([]any)
+			for _, m := range messages {
+				if s, ok := m.(string); ok {
+					f.Messages = append(f.Messages, s)
+				}
+			}
+		}
+
+		if m := fieldErrorPath.FindStringSubmatch(path); m != nil {
+			f.FieldCode = m[2]
+			if m[1] != "" {
+				i, _ := strconv.Atoi(m[1])
+				f.Record = &i
+			}
+			if m[4] != "" {
+				row, _ := strconv.Atoi(m[3])
+				f.Table, f.Row, f.FieldCode = m[2], &row, m[4]
+			}
+		}
+
+		result = append(result, f)
+	}
+	return result
+}
+
+// describe returns the location of the error for humans, such as "price in row 0 of table".
+func (f KintoneFieldError) describe() string {
+	if f.FieldCode == "" {
+		return f.Path
+	}
+	s := f.FieldCode
+	if f.Table != "" {
+		s = fmt.Sprintf("%s in row %d of %s", s, *f.Row, f.Table)
+	}
+	if f.Record != nil {
+		s = fmt.Sprintf("%s of record %d", s, *f.Record)
+	}
+	return s
+}
+
+// RPCError converts the error into a JSON-RPC error.
+func (e *KintoneAPIError) RPCError() jsonrpc2.Error {
+	return jsonrpc2.Error{
+		Code:    jsonrpc2.InternalErrorCode,
+		Message: e.Error(),
+		Data:    e,
+	}
+}
+
+// As makes errors.As can convert KintoneAPIError into jsonrpc2.Error.
+func (e *KintoneAPIError) As(target any) bool {
+	if t, ok := target.(*jsonrpc2.Error); ok {
+		*t = e.RPCError()
+		return true
+	}
+	return false
+}
+
+// ToolResult converts the error into a tool result with isError flag.
+func (e *KintoneAPIError) ToolResult() ToolsCallResult {
+	content, err := JSONContent(JsonMap{"error": e})
+	if err != nil {
+		content = []Content{{Type: "text", Text: e.Error()}}
+	}
+	return ToolsCallResult{
+		Content: content,
+		IsError: true,
+	}
+}
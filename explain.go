@@ -0,0 +1,673 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+type queryTokenKind int
+
+const (
+	queryWord queryTokenKind = iota
+	queryString
+	queryOperator
+	queryOpen
+	queryClose
+	queryComma
+)
+
+type queryToken struct {
+	Kind queryTokenKind
+	Text string
+}
+
+// lexQuery splits the kintone query into tokens. Unlike queryTokens, it keeps the string literals and the operators.
+func lexQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	rs := []rune(query)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case strings.ContainsRune(" \t\r\n", r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{queryOpen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{queryClose, ")"})
+			i++
+		case r == ',':
+			tokens = append(tokens, queryToken{queryComma, ","})
+			i++
+		case r == '"':
+			var buf strings.Builder
+			buf.WriteRune(r)
+			closed := false
+			for i++; i < len(rs); i++ {
+				buf.WriteRune(rs[i])
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+					buf.WriteRune(rs[i])
+				} else if rs[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+			}
+			if !closed {
+				return nil, errors.New("the string literal is not closed")
+			}
+			tokens = append(tokens, queryToken{queryString, buf.String()})
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(rs) && rs[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, errors.New("unexpected '!'")
+			}
+			tokens = append(tokens, queryToken{queryOperator, op})
+			i += len(op)
+		default:
+			start := i
+			for i < len(rs) && !strings.ContainsRune(" \t\r\n(),\"=!<>", rs[i]) {
+				i++
+			}
+			tokens = append(tokens, queryToken{queryWord, string(rs[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+// queryCondition is a condition in a kintone query, such as `status in ("Open")`.
+type queryCondition struct {
+	Field    string
+	Operator string
+	Values   []string
+}
+
+// querySort is a key of "order by" in a kintone query.
+type querySort struct {
+	Field string
+	Order string
+}
+
+// parsedQuery is a kintone query that is parsed by queryParser.
+type parsedQuery struct {
+	Conditions []queryCondition
+
+	// Logic is the structure of the condition that refers the conditions by their numbers, such as "#1 and (#2 or #3)".
+	Logic string
+
+	// MixedLogic is true if "and" and "or" are used at the same level without parentheses.
+	MixedLogic bool
+
+	Sort   []querySort
+	Limit  string
+	Offset string
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	query  parsedQuery
+}
+
+func parseQuery(query string) (parsedQuery, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return parsedQuery{}, err
+	}
+	p := &queryParser{tokens: tokens}
+	if err := p.parse(); err != nil {
+		return parsedQuery{}, err
+	}
+	return p.query, nil
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// peekWord reports whether the next token is the keyword, ignoring the case.
+func (p *queryParser) peekWord(word string) bool {
+	t, ok := p.peek()
+	return ok && t.Kind == queryWord && strings.EqualFold(t.Text, word)
+}
+
+func (p *queryParser) expectWord(word string) error {
+	if !p.peekWord(word) {
+		return p.unexpected(fmt.Sprintf("'%s'", word))
+	}
+	p.pos++
+	return nil
+}
+
+func (p *queryParser) unexpected(expected string) error {
+	t, ok := p.peek()
+	if !ok {
+		return fmt.Errorf("expected %s but the query ended", expected)
+	}
+	return fmt.Errorf("expected %s but found '%s'", expected, t.Text)
+}
+
+func (p *queryParser) parse() error {
+	if _, ok := p.peek(); ok && !p.peekOption() {
+		logic, err := p.parseExpr()
+		if err != nil {
+			return err
+		}
+		p.query.Logic = logic
+	}
+
+	for {
+		switch {
+		case p.peekWord("order"):
+			p.pos++
+			if err := p.expectWord("by"); err != nil {
+				return err
+			}
+			for {
+				t, ok := p.peek()
+				if !ok || t.Kind != queryWord {
+					return p.unexpected("a field code")
+				}
+				p.pos++
+				s := querySort{Field: t.Text, Order: "asc"}
+				if p.peekWord("asc") || p.peekWord("desc") {
+					s.Order = strings.ToLower(p.tokens[p.pos].Text)
+					p.pos++
+				}
+				p.query.Sort = append(p.query.Sort, s)
+				if t, ok := p.peek(); !ok || t.Kind != queryComma {
+					break
+				}
+				p.pos++
+			}
+		case p.peekWord("limit") || p.peekWord("offset"):
+			key := strings.ToLower(p.tokens[p.pos].Text)
+			p.pos++
+			t, ok := p.peek()
+			if !ok || t.Kind != queryWord {
+				return p.unexpected("a number")
+			}
+			p.pos++
+			if key == "limit" {
+				p.query.Limit = t.Text
+			} else {
+				p.query.Offset = t.Text
+			}
+		default:
+			if _, ok := p.peek(); ok {
+				return p.unexpected("'and', 'or', 'order by', 'limit', or 'offset'")
+			}
+			return nil
+		}
+	}
+}
+
+func (p *queryParser) peekOption() bool {
+	return p.peekWord("order") || p.peekWord("limit") || p.peekWord("offset")
+}
+
+func (p *queryParser) parseExpr() (string, error) {
+	logic, err := p.parseTerm()
+	if err != nil {
+		return "", err
+	}
+
+	var op string
+	for p.peekWord("and") || p.peekWord("or") {
+		next := strings.ToLower(p.tokens[p.pos].Text)
+		if op != "" && op != next {
+			p.query.MixedLogic = true
+		}
+		op = next
+		p.pos++
+
+		right, err := p.parseTerm()
+		if err != nil {
+			return "", err
+		}
+		logic += " " + op + " " + right
+	}
+	return logic, nil
+}
+
+func (p *queryParser) parseTerm() (string, error) {
+	t, ok := p.peek()
+	if ok && t.Kind == queryOpen {
+		p.pos++
+		logic, err := p.parseExpr()
+		if err != nil {
+			return "", err
+		}
+		if t, ok := p.peek(); !ok || t.Kind != queryClose {
+			return "", p.unexpected("')'")
+		}
+		p.pos++
+		return "(" + logic + ")", nil
+	}
+
+	if !ok || t.Kind != queryWord {
+		return "", p.unexpected("a field code")
+	}
+	p.pos++
+	cond := queryCondition{Field: t.Text}
+
+	switch {
+	case p.peekWord("in"):
+		p.pos++
+		cond.Operator = "in"
+	case p.peekWord("like"):
+		p.pos++
+		cond.Operator = "like"
+	case p.peekWord("not"):
+		p.pos++
+		if !p.peekWord("in") && !p.peekWord("like") {
+			return "", p.unexpected("'in' or 'like'")
+		}
+		cond.Operator = "not " + strings.ToLower(p.tokens[p.pos].Text)
+		p.pos++
+	case p.peekWord("is"):
+		p.pos++
+		cond.Operator = "is empty"
+		if p.peekWord("not") {
+			p.pos++
+			cond.Operator = "is not empty"
+		}
+		if err := p.expectWord("empty"); err != nil {
+			return "", err
+		}
+	default:
+		t, ok := p.peek()
+		if !ok || t.Kind != queryOperator {
+			return "", p.unexpected("an operator")
+		}
+		p.pos++
+		cond.Operator = t.Text
+	}
+
+	switch cond.Operator {
+	case "is empty", "is not empty":
+	case "in", "not in":
+		if t, ok := p.peek(); !ok || t.Kind != queryOpen {
+			return "", p.unexpected("'('")
+		}
+		p.pos++
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return "", err
+			}
+			cond.Values = append(cond.Values, v)
+			t, ok := p.peek()
+			if ok && t.Kind == queryComma {
+				p.pos++
+				continue
+			}
+			if !ok || t.Kind != queryClose {
+				return "", p.unexpected("',' or ')'")
+			}
+			p.pos++
+			break
+		}
+	default:
+		v, err := p.parseValue()
+		if err != nil {
+			return "", err
+		}
+		cond.Values = []string{v}
+	}
+
+	p.query.Conditions = append(p.query.Conditions, cond)
+	return fmt.Sprintf("#%d", len(p.query.Conditions)), nil
+}
+
+// parseValue reads a string literal, a number, or a function call such as FROM_TODAY(-7, DAYS).
+func (p *queryParser) parseValue() (string, error) {
+	t, ok := p.peek()
+	if !ok || (t.Kind != queryString && t.Kind != queryWord) {
+		return "", p.unexpected("a value")
+	}
+	p.pos++
+	if t.Kind == queryString {
+		return t.Text, nil
+	}
+
+	if next, ok := p.peek(); !ok || next.Kind != queryOpen {
+		return t.Text, nil
+	}
+	p.pos++
+	var args []string
+	for {
+		next, ok := p.peek()
+		if !ok {
+			return "", p.unexpected("')'")
+		}
+		p.pos++
+		if next.Kind == queryClose {
+			break
+		}
+		if next.Kind != queryComma {
+			args = append(args, next.Text)
+		}
+	}
+	return t.Text + "(" + strings.Join(args, ", ") + ")", nil
+}
+
+var (
+	comparisonOperators = []string{"=", "!=", ">", "<", ">=", "<="}
+	listOperators       = []string{"in", "not in"}
+	likeOperators       = []string{"like", "not like"}
+	emptyOperators      = []string{"is empty", "is not empty"}
+)
+
+// queryOperators is the operators that kintone accepts for each field type.
+var queryOperators = map[string][]string{
+	"__ID__":              slices.Concat(comparisonOperators, listOperators),
+	"__REVISION__":        slices.Concat(comparisonOperators, listOperators),
+	"RECORD_NUMBER":       slices.Concat(comparisonOperators, listOperators),
+	"NUMBER":              slices.Concat(comparisonOperators, listOperators, emptyOperators),
+	"CALC":                slices.Concat(comparisonOperators, listOperators),
+	"SINGLE_LINE_TEXT":    slices.Concat([]string{"=", "!="}, listOperators, likeOperators, emptyOperators),
+	"LINK":                slices.Concat([]string{"=", "!="}, listOperators, likeOperators, emptyOperators),
+	"MULTI_LINE_TEXT":     slices.Concat(likeOperators, emptyOperators),
+	"RICH_TEXT":           likeOperators,
+	"FILE":                slices.Concat(likeOperators, emptyOperators),
+	"CHECK_BOX":           slices.Concat(listOperators, emptyOperators),
+	"RADIO_BUTTON":        listOperators,
+	"DROP_DOWN":           slices.Concat(listOperators, emptyOperators),
+	"MULTI_SELECT":        slices.Concat(listOperators, emptyOperators),
+	"USER_SELECT":         slices.Concat(listOperators, emptyOperators),
+	"ORGANIZATION_SELECT": slices.Concat(listOperators, emptyOperators),
+	"GROUP_SELECT":        slices.Concat(listOperators, emptyOperators),
+	"STATUS_ASSIGNEE":     slices.Concat(listOperators, emptyOperators),
+	"CREATOR":             listOperators,
+	"MODIFIER":            listOperators,
+	"STATUS":              slices.Concat([]string{"=", "!="}, listOperators),
+	"CATEGORY":            listOperators,
+	"DATE":                slices.Concat(comparisonOperators, emptyOperators),
+	"TIME":                slices.Concat(comparisonOperators, emptyOperators),
+	"DATETIME":            slices.Concat(comparisonOperators, emptyOperators),
+	"CREATED_TIME":        comparisonOperators,
+	"UPDATED_TIME":        comparisonOperators,
+}
+
+// queryOperatorWords is the readable descriptions of the operators.
+var queryOperatorWords = map[string]string{
+	"=":            "is",
+	"!=":           "is not",
+	">":            "is greater than",
+	"<":            "is less than",
+	">=":           "is greater than or equal to",
+	"<=":           "is less than or equal to",
+	"in":           "is one of",
+	"not in":       "is none of",
+	"like":         "contains",
+	"not like":     "does not contain",
+	"is empty":     "is empty",
+	"is not empty": "is not empty",
+}
+
+// queryTimeOperatorWords is the descriptions of the operators for the date and time fields.
+var queryTimeOperatorWords = map[string]string{
+	">":  "is after",
+	"<":  "is before",
+	">=": "is on or after",
+	"<=": "is on or before",
+}
+
+// queryFunctions is the functions that kintone accepts in queries.
+var queryFunctions = []string{
+	"LOGINUSER", "PRIMARY_ORGANIZATION", "NOW", "TODAY", "YESTERDAY", "TOMORROW", "FROM_TODAY",
+	"THIS_WEEK", "LAST_WEEK", "NEXT_WEEK", "THIS_MONTH", "LAST_MONTH", "NEXT_MONTH", "THIS_YEAR", "LAST_YEAR", "NEXT_YEAR",
+}
+
+// unsortableFieldTypes is the field types that can't be used in "order by".
+var unsortableFieldTypes = map[string]bool{
+	"CHECK_BOX":           true,
+	"MULTI_SELECT":        true,
+	"USER_SELECT":         true,
+	"ORGANIZATION_SELECT": true,
+	"GROUP_SELECT":        true,
+	"STATUS_ASSIGNEE":     true,
+	"FILE":                true,
+	"RICH_TEXT":           true,
+	"CATEGORY":            true,
+	"SUBTABLE":            true,
+}
+
+var queryDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// checkQueryValue returns a warning if the value doesn't fit the field, or an empty string.
+func checkQueryValue(f formField, op, value string) string {
+	if strings.HasSuffix(value, ")") {
+		name, _, _ := strings.Cut(value, "(")
+		if !slices.Contains(queryFunctions, strings.ToUpper(name)) {
+			return fmt.Sprintf("%s is not a function of kintone.", name)
+		}
+		return ""
+	}
+
+	if !strings.HasPrefix(value, "\"") {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("%s is not quoted. String values have to be enclosed in double quotes.", value)
+		}
+	}
+	s, err := strconv.Unquote(value)
+	if err != nil {
+		s = value
+	}
+
+	switch f.Type {
+	case "NUMBER", "CALC", "__ID__", "__REVISION__":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Sprintf("%s is not a number, but %s is a %s field.", value, f.Code, f.Type)
+		}
+	case "DATE":
+		if !queryDatePattern.MatchString(s) {
+			return fmt.Sprintf("%s is not a date such as \"2025-01-31\", but %s is a %s field.", value, f.Code, f.Type)
+		}
+	case "DATETIME", "CREATED_TIME", "UPDATED_TIME":
+		if _, err := time.Parse(time.RFC3339, s); err != nil && !queryDatePattern.MatchString(s) {
+			return fmt.Sprintf("%s is not a datetime such as \"2025-01-31T09:00:00Z\", but %s is a %s field.", value, f.Code, f.Type)
+		}
+	case "CHECK_BOX", "RADIO_BUTTON", "DROP_DOWN", "MULTI_SELECT":
+		if _, ok := f.Options[s]; !ok && len(f.Options) > 0 && (op == "in" || op == "not in") && s != "" {
+			return fmt.Sprintf("%s is not an option of %s.", value, f.Code)
+		}
+	}
+	return ""
+}
+
+type ExplainQueryParams struct {
+	AppID string `json:"appID" required:"true" description:"The app ID that the query is for."`
+	Query string `json:"query" required:"true" description:"The query to explain. Query format is the same as kintone's query format."`
+}
+
+func (h *KintoneHandlers) ExplainQuery(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req ExplainQueryParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required",
+		}
+	}
+
+	if err := h.checkPermissions(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+
+	defaults := h.recordDefaults(req.AppID)
+	query := defaults.mergeQuery(req.Query)
+	parsed, err := parseQuery(query)
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Failed to parse the query: %v", err),
+			Data:    JsonMap{"query": query},
+		}
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	conditions := make([]JsonMap, len(parsed.Conditions))
+	descriptions := make([]string, len(parsed.Conditions))
+	for i, c := range parsed.Conditions {
+		cond := JsonMap{
+			"number":   i + 1,
+			"field":    c.Field,
+			"operator": c.Operator,
+		}
+		if len(c.Values) > 0 {
+			cond["values"] = c.Values
+		}
+
+		name := c.Field
+		words := queryOperatorWords[c.Operator]
+		f, table, ok := lookupField(fields, c.Field)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("#%d: Field %s is not found in the app.", i+1, c.Field))
+		} else {
+			cond["label"] = f.Label
+			cond["type"] = f.Type
+			if f.Label != "" {
+				name = fmt.Sprintf("%s (%s)", f.Label, c.Field)
+			}
+			if table != "" {
+				cond["table"] = table
+				name += " in any row of " + table
+			}
+			if dateFieldTypes[f.Type] || f.Type == "TIME" {
+				if w, ok := queryTimeOperatorWords[c.Operator]; ok {
+					words = w
+				}
+			}
+
+			if ops, ok := queryOperators[f.Type]; ok && !slices.Contains(ops, c.Operator) {
+				warnings = append(warnings, fmt.Sprintf("#%d: Operator '%s' can't be used for %s, because it is a %s field. The operators for this field are: %s.", i+1, c.Operator, c.Field, f.Type, strings.Join(ops, ", ")))
+			}
+			if table != "" && (c.Operator == "=" || c.Operator == "!=") {
+				warnings = append(warnings, fmt.Sprintf("#%d: Operator '%s' can't be used for %s, because it is in a table. Use 'in' or 'not in' instead.", i+1, c.Operator, c.Field))
+			}
+			for _, v := range c.Values {
+				if w := checkQueryValue(f, c.Operator, v); w != "" {
+					warnings = append(warnings, fmt.Sprintf("#%d: %s", i+1, w))
+				}
+			}
+		}
+
+		desc := name + " " + words
+		if len(c.Values) > 0 {
+			desc += " " + strings.Join(c.Values, ", ")
+		}
+		cond["description"] = desc
+		conditions[i] = cond
+		descriptions[i] = fmt.Sprintf("#%d: %s", i+1, desc)
+	}
+	if parsed.MixedLogic {
+		warnings = append(warnings, "'and' and 'or' are used together without parentheses. Add parentheses to make the intended order explicit.")
+	}
+
+	sort := make([]JsonMap, 0, len(parsed.Sort))
+	var sortDescs []string
+	for _, s := range parsed.Sort {
+		entry := JsonMap{"field": s.Field, "order": s.Order}
+		if f, table, ok := lookupField(fields, s.Field); !ok {
+			warnings = append(warnings, fmt.Sprintf("Field %s in 'order by' is not found in the app.", s.Field))
+		} else {
+			entry["label"] = f.Label
+			entry["type"] = f.Type
+			if table != "" || unsortableFieldTypes[f.Type] {
+				warnings = append(warnings, fmt.Sprintf("Field %s can't be used in 'order by', because it is a %s field.", s.Field, f.Type))
+			}
+		}
+		sort = append(sort, entry)
+		sortDescs = append(sortDescs, fmt.Sprintf("%s %s", s.Field, map[string]string{"asc": "ascending", "desc": "descending"}[s.Order]))
+	}
+	if len(parsed.Sort) == 0 {
+		sortDescs = []string{"$id descending (the default of kintone)"}
+	}
+
+	if n, err := strconv.Atoi(parsed.Limit); parsed.Limit != "" && (err != nil || n < 1 || n > 500) {
+		warnings = append(warnings, "'limit' must be between 1 and 500.")
+	}
+	if n, err := strconv.Atoi(parsed.Offset); parsed.Offset != "" && (err != nil || n < 0 || n > 10000) {
+		warnings = append(warnings, "'offset' must be between 0 and 10000.")
+	}
+
+	var explanation strings.Builder
+	if parsed.Logic == "" {
+		explanation.WriteString("All records.")
+	} else {
+		fmt.Fprintf(&explanation, "Records that match %s, where:\n%s", parsed.Logic, strings.Join(descriptions, "\n"))
+	}
+	fmt.Fprintf(&explanation, "\nSorted by %s.", strings.Join(sortDescs, ", "))
+
+	result := JsonMap{
+		"appID":       req.AppID,
+		"query":       query,
+		"conditions":  conditions,
+		"sort":        sort,
+		"explanation": explanation.String(),
+	}
+	if parsed.Logic != "" {
+		result["logic"] = parsed.Logic
+	}
+	if parsed.Limit != "" {
+		result["limit"] = parsed.Limit
+	}
+	if parsed.Offset != "" {
+		result["offset"] = parsed.Offset
+	}
+	if query != strings.TrimSpace(req.Query) {
+		result["note"] = "The query is combined with the default condition or sort order set by the server for the app."
+	}
+
+	// Ask kintone the number of the matched records, which also validates the query.
+	cond, _ := splitQuery(query)
+	count, err := h.fetchRecords(ctx, "GET", "/k/v1/records.json", nil, JsonMap{
+		"app":        req.AppID,
+		"query":      strings.TrimSpace(cond + " limit 1"),
+		"fields":     []string{"$id"},
+		"totalCount": true,
+	}, func(record map[string]any) error { return nil })
+	var apiErr *KintoneAPIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest {
+		warnings = append(warnings, "kintone rejected the query: "+apiErr.Message)
+	} else if err != nil {
+		return nil, err
+	} else if total, err := strconv.Atoi(fmt.Sprint(count["totalCount"])); err == nil {
+		result["matchedRecords"] = total
+	}
+
+	if warnings == nil {
+		warnings = []string{}
+	}
+	result["warnings"] = warnings
+	return JSONContent(result)
+}
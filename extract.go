@@ -0,0 +1,280 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var errUnsupportedFormat = errors.New("unsupported file format")
+
+// detectDocumentFormat guesses the document format from the content type and the file name.
+// It returns an empty string if the format is not supported for text extraction.
+func detectDocumentFormat(contentType, fileName string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	switch {
+	case mediaType == "application/pdf" || ext == ".pdf":
+		return "pdf"
+	case mediaType == "application/vnd.openxmlformats-officedocument.wordprocessingml.document" || ext == ".docx":
+		return "docx"
+	case mediaType == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" || ext == ".xlsx":
+		return "xlsx"
+	case mediaType == "application/vnd.openxmlformats-officedocument.presentationml.presentation" || ext == ".pptx":
+		return "pptx"
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		slices.Contains([]string{".txt", ".csv", ".tsv", ".md", ".json", ".xml", ".log", ".html", ".htm"}, ext):
+		return "text"
+	}
+
+	return ""
+}
+
+// extractText extracts plain text from the document data.
+func extractText(data []byte, format string) (string, error) {
+	switch format {
+	case "pdf":
+		return extractPDFText(data)
+	case "docx":
+		return extractOfficeText(data, func(name string) bool {
+			return name == "word/document.xml"
+		})
+	case "pptx":
+		return extractOfficeText(data, func(name string) bool {
+			return strings.HasPrefix(name, "ppt/slides/slide") && strings.HasSuffix(name, ".xml")
+		})
+	case "xlsx":
+		return extractSpreadsheetText(data)
+	case "text":
+		if !utf8.Valid(data) {
+			return strings.ToValidUTF8(string(data), "�"), nil
+		}
+		return string(data), nil
+	}
+	return "", errUnsupportedFormat
+}
+
+func extractPDFText(data []byte) (text string, err error) {
+	// The PDF parser panics on some malformed files, so convert it to an error.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse PDF: %w", err)
+	}
+
+	tr, err := r.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text from PDF: %w", err)
+	}
+
+	bs, err := io.ReadAll(tr)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text from PDF: %w", err)
+	}
+	return string(bs), nil
+}
+
+// readZipEntries returns the contents of the entries in the zip archive that match the filter, sorted by natural order of the name.
+func readZipEntries(data []byte, filter func(name string) bool) ([]string, map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open document: %w", err)
+	}
+
+	var names []string
+	contents := make(map[string][]byte)
+	for _, f := range zr.File {
+		if !filter(f.Name) {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read document: %w", err)
+		}
+		bs, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read document: %w", err)
+		}
+		names = append(names, f.Name)
+		contents[f.Name] = bs
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		ni, nj := trailingNumber(names[i]), trailingNumber(names[j])
+		if ni != nj {
+			return ni < nj
+		}
+		return names[i] < names[j]
+	})
+
+	return names, contents, nil
+}
+
+// trailingNumber returns the number at the end of the base name, such as 12 for "slide12.xml".
+func trailingNumber(name string) int {
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	i := len(base)
+	for i > 0 && base[i-1] >= '0' && base[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(base[i:])
+	return n
+}
+
+// extractOfficeText extracts text from Word or PowerPoint documents.
+func extractOfficeText(data []byte, filter func(name string) bool) (string, error) {
+	names, contents, err := readZipEntries(data, filter)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", errors.New("failed to read document: no content found")
+	}
+
+	var buf strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if err := extractXMLText(&buf, contents[name]); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// extractXMLText writes text elements in the OOXML document to buf.
+func extractXMLText(buf *strings.Builder, data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to parse document: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				buf.WriteString("\t")
+			case "br", "cr":
+				buf.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				buf.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				buf.Write(t)
+			}
+		}
+	}
+}
+
+// extractSpreadsheetText extracts cell values from Excel workbooks as tab separated text.
+func extractSpreadsheetText(data []byte) (string, error) {
+	names, contents, err := readZipEntries(data, func(name string) bool {
+		return name == "xl/sharedStrings.xml" || (strings.HasPrefix(name, "xl/worksheets/sheet") && strings.HasSuffix(name, ".xml"))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var shared []string
+	if bs, ok := contents["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []struct {
+				Text string `xml:"t"`
+				Runs []struct {
+					Text string `xml:"t"`
+				} `xml:"r"`
+			} `xml:"si"`
+		}
+		if err := xml.Unmarshal(bs, &sst); err != nil {
+			return "", fmt.Errorf("failed to parse document: %w", err)
+		}
+		for _, si := range sst.Items {
+			s := si.Text
+			for _, r := range si.Runs {
+				s += r.Text
+			}
+			shared = append(shared, s)
+		}
+	}
+
+	var buf strings.Builder
+	sheet := 0
+	for _, name := range names {
+		if name == "xl/sharedStrings.xml" {
+			continue
+		}
+		sheet++
+
+		var ws struct {
+			Rows []struct {
+				Cells []struct {
+					Type   string `xml:"t,attr"`
+					Value  string `xml:"v"`
+					Inline struct {
+						Text string `xml:"t"`
+					} `xml:"is"`
+				} `xml:"c"`
+			} `xml:"sheetData>row"`
+		}
+		if err := xml.Unmarshal(contents[name], &ws); err != nil {
+			return "", fmt.Errorf("failed to parse document: %w", err)
+		}
+
+		fmt.Fprintf(&buf, "# Sheet %d\n", sheet)
+		for _, row := range ws.Rows {
+			values := make([]string, 0, len(row.Cells))
+			for _, c := range row.Cells {
+				switch c.Type {
+				case "s":
+					if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(shared) {
+						values = append(values, shared[i])
+					} else {
+						values = append(values, "")
+					}
+				case "inlineStr":
+					values = append(values, c.Inline.Text)
+				default:
+					values = append(values, c.Value)
+				}
+			}
+			buf.WriteString(strings.Join(values, "\t"))
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// fieldRight is the permissions of a field in the response of /k/v1/field/acl.json.
+// The entities are in the order of priority, and the first entity that matches the user decides the permission.
+type fieldRight struct {
+	Code     string           `json:"code"`
+	Entities []fieldACLEntity `json:"entities"`
+}
+
+type fieldACLEntity struct {
+	Accessibility string `json:"accessibility"`
+	Entity        struct {
+		Type string `json:"type"`
+		Code string `json:"code"`
+	} `json:"entity"`
+	IncludeSubs bool `json:"includeSubs"`
+}
+
+// fieldACL hides the fields that the user can't view by the field permissions of kintone.
+// kintone doesn't apply the field permissions to API tokens, so this is needed to share an API token by users with different roles.
+type fieldACL struct {
+	user   string
+	rights []fieldRight
+
+	// loaded is the codes of the groups and the organizations of the user, that are fetched before reading the records.
+	// A nil map means that the User API failed or the memberships are not needed by the permissions.
+	loaded map[string]map[string]bool
+}
+
+// fieldACL returns the field permissions of the app for the user of the request.
+// It returns nil if KINTONE_FIELD_ACL is disabled, or if kintone applies the permissions by itself because of password authentication.
+func (h *KintoneHandlers) fieldACL(ctx context.Context, appID string) (*fieldACL, error) {
+	if !h.FieldACL {
+		return nil, nil
+	}
+	auth, err := h.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Token == "" {
+		return nil, nil
+	}
+
+	user := auth.User
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		if s.user != "" {
+			user = s.user
+		}
+		s.mu.Unlock()
+	}
+	if user == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidRequestCode,
+			Message: "The records can't be read because the user to apply the field permissions is unknown. Please bind the bearer token to the user in KINTONE_MCP_AUTH_TOKENS, or provide the username and the password of the user.",
+		}
+	}
+
+	var res struct {
+		Rights []fieldRight `json:"rights"`
+	}
+	if err := h.fetchAppSchema(ctx, "/k/v1/field/acl.json", appID, &res); err != nil {
+		return nil, err
+	}
+
+	// The memberships are fetched here, because filterRecord is called while reading the response of the records.
+	// A request in it would wait for a slot of KINTONE_MAX_CONCURRENT_REQUESTS that the response is holding.
+	acl := &fieldACL{user: user, rights: res.Rights, loaded: make(map[string]map[string]bool)}
+	for _, kind := range acl.membershipKinds() {
+		codes, err := h.userMemberships(ctx, user, kind)
+		if err != nil {
+			serverLog.DebugContext(ctx, "Failed to fetch the memberships of the user by the User API", "user", user, "kind", kind, "error", err)
+		}
+		acl.loaded[kind] = codes
+	}
+	return acl, nil
+}
+
+// membershipKinds returns the kinds of the memberships, "groups" or "organizations", that are needed to evaluate the permissions.
+// The entities in the fields of the records can be both kinds, because the types of the fields are unknown until the records are read.
+func (a *fieldACL) membershipKinds() []string {
+	var groups, orgs bool
+	for _, r := range a.rights {
+		for _, e := range r.Entities {
+			switch e.Entity.Type {
+			case "GROUP":
+				groups = groups || e.Entity.Code != "everyone"
+			case "ORGANIZATION":
+				orgs = true
+			case "FIELD_ENTITY":
+				groups, orgs = true, true
+			}
+		}
+	}
+
+	var kinds []string
+	if groups {
+		kinds = append(kinds, "groups")
+	}
+	if orgs {
+		kinds = append(kinds, "organizations")
+	}
+	return kinds
+}
+
+// filterRecord removes the fields that the user can't view from the record, including fields in tables.
+// It has to be called before the other filters, because the permissions may depend on the values of the record.
+func (a *fieldACL) filterRecord(record map[string]any) {
+	if a == nil {
+		return
+	}
+
+	var hidden []string
+	for _, r := range a.rights {
+		if !a.readable(r, record) {
+			hidden = append(hidden, r.Code)
+		}
+	}
+
+	for _, code := range hidden {
+		if _, ok := record[code]; ok {
+			delete(record, code)
+			continue
+		}
+		for _, v := range record {
+			field, ok := v.(map[string]any)
+			if !ok || field["type"] != "SUBTABLE" {
+				continue
+			}
+			rows, _ := field["value"].([]any)
+			for _, row := range rows {
+				row, _ := row.(map[string]any)
+				if cells, ok := row["value"].(map[string]any); ok {
+					delete(cells, code)
+				}
+			}
+		}
+	}
+}
+
+// filterRawRecord is filterRecord for the record in JSON, for the callers that decode the records into structs.
+func (a *fieldACL) filterRawRecord(raw json.RawMessage) (json.RawMessage, error) {
+	if a == nil {
+		return raw, nil
+	}
+	var record map[string]any
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, err
+	}
+	a.filterRecord(record)
+	return json.Marshal(record)
+}
+
+// checkQuery checks that the query doesn't refer the fields that the user can't view, because the condition or the order could leak the values.
+// The fields whose permissions depend on the records, such as the creator, are also rejected.
+func (a *fieldACL) checkQuery(appID, query string) error {
+	if a == nil {
+		return nil
+	}
+
+	tokens := queryTokens(query)
+	for _, r := range a.rights {
+		if slices.Contains(tokens, r.Code) && !a.readable(r, nil) {
+			return jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Field %s in app ID %s is inaccessible because of the field permissions of kintone.", r.Code, appID),
+			}
+		}
+	}
+	return nil
+}
+
+// readable reports whether the user can view the field in the record.
+// The permissions that can't be decided, such as the groups without the User API, are treated as no access.
+func (a *fieldACL) readable(r fieldRight, record map[string]any) bool {
+	for _, e := range r.Entities {
+		matched, known := a.matches(e, record)
+		if !known {
+			return false
+		}
+		if matched {
+			return e.Accessibility != "NONE"
+		}
+	}
+	return false
+}
+
+// matches reports whether the entity of the permission includes the user.
+// known is false if it can't be decided.
+func (a *fieldACL) matches(e fieldACLEntity, record map[string]any) (matched, known bool) {
+	switch e.Entity.Type {
+	case "USER":
+		return e.Entity.Code == a.user, true
+	case "GROUP":
+		if e.Entity.Code == "everyone" {
+			return true, true
+		}
+		groups := a.memberships("groups")
+		return groups[e.Entity.Code], groups != nil
+	case "ORGANIZATION":
+		orgs := a.memberships("organizations")
+		if orgs == nil {
+			return false, false
+		}
+		// The members of the child organizations can't be decided, because the User API doesn't tell the parents of the user's organizations.
+		return orgs[e.Entity.Code], orgs[e.Entity.Code] || !e.IncludeSubs
+	case "CREATOR":
+		for _, v := range record {
+			if field, ok := v.(map[string]any); ok && field["type"] == "CREATOR" {
+				return a.includes(field, e.IncludeSubs)
+			}
+		}
+		return false, false
+	case "FIELD_ENTITY":
+		field, ok := record[e.Entity.Code].(map[string]any)
+		if !ok {
+			return false, false
+		}
+		return a.includes(field, e.IncludeSubs)
+	default:
+		return false, false
+	}
+}
+
+// includes reports whether the value of the user, organization, or group field includes the user.
+func (a *fieldACL) includes(field map[string]any, includeSubs bool) (matched, known bool) {
+	fieldType, _ := field["type"].(string)
+	kind, ok := entityKinds[fieldType]
+	if !ok {
+		return false, false
+	}
+
+	entities, ok := field["value"].([]any)
+	if !ok {
+		// CREATOR and MODIFIER have a single entity.
+		entities = []any{field["value"]}
+	}
+
+	known = true
+	for _, e := range entities {
+		e, _ := e.(map[string]any)
+		code, _ := e["code"].(string)
+		if code == "" {
+			continue
+		}
+		var m, k bool
+		switch kind {
+		case "users":
+			m, k = code == a.user, true
+		case "groups":
+			groups := a.memberships("groups")
+			m, k = groups[code], groups != nil
+		case "organizations":
+			orgs := a.memberships("organizations")
+			m, k = orgs[code], orgs != nil && (orgs[code] || !includeSubs)
+		}
+		if m {
+			return true, true
+		}
+		known = known && k
+	}
+	return false, known
+}
+
+// memberships returns the codes of the groups or the organizations that the user belongs to, or nil if the User API failed.
+// The User API of cybozu.com accepts only password authentication, so it fails if the server has only API tokens.
+func (a *fieldACL) memberships(kind string) map[string]bool {
+	return a.loaded[kind]
+}
+
+// userMemberships fetches the codes of the groups or the organizations that the user belongs to.
+func (h *KintoneHandlers) userMemberships(ctx context.Context, user, kind string) (map[string]bool, error) {
+	auth, err := h.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Auth == "" {
+		return nil, errors.New("the User API requires password authentication")
+	}
+
+	return cached(ctx, &h.cache, h.cacheScope(ctx)+"memberships:"+kind+":"+user, h.CacheTTL, func(ctx context.Context) (map[string]bool, error) {
+		var res struct {
+			Groups []struct {
+				Code string `json:"code"`
+			} `json:"groups"`
+			OrganizationTitles []struct {
+				Organization struct {
+					Code string `json:"code"`
+				} `json:"organization"`
+			} `json:"organizationTitles"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "GET", "/v1/user/"+kind+".json", Query{"code": user}, nil, &res); err != nil {
+			return nil, err
+		}
+
+		codes := make(map[string]bool)
+		for _, g := range res.Groups {
+			codes[g.Code] = true
+		}
+		for _, o := range res.OrganizationTitles {
+			codes[o.Organization.Code] = true
+		}
+		return codes, nil
+	})
+}
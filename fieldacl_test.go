@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFieldACLWithOneConcurrentRequest(t *testing.T) {
+	mock, err := newMockKintone(MockFixture{
+		Users: []map[string]any{{"code": "alice"}},
+		Apps: []MockAppFixture{{
+			Fields: map[string]map[string]any{
+				"title":  {"type": "SINGLE_LINE_TEXT"},
+				"note":   {"type": "MULTI_LINE_TEXT"},
+				"salary": {"type": "NUMBER"},
+			},
+			FieldACL: []map[string]any{
+				{"code": "note", "entities": []any{
+					map[string]any{"accessibility": "READ", "entity": map[string]any{"type": "GROUP", "code": "sales"}},
+				}},
+				{"code": "salary", "entities": []any{
+					map[string]any{"accessibility": "READ", "entity": map[string]any{"type": "GROUP", "code": "managers"}},
+				}},
+			},
+			Records: []map[string]any{{"title": "hello", "note": "visible to sales", "salary": "100"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the mock kintone: %v", err)
+	}
+
+	// The mock kintone doesn't have the User API.
+	mux := http.NewServeMux()
+	mux.Handle("/", mock)
+	mux.HandleFunc("/v1/user/groups.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(JsonMap{"groups": []JsonMap{{"code": "sales"}}})
+	})
+	mux.HandleFunc("/v1/user/organizations.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(JsonMap{"organizationTitles": []JsonMap{}})
+	})
+
+	// With only one request at a time, a request while reading the records would wait for the slot forever.
+	h := newTestHandlersWithServer(t, mux, map[string]string{
+		"KINTONE_USERNAME":                "alice",
+		"KINTONE_API_TOKEN":               "test-token",
+		"KINTONE_FIELD_ACL":               "true",
+		"KINTONE_MAX_CONCURRENT_REQUESTS": "1",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bs, _ := json.Marshal(JsonMap{"appID": "1"})
+	res, err := h.ToolsCall(ctx, ToolsCallRequest{Name: "readRecords", Arguments: bs})
+	if err != nil {
+		t.Fatalf("readRecords failed: %v", err)
+	}
+	if res.IsError {
+		t.Fatalf("readRecords returned an error: %s", res.Content[0].Text)
+	}
+
+	var out struct {
+		Records []map[string]any `json:"records"`
+	}
+	if err := json.Unmarshal([]byte(res.Content[0].Text), &out); err != nil {
+		t.Fatalf("failed to parse the result: %v", err)
+	}
+	if len(out.Records) != 1 {
+		t.Fatalf("expected 1 record but got %d", len(out.Records))
+	}
+	if _, ok := out.Records[0]["note"]; !ok {
+		t.Errorf("expected the field for the group of the user to be visible: %v", out.Records[0])
+	}
+	if _, ok := out.Records[0]["salary"]; ok {
+		t.Errorf("expected the field for the other group to be hidden: %v", out.Records[0])
+	}
+}
+
+func TestFieldACLUserFromBearerToken(t *testing.T) {
+	tokens := parseAuthTokens([]string{"shared-token", "alice:alice-token"})
+
+	var got []string
+	handler := requireBearerToken(tokens, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, authenticatedUser(r.Context()))
+	}))
+	for _, token := range []string{"shared-token", "alice-token", "alice:alice-token"} {
+		req := httptest.NewRequest("POST", "/mcp", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		// The username that the client declares is not trusted.
+		req.Header.Set("X-Kintone-Username", "administrator")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if want := []string{"", "alice"}; !slices.Equal(got, want) {
+		t.Errorf("expected the users %q but got %q", want, got)
+	}
+}
+
+func TestFieldACLRejectsDeclaredUser(t *testing.T) {
+	for _, sessionCredentials := range []string{"false", "true"} {
+		h := newTestHandlers(t, map[string]string{
+			"KINTONE_API_TOKEN":           "test-token",
+			"KINTONE_FIELD_ACL":           "true",
+			"KINTONE_SESSION_CREDENTIALS": sessionCredentials,
+		})
+
+		s := &Session{w: io.Discard}
+		ctx := s.context(context.Background())
+		params := InitializeRequest{}
+		params.Meta.Kintone = &KintoneCredentials{Username: "administrator"}
+		if _, err := h.InitializeHandler(ctx, params); err == nil {
+			t.Errorf("KINTONE_SESSION_CREDENTIALS=%s: expected the username without a password to be rejected", sessionCredentials)
+		}
+		if s.user != "" {
+			t.Errorf("KINTONE_SESSION_CREDENTIALS=%s: expected the declared user to be ignored but got %q", sessionCredentials, s.user)
+		}
+	}
+}
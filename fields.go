@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// fieldFilter decides which fields of an app can be passed to the client, based on the configuration file.
+type fieldFilter struct {
+	allow []string
+	deny  []string
+}
+
+// fieldFilter returns the filter for the fields of the app.
+func (h *KintoneHandlers) fieldFilter(appID string) fieldFilter {
+	app, _ := h.policy().Config.appConfiguration(appID)
+	return fieldFilter{allow: app.AllowFields, deny: app.DenyFields}
+}
+
+// active reports whether any field is restricted.
+func (f fieldFilter) active() bool {
+	return len(f.allow) > 0 || len(f.deny) > 0
+}
+
+// allowed reports whether the field can be passed to the client.
+// table is the code of the table that contains the field, or an empty string if the field is not in a table.
+func (f fieldFilter) allowed(table, code string) bool {
+	if code == "$id" || code == "$revision" {
+		return true
+	}
+	if table != "" {
+		return f.allowed("", table) && !slices.Contains(f.deny, code)
+	}
+	if slices.Contains(f.deny, code) {
+		return false
+	}
+	return len(f.allow) == 0 || slices.Contains(f.allow, code)
+}
+
+// filterRecord removes the fields that are not allowed from the record, including fields in tables.
+func (f fieldFilter) filterRecord(record map[string]any) {
+	if !f.active() {
+		return
+	}
+
+	for code, v := range record {
+		if !f.allowed("", code) {
+			delete(record, code)
+			continue
+		}
+
+		field, ok := v.(map[string]any)
+		if !ok || field["type"] != "SUBTABLE" {
+			continue
+		}
+		rows, _ := field["value"].([]any)
+		for _, row := range rows {
+			if row, ok := row.(map[string]any); ok {
+				if cells, ok := row["value"].(map[string]any); ok {
+					for sub := range cells {
+						if !f.allowed(code, sub) {
+							delete(cells, sub)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// filterProperties removes the fields that are not allowed from the field definitions of the app.
+func (f fieldFilter) filterProperties(properties JsonMap) {
+	if !f.active() {
+		return
+	}
+
+	for code, v := range properties {
+		if !f.allowed("", code) {
+			delete(properties, code)
+			continue
+		}
+
+		if prop, ok := v.(map[string]any); ok {
+			if subs, ok := prop["fields"].(map[string]any); ok {
+				for sub := range subs {
+					if !f.allowed(code, sub) {
+						delete(subs, sub)
+					}
+				}
+			}
+		}
+	}
+}
+
+// filterViews removes the fields that are not allowed from the views in the response of /k/v1/app/views.json.
+// The views that filter or sort by the fields are removed entirely, because the conditions reveal the fields.
+// tables is the result of fieldTables.
+func (f fieldFilter) filterViews(views JsonMap, tables map[string]string) {
+	if !f.active() {
+		return
+	}
+
+	for name, v := range views {
+		view, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		cond, _ := view["filterCond"].(string)
+		sort, _ := view["sort"].(string)
+		if slices.ContainsFunc(queryTokens(cond+" "+sort), func(token string) bool {
+			table, ok := tables[token]
+			return (ok && !f.allowed(table, token)) || slices.Contains(f.deny, token)
+		}) {
+			delete(views, name)
+			continue
+		}
+
+		if codes, ok := view["fields"].([]any); ok {
+			view["fields"] = slices.DeleteFunc(codes, func(c any) bool {
+				code, _ := c.(string)
+				return !f.allowed("", code)
+			})
+		}
+	}
+}
+
+// checkRecord checks that the record to create or update doesn't contain the fields that are not allowed.
+func (f fieldFilter) checkRecord(appID string, record any) error {
+	if !f.active() {
+		return nil
+	}
+
+	fields, ok := record.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for code, v := range fields {
+		if !f.allowed("", code) {
+			return fieldDeniedError(appID, code)
+		}
+
+		field, _ := v.(map[string]any)
+		rows, _ := field["value"].([]any)
+		for _, row := range rows {
+			row, _ := row.(map[string]any)
+			cells, _ := row["value"].(map[string]any)
+			for sub := range cells {
+				if !f.allowed(code, sub) {
+					return fieldDeniedError(appID, sub)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkQuery checks that the query doesn't refer the fields that are not allowed, because the condition or the order could leak the values.
+func (h *KintoneHandlers) checkQuery(ctx context.Context, appID, query string) error {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+
+	acl, err := h.fieldACL(ctx, appID)
+	if err != nil {
+		return err
+	}
+	if err := acl.checkQuery(appID, query); err != nil {
+		return err
+	}
+
+	f := h.fieldFilter(appID)
+	if !f.active() {
+		return nil
+	}
+
+	tables, err := h.fieldTables(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range queryTokens(query) {
+		if table, ok := tables[token]; ok && !f.allowed(table, token) {
+			return fieldDeniedError(appID, token)
+		}
+		if slices.Contains(f.deny, token) {
+			return fieldDeniedError(appID, token)
+		}
+	}
+
+	return nil
+}
+
+// queryTokens splits the kintone query into words, excluding string literals.
+func queryTokens(query string) []string {
+	var tokens []string
+	var buf strings.Builder
+	inString, escaped := false, false
+
+	flush := func() {
+		if buf.Len() > 0 {
+			tokens = append(tokens, buf.String())
+			buf.Reset()
+		}
+	}
+
+	for _, r := range query {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			flush()
+			inString = true
+		case strings.ContainsRune(" \t\r\n()=!<>,", r):
+			flush()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+func fieldDeniedError(appID, code string) error {
+	return jsonrpc2.Error{
+		Code:    jsonrpc2.InvalidParamsCode,
+		Message: fmt.Sprintf("Field %s in app ID %s is inaccessible because it is restricted in the configuration file (KINTONE_CONFIG_FILE). Please check the MCP server settings.", code, appID),
+	}
+}
+
+// fieldTables returns all field codes in the app, mapped to the code of the table that contains the field, or an empty string if the field is not in a table.
+func (h *KintoneHandlers) fieldTables(ctx context.Context, appID string) (map[string]string, error) {
+	var fields struct {
+		Properties map[string]struct {
+			Fields map[string]any `json:"fields"`
+		} `json:"properties"`
+	}
+	if err := h.fetchAppSchema(ctx, "/k/v1/app/form/fields.json", appID, &fields); err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]string)
+	for code, f := range fields.Properties {
+		tables[code] = ""
+		for sub := range f.Fields {
+			tables[sub] = code
+		}
+	}
+	return tables, nil
+}
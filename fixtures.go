@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// fixtureHeaders is the response headers that are kept in the fixtures. The others, such as cookies, are dropped.
+var fixtureHeaders = []string{"Content-Type", "Content-Disposition", "Content-Range", "Accept-Ranges", "Retry-After"}
+
+// httpFixture is the responses of kintone to a request, that are recorded by KINTONE_RECORD_FIXTURES.
+// The same request can have multiple responses, such as reading records before and after an update, and they are replayed in order.
+type httpFixture struct {
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Query     string            `json:"query,omitempty"`
+	Range     string            `json:"range,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Responses []fixtureResponse `json:"responses"`
+}
+
+type fixtureResponse struct {
+	Status     int               `json:"status"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	BodyBase64 string            `json:"bodyBase64,omitempty"`
+}
+
+// readFixtureRequest reads the request into a fixture without responses, and returns the file name of the fixture.
+// The body of the request is replaced, so that it can be sent after this.
+// The credentials are not recorded, because the fixture has no request headers.
+func readFixtureRequest(req *http.Request) (httpFixture, string, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return httpFixture{}, "", err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		if gz, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if plain, err := io.ReadAll(gz); err == nil {
+				body = plain
+			}
+		}
+	}
+	// The boundary of multipart bodies is random, so it is replaced to make the same upload match.
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("BOUNDARY"))
+	}
+
+	f := httpFixture{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query().Encode(),
+		Range:  req.Header.Get("Range"),
+	}
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s\x00%s\x00%s\x00%s\x00", f.Method, f.Path, f.Query, f.Range)
+	sum.Write(body)
+
+	if utf8.Valid(body) {
+		f.Body = string(body)
+	} else {
+		f.Body = fmt.Sprintf("(%d bytes)", len(body))
+	}
+
+	name := strings.NewReplacer("/", "_", ".", "_").Replace(strings.Trim(f.Path, "/"))
+	return f, fmt.Sprintf("%s_%s_%s.json", strings.ToLower(f.Method), name, hex.EncodeToString(sum.Sum(nil))[:12]), nil
+}
+
+// fixtureRecorder is the transport that records the responses of kintone into the directory of KINTONE_RECORD_FIXTURES.
+// The responses are recorded as they are, without the masking rules or the field restrictions, so the files contain the real records.
+// Therefore, the directory and the files are readable only by the owner.
+type fixtureRecorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu sync.Mutex
+
+	// recorded is the fixtures that are written by this process. The other files in the directory are overwritten, not appended.
+	recorded map[string]bool
+}
+
+func newFixtureRecorder(dir string, next http.RoundTripper) (*fixtureRecorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fixtureRecorder{dir: dir, next: next, recorded: make(map[string]bool)}, nil
+}
+
+func (r *fixtureRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	f, name, err := readFixtureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(data))
+
+	recorded := fixtureResponse{Status: res.StatusCode, Header: make(map[string]string)}
+	for _, key := range fixtureHeaders {
+		if v := res.Header.Get(key); v != "" {
+			recorded.Header[key] = v
+		}
+	}
+	if utf8.Valid(data) && isTextContent(res.Header.Get("Content-Type")) {
+		recorded.Body = string(data)
+	} else {
+		recorded.BodyBase64 = base64.StdEncoding.EncodeToString(data)
+	}
+
+	if err := r.save(f, name, recorded); err != nil {
+		serverLog.Error("failed to record a fixture", "path", filepath.Join(r.dir, name), "error", err)
+	}
+	return res, nil
+}
+
+// save appends the response to the fixture file.
+func (r *fixtureRecorder) save(f httpFixture, name string, res fixtureResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := filepath.Join(r.dir, name)
+	if r.recorded[name] {
+		if data, err := os.ReadFile(path); err == nil {
+			var existing httpFixture
+			if err := json.Unmarshal(data, &existing); err == nil {
+				f.Responses = existing.Responses
+			}
+		}
+	}
+	r.recorded[name] = true
+	f.Responses = append(f.Responses, res)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// fixtureReplayer is the transport that responds the fixtures in the directory of KINTONE_REPLAY_FIXTURES instead of kintone.
+type fixtureReplayer struct {
+	dir      string
+	fixtures map[string]httpFixture
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+func loadFixtureReplayer(dir string) (*fixtureReplayer, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", dir)
+	}
+
+	r := &fixtureReplayer{dir: dir, fixtures: make(map[string]httpFixture), served: make(map[string]int)}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f httpFixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		if len(f.Responses) == 0 {
+			return nil, fmt.Errorf("%s: no responses", filepath.Base(path))
+		}
+		r.fixtures[filepath.Base(path)] = f
+	}
+	return r, nil
+}
+
+// RoundTrip responds the recorded responses in order. The last one is repeated if the request is sent more times than it was recorded.
+func (r *fixtureReplayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, name, err := readFixtureRequest(req.Clone(req.Context()))
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	f, ok := r.fixtures[name]
+	i := r.served[name]
+	r.served[name]++
+	r.mu.Unlock()
+
+	if !ok {
+		body, _ := json.Marshal(JsonMap{
+			"message": fmt.Sprintf("No fixture is recorded for %s %s. Record it by KINTONE_RECORD_FIXTURES, and save it as %s.", req.Method, req.URL.Path, filepath.Join(r.dir, name)),
+		})
+		return fixtureHTTPResponse(req, fixtureResponse{Status: http.StatusNotFound, Header: map[string]string{"Content-Type": "application/json"}, Body: string(body)})
+	}
+	return fixtureHTTPResponse(req, f.Responses[min(i, len(f.Responses)-1)])
+}
+
+func fixtureHTTPResponse(req *http.Request, res fixtureResponse) (*http.Response, error) {
+	body := []byte(res.Body)
+	if res.BodyBase64 != "" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(res.BodyBase64); err != nil {
+			return nil, errors.New("the fixture has a broken body")
+		}
+	}
+
+	header := make(http.Header)
+	for k, v := range res.Header {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.Status, http.StatusText(res.Status)),
+		StatusCode:    res.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
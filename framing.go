@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxMessageSize is the maximum size of a message, the same as a request of the HTTP transports.
+	// The larger messages are skipped without keeping them in memory.
+	maxMessageSize = maxHTTPRequestSize
+
+	// maxHeaderLineSize is the maximum length of a header line of the LSP-style framing.
+	maxHeaderLineSize = 1024
+)
+
+// errMessageTooLarge is the error of a message that exceeds maxMessageSize.
+var errMessageTooLarge = fmt.Errorf("the message exceeds the maximum size of %s", formatSize(maxMessageSize))
+
+// headerLinePattern matches a header line of the LSP-style framing, such as "Content-Length: 123".
+var headerLinePattern = regexp.MustCompile(`^[A-Za-z0-9-]+:`)
+
+// framingError is an error in a message from the client, that can be recovered by skipping the message.
+type framingError struct {
+	// Offset is the position in the stream where the broken message starts.
+	Offset int64
+	Err    error
+}
+
+func (e *framingError) Error() string {
+	return fmt.Sprintf("at offset %d: %s", e.Offset, e.Err)
+}
+
+func (e *framingError) Unwrap() error {
+	return e.Err
+}
+
+// messageReader reads JSON-RPC messages from a stream.
+// It accepts both the newline-delimited JSON and the LSP-style framing with Content-Length header, because the clients use either of them.
+type messageReader struct {
+	r      *bufio.Reader
+	offset int64
+
+	// framed reports whether the last message was framed with Content-Length header.
+	framed bool
+}
+
+func newMessageReader(r io.Reader) *messageReader {
+	return &messageReader{r: bufio.NewReader(r)}
+}
+
+// Read reads the next message.
+// It returns a *framingError if the message is broken. The broken message is skipped, so the caller can continue reading.
+func (m *messageReader) Read() (json.RawMessage, error) {
+	for {
+		b, err := m.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case bytes.ContainsAny(b, " \t\r\n"):
+			m.r.Discard(1)
+			m.offset++
+		case b[0] == '{' || b[0] == '[':
+			m.framed = false
+			return m.readJSON()
+		default:
+			m.framed = true
+			return m.readFramed()
+		}
+	}
+}
+
+// readJSON reads a JSON object or array without any framing.
+// The end of the value is found by counting the brackets, so the pretty-printed JSON over multiple lines works too.
+// A value larger than maxMessageSize is read to the end without keeping it, to continue from the next message.
+func (m *messageReader) readJSON() (json.RawMessage, error) {
+	start := m.offset
+	var buf []byte
+	tooLarge := false
+	depth := 0
+	inString, escaped := false, false
+	for {
+		c, err := m.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil, &framingError{Offset: start, Err: io.ErrUnexpectedEOF}
+		} else if err != nil {
+			return nil, err
+		}
+		m.offset++
+		if !tooLarge {
+			buf = append(buf, c)
+			if len(buf) > maxMessageSize {
+				tooLarge, buf = true, nil
+			}
+		}
+
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+
+	if tooLarge {
+		return nil, &framingError{Offset: start, Err: errMessageTooLarge}
+	}
+	if !json.Valid(buf) {
+		return nil, &framingError{Offset: start, Err: errors.New("invalid JSON")}
+	}
+	return buf, nil
+}
+
+// readFramed reads a message with the headers such as "Content-Length: 123\r\n\r\n".
+// A line that is not a header is skipped as an error, such as a stray output of the client.
+func (m *messageReader) readFramed() (json.RawMessage, error) {
+	start := m.offset
+	length := -1
+	for {
+		line, truncated, err := m.readLine()
+		if errors.Is(err, io.EOF) && line != "" {
+			return nil, &framingError{Offset: start, Err: io.ErrUnexpectedEOF}
+		} else if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if truncated {
+			return nil, &framingError{Offset: start, Err: fmt.Errorf("too long line: %q", line[:100]+"...")}
+		}
+		if line == "" {
+			break
+		}
+		if !headerLinePattern.MatchString(line) {
+			if len(line) > 100 {
+				line = line[:100] + "..."
+			}
+			return nil, &framingError{Offset: start, Err: fmt.Errorf("unexpected data: %q", line)}
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(name, "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return nil, &framingError{Offset: start, Err: fmt.Errorf("invalid Content-Length: %q", strings.TrimSpace(value))}
+			}
+			length = n
+		}
+	}
+	if length < 0 {
+		return nil, &framingError{Offset: start, Err: errors.New("missing Content-Length header")}
+	}
+	if length > maxMessageSize {
+		n, err := io.CopyN(io.Discard, m.r, int64(length))
+		m.offset += n
+		if errors.Is(err, io.EOF) {
+			return nil, &framingError{Offset: start, Err: io.ErrUnexpectedEOF}
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, &framingError{Offset: start, Err: errMessageTooLarge}
+	}
+
+	// The body is copied instead of allocating the length at once, so that a broken header doesn't allocate a huge buffer.
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, m.r, int64(length))
+	m.offset += n
+	if errors.Is(err, io.EOF) {
+		return nil, &framingError{Offset: start, Err: io.ErrUnexpectedEOF}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(buf.Bytes()) {
+		return nil, &framingError{Offset: start, Err: errors.New("invalid JSON")}
+	}
+	return buf.Bytes(), nil
+}
+
+// readLine reads a line including the newline.
+// Only the first maxHeaderLineSize bytes are kept, and truncated reports whether the rest is dropped.
+func (m *messageReader) readLine() (line string, truncated bool, err error) {
+	var buf []byte
+	for {
+		chunk, err := m.r.ReadSlice('\n')
+		m.offset += int64(len(chunk))
+		keep := min(len(chunk), maxHeaderLineSize-len(buf))
+		buf = append(buf, chunk[:keep]...)
+		truncated = truncated || keep < len(chunk)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return string(buf), truncated, err
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMessageReaderTooLarge(t *testing.T) {
+	large := `{"jsonrpc": "2.0", "method": "ping", "params": {"data": "` + strings.Repeat("x", maxMessageSize) + `"}}`
+	const next = `{"jsonrpc": "2.0", "id": 1, "method": "ping"}`
+
+	for _, tt := range []struct {
+		name   string
+		stream string
+	}{
+		{"newline-delimited", large + "\n" + next + "\n"},
+		{"Content-Length", fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(large), large) + fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(next), next)},
+		{"long header line", "X-Header: " + strings.Repeat("x", maxMessageSize) + "\r\n" + fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(next), next)},
+	} {
+		r := newMessageReader(strings.NewReader(tt.stream))
+
+		_, err := r.Read()
+		var ferr *framingError
+		if !errors.As(err, &ferr) {
+			t.Errorf("%s: expected a framing error but got %v", tt.name, err)
+			continue
+		}
+		if ferr.Offset != 0 {
+			t.Errorf("%s: expected the error at offset 0 but got %d", tt.name, ferr.Offset)
+		}
+
+		// The large message is skipped, and the next message can be read.
+		for {
+			msg, err := r.Read()
+			if errors.As(err, &ferr) {
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: failed to read the next message: %v", tt.name, err)
+			} else if string(msg) != next {
+				t.Errorf("%s: expected the next message but got %.100s", tt.name, msg)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestHandlers creates the handlers from the environment variables, connected to the mock kintone that is served by an httptest server.
+func newTestHandlers(t *testing.T, env map[string]string) *KintoneHandlers {
+	t.Helper()
+
+	mock, err := loadMockKintone("")
+	if err != nil {
+		t.Fatalf("failed to load the mock kintone: %v", err)
+	}
+	t.Setenv("KINTONE_USERNAME", mock.user)
+	return newTestHandlersWithServer(t, mock, env)
+}
+
+// newTestHandlersWithServer is the same as newTestHandlers, but sends the requests to the handler, such as a mock kintone with additional APIs.
+func newTestHandlersWithServer(t *testing.T, handler http.Handler, env map[string]string) *KintoneHandlers {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("KINTONE_BASE_URL", srv.URL)
+	t.Setenv("KINTONE_CUSTOM_DOMAIN", "true")
+	t.Setenv("KINTONE_PASSWORD", "test-password")
+	t.Setenv("KINTONE_DOWNLOAD_DIR", t.TempDir())
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	h, err := NewKintoneHandlersFromEnv()
+	if err != nil {
+		t.Fatalf("failed to create the handlers: %v", err)
+	}
+	return h
+}
+
+// writeTestConfig writes the configuration file, and returns the path.
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// callTestTool calls the tool and returns the first content as JSON.
+func callTestTool(t *testing.T, h *KintoneHandlers, name string, args any) JsonMap {
+	t.Helper()
+
+	bs, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("failed to encode the arguments: %v", err)
+	}
+	res, err := h.ToolsCall(context.Background(), ToolsCallRequest{Name: name, Arguments: bs})
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	if res.IsError {
+		t.Fatalf("%s returned an error: %s", name, res.Content[0].Text)
+	}
+
+	var out JsonMap
+	if err := json.Unmarshal([]byte(res.Content[0].Text), &out); err != nil {
+		t.Fatalf("%s returned a non-JSON content: %s", name, res.Content[0].Text)
+	}
+	return out
+}
+
+func TestListApps(t *testing.T) {
+	h := newTestHandlers(t, nil)
+
+	out := callTestTool(t, h, "listApps", JsonMap{})
+	apps, _ := out["apps"].([]any)
+	if len(apps) != 2 {
+		t.Fatalf("expected 2 apps but got %d: %v", len(apps), out)
+	}
+	if out["hasNext"] != false {
+		t.Errorf("expected hasNext to be false but got %v", out["hasNext"])
+	}
+
+	out = callTestTool(t, h, "listApps", JsonMap{"name": "Support"})
+	apps, _ = out["apps"].([]any)
+	if len(apps) != 1 || apps[0].(map[string]any)["name"] != "Support tickets" {
+		t.Errorf("expected only Support tickets but got %v", apps)
+	}
+}
+
+func TestListAppsDenied(t *testing.T) {
+	h := newTestHandlers(t, map[string]string{"KINTONE_DENY_APPS": "2"})
+
+	out := callTestTool(t, h, "listApps", JsonMap{})
+	apps, _ := out["apps"].([]any)
+	if len(apps) != 1 || apps[0].(map[string]any)["appID"] != "1" {
+		t.Errorf("expected only app 1 but got %v", apps)
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	h := newTestHandlers(t, nil)
+
+	out := callTestTool(t, h, "readRecords", JsonMap{"appID": "1", "limit": 2, "format": "simple"})
+	records, _ := out["records"].([]any)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records but got %d: %v", len(records), out)
+	}
+	if out["hasMore"] != true || out["nextOffset"] != float64(2) {
+		t.Errorf("expected the next page at offset 2 but got hasMore=%v nextOffset=%v", out["hasMore"], out["nextOffset"])
+	}
+	if _, ok := records[0].(map[string]any)["company"].(string); !ok {
+		t.Errorf("expected the simple format but got %v", records[0])
+	}
+
+	out = callTestTool(t, h, "readRecords", JsonMap{"appID": "1", "query": `company = "Stark Finance"`, "fields": []string{"company"}})
+	records, _ = out["records"].([]any)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record but got %d: %v", len(records), out)
+	}
+	if record := records[0].(map[string]any); record["note"] != nil {
+		t.Errorf("expected only the requested fields but got %v", record)
+	}
+}
+
+func TestReadRecordsDenyFields(t *testing.T) {
+	config := writeTestConfig(t, "apps:\n  \"1\":\n    permissions: {read: true}\n    denyFields: [note]\n")
+	h := newTestHandlers(t, map[string]string{"KINTONE_CONFIG_FILE": config})
+
+	out := callTestTool(t, h, "readRecords", JsonMap{"appID": "1", "limit": 1})
+	records, _ := out["records"].([]any)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record but got %d: %v", len(records), out)
+	}
+	if _, ok := records[0].(map[string]any)["note"]; ok {
+		t.Errorf("expected the denied field to be removed but got %v", records[0])
+	}
+}
+
+func TestExportRecordsXLSXDenyFields(t *testing.T) {
+	config := writeTestConfig(t, "apps:\n  \"1\":\n    permissions: {read: true}\n    denyFields: [note]\n")
+	h := newTestHandlers(t, map[string]string{"KINTONE_CONFIG_FILE": config})
+
+	msg := callTestToolError(t, h, "exportRecordsXLSX", JsonMap{"appID": "1", "fields": []string{"company", "note"}})
+	if !strings.Contains(msg, "Field note in app ID 1 is inaccessible") {
+		t.Errorf("expected the denied field to be rejected but got %s", msg)
+	}
+
+	callTestTool(t, h, "exportRecordsXLSX", JsonMap{"appID": "1", "fields": []string{"company"}})
+}
+
+func TestAttachmentFile(t *testing.T) {
+	h := newTestHandlers(t, nil)
+
+	out := callTestTool(t, h, "uploadAttachmentFile", JsonMap{"name": "見積.txt", "content": "hello, kintone"})
+	fileKey, _ := out["fileKey"].(string)
+	if fileKey == "" {
+		t.Fatalf("expected a file key but got %v", out)
+	}
+
+	out = callTestTool(t, h, "downloadAttachmentFile", JsonMap{"fileKey": fileKey})
+	if out["fileName"] != "見積.txt" {
+		t.Errorf("expected the original file name but got %v", out["fileName"])
+	}
+	path, _ := out["filePath"].(string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the downloaded file: %v", err)
+	}
+	if string(data) != "hello, kintone" {
+		t.Errorf("unexpected content of the downloaded file: %q", data)
+	}
+	if out["size"] != float64(len(data)) {
+		t.Errorf("expected the size %d but got %v", len(data), out["size"])
+	}
+}
+
+func TestRecordComments(t *testing.T) {
+	h := newTestHandlers(t, nil)
+
+	callTestTool(t, h, "createRecordComment", JsonMap{"appID": "1", "recordID": "1", "comment": JsonMap{"text": "first"}})
+	callTestTool(t, h, "createRecordComment", JsonMap{"appID": "1", "recordID": "1", "comment": JsonMap{"text": "second"}})
+
+	out := callTestTool(t, h, "readRecordComments", JsonMap{"appID": "1", "recordID": "1", "order": "asc", "compact": true})
+	comments, _ := out["comments"].([]any)
+	if len(comments) != 2 {
+		t.Fatalf("expected 2 comments but got %d: %v", len(comments), out)
+	}
+	for i, want := range []string{"first", "second"} {
+		if got := comments[i].(map[string]any)["text"]; got != want {
+			t.Errorf("comments[%d]: expected %q but got %v", i, want, got)
+		}
+	}
+}
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	h := newTestHandlers(t, map[string]string{"KINTONE_READ_ONLY": "true"})
+
+	bs, _ := json.Marshal(JsonMap{"appID": "1", "recordID": "1", "comment": JsonMap{"text": "hello"}})
+	if _, err := h.ToolsCall(context.Background(), ToolsCallRequest{Name: "createRecordComment", Arguments: bs}); err == nil {
+		t.Error("expected createRecordComment to be rejected in read-only mode")
+	}
+}
+
+func TestFixtureReplay(t *testing.T) {
+	// The directory is created by the recorder, to check its permission.
+	dir := filepath.Join(t.TempDir(), "fixtures")
+
+	recorder := newTestHandlers(t, map[string]string{"KINTONE_RECORD_FIXTURES": dir})
+	recorded := callTestTool(t, recorder, "readRecords", JsonMap{"appID": "2", "limit": 3})
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("expected the fixture directory to be private but got %v", info.Mode().Perm())
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(paths) == 0 {
+		t.Fatal("no fixtures are recorded")
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("expected %s to be private but got %v", filepath.Base(path), info.Mode().Perm())
+		}
+	}
+
+	replayer := newTestHandlers(t, map[string]string{"KINTONE_RECORD_FIXTURES": "", "KINTONE_REPLAY_FIXTURES": dir})
+	replayed := callTestTool(t, replayer, "readRecords", JsonMap{"appID": "2", "limit": 3})
+
+	want, _ := json.Marshal(recorded)
+	got, _ := json.Marshal(replayed)
+	if string(got) != string(want) {
+		t.Errorf("the replayed result is different from the recorded one\nrecorded: %s\nreplayed: %s", want, got)
+	}
+
+	bs, _ := json.Marshal(JsonMap{"appID": "1"})
+	res, err := replayer.ToolsCall(context.Background(), ToolsCallRequest{Name: "readRecords", Arguments: bs})
+	if err == nil && !res.IsError {
+		t.Error("expected an error for the request that is not recorded")
+	}
+}
+
+func TestListAppsFullPage(t *testing.T) {
+	for _, tt := range []struct {
+		apps     int
+		hasNext  bool
+		pageSize int
+	}{
+		{apps: 100, hasNext: false, pageSize: 100},
+		{apps: 101, hasNext: true, pageSize: 100},
+	} {
+		fixture := MockFixture{Apps: make([]MockAppFixture, tt.apps)}
+		for i := range fixture.Apps {
+			fixture.Apps[i].Fields = map[string]map[string]any{"title": {"type": "SINGLE_LINE_TEXT"}}
+		}
+		mock, err := newMockKintone(fixture)
+		if err != nil {
+			t.Fatalf("failed to create the mock kintone: %v", err)
+		}
+		t.Setenv("KINTONE_USERNAME", mock.user)
+		h := newTestHandlersWithServer(t, mock, nil)
+
+		out := callTestTool(t, h, "listApps", JsonMap{})
+		apps, _ := out["apps"].([]any)
+		if len(apps) != tt.pageSize {
+			t.Errorf("%d apps: expected %d apps in the page but got %d", tt.apps, tt.pageSize, len(apps))
+		}
+		if out["hasNext"] != tt.hasNext {
+			t.Errorf("%d apps: expected hasNext to be %v but got %v", tt.apps, tt.hasNext, out["hasNext"])
+		}
+		if tt.hasNext && out["nextOffset"] != float64(100) {
+			t.Errorf("%d apps: expected nextOffset to be 100 but got %v", tt.apps, out["nextOffset"])
+		}
+		if !tt.hasNext && out["nextOffset"] != nil {
+			t.Errorf("%d apps: expected no nextOffset but got %v", tt.apps, out["nextOffset"])
+		}
+	}
+}
+
+func TestConfigFileNotInEnviron(t *testing.T) {
+	config := writeTestConfig(t, "kintone:\n  apiTokens: [config-token]\n  appTokens:\n    \"1\": [config-app-token]\n  basicAuth: {username: proxy, password: config-proxy-password}\naccess:\n  readOnly: true\n")
+	h := newTestHandlers(t, map[string]string{"KINTONE_CONFIG_FILE": config})
+
+	for _, kv := range os.Environ() {
+		if strings.Contains(kv, "config-") {
+			t.Errorf("the setting of the configuration file is copied into the environment: %s", kv)
+		}
+	}
+
+	if p := h.policy(); !p.ReadOnly || p.Token != "config-token" || !slices.Equal(p.AppTokens["1"], []string{"config-app-token"}) {
+		t.Errorf("the configuration file is not applied: readOnly=%v token=%q appTokens=%v", p.ReadOnly, p.Token, p.AppTokens)
+	}
+	if h.BasicAuth != basicAuth("proxy", "config-proxy-password") {
+		t.Errorf("the Basic authentication of the configuration file is not applied")
+	}
+
+	// The environment variables have a higher priority than the configuration file.
+	t.Setenv("KINTONE_READ_ONLY", "false")
+	if err := h.Reload(context.Background()); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if h.policy().ReadOnly {
+		t.Error("expected the environment variable to override the configuration file")
+	}
+
+	// The settings of the previous file don't remain without a file.
+	t.Setenv("KINTONE_CONFIG_FILE", "")
+	t.Setenv("KINTONE_READ_ONLY", "")
+	h, err := NewKintoneHandlersFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := h.policy(); p.ReadOnly || p.Token != "" {
+		t.Errorf("the settings of the previous configuration file remain: readOnly=%v token=%q", p.ReadOnly, p.Token)
+	}
+}
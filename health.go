@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readinessCacheTTL is how long the result of the readiness check is reused, so that frequent probes don't use up the API limit of kintone.
+	readinessCacheTTL = 30 * time.Second
+
+	// readinessTimeout is the timeout of the readiness check.
+	readinessTimeout = 10 * time.Second
+)
+
+// readinessCache is the last result of the readiness check.
+type readinessCache struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// checkReadiness verifies that kintone is reachable and the credentials are valid.
+// The result, including failures, is cached for readinessCacheTTL.
+func (h *KintoneHandlers) checkReadiness(ctx context.Context) error {
+	h.readiness.mu.Lock()
+	defer h.readiness.mu.Unlock()
+
+	if !h.readiness.checked.IsZero() && time.Since(h.readiness.checked) < readinessCacheTTL {
+		return h.readiness.err
+	}
+
+	h.readiness.err = h.probeKintone(ctx)
+	h.readiness.checked = time.Now()
+	return h.readiness.err
+}
+
+// probeKintone sends a request to kintone with the credentials of the server.
+// If the server has no credentials because the clients provide them, there is nothing to check.
+func (h *KintoneHandlers) probeKintone(ctx context.Context) error {
+	if h.URL == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apis.json", nil, nil, nil); err != nil {
+		return fmt.Errorf("kintone is not available: %w", err)
+	}
+	return nil
+}
+
+// healthHandler serves /healthz and /readyz for container orchestrators.
+// /healthz reports that the process is running, and /readyz reports that kintone is reachable with the credentials of the server.
+// They don't require authentication, so the reason of failures is written only to the server log.
+func (h *KintoneHandlers) healthHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, JsonMap{"status": "ok"})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-shuttingDown(r.Context()):
+			writeJSONResponse(w, http.StatusServiceUnavailable, JsonMap{"status": "shutting down"})
+			return
+		default:
+		}
+
+		if err := h.checkReadiness(r.Context()); err != nil {
+			serverLog.WarnContext(r.Context(), "Readiness check failed", "error", err)
+			writeJSONResponse(w, http.StatusServiceUnavailable, JsonMap{"status": "unavailable"})
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, JsonMap{"status": "ok"})
+	})
+
+	return mux
+}
+
+// runHealthcheck runs the readiness check once and exits, for HEALTHCHECK of Docker.
+func runHealthcheck(ctx context.Context, args []string) error {
+	handlers, err := NewKintoneHandlersFromEnv()
+	if err != nil {
+		return err
+	}
+
+	if err := handlers.checkReadiness(ctx); err != nil {
+		return err
+	}
+	fmt.Println("OK")
+	return nil
+}
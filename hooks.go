@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// defaultHookTimeout is the time limit of a hook if the timeout is not set.
+const defaultHookTimeout = 10 * time.Second
+
+// HooksConfiguration is the hooks that are called around tool calls, to enforce the business rules outside of the AI.
+type HooksConfiguration struct {
+	// BeforeWrite is called before the tools that modify data. It can reject the call, or replace the arguments.
+	BeforeWrite []Hook `json:"beforeWrite"`
+
+	// After is called after any tool call, such as for logging or notification. The result doesn't affect the call.
+	After []Hook `json:"after"`
+}
+
+// Hook is an external command or an HTTP endpoint that receives a tool call as JSON.
+type Hook struct {
+	// Command is the program and its arguments. The request is passed by the standard input.
+	Command []string `json:"command"`
+
+	// Env is the environment variables that are passed to the command.
+	Env map[string]string `json:"env"`
+
+	// URL is the endpoint that receives the request by POST.
+	URL string `json:"url"`
+
+	// Headers is the HTTP headers to send to the URL, such as Authorization.
+	Headers map[string]string `json:"headers"`
+
+	// Tools is the names of the tools to call the hook. In default, the hook is called for all tools.
+	Tools []string `json:"tools"`
+
+	// Timeout is the time limit of the hook, such as "10s".
+	Timeout string `json:"timeout"`
+}
+
+// validate checks the settings of the hook.
+func (h Hook) validate(c Configuration, path string) error {
+	if (len(h.Command) == 0) == (h.URL == "") {
+		return fmt.Errorf("invalid value for %q: either \"command\" or \"url\" must be set", path)
+	}
+	if len(h.Command) > 0 && h.Command[0] == "" {
+		return fmt.Errorf("invalid value for \"%s.command\": the command must not be empty", path)
+	}
+	if h.URL != "" {
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid value for \"%s.url\": %q is not an HTTP or HTTPS URL", path, h.URL)
+		}
+	}
+	for _, name := range h.Tools {
+		_, isTool := findTool(name)
+		_, isCommandTool := c.findCommandTool(name)
+		isAppTool := slices.ContainsFunc(c.appTools(), func(t appTool) bool { return t.Name == name })
+		if !isTool && !isCommandTool && !isAppTool {
+			return fmt.Errorf("invalid value for \"%s.tools\": unknown tool name %q", path, name)
+		}
+	}
+	if h.Timeout != "" {
+		if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid value for \"%s.timeout\": %q is not a positive duration", path, h.Timeout)
+		}
+	}
+	return nil
+}
+
+// validateHooks checks the hooks in the configuration file.
+func (c Configuration) validateHooks() error {
+	for i, h := range c.Hooks.BeforeWrite {
+		if err := h.validate(c, fmt.Sprintf("hooks.beforeWrite[%d]", i)); err != nil {
+			return err
+		}
+	}
+	for i, h := range c.Hooks.After {
+		if err := h.validate(c, fmt.Sprintf("hooks.after[%d]", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the command or the URL of the hook for logs.
+func (h Hook) String() string {
+	if h.URL != "" {
+		return h.URL
+	}
+	return strings.Join(h.Command, " ")
+}
+
+// matches reports whether the hook is called for the tool.
+func (h Hook) matches(tool string) bool {
+	return len(h.Tools) == 0 || slices.Contains(h.Tools, tool)
+}
+
+// call sends the request to the hook, and returns the response.
+// The client is used for the hooks of URL.
+func (h Hook) call(ctx context.Context, client *http.Client, request any) ([]byte, error) {
+	timeout := defaultHookTimeout
+	if h.Timeout != "" {
+		timeout, _ = time.ParseDuration(h.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(h.Command) > 0 {
+		return runCommand(ctx, h.Command, commandEnv(h.Env), body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	out, err := io.ReadAll(io.LimitReader(res.Body, maxCommandOutputSize))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+// hookRequest is the JSON that the hooks receive.
+type hookRequest struct {
+	Event     string          `json:"event"`
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments"`
+	RequestID string          `json:"requestID"`
+	Profile   string          `json:"profile,omitempty"`
+	User      string          `json:"user,omitempty"`
+
+	// Result and Error are only set for the "after" hooks.
+	Result []Content `json:"result,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// newHookRequest makes the request to the hooks about the tool call.
+func (h *KintoneHandlers) newHookRequest(ctx context.Context, event string, params ToolsCallRequest) hookRequest {
+	req := hookRequest{
+		Event:     event,
+		Tool:      params.Name,
+		Arguments: params.Arguments,
+		RequestID: requestIDFromContext(ctx),
+		Profile:   profileFromContext(ctx),
+	}
+	if len(req.Arguments) == 0 {
+		req.Arguments = json.RawMessage("{}")
+	}
+	if auth, err := h.auth(ctx); err == nil {
+		req.User = auth.User
+	}
+	return req
+}
+
+// hookResponse is the JSON that the beforeWrite hooks return.
+// An empty response allows the call as it is.
+type hookResponse struct {
+	// Allow is false to reject the call.
+	Allow *bool `json:"allow"`
+
+	// Message is the reason of the rejection, which is passed to the AI.
+	Message string `json:"message"`
+
+	// Arguments replaces the arguments of the tool if it is set.
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// runBeforeWriteHooks calls the beforeWrite hooks, and returns the arguments that may be replaced by the hooks.
+// The call is rejected if a hook fails, so that the rules are never skipped.
+func (h *KintoneHandlers) runBeforeWriteHooks(ctx context.Context, params ToolsCallRequest) (json.RawMessage, error) {
+	for _, hook := range h.policy().Config.Hooks.BeforeWrite {
+		if !hook.matches(params.Name) {
+			continue
+		}
+
+		out, err := hook.call(ctx, h.hookHTTPClient(), h.newHookRequest(ctx, "beforeWrite", params))
+		if err != nil {
+			serverLog.WarnContext(ctx, "Failed to run a hook", "hook", hook.String(), "tool", params.Name, "error", err)
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Tool %s is rejected because the hook failed: %v", params.Name, err),
+			}
+		}
+
+		var res hookResponse
+		if len(out) > 0 {
+			if err := json.Unmarshal(out, &res); err != nil {
+				serverLog.WarnContext(ctx, "Failed to parse the response of a hook", "hook", hook.String(), "tool", params.Name, "error", err)
+				return nil, jsonrpc2.Error{
+					Code:    jsonrpc2.InternalErrorCode,
+					Message: fmt.Sprintf("Tool %s is rejected because the hook returned an invalid response: %v", params.Name, err),
+				}
+			}
+		}
+
+		if res.Allow != nil && !*res.Allow {
+			serverLog.InfoContext(ctx, "Tool call was rejected by a hook", "hook", hook.String(), "tool", params.Name, "message", res.Message)
+			msg := fmt.Sprintf("Tool %s is rejected by the server's rule", params.Name)
+			if res.Message != "" {
+				msg = fmt.Sprintf("Tool %s is rejected by the server's rule: %s", params.Name, res.Message)
+			}
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: msg,
+			}
+		}
+		if len(res.Arguments) > 0 && string(res.Arguments) != "null" {
+			if !bytes.HasPrefix(res.Arguments, []byte("{")) {
+				return nil, jsonrpc2.Error{
+					Code:    jsonrpc2.InternalErrorCode,
+					Message: fmt.Sprintf("Tool %s is rejected because the hook returned arguments that are not an object", params.Name),
+				}
+			}
+			serverLog.InfoContext(ctx, "Arguments were replaced by a hook", "hook", hook.String(), "tool", params.Name)
+			params.Arguments = res.Arguments
+		}
+	}
+	return params.Arguments, nil
+}
+
+// runAfterHooks calls the after hooks in background, so that the hooks don't delay the response.
+// Shutdown waits for them, so that the hooks such as notifications are not lost when the server exits.
+func (h *KintoneHandlers) runAfterHooks(ctx context.Context, params ToolsCallRequest, content []Content, err error) {
+	var hooks []Hook
+	for _, hook := range h.policy().Config.Hooks.After {
+		if hook.matches(params.Name) {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	req := h.newHookRequest(ctx, "after", params)
+	req.Result = content
+	if err != nil {
+		req.Error = err.Error()
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	h.afterHooks.Add(1)
+	go func() {
+		defer h.afterHooks.Done()
+		for _, hook := range hooks {
+			if _, err := hook.call(ctx, h.hookHTTPClient(), req); err != nil {
+				serverLog.WarnContext(ctx, "Failed to run a hook", "hook", hook.String(), "tool", params.Name, "error", err)
+			}
+		}
+	}()
+}
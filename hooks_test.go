@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHookUsesProxy(t *testing.T) {
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/after" {
+			proxied.Store(r.URL.String())
+		}
+	}))
+	t.Cleanup(proxy.Close)
+
+	h := newTestHandlers(t, map[string]string{"KINTONE_PROXY": proxy.URL})
+	if _, err := (Hook{URL: "http://hook.example.com/after"}).call(context.Background(), h.hookHTTPClient(), JsonMap{}); err != nil {
+		t.Fatalf("failed to call the hook: %v", err)
+	}
+	if got, _ := proxied.Load().(string); got != "http://hook.example.com/after" {
+		t.Errorf("expected the hook to be called via the proxy but got %q", got)
+	}
+}
+
+func TestHookDoesNotFollowRedirects(t *testing.T) {
+	var redirected atomic.Bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected.Store(true)
+	}))
+	t.Cleanup(target.Close)
+	hook := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(hook.Close)
+
+	h := newTestHandlers(t, nil)
+	_, err := (Hook{URL: hook.URL}).call(context.Background(), h.hookHTTPClient(), JsonMap{})
+	if err == nil || !strings.Contains(err.Error(), "307") {
+		t.Errorf("expected the redirect to be an error but got %v", err)
+	}
+	if redirected.Load() {
+		t.Error("the redirect is followed")
+	}
+}
+
+func TestShutdownWaitsForAfterHooks(t *testing.T) {
+	var called atomic.Bool
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		called.Store(true)
+	}))
+	t.Cleanup(hook.Close)
+
+	config := writeTestConfig(t, "hooks:\n  after:\n    - url: "+hook.URL+"\n")
+	h := newTestHandlers(t, map[string]string{"KINTONE_CONFIG_FILE": config})
+	callTestTool(t, h, "listApps", JsonMap{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h.Shutdown(ctx)
+	if !called.Load() {
+		t.Error("the after hook is not completed before the shutdown")
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+const (
+	// httpSessionTimeout is how long an idle session is kept.
+	httpSessionTimeout = 1 * time.Hour
+
+	// maxHTTPRequestSize is the maximum size of a request body.
+	maxHTTPRequestSize = 10 * 1024 * 1024
+)
+
+// HTTPTransport is a http.Handler that implements the Streamable HTTP transport of MCP.
+type HTTPTransport struct {
+	server *jsonrpc2.Server
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// NewHTTPTransport creates a new HTTPTransport that handles requests with server.
+func NewHTTPTransport(server *jsonrpc2.Server) *HTTPTransport {
+	return &HTTPTransport{
+		server:   server,
+		sessions: make(map[string]*httpSession),
+	}
+}
+
+// httpSession is a Session that is connected via HTTP.
+type httpSession struct {
+	*Session
+	stream *sseStream
+
+	lastUsed time.Time
+}
+
+// sseStream is an io.Writer that sends messages to the stream opened by the GET request.
+// Messages are discarded if the stream is not opened.
+type sseStream struct {
+	mu sync.Mutex
+	w  *sseWriter
+}
+
+func (s *sseStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.w == nil {
+		return len(p), nil
+	}
+	if err := s.w.WriteMessage(bytes.TrimSpace(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sseWriter writes messages in the Server-Sent Events format.
+type sseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return &sseWriter{w: w}
+}
+
+// WriteMessage writes a JSON-RPC message as an event.
+func (s *sseWriter) WriteMessage(msg []byte) error {
+	return s.WriteEvent("message", msg)
+}
+
+// Send marshals v and writes it as an event.
+func (s *sseWriter) Send(v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.WriteMessage(bs)
+}
+
+func (t *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isAllowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r)
+	case http.MethodGet:
+		t.handleGet(w, r)
+	case http.MethodDelete:
+		t.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// isAllowedOrigin checks the Origin header to prevent DNS rebinding attacks.
+// Requests without Origin header are allowed because they are not sent by browsers.
+func isAllowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// newSessionID generates a cryptographically secure session ID.
+func newSessionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate session ID: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// lookupSession returns the session specified by the Mcp-Session-Id header.
+// It writes an error response and returns nil if the session is not found.
+func (t *HTTPTransport) lookupSession(w http.ResponseWriter, r *http.Request) *httpSession {
+	id := r.Header.Get("Mcp-Session-Id")
+	if id == "" {
+		http.Error(w, "Mcp-Session-Id header is required", http.StatusBadRequest)
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[id]
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil
+	}
+	s.lastUsed = time.Now()
+	return s
+}
+
+// newSession creates a new session, and removes sessions that are not used for a while.
+func (t *HTTPTransport) newSession() (string, *httpSession) {
+	stream := &sseStream{}
+	s := &httpSession{
+		Session:  NewSession(t.server, stream),
+		stream:   stream,
+		lastUsed: time.Now(),
+	}
+	id := newSessionID()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k, v := range t.sessions {
+		if time.Since(v.lastUsed) > httpSessionTimeout {
+			delete(t.sessions, k)
+			v.Close()
+		}
+	}
+	t.sessions[id] = s
+
+	return id, s
+}
+
+func (t *HTTPTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPRequestSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	} else if len(body) > maxHTTPRequestSize {
+		http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var messages []rpcMessage
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &messages)
+	} else {
+		var msg rpcMessage
+		err = json.Unmarshal(trimmed, &msg)
+		messages = []rpcMessage{msg}
+	}
+	if err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, rpcResponse{Jsonrpc: "2.0", Error: &jsonrpc2.ErrParseError, ID: jsonrpc2.NullID()})
+		return
+	}
+
+	var s *httpSession
+	if len(messages) == 1 && messages[0].Method == "initialize" {
+		var id string
+		id, s = t.newSession()
+		s.credentials = credentialsFromHeader(r.Header)
+		s.user = authenticatedUser(r.Context())
+		w.Header().Set("Mcp-Session-Id", id)
+	} else if s = t.lookupSession(w, r); s == nil {
+		return
+	}
+
+	hasRequest := false
+	for _, msg := range messages {
+		if msg.Method != "" && msg.ID != nil {
+			hasRequest = true
+		}
+	}
+
+	ctx := s.context(extractTraceHeaders(r.Context(), r.Header))
+
+	if !hasRequest {
+		// Notifications and responses don't need a response body.
+		s.handleRaw(ctx, body)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		// The client can't receive messages in the stream, so send notifications via the stream of GET request instead.
+		writeJSONResponse(w, http.StatusOK, s.handleRaw(ctx, body))
+		return
+	}
+
+	sse := newSSEWriter(w)
+	ctx = withMessageSink(ctx, sse.Send)
+	if res := s.handleRaw(ctx, body); res != nil {
+		sse.Send(res)
+	}
+}
+
+func (t *HTTPTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "Accept header must include text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	s := t.lookupSession(w, r)
+	if s == nil {
+		return
+	}
+
+	s.stream.mu.Lock()
+	if s.stream.w != nil {
+		s.stream.mu.Unlock()
+		http.Error(w, "Stream is already opened", http.StatusConflict)
+		return
+	}
+	sse := newSSEWriter(w)
+	s.stream.w = sse
+	s.stream.mu.Unlock()
+
+	select {
+	case <-r.Context().Done():
+	case <-shuttingDown(r.Context()):
+	}
+
+	s.stream.mu.Lock()
+	if s.stream.w == sse {
+		s.stream.w = nil
+	}
+	s.stream.mu.Unlock()
+}
+
+func (t *HTTPTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	s := t.lookupSession(w, r)
+	if s == nil {
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.sessions, r.Header.Get("Mcp-Session-Id"))
+	t.mu.Unlock()
+	s.Close()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ServeHTTP starts the MCP server on addr.
+// It serves the Streamable HTTP transport on /mcp, the legacy HTTP+SSE transport on /sse and /messages for older clients, and WebSocket on /ws.
+// If tlsConfig is not nil, it serves HTTPS instead of HTTP.
+// If authTokens is not empty, clients have to send one of them as a bearer token.
+// The health check endpoints, /healthz and /readyz, are served by health without authentication.
+func ServeHTTP(ctx context.Context, server *jsonrpc2.Server, addr string, tlsConfig *tls.Config, authTokens []authToken, health http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", NewHTTPTransport(server))
+
+	sse := NewSSETransport(server)
+	mux.HandleFunc("/sse", sse.HandleStream)
+	mux.HandleFunc("/messages", sse.HandleMessage)
+
+	mux.Handle("/ws", NewWebSocketTransport(server))
+
+	root := http.NewServeMux()
+	root.Handle("/", requireBearerToken(authTokens, mux))
+	root.Handle("/healthz", health)
+	root.Handle("/readyz", health)
+
+	lifecycle := &serverLifecycle{done: make(chan struct{})}
+	baseCtx, cancel := context.WithCancel(context.WithValue(context.Background(), lifecycleKey{}, lifecycle))
+	defer cancel()
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           root,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		TLSConfig:         tlsConfig,
+	}
+
+	scheme, wsScheme := "http", "ws"
+	if tlsConfig != nil {
+		scheme, wsScheme = "https", "wss"
+	}
+	serverLog.Info("kintone server is running",
+		"url", fmt.Sprintf("%s://%s/mcp", scheme, addr),
+		"sse", fmt.Sprintf("%s://%s/sse", scheme, addr),
+		"websocket", fmt.Sprintf("%s://%s/ws", wsScheme, addr),
+	)
+
+	if len(authTokens) == 0 {
+		serverLog.Warn(fmt.Sprintf("KINTONE_MCP_AUTH_TOKENS is not set. Anyone who can reach %s can access your kintone data.", addr))
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			errCh <- srv.ListenAndServeTLS("", "")
+		} else {
+			errCh <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting new connections and close streams, then wait for in-flight requests to complete.
+	serverLog.Info("Shutting down kintone server, waiting for in-flight requests")
+	close(lifecycle.done)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err == nil {
+		connsDone := make(chan struct{})
+		go func() {
+			lifecycle.conns.Wait()
+			close(connsDone)
+		}()
+		select {
+		case <-connsDone:
+		case <-shutdownCtx.Done():
+			err = shutdownCtx.Err()
+		}
+	}
+	if err != nil {
+		cancel()
+		srv.Close()
+		return fmt.Errorf("failed to shutdown gracefully: %w", err)
+	}
+	return nil
+}
+
+// authToken is a bearer token that clients send to access the server.
+type authToken struct {
+	Token string
+
+	// User is the kintone user who is authenticated by the token, whose field permissions are applied by KINTONE_FIELD_ACL.
+	// It is empty if the token is not bound to a user.
+	User string
+}
+
+// parseAuthTokens parses KINTONE_MCP_AUTH_TOKENS, such as "token" or "alice:token" that binds the token to the kintone user "alice".
+func parseAuthTokens(ss []string) []authToken {
+	tokens := make([]authToken, 0, len(ss))
+	for _, s := range ss {
+		if user, token, ok := strings.Cut(s, ":"); ok {
+			tokens = append(tokens, authToken{Token: strings.TrimSpace(token), User: strings.TrimSpace(user)})
+		} else {
+			tokens = append(tokens, authToken{Token: s})
+		}
+	}
+	return tokens
+}
+
+// matchAuthToken finds the token that is the same as given.
+func matchAuthToken(tokens []authToken, given string) (authToken, bool) {
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token.Token)) == 1 {
+			return token, true
+		}
+	}
+	return authToken{}, false
+}
+
+type authUserKey struct{}
+
+// authenticatedUser returns the kintone user who is bound to the bearer token of the request, or an empty string if unknown.
+func authenticatedUser(ctx context.Context) string {
+	user, _ := ctx.Value(authUserKey{}).(string)
+	return user
+}
+
+// requireBearerToken wraps the handler to reject requests that don't have one of the tokens in the Authorization header.
+// The user who is bound to the token is passed to the handler via the context.
+// It does nothing if tokens is empty.
+func requireBearerToken(tokens []authToken, next http.Handler) http.Handler {
+	if len(tokens) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok {
+			if token, ok := matchAuthToken(tokens, given); ok {
+				if token.User != "" {
+					r = r.WithContext(context.WithValue(r.Context(), authUserKey{}, token.User))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kintone"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
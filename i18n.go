@@ -0,0 +1,494 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// defaultLang is the language of the messages in the source code.
+const defaultLang = "en"
+
+// supportedLangs is the languages that can be set to KINTONE_LANG.
+var supportedLangs = []string{"en", "ja"}
+
+// messageCatalogs is the translations of the messages, keyed by the English text.
+// A key that contains verbs such as %s is a format, and it also matches the messages that are generated from the format.
+// The arguments are passed to the translation as strings, so the translation should use %s or %[n]s for all of them.
+var messageCatalogs = map[string]map[string]string{
+	"ja": messagesJa,
+}
+
+var messagesJa = map[string]string{
+	// Instructions.
+	defaultInstructions:                                          "kintoneは業務データを保存・管理するためのデータベースサービスです。このサーバーを使うとkintoneを操作できます。",
+	"The following kintone apps are available:\n":                "以下のkintoneアプリが利用できます:\n",
+	"\n...and more apps. Use 'listApps' tool to see all apps.\n": "\n...他にもアプリがあります。すべてのアプリを見るには 'listApps' ツールを使ってください。\n",
+	"Fields:\n": "フィールド:\n",
+	"- ...and %d more fields. Use 'readAppInfo' tool to see all fields.\n": "- ...他に%sフィールドがあります。すべてのフィールドを見るには 'readAppInfo' ツールを使ってください。\n",
+
+	// Tools.
+	"List all applications made on kintone. Response includes the app ID, name, and description.":                                                                                                                                                                                                                                                                             "kintoneに作成されたアプリの一覧を取得します。レスポンスにはアプリID、名前、説明が含まれます。",
+	"Get information about the specified app. Response includes the app ID, name, description, and schema.":                                                                                                                                                                                                                                                                   "指定したアプリの情報を取得します。レスポンスにはアプリID、名前、説明、スキーマが含まれます。",
+	"Create a new record in the specified app. Before use this tool, you better to know the schema of the app by using 'readAppInfo' tool.":                                                                                                                                                                                                                                   "指定したアプリに新しいレコードを作成します。このツールを使う前に、'readAppInfo' ツールでアプリのスキーマを確認してください。",
+	"Read records from the specified app. Response includes the record ID and record data, and 'hasMore' and 'nextOffset' to read the next page. Before search records using this tool, you better to know the schema of the app by using 'readAppInfo' tool.":                                                                                                                "指定したアプリのレコードを取得します。レスポンスにはレコードIDとレコードのデータ、次のページを取得するための 'hasMore' と 'nextOffset' が含まれます。このツールでレコードを検索する前に、'readAppInfo' ツールでアプリのスキーマを確認してください。",
+	"Update the specified record in the specified app. Before use this tool, you better to know the schema of the app by using 'readAppInfo' tool and check which record to update by using 'readRecords' tool.":                                                                                                                                                              "指定したアプリの指定したレコードを更新します。このツールを使う前に、'readAppInfo' ツールでアプリのスキーマを確認し、'readRecords' ツールで更新するレコードを確認してください。",
+	"Delete the specified record in the specified app. Before use this tool, you should check which record to delete by using 'readRecords' tool. This operation is unrecoverable, so make sure that the user really want to delete the record.":                                                                                                                              "指定したアプリの指定したレコードを削除します。このツールを使う前に、'readRecords' ツールで削除するレコードを確認してください。この操作は元に戻せないため、ユーザーが本当にレコードを削除したいのかを必ず確認してください。",
+	"Download the specified attachment file. Before use this tool, you should check file key by using 'readRecords' tool. Response includes the saved file path, the original file name, the content type, and the SHA-256 hash of the file.":                                                                                                                                 "指定した添付ファイルをダウンロードします。このツールを使う前に、'readRecords' ツールでファイルキーを確認してください。レスポンスには保存先のパス、元のファイル名、Content-Type、ファイルのSHA-256ハッシュが含まれます。",
+	"Download all attachment files on the records that match the query in the specified app. The files can be saved separately or bundled into a single zip file with a manifest.json that maps each file to its record and field. Response includes the list of downloaded files.":                                                                                           "指定したアプリでクエリに一致するレコードの添付ファイルをすべてダウンロードします。ファイルは個別に保存するか、各ファイルとレコード・フィールドの対応を記したmanifest.jsonと一緒に1つのzipファイルにまとめられます。レスポンスにはダウンロードしたファイルの一覧が含まれます。",
+	"Read the text content of the specified attachment file. Supported file types are PDF, Word (.docx), Excel (.xlsx), PowerPoint (.pptx), and plain text files such as .txt, .csv, or .md. Use this tool instead of 'downloadAttachmentFile' when you want to read the content of a document. Before use this tool, you should check file key by using 'readRecords' tool.": "指定した添付ファイルのテキストを読み取ります。対応しているファイル形式は、PDF、Word (.docx)、Excel (.xlsx)、PowerPoint (.pptx)、および .txt、.csv、.md などのテキストファイルです。文書の内容を読みたい場合は 'downloadAttachmentFile' ではなくこのツールを使ってください。このツールを使う前に、'readRecords' ツールでファイルキーを確認してください。",
+	"Upload a new attachment file to the specified app. The response includes a file key that you can use for creating or updating records. You can specify the file by path or content.":                                                                                                                                                                                     "指定したアプリに新しい添付ファイルをアップロードします。レスポンスにはレコードの作成や更新に使えるファイルキーが含まれます。ファイルはパスか内容で指定できます。",
+	"Read comments on the specified record in the specified app.":                                                                                                                                                                                                   "指定したアプリの指定したレコードのコメントを取得します。",
+	"Create a new comment on the specified record in the specified app.":                                                                                                                                                                                            "指定したアプリの指定したレコードに新しいコメントを投稿します。",
+	"Update the assignee of process management of the specified record in the specified app.":                                                                                                                                                                       "指定したアプリの指定したレコードのプロセス管理の作業者を更新します。",
+	"Execute the specified action of process management of the specified record in the specified app.":                                                                                                                                                              "指定したアプリの指定したレコードでプロセス管理のアクションを実行します。",
+	"List the kintone environments that this server can access, such as production and sandbox. Each tool accepts the 'profile' argument to select the environment.":                                                                                                "本番環境やサンドボックス環境など、このサーバーがアクセスできるkintone環境の一覧を取得します。各ツールの 'profile' 引数で環境を選択できます。",
+	"Check the connection to kintone. It verifies that the base URL resolves, the credentials work, and the allowed apps are reachable. Use this tool when other tools fail with connection or permission errors.":                                                  "kintoneへの接続を確認します。ベースURLの名前解決、認証情報、許可されたアプリへのアクセスを検証します。他のツールが接続や権限のエラーで失敗する場合に使ってください。",
+	"Clear the cached app list and app settings such as fields and process management, and fetch them again on the next use. Use this tool after the settings of an app are changed.":                                                                               "キャッシュされたアプリ一覧と、フィールドやプロセス管理などのアプリ設定を破棄し、次に使うときに再取得します。アプリの設定が変更された後に使ってください。",
+	"If true, the views of the app are included, such as the filter conditions and the displayed fields. Default is false.":                                                                                                                                         "trueの場合、絞り込み条件や表示するフィールドなど、アプリの一覧の設定を含めます。デフォルトはfalseです。",
+	"If true, the general settings of the app are included, such as the icon, the theme, and the title field. Default is false.":                                                                                                                                    "trueの場合、アイコン、デザインテーマ、レコードタイトルなど、アプリの一般設定を含めます。デフォルトはfalseです。",
+	"Report the statistics of this server and the current session: uptime, the number of calls and errors of each tool, the hit rate of the cache, and the number of requests to kintone. Use this tool to see how many API requests the previous operations cost.": "このサーバーと現在のセッションの統計情報を報告します: 稼働時間、各ツールの呼び出し回数とエラー数、キャッシュのヒット率、kintoneへのリクエスト数。これまでの操作でAPIリクエストがいくつ使われたかを確認するために使ってください。",
+	"The app ID to refresh. If omitted, the app list and the settings of all apps are refreshed.":                                                                                                                                                                   "再取得するアプリのID。省略した場合は、アプリ一覧とすべてのアプリの設定を再取得します。",
+	"Create a new record in the kintone app %s. The arguments are the values of the fields.":                                                                                                                                                                        "kintoneアプリ %s に新しいレコードを作成します。引数はフィールドの値です。",
+	"Update the specified record in the kintone app %s. The arguments are the record ID and the values of the fields to change.":                                                                                                                                    "kintoneアプリ %s の指定したレコードを更新します。引数はレコードIDと変更するフィールドの値です。",
+	" This server requires confirmation to delete: the first call returns a preview of the record and a confirmationToken, and the record is deleted only when called again with the token.":                                                                        " このサーバーでは削除に確認が必要です。1回目の呼び出しではレコードのプレビューとconfirmationTokenが返され、そのトークンを付けて再度呼び出したときにだけレコードが削除されます。",
+	" This tool can only be used for the following app IDs: %s.":                                                                                                                                                                                                    " このツールは次のアプリIDでのみ使用できます: %s。",
+	" The available templates are: %s.": " 使用できるテンプレート: %s。",
+
+	// Tool titles.
+	"List kintone apps":            "kintoneアプリの一覧",
+	"Read kintone app information": "kintoneアプリの情報を取得",
+	"Create a kintone record":      "kintoneレコードを作成",
+	"Create a new record from a template that the server defines for the app, such as a ticket or an expense report. The template presets some fields and tells which fields to fill in. Call this tool without 'template' to list the available templates with their inputs.": "チケットや経費精算など、サーバーがアプリに定義したテンプレートから新しいレコードを作成します。テンプレートは一部のフィールドをあらかじめ設定し、入力すべきフィールドを示します。'template'を指定せずにこのツールを呼ぶと、使用できるテンプレートとその入力項目を一覧できます。",
+	"Create a kintone record from a template": "テンプレートからkintoneレコードを作成",
+	"The app ID to create a record in. Required if 'template' is specified. If 'template' is omitted, only the templates of this app are listed.":                                                                                                                                                  "レコードを作成するアプリのID。'template'を指定する場合は必須です。'template'を省略した場合は、このアプリのテンプレートだけを一覧します。",
+	"The name of the template. If omitted, the available templates are listed instead of creating a record.":                                                                                                                                                                                       "テンプレートの名前。省略すると、レコードを作成する代わりに使用できるテンプレートを一覧します。",
+	"The values of the fields in the simple format, such as {\"field1\": \"value1\"}. The fields in 'inputs' of the template are required, and the other values overwrite the preset values of the template.":                                                                                      "{\"field1\": \"value1\"}のようなsimple形式のフィールドの値。テンプレートの'inputs'のフィールドは必須で、それ以外の値はテンプレートで設定された値を上書きします。",
+	"Create records in the specified app from a CSV or JSON Lines file. The columns are mapped to the fields by the field codes or the labels, and all rows are validated before creating records. The response includes the errors of each row. Use 'dryRun' to check the file before importing.": "CSVまたはJSON Linesファイルから、指定したアプリにレコードを作成します。列はフィールドコードまたはフィールド名でフィールドに対応付けられ、レコードを作成する前にすべての行が検証されます。レスポンスには行ごとのエラーが含まれます。インポートする前にファイルを確認するには'dryRun'を使ってください。",
+	"Import kintone records from a file": "ファイルからkintoneレコードをインポート",
+	"Back up the records and the attachment files of the specified app into a zip file or a directory. The backup can be restored by using 'restoreAppData' tool. The response includes the path of the backup.": "指定したアプリのレコードと添付ファイルを、zipファイルまたはディレクトリにバックアップします。バックアップは'restoreAppData'ツールで復元できます。レスポンスにはバックアップのパスが含まれます。",
+	"Back up a kintone app":  "kintoneアプリをバックアップ",
+	"The app ID to back up.": "バックアップするアプリのID。",
+	"The query to filter records to back up. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed. Default is all records.":                                                                                "バックアップするレコードを絞り込むクエリ。クエリの形式はkintoneのクエリ形式と同じですが、'limit'と'offset'は使えません。デフォルトはすべてのレコードです。",
+	"If true, the backup is saved as a directory instead of a zip file. Default is false.":                                                                                                                                                         "trueの場合、バックアップをzipファイルではなくディレクトリとして保存します。デフォルトはfalseです。",
+	"If true, the attachment files are not included in the backup. Default is false.":                                                                                                                                                              "trueの場合、添付ファイルをバックアップに含めません。デフォルトはfalseです。",
+	"Restore the records and the attachment files from a backup that 'backupAppData' tool created, by creating new records in the specified app. The attachment files are uploaded again. The record IDs and the record numbers are not restored.": "'backupAppData'ツールで作成したバックアップから、指定したアプリに新しいレコードを作成してレコードと添付ファイルを復元します。添付ファイルは再度アップロードされます。レコードIDとレコード番号は復元されません。",
+	"Restore a kintone app from a backup": "バックアップからkintoneアプリを復元",
+	"The app ID to restore records into. It can be different from the app of the backup if the app has the same fields. The records are created as new records, so restoring into the same app duplicates the records that still exist.": "レコードを復元するアプリのID。同じフィールドを持つアプリであれば、バックアップ元と異なるアプリでも構いません。レコードは新しいレコードとして作成されるため、同じアプリに復元すると残っているレコードは重複します。",
+	"The path of the backup that 'backupAppData' tool created, which is a zip file or a directory.":                                                                                                                                      "'backupAppData'ツールで作成したバックアップのパス。zipファイルまたはディレクトリです。",
+	"The app ID to import records into.": "レコードをインポートするアプリのID。",
+	"The path of the CSV or JSON Lines file to import. The CSV file has to have a header row. Each line of JSON Lines file is a record in the simple format such as {\"field1\": \"value1\"}.": "インポートするCSVまたはJSON Linesファイルのパス。CSVファイルにはヘッダー行が必要です。JSON Linesファイルの各行は{\"field1\": \"value1\"}のようなsimple形式のレコードです。",
+	"The format of the file. Default is determined by the extension of the file.":                                                                                                              "ファイルの形式。デフォルトではファイルの拡張子から判断します。",
+	"The map from the column names in the file to the field codes. Default is to match the column names with the field codes or the field labels of the app.":                                  "ファイルの列名からフィールドコードへの対応表。デフォルトでは列名をアプリのフィールドコードまたはフィールド名と照合します。",
+	"If true, only validates the rows without creating records. Default is false.":                                                                                                             "trueの場合、レコードを作成せずに行の検証だけを行います。デフォルトはfalseです。",
+	"Read kintone records": "kintoneレコードを取得",
+	"Export records in the specified app into an Excel workbook. All records that match the query are exported, with dates and numbers as typed cells. The response includes the path of the saved file. Use this tool when the user wants a spreadsheet, rather than reading many records by 'readRecords' tool.": "指定したアプリのレコードをExcelブックにエクスポートします。クエリに一致するすべてのレコードが、日付や数値を型付きのセルとしてエクスポートされます。レスポンスには保存したファイルのパスが含まれます。ユーザーが表計算ファイルを求めている場合は、'readRecords'ツールで多くのレコードを読む代わりにこのツールを使ってください。",
+	"Export kintone records to Excel":    "kintoneレコードをExcelにエクスポート",
+	"The app ID to export records from.": "レコードをエクスポートするアプリのID。",
+	"The query to filter records. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed. Default is all records.":                                                                                                                                                              "レコードを絞り込むクエリ。クエリの形式はkintoneのクエリ形式と同じですが、'limit'と'offset'は使えません。デフォルトはすべてのレコードです。",
+	"The field codes to export, in the order of the columns. Default is all fields, or the fields set by the server for the app.":                                                                                                                                                                                     "エクスポートするフィールドコードを列の順に指定します。デフォルトはすべてのフィールド、またはサーバーでアプリに設定されたフィールドです。",
+	"If true, the rows of each table are exported into a separate sheet with the record ID. Otherwise, the tables are exported as JSON in a cell. Default is false.":                                                                                                                                                  "trueの場合、各テーブルの行をレコードIDとともに別のシートにエクスポートします。そうでなければ、テーブルはセル内のJSONとしてエクスポートされます。デフォルトはfalseです。",
+	"Compute the statistics of a number field over the records that match the query: the count, sum, minimum, maximum, average, and percentiles. All records are aggregated on the server, so use this tool instead of reading many records by 'readRecords' tool to answer questions such as the average deal size.": "クエリに一致するレコードについて、数値フィールドの件数、合計、最小値、最大値、平均値、パーセンタイルを計算します。全てのレコードはサーバー上で集計されるため、平均受注額のような質問に答えるときは'readRecords'ツールで多くのレコードを読み込む代わりにこのツールを使用してください。",
+	"Compute statistics of a kintone field": "kintoneフィールドの統計情報を計算",
+	"The app ID to aggregate records in.":   "レコードを集計するアプリのID。",
+	"The field code of the number or calculated field to aggregate. A field in a table is aggregated over all rows.":                                                                                                                                                                "集計する数値フィールドまたは計算フィールドのフィールドコード。テーブル内のフィールドは全ての行について集計されます。",
+	"Count records, or sum a number field, for each day, week, or month of a date field, optionally split into groups by another field such as a status. The response is a compact time series that is suitable to describe trends or to draw a chart, like the graphs of kintone.": "日付フィールドの日、週、月ごとにレコードを数えるか、数値フィールドを合計します。ステータスなどの別のフィールドでグループに分けることもできます。レスポンスはkintoneのグラフのようなコンパクトな時系列データで、傾向の説明やグラフの描画に適しています。",
+	"Aggregate kintone records over time": "kintoneレコードを期間ごとに集計",
+	"The field code of the date or datetime field to bucket records by, such as the created time.":              "レコードを期間に分けるための日付フィールドまたは日時フィールドのフィールドコード。作成日時など。",
+	"The size of the buckets. Weeks start on Monday. Default is 'month'.":                                       "期間の単位。週は月曜日から始まります。デフォルトは'month'です。",
+	"The field code to split each bucket into groups, such as a drop-down or a status. Default is no grouping.": "各期間をグループに分けるためのフィールドコード。ドロップダウンやステータスなど。デフォルトではグループに分けません。",
+	"The field code of the number or calculated field to sum in each bucket. Default is to count records.":      "各期間で合計する数値フィールドまたは計算フィールドのフィールドコード。デフォルトではレコードの数を数えます。",
+	"Explain what records the query matches in the specified app: each condition with the label and type of the field, the sort order, the number of matched records, and warnings such as an operator that can't be used for the field. Use this tool to check the query before updating or deleting the records it matches.": "指定したアプリでクエリがどのレコードに一致するかを説明します。フィールドのラベルと種類を含む各条件、並び順、一致するレコードの数、フィールドに使用できない演算子などの警告が含まれます。クエリに一致するレコードを更新・削除する前に、このツールでクエリを確認してください。",
+	"Explain a kintone query":           "kintoneのクエリを説明",
+	"The app ID that the query is for.": "クエリの対象のアプリのID。",
+	"The query to explain. Query format is the same as kintone's query format.": "説明するクエリ。クエリの形式はkintoneのクエリ形式と同じです。",
+	"Update a kintone record":           "kintoneレコードを更新",
+	"Delete a kintone records":          "kintoneレコードを削除",
+	"Download a file from kintone":      "kintoneからファイルをダウンロード",
+	"Download files on kintone records": "kintoneレコードのファイルをダウンロード",
+	"Read text in a file on kintone":    "kintoneのファイルのテキストを読み取り",
+	"Upload a file to kintone":          "kintoneにファイルをアップロード",
+	"Read kintone record's comments":    "kintoneレコードのコメントを取得",
+	"Post a comment to kintone record":  "kintoneレコードにコメントを投稿",
+	"Post the same comment on every record that matches the query in the specified app, such as asking for a status update on all overdue tasks. The text can include the values of the fields of each record, and the mentions can refer a user field of each record. If the query matches more than 10 records, the first call returns a preview and a confirmationToken, and the comments are posted only when called again with the token.": "指定したアプリでクエリに一致する全てのレコードに同じコメントを投稿します。期限切れの全てのタスクに進捗の報告を依頼する場合などに使用します。本文には各レコードのフィールドの値を含めることができ、メンションでは各レコードのユーザーフィールドを参照できます。クエリが10件より多いレコードに一致する場合、最初の呼び出しではプレビューとconfirmationTokenが返され、そのトークンを付けて再度呼び出したときにのみコメントが投稿されます。",
+	"Post a comment to kintone records": "kintoneレコードにまとめてコメントを投稿",
+	"The app ID to create comments in.": "コメントを投稿するアプリのID。",
+	"The query to select the records to comment on. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed.":                                           "コメントを投稿するレコードを選ぶクエリ。クエリの形式はkintoneのクエリ形式と同じですが、'limit'と'offset'は使用できません。",
+	"The text of the comment. Placeholders such as {{title}} are replaced with the values of the fields of each record.":                                                                     "コメントの本文。{{title}}のようなプレースホルダーは各レコードのフィールドの値に置き換えられます。",
+	"The code or the display name of the mention target, or a placeholder of a user, organization, or group field such as {{assignee}} to mention the entities in the field of each record.": "メンション先のコードまたは表示名。{{assignee}}のようにユーザー・組織・グループフィールドのプレースホルダーを指定すると、各レコードのそのフィールドの値にメンションします。",
+	"The type of the mention target. Default is 'USER'. Ignored for placeholders.":                                                                                                           "メンション先の種類。デフォルトは'USER'です。プレースホルダーの場合は無視されます。",
+	"The mention targets of the comments.": "コメントのメンション先。",
+	"The confirmation token that is returned by the previous call of this tool. Only required if the query matches more than 10 records.":                                                                             "前回このツールを呼び出したときに返された確認用トークン。クエリが10件より多いレコードに一致する場合にのみ必要です。",
+	"Call a kintone REST API that other tools don't cover, such as '/k/v1/app/acl.json'. Only the endpoints allowed by the server settings can be called. Use the other tools instead if they can do the same thing.": "他のツールで扱えないkintone REST APIを'/k/v1/app/acl.json'のように呼び出します。サーバーの設定で許可されたエンドポイントのみ呼び出せます。同じことができる場合は他のツールを使ってください。",
+	"Call kintone REST API":                                           "kintone REST APIを呼び出し",
+	"The HTTP method to call the API.":                                "APIを呼び出すHTTPメソッド。",
+	"The path of the kintone REST API, such as '/k/v1/app/acl.json'.": "'/k/v1/app/acl.json'のようなkintone REST APIのパス。",
+	"The parameters of the API. They are sent as the JSON body, also for GET requests. For example, {\"app\": \"1\"}.": "APIのパラメータ。GETリクエストでもJSONのボディとして送信されます。例えば、{\"app\": \"1\"}。",
+	"Start watching the records in the specified app, such as new inquiries, while this session lasts. The records are checked periodically, and the added and updated records are kept until 'getWatchUpdates' tool is called. Use this tool when the user asks to be told about new or updated records during the work.": "新しい問い合わせなど、指定したアプリのレコードの監視をこのセッションの間だけ開始します。レコードは定期的に確認され、追加・更新されたレコードは'getWatchUpdates'ツールを呼び出すまで保持されます。作業中に新しいレコードや更新されたレコードを知らせてほしいと頼まれたときに使ってください。",
+	"Watch kintone records":           "kintoneレコードを監視",
+	"The app ID to watch records in.": "レコードを監視するアプリのID。",
+	"The query to filter the records to watch, such as 'status in (\"New\")'. Query format is the same as kintone's query format, but 'order by', 'limit', and 'offset' are not allowed. Default is all records.":                            "監視するレコードを絞り込むクエリ。例えば'status in (\"New\")'。クエリの形式はkintoneのクエリ形式と同じですが、'order by'、'limit'、'offset'は使えません。デフォルトは全てのレコードです。",
+	"How often to check the records, such as '30s' or '5m'. Default is '1m', minimum is '10s'.":                                                                                                                                              "レコードを確認する間隔。例えば'30s'や'5m'。デフォルトは'1m'、最小は'10s'です。",
+	"The format of the records in the updates. 'kintone' is the format of kintone REST API that includes the type of each field, and 'simple' is the plain values such as {\"field1\": \"value1\"} that saves tokens. Default is 'kintone'.": "更新されたレコードの形式。'kintone'は各フィールドの型を含むkintone REST APIの形式で、'simple'は{\"field1\": \"value1\"}のような値のみの形式でトークンを節約できます。デフォルトは'kintone'です。",
+	"Get the records that are added or updated since the last call, for the watches started by 'watchRecords' tool. The returned changes are cleared. Set 'stop' to stop watching.":                                                          "'watchRecords'ツールで開始した監視について、前回の呼び出し以降に追加・更新されたレコードを取得します。返された変更は消去されます。監視を止めるには'stop'を指定してください。",
+	"Get updates of watched kintone records":                                                        "監視中のkintoneレコードの更新を取得",
+	"The watch ID that is returned by 'watchRecords' tool. Default is all watches in this session.": "'watchRecords'ツールが返した監視のID。デフォルトはこのセッションの全ての監視です。",
+	"If true, stops the watch after returning the updates. Default is false.":                       "trueの場合、更新を返した後に監視を止めます。デフォルトはfalseです。",
+	"Get the operational statistics of the specified apps at once: the number of records, the last time a record was updated, the number of views, the number of fields by type, and whether the process management is enabled. Use this tool to find which apps are actually in use.": "指定したアプリの運用状況の統計をまとめて取得します: レコード数、最後にレコードが更新された日時、一覧の数、種類ごとのフィールドの数、プロセス管理が有効かどうか。実際に使われているアプリを探すときに使ってください。",
+	"Get statistics of kintone apps": "kintoneアプリの統計を取得",
+	"The app IDs to get the statistics. Up to 100 apps. Use 'listApps' tool to get the app IDs.":                                                                                           "統計を取得するアプリのID。最大100個まで。アプリのIDは'listApps'ツールで確認できます。",
+	"If true, the records are changed even if the number exceeds the limit of the server settings. Only use it if the user explicitly asks to change that many records. Default is false.": "trueの場合、サーバーの設定の上限を超える件数でもレコードを変更します。ユーザーがその件数のレコードの変更を明示的に求めた場合にのみ使ってください。デフォルトはfalseです。",
+	"Report the APIs and the apps that the credentials can access. For each app, it tells the operations that this server allows, and whether kintone allows the credentials to view the app, view the records, and manage the app. Use this tool when other tools fail with permission errors, to tell whether the API token lacks the permission or the server settings don't allow the app.": "認証情報でアクセスできるAPIとアプリを報告します。各アプリについて、このサーバーが許可している操作と、kintoneが認証情報にアプリの閲覧、レコードの閲覧、アプリの管理を許可しているかを示します。他のツールが権限のエラーで失敗する場合に、APIトークンの権限が足りないのか、サーバーの設定でアプリが許可されていないのかを判断するために使ってください。",
+	"The app IDs to check. In default, the apps that the server allows explicitly, or the apps that have API tokens or settings. Up to 50 apps.": "確認するアプリID。デフォルトは、サーバーで明示的に許可されているアプリ、またはAPIトークンや設定があるアプリです。最大50個まで。",
+	"Replace the app permissions of the specified app, such as who can manage the app or add, edit, and delete records. Include the current permissions in the result of the first call to keep them. The first call returns the current permissions and the changes with a confirmationToken, and the permissions are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.":                       "指定したアプリのアプリのアクセス権を置き換えます。アプリを管理できる人や、レコードを追加、編集、削除できる人などを設定します。既存の権限を残すには、1回目の呼び出しの結果にある現在の権限を含めてください。1回目の呼び出しでは現在の権限と変更内容がconfirmationTokenとともに返され、そのトークンを付けて再度呼び出したときにだけ権限が変更されます。変更は動作テスト環境に対して行われ、'deployAppSettings'ツールでアプリに反映されます。",
+	"Replace the record permissions of the specified app, that restrict who can view, edit, and delete the records that match the conditions. Include the current permissions in the result of the first call to keep them. The first call returns the current permissions and the changes with a confirmationToken, and the permissions are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.": "指定したアプリのレコードのアクセス権を置き換えます。条件に一致するレコードを閲覧、編集、削除できる人を制限します。既存の権限を残すには、1回目の呼び出しの結果にある現在の権限を含めてください。1回目の呼び出しでは現在の権限と変更内容がconfirmationTokenとともに返され、そのトークンを付けて再度呼び出したときにだけ権限が変更されます。変更は動作テスト環境に対して行われ、'deployAppSettings'ツールでアプリに反映されます。",
+	"Replace the field permissions of the specified app, that restrict who can view and edit each field. Include the current permissions in the result of the first call to keep them. The first call returns the current permissions and the changes with a confirmationToken, and the permissions are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.":                                      "指定したアプリのフィールドのアクセス権を置き換えます。各フィールドを閲覧、編集できる人を制限します。既存の権限を残すには、1回目の呼び出しの結果にある現在の権限を含めてください。1回目の呼び出しでは現在の権限と変更内容がconfirmationTokenとともに返され、そのトークンを付けて再度呼び出したときにだけ権限が変更されます。変更は動作テスト環境に対して行われ、'deployAppSettings'ツールでアプリに反映されます。",
+	"Apply the changed settings in the preview environment of the specified app, such as the permissions changed by 'updateAppAcl', 'updateRecordAcl', and 'updateFieldAcl' tools, and the notifications changed by 'updateGeneralNotifications' and 'updatePerRecordNotifications' tools. Other changes in the preview environment that are made in kintone are also applied. Set 'revert' to discard the changes instead.":                                                                      "指定したアプリの動作テスト環境で変更された設定を運用環境に反映します。'updateAppAcl'、'updateRecordAcl'、'updateFieldAcl'ツールで変更した権限や、'updateGeneralNotifications'、'updatePerRecordNotifications'ツールで変更した通知などが対象です。kintone上で行われた動作テスト環境の他の変更も反映されます。代わりに変更を破棄するには'revert'を指定してください。",
+	"All field permissions of the app. They replace the current permissions, so include the current ones to keep. The fields that are not included can be viewed and edited by everyone who can view the records.":                                                                                                                                                                                                                                                                                "アプリの全てのフィールドのアクセス権。現在の権限を置き換えるので、残したい現在の権限も含めてください。含まれないフィールドは、レコードを閲覧できる全員が閲覧、編集できます。",
+	"All permissions of the app in the order of priority. They replace the current permissions, so include the current ones to keep.":        "優先順位の順に並べたアプリの全てのアクセス権。現在の権限を置き換えるので、残したい現在の権限も含めてください。",
+	"All record permissions of the app in the order of priority. They replace the current permissions, so include the current ones to keep.": "優先順位の順に並べたアプリの全てのレコードのアクセス権。現在の権限を置き換えるので、残したい現在の権限も含めてください。",
+	"Add records.":               "レコードを追加できます。",
+	"Delete records.":            "レコードを削除できます。",
+	"Delete the records.":        "対象のレコードを削除できます。",
+	"Edit records.":              "レコードを編集できます。",
+	"Edit the records.":          "対象のレコードを編集できます。",
+	"Export records to files.":   "レコードをファイルに書き出せます。",
+	"Import records from files.": "ファイルからレコードを読み込めます。",
+	"View records.":              "レコードを閲覧できます。",
+	"View the records.":          "対象のレコードを閲覧できます。",
+	"Manage the app.":            "アプリを管理できます。",
+	"If true, the changes in the preview environment are discarded instead of being applied. Default is false.": "trueの場合、動作テスト環境の変更を反映せずに破棄します。デフォルトはfalseです。",
+	"If true, the permission also applies to the child organizations. Only for ORGANIZATION.":                   "trueの場合、下位の組織にも権限を適用します。ORGANIZATIONでのみ使えます。",
+	"READ to view the field, WRITE to view and edit it, and NONE to hide it.":                                   "READは閲覧、WRITEは閲覧と編集、NONEは非表示です。",
+	"The app ID to change the permissions.":                                                                     "アクセス権を変更するアプリID。",
+	"The app ID to deploy the settings.":                                                                        "設定を反映するアプリID。",
+	"The code of the user, the group, or the organization, or the field code for FIELD_ENTITY. Use 'everyone' with GROUP for all users. Not needed for CREATOR.":                      "ユーザー、グループ、組織のコード、またはFIELD_ENTITYの場合はフィールドコード。全てのユーザーを対象にするにはGROUPで'everyone'を使ってください。CREATORでは不要です。",
+	"The confirmation token that is returned by the previous call of this tool. The first call returns the current permissions and the changes with a token, and nothing is changed.": "このツールの前回の呼び出しで返された確認トークン。1回目の呼び出しでは現在の権限と変更内容がトークンとともに返され、何も変更されません。",
+	"The field code.": "フィールドコード。",
+	"The permissions of the field in the order of priority.":                                                                                                                                                       "優先順位の順に並べたフィールドのアクセス権。",
+	"The permissions of the records in the order of priority.":                                                                                                                                                     "優先順位の順に並べたレコードのアクセス権。",
+	"The query to select the records that the permissions apply to, such as 'status in (\"Done\")'. Empty for all records.":                                                                                        "権限を適用するレコードを選ぶクエリ。例: 'status in (\"Done\")'。空の場合は全てのレコードが対象です。",
+	"The type of the entity. CREATOR is the creator of the app or the record, and FIELD_ENTITY is the entities in a user, group, or organization field of the record.":                                             "対象の種類。CREATORはアプリまたはレコードの作成者、FIELD_ENTITYはレコードのユーザー、グループ、組織フィールドに含まれる対象です。",
+	"If true, the permissions of the records are evaluated in kintone before posting, and the records that the credentials can't view are skipped and reported in 'skipped' instead of failing. Default is false.": "trueの場合、投稿の前にkintoneでレコードのアクセス権を評価し、認証情報で閲覧できないレコードは失敗させずにスキップして'skipped'で報告します。デフォルトはfalseです。",
+	"Replace the general notifications of the specified app, that notify the users when records are added or edited, comments are posted, or files are imported. Include the current notifications in the result of the first call to keep them. The first call returns the current notifications and the changes with a confirmationToken, and the notifications are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.":                     "指定したアプリのアプリの条件通知を置き換えます。レコードの追加や編集、コメントの投稿、ファイルの読み込みのときにユーザーに通知します。既存の通知を残すには、1回目の呼び出しの結果にある現在の通知を含めてください。1回目の呼び出しでは現在の通知と変更内容がconfirmationTokenとともに返され、そのトークンを付けて再度呼び出したときにだけ通知が変更されます。変更は動作テスト環境に対して行われ、'deployAppSettings'ツールでアプリに反映されます。",
+	"Replace the per-record notifications of the specified app, that notify the users when a record matches the conditions, such as notifying the manager when the priority is High. Include the current notifications in the result of the first call to keep them. The first call returns the current notifications and the changes with a confirmationToken, and the notifications are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.": "指定したアプリのレコードの条件通知を置き換えます。優先度が高の場合に管理者に通知するなど、レコードが条件に一致したときにユーザーに通知します。既存の通知を残すには、1回目の呼び出しの結果にある現在の通知を含めてください。1回目の呼び出しでは現在の通知と変更内容がconfirmationTokenとともに返され、そのトークンを付けて再度呼び出したときにだけ通知が変更されます。変更は動作テスト環境に対して行われ、'deployAppSettings'ツールでアプリに反映されます。",
+	"If true, the members of the child organizations are also notified. Only for ORGANIZATION.": "trueの場合、下位の組織のメンバーにも通知します。ORGANIZATIONでのみ使えます。",
+	"Notify when a record is added.":                                                     "レコードが追加されたときに通知します。",
+	"Notify when a record is edited.":                                                    "レコードが編集されたときに通知します。",
+	"Notify when a comment is posted.":                                                   "コメントが投稿されたときに通知します。",
+	"Notify when records are imported from a file.":                                      "ファイルからレコードが読み込まれたときに通知します。",
+	"The query to select the records to notify about, such as 'priority in (\"High\")'.": "通知するレコードを選ぶクエリ。例: 'priority in (\"High\")'。",
+	"The title of the notification.":                                                     "通知のタイトル。",
+	"The entities to notify.":                                                            "通知先。",
+	"The app ID to change the notifications.":                                            "通知を変更するアプリID。",
+	"All general notifications of the app. They replace the current notifications, so include the current ones to keep.":                                                                "アプリの全てのアプリの条件通知。現在の通知を置き換えるので、残したい現在の通知も含めてください。",
+	"All per-record notifications of the app. They replace the current notifications, so include the current ones to keep.":                                                             "アプリの全てのレコードの条件通知。現在の通知を置き換えるので、残したい現在の通知も含めてください。",
+	"If true, the users who commented on a record are notified of new comments on the record. Default is not to change the current setting.":                                            "trueの場合、レコードにコメントしたユーザーにそのレコードへの新しいコメントを通知します。デフォルトは現在の設定を変更しません。",
+	"The confirmation token that is returned by the previous call of this tool. The first call returns the current notifications and the changes with a token, and nothing is changed.": "このツールの前回の呼び出しで返された確認トークン。1回目の呼び出しでは現在の通知と変更内容がトークンとともに返され、何も変更されません。",
+	"Update kintone record's assignee":                   "kintoneレコードの作業者を更新",
+	"Check the permissions of the credentials":           "認証情報の権限を確認",
+	"Update kintone app permissions":                     "kintoneアプリのアクセス権を更新",
+	"Update kintone record permissions":                  "kintoneレコードのアクセス権を更新",
+	"Update kintone field permissions":                   "kintoneフィールドのアクセス権を更新",
+	"Deploy kintone app settings":                        "kintoneアプリの設定を運用環境に反映",
+	"Update kintone general notifications":               "kintoneアプリの条件通知を更新",
+	"Update kintone per-record notifications":            "kintoneレコードの条件通知を更新",
+	"Check the connection to kintone":                    "kintoneへの接続を確認",
+	"Show server statistics":                             "サーバーの統計情報を表示",
+	"Refresh cached kintone app settings":                "キャッシュされたkintoneアプリ設定の再取得",
+	"List kintone environments":                          "kintone環境の一覧",
+	"Create a record in kintone app %s":                  "kintoneアプリ %s にレコードを作成",
+	"Update a record in kintone app %s":                  "kintoneアプリ %s のレコードを更新",
+	"Execute kintone record's process management action": "kintoneレコードのプロセス管理アクションを実行",
+
+	// Tool arguments.
+	"The offset of apps to read. Default is 0.": "取得するアプリのオフセット。デフォルトは0です。",
+	"The maximum number of apps to read. Default is 100, maximum is 100. The result might be different from the limit because of the permission. Use nextOffset in the result to read the next page.": "取得するアプリの最大数。デフォルトは100、最大は100です。権限によって結果の件数が上限と異なる場合があります。次のページを取得するには結果のnextOffsetを使ってください。",
+	"The name or a part of name of the apps to search. Highly recommended to use this parameter to find the app you want to use.":                                                                     "検索するアプリの名前、または名前の一部。使いたいアプリを探すために、この引数を使うことを強く推奨します。",
+	"The app ID to get information from.": "情報を取得するアプリのID。",
+	"The app ID to create a record in.":   "レコードを作成するアプリのID。",
+	"The record data to create. Record data format is the same as kintone's record data format. For example, {\"field1\": {\"value\": \"value1\"}, \"field2\": {\"value\": \"value2\"}, \"field3\": {\"value\": \"value3\"}}.": "作成するレコードのデータ。形式はkintoneのレコードの形式と同じです。例: {\"field1\": {\"value\": \"value1\"}, \"field2\": {\"value\": \"value2\"}, \"field3\": {\"value\": \"value3\"}}",
+	"The app ID to read records from.": "レコードを取得するアプリのID。",
+	"The query to filter records. Query format is the same as kintone's query format. For example, 'field1 = \"value1\" and (field2 like \"value2\"' or field3 not in (\"value3.1\",\"value3.2\")) and date > \"2006-01-02\"'.": "レコードを絞り込むクエリ。形式はkintoneのクエリの形式と同じです。例: 'field1 = \"value1\" and (field2 like \"value2\"' or field3 not in (\"value3.1\",\"value3.2\")) and date > \"2006-01-02\"'",
+	"The maximum number of records to read. Default is 10 or the value set by the server for the app, maximum is 500.":                                                                                                          "取得するレコードの最大数。デフォルトは10またはサーバーでアプリに設定された値で、最大は500です。",
+	"The field codes to include in the response. Default is all fields, or the fields set by the server for the app.":                                                                                                           "レスポンスに含めるフィールドコード。デフォルトはすべてのフィールド、またはサーバーでアプリに設定されたフィールドです。",
+	"The offset of records to read. Default is 0, maximum is 10,000. Use nextOffset in the result to read the next page.":                                                                                                       "取得するレコードのオフセット。デフォルトは0、最大は10,000です。次のページを取得するには結果のnextOffsetを使ってください。",
+	"The app ID to update a record in.": "レコードを更新するアプリのID。",
+	"The record ID to update.":          "更新するレコードのID。",
+	"The record data to update. Record data format is the same as kintone's record data format. For example, {\"field1\": {\"value\": \"value1\"}, \"field2\": {\"value\": \"value2\"}, \"field3\": {\"value\": \"value3\"}}. Omits the field that you don't want to update.": "更新するレコードのデータ。形式はkintoneのレコードの形式と同じです。例: {\"field1\": {\"value\": \"value1\"}, \"field2\": {\"value\": \"value2\"}, \"field3\": {\"value\": \"value3\"}}。更新しないフィールドは省略してください。",
+	"The app ID to delete a record from.": "レコードを削除するアプリのID。",
+	"The record ID to delete.":            "削除するレコードのID。",
+	"The confirmation token that is returned by the previous call of this tool. Only required if the server asks for confirmation.": "前回このツールを呼び出したときに返された確認用トークン。サーバーが確認を求めた場合にのみ必要です。",
+	"The file key to download.": "ダウンロードするファイルのキー。",
+	"If true, the file is not saved again when a file with the same content already exists in the download directory. The path of the existing file is returned instead. Default is false.": "trueの場合、同じ内容のファイルがダウンロード先に既にあれば再保存せず、既存のファイルのパスを返します。デフォルトはfalseです。",
+	"The file key to read.": "読み取るファイルのキー。",
+	"The maximum number of characters to return. Default is 20000, maximum is 200000. The response tells you if the text was truncated.": "返す最大文字数。デフォルトは20000、最大は200000です。テキストが切り詰められた場合はレスポンスで分かります。",
+	"The path of the file to upload. Required if 'content' is not specified.":                                                            "アップロードするファイルのパス。'content' を指定しない場合は必須です。",
+	"The file name for the 'content'. This is only used when 'content' is specified.":                                                    "'content' のファイル名。'content' を指定した場合にのみ使われます。",
+	"The content of the file to upload. Required if 'path' is not specified.":                                                            "アップロードするファイルの内容。'path' を指定しない場合は必須です。",
+	"The 'content' is base64 encoded or not. Default is false. This is only used when 'content' is specified.":                           "'content' がbase64でエンコードされているかどうか。デフォルトはfalseです。'content' を指定した場合にのみ使われます。",
+	"The app ID to read comments from.":                                     "コメントを取得するアプリのID。",
+	"The record ID to read comments from.":                                  "コメントを取得するレコードのID。",
+	"The order of comments. Default is 'desc'.":                             "コメントの並び順。デフォルトは 'desc' です。",
+	"The offset of comments to read. Default is 0.":                         "取得するコメントのオフセット。デフォルトは0です。",
+	"The maximum number of comments to read. Default is 10, maximum is 10.": "取得するコメントの最大数。デフォルトは10、最大は10です。",
+	"If true, all comments from the offset are read by paging through the thread, up to 500 comments. The 'limit' is ignored. Default is false.": "trueの場合、オフセット以降の全てのコメントをページをたどって最大500件まで取得します。'limit'は無視されます。デフォルトはfalseです。",
+	"If true, each comment includes only the ID, the author, the timestamp, and the text, to keep long threads readable. Default is false.":      "trueの場合、長いスレッドを読みやすくするため、各コメントにはID、投稿者、日時、本文のみが含まれます。デフォルトはfalseです。",
+	"The app ID to create a comment in.":    "コメントを投稿するアプリのID。",
+	"The record ID to create a comment on.": "コメントを投稿するレコードのID。",
+	"The text of the comment.":              "コメントの本文。",
+	"The code of the mention target. You can get the code by other records or comments.":                                             "メンション先のコード。コードは他のレコードやコメントから取得できます。",
+	"The type of the mention target. Default is 'USER'.":                                                                             "メンション先の種類。デフォルトは 'USER' です。",
+	"The mention targets of the comment. The target can be a user, a group, or a organization.":                                      "コメントのメンション先。ユーザー、グループ、組織を指定できます。",
+	"The app ID to update the assignee.":                                                                                             "作業者を更新するアプリのID。",
+	"The record ID to update the assignee.":                                                                                          "作業者を更新するレコードのID。",
+	"The codes of the assignee users of the record.":                                                                                 "レコードの作業者にするユーザーのコード。",
+	"The app ID to execute the action.":                                                                                              "アクションを実行するアプリのID。",
+	"The record ID to execute the action.":                                                                                           "アクションを実行するレコードのID。",
+	"The action to execute.":                                                                                                         "実行するアクション。",
+	"The next assignee of the record.":                                                                                               "レコードの次の作業者。",
+	"The app ID to download attachment files from.":                                                                                  "添付ファイルをダウンロードするアプリのID。",
+	"The query to filter records. Query format is the same as kintone's query format. Default is all records.":                       "レコードを絞り込むクエリ。形式はkintoneのクエリの形式と同じです。デフォルトはすべてのレコードです。",
+	"The field codes of the attachment fields to download. Default is all attachment fields.":                                        "ダウンロードする添付ファイルフィールドのフィールドコード。デフォルトはすべての添付ファイルフィールドです。",
+	"The maximum number of records to read. Default is 100, maximum is 500.":                                                         "取得するレコードの最大数。デフォルトは100、最大は500です。",
+	"If true, all files are bundled into a single zip file with a manifest.json. Default is false.":                                  "trueの場合、すべてのファイルをmanifest.jsonと一緒に1つのzipファイルにまとめます。デフォルトはfalseです。",
+	"The name of the kintone environment to use. Default is \"default\". Use 'listProfiles' tool to see the available environments.": "使用するkintone環境の名前。デフォルトは \"default\" です。利用できる環境は 'listProfiles' ツールで確認できます。",
+	"The plain value of the field, if the format is 'simple'.":                                                                       "formatが'simple'の場合の、フィールドの値そのもの。",
+	"The format of the record data. 'kintone' is the format of kintone REST API, and 'simple' is the plain values without {\"value\": ...} such as {\"field1\": \"value1\", \"table1\": [{\"cell1\": \"value\"}]}. Default is 'kintone'.":                      "レコードデータの形式。'kintone'はkintone REST APIの形式で、'simple'は{\"field1\": \"value1\", \"table1\": [{\"cell1\": \"value\"}]}のように{\"value\": ...}を省いた値そのものです。デフォルトは'kintone'です。",
+	"The format of the record data. 'kintone' is the format of kintone REST API, and 'simple' is the plain values without {\"value\": ...} such as {\"field1\": \"value1\", \"table1\": [{\"$id\": \"row ID\", \"cell1\": \"value\"}]}. Default is 'kintone'.": "レコードデータの形式。'kintone'はkintone REST APIの形式で、'simple'は{\"field1\": \"value1\", \"table1\": [{\"$id\": \"行ID\", \"cell1\": \"value\"}]}のように{\"value\": ...}を省いた値そのものです。デフォルトは'kintone'です。",
+	"The format of the records. 'kintone' is the format of kintone REST API that includes the type of each field, and 'simple' is the plain values such as {\"field1\": \"value1\"} that saves tokens. Default is 'kintone'.":                                  "レコードの形式。'kintone'は各フィールドの型を含むkintone REST APIの形式で、'simple'は{\"field1\": \"value1\"}のような値そのもので、トークンを節約できます。デフォルトは'kintone'です。",
+	"If true, fills in the display names of users, organizations, and groups that have only their codes. It requires password authentication. Default is false.":                                                                                               "trueの場合、コードのみのユーザー、組織、グループに表示名を補います。パスワード認証が必要です。デフォルトはfalseです。",
+	"Usual values for text, number, etc.": "文字列や数値などの通常の値。",
+	"Values for checkbox.":                "チェックボックスの値。",
+	"Values for file attachment.":         "添付ファイルの値。",
+	"Values for table.":                   "テーブルの値。",
+	"The content type of the file.":       "ファイルのContent-Type。",
+	"The file key. You can get the file key to upload a file by using 'uploadAttachmentFile' tool. The file can donwload by using 'downloadAttachmentFile' tool.": "ファイルキー。'uploadAttachmentFile' ツールでファイルをアップロードするとファイルキーを取得できます。ファイルは 'downloadAttachmentFile' ツールでダウンロードできます。",
+	"The file name.": "ファイル名。",
+	"%s (HH:MM)":     "%s (HH:MM)",
+	"%s (the codes of users, organizations, or groups)":          "%s (ユーザー、組織、グループのコード)",
+	"%s (the file keys returned by 'uploadAttachmentFile' tool)": "%s ('uploadAttachmentFile' ツールが返したファイルキー)",
+	"%s (the rows of the table)":                                 "%s (テーブルの行)",
+
+	// Errors.
+	"Failed to parse parameters: %v":                                                     "引数を解析できませんでした: %s",
+	"Unknown tool name: %s":                                                              "不明なツール名です: %s",
+	"Tool %s is disabled because the server is in read-only mode":                        "サーバーが読み取り専用モードのため、ツール %s は無効になっています",
+	"Tool %s is disabled by the server settings":                                         "ツール %s はサーバーの設定で無効になっています",
+	"Tool %s is disabled because the write permission for app ID %s is not granted":      "アプリID %[2]s への書き込み権限がないため、ツール %[1]s は無効になっています",
+	"Tool %s is disabled because no profiles are configured":                             "プロファイルが設定されていないため、ツール %s は無効になっています",
+	"Tool %s is disabled because no record templates are configured":                     "レコードのテンプレートが設定されていないため、ツール %s は無効になっています",
+	"Tool %s is disabled because no app has the %s permission in the configuration file": "設定ファイルで %[2]s 権限を持つアプリがないため、ツール %[1]s は無効になっています",
+	"App ID %s is inaccessible because the app names for KINTONE_DENY_APPS could not be fetched. Please check the MCP server settings.":                     "KINTONE_DENY_APPS のためのアプリ名を取得できなかったため、アプリID %s にはアクセスできません。MCPサーバーの設定を確認してください。",
+	"App ID %s is inaccessible because it is listed in the KINTONE_DENY_APPS environment variable. Please check the MCP server settings.":                   "アプリID %s は環境変数 KINTONE_DENY_APPS に含まれているため、アクセスできません。MCPサーバーの設定を確認してください。",
+	"App ID %s is inaccessible because it is not listed in the KINTONE_ALLOW_APPS environment variable. Please check the MCP server settings.":              "アプリID %s は環境変数 KINTONE_ALLOW_APPS に含まれていないため、アクセスできません。MCPサーバーの設定を確認してください。",
+	"App ID %s is inaccessible because it is not listed in the configuration file (KINTONE_CONFIG_FILE). Please check the MCP server settings.":             "アプリID %s は設定ファイル (KINTONE_CONFIG_FILE) に含まれていないため、アクセスできません。MCPサーバーの設定を確認してください。",
+	"The %s permission for app ID %s is not granted in the configuration file (KINTONE_CONFIG_FILE). Please check the MCP server settings.":                 "設定ファイル (KINTONE_CONFIG_FILE) でアプリID %[2]s に %[1]s 権限が与えられていません。MCPサーバーの設定を確認してください。",
+	"Field %s in app ID %s is inaccessible because it is restricted in the configuration file (KINTONE_CONFIG_FILE). Please check the MCP server settings.": "アプリID %[2]s のフィールド %[1]s は設定ファイル (KINTONE_CONFIG_FILE) で制限されているため、アクセスできません。MCPサーバーの設定を確認してください。",
+	"Offset must be greater than or equal to 0":                      "Offsetは0以上である必要があります",
+	"Offset must be between 0 and 10000":                             "Offsetは0から10000の間である必要があります",
+	"Limit must be between 1 and 100":                                "Limitは1から100の間である必要があります",
+	"Limit must be between 1 and 500":                                "Limitは1から500の間である必要があります",
+	"Limit must be between 1 and 10":                                 "Limitは1から10の間である必要があります",
+	"MaxLength must be between 1 and 200000":                         "MaxLengthは1から200000の間である必要があります",
+	"Argument 'appID' is required":                                   "引数 'appID' は必須です",
+	"Argument 'recordID' is required":                                "引数 'recordID' は必須です",
+	"Argument 'fileKey' is required":                                 "引数 'fileKey' は必須です",
+	"Arguments 'appID' and 'record' are required":                    "引数 'appID' と 'record' は必須です",
+	"Arguments 'appID' and 'recordID' are required":                  "引数 'appID' と 'recordID' は必須です",
+	"Arguments 'appID', 'recordID', and 'record' are required":       "引数 'appID'、'recordID'、'record' は必須です",
+	"Arguments 'appID', 'recordID', and 'comment.text' are required": "引数 'appID'、'recordID'、'comment.text' は必須です",
+	"Arguments 'appID', 'recordID', and 'action' are required":       "引数 'appID'、'recordID'、'action' は必須です",
+	"Arguments 'path' or 'content' is required":                      "引数 'path' か 'content' のどちらかが必須です",
+	"Arguments 'path' and 'content' are mutually exclusive":          "引数 'path' と 'content' は同時に指定できません",
+	"Order must be 'asc' or 'desc'":                                  "Orderは 'asc' か 'desc' である必要があります",
+	"Mention code is required":                                       "メンション先のコードは必須です",
+	"Mention type must be 'USER', 'GROUP', or 'ORGANIZATION'":        "メンション先の種類は 'USER'、'GROUP'、'ORGANIZATION' のいずれかである必要があります",
+	"The confirmation token is invalid or expired. Please call deleteRecord again without 'confirmationToken' to get a new one.": "確認用トークンが無効か期限切れです。新しいトークンを取得するには、'confirmationToken' を付けずに deleteRecord をもう一度呼び出してください。",
+	"Unknown field code: %s. Please check the input schema of the tool.":                                                         "不明なフィールドコードです: %s。ツールの入力スキーマを確認してください。",
+	"Unknown field code: %s. Please check the field codes by using 'readAppInfo' tool.":                                          "不明なフィールドコードです: %s。'readAppInfo'ツールでフィールドコードを確認してください。",
+	"Field %s is a %s field. Only number and calculated fields can be aggregated.":                                               "フィールド %[1]s は %[2]s フィールドです。集計できるのは数値フィールドと計算フィールドのみです。",
+	"Unknown field code: %s. Please check the field codes by using 'readAppInfo' tool. Fields in tables can't be used.":          "不明なフィールドコードです: %s。'readAppInfo'ツールでフィールドコードを確認してください。テーブル内のフィールドは使用できません。",
+	"Field %s is a %s field, which can't be used to aggregate records.":                                                          "フィールド %[1]s は %[2]s フィールドのため、レコードの集計には使用できません。",
+	"Field %s is a %s field. Only date, datetime, created time, and updated time fields can be used as 'dateField'.":             "フィールド %[1]s は %[2]s フィールドです。'dateField'に使用できるのは日付、日時、作成日時、更新日時フィールドのみです。",
+	"Interval must be 'day', 'week', or 'month'":                                                                                 "Intervalは 'day'、'week'、'month' のいずれかである必要があります",
+	"Too many buckets: the records span more than %d %ss. Please use a larger interval or narrow down the records by the query.": "期間が多すぎます: レコードが%s個以上の期間 (%s) にまたがっています。より大きな期間の単位を使うか、クエリでレコードを絞り込んでください。",
+	"Failed to parse the query: %v": "クエリの解析に失敗しました: %s",
+	"Field %s is a %s field, which can't be used to mention. Use a user, organization, or group field.":                                  "フィールド %[1]s は %[2]s フィールドのため、メンションには使用できません。ユーザー、組織、グループフィールドを使用してください。",
+	"The query matches more than %d records. Please narrow down the records by the query.":                                               "クエリが%s件より多いレコードに一致します。クエリでレコードを絞り込んでください。",
+	"The confirmation token is invalid or expired. Please call createRecordComments again without 'confirmationToken' to get a new one.": "確認用トークンが無効か期限切れです。新しいトークンを取得するには、'confirmationToken'を指定せずにcreateRecordCommentsを再度呼び出してください。",
+	"Tool %s is disabled because no endpoints are allowed by KINTONE_REQUEST_ENDPOINTS":                                                  "KINTONE_REQUEST_ENDPOINTS で許可されたエンドポイントがないため、ツール %s は無効になっています",
+	"Tool %s is disabled because the server is in read-only mode and no GET endpoints are allowed by KINTONE_REQUEST_ENDPOINTS":          "サーバーが読み取り専用モードで、KINTONE_REQUEST_ENDPOINTS でGETのエンドポイントが許可されていないため、ツール %s は無効になっています",
+	"Tool %s is disabled because masking rules are configured":                                                                           "マスキングルールが設定されているため、ツール %s は無効になっています",
+	"Arguments 'method' and 'path' are required":                                                                                         "引数 'method' と 'path' は必須です",
+	"Method must be 'GET', 'POST', 'PUT', or 'DELETE'":                                                                                   "Methodは 'GET'、'POST'、'PUT'、'DELETE' のいずれかである必要があります",
+	"Invalid path: %s. The path must start with /k/v1/, such as '/k/v1/app/acl.json'.":                                                   "不正なパスです: %s。パスは '/k/v1/app/acl.json' のように /k/v1/ から始まる必要があります。",
+	"%s %s is not allowed by the server settings. The allowed endpoints are: %s":                                                         "%s %s はサーバーの設定で許可されていません。許可されたエンドポイント: %s",
+	"%s requests are disabled because the server is in read-only mode":                                                                   "サーバーが読み取り専用モードのため、%s リクエストは無効になっています",
+	"App ID %s can't be used with kintoneRequest because some fields of the app are restricted by the server settings":                   "アプリID %s は一部のフィールドがサーバーの設定で制限されているため、kintoneRequestでは使用できません",
+	"%s %s can't be called because the accessible apps are restricted by the server settings and the request doesn't specify an app ID":  "アクセスできるアプリがサーバーの設定で制限されていて、リクエストにアプリIDが指定されていないため、%s %s は呼び出せません",
+	"Subscriptions are not available without a session":                                                                                  "セッションがないため購読できません",
+	"Only records can be subscribed, such as kintone://app/1/record/2":                                                                   "購読できるのは kintone://app/1/record/2 のようなレコードのみです",
+	"Watches are not available without a session":                                                                                        "セッションがないため監視できません",
+	"Interval must be a duration of %s or longer, such as '30s' or '5m'":                                                                 "Intervalは'30s'や'5m'のような%s以上の時間である必要があります",
+	"The query of watchRecords can't have 'order by', 'limit', or 'offset'":                                                              "watchRecordsのクエリには'order by'、'limit'、'offset'を指定できません",
+	"App ID %s can't be watched because it has no updated time field":                                                                    "アプリID %s には更新日時フィールドがないため監視できません",
+	"Too many watches. A session can have up to %d watches. Please stop a watch by using 'getWatchUpdates' tool with 'stop'.":            "監視が多すぎます。1つのセッションで監視できるのは%s個までです。'getWatchUpdates'ツールの'stop'で監視を止めてください。",
+	"Unknown watch ID: %s. The watches are lost when the session ends.":                                                                  "不明な監視のIDです: %s。監視はセッションが終了すると失われます。",
+	"Tool %s failed: %v":                                                             "ツール %s の実行に失敗しました: %s",
+	"Tool %s is rejected because the hook failed: %v":                                "フックが失敗したため、ツール %s は拒否されました: %s",
+	"Tool %s is rejected because the hook returned an invalid response: %v":          "フックが不正な応答を返したため、ツール %s は拒否されました: %s",
+	"Tool %s is rejected because the hook returned arguments that are not an object": "フックがオブジェクトではない引数を返したため、ツール %s は拒否されました",
+	"Tool %s is rejected by the server's rule: %s":                                   "ツール %s はサーバーのルールによって拒否されました: %s",
+	"Tool %s is rejected by the server's rule":                                       "ツール %s はサーバーのルールによって拒否されました",
+	"Argument 'appIDs' is required":                                                  "引数 'appIDs' は必須です",
+	"Too many apps: %d. Up to %d apps can be read at once.":                          "アプリが多すぎます: %s。一度に読み込めるのは%s個までです。",
+	"The records can't be read because the user to apply the field permissions is unknown. Please bind the bearer token to the user in KINTONE_MCP_AUTH_TOKENS, or provide the username and the password of the user.": "フィールドのアクセス権を適用するユーザーが不明なため、レコードを読み込めません。KINTONE_MCP_AUTH_TOKENSでBearerトークンにユーザーを結びつけるか、ユーザーのユーザー名とパスワードを指定してください。",
+	"Field %s in app ID %s is inaccessible because of the field permissions of kintone.": "アプリID %[2]s のフィールド %[1]s はkintoneのフィールドのアクセス権によりアクセスできません。",
+	"Tool %s is going to change %d records in app ID %s, but the server allows up to %d records at once. No records are changed. Please narrow down the records, or call %s again with 'overrideRecordLimit' set to true only if the user really intends to change all of them.": "ツール %[1]s はアプリID %[3]s の %[2]s 件のレコードを変更しようとしていますが、サーバーは一度に %[4]s 件までしか許可していません。レコードは変更されていません。対象のレコードを絞り込むか、ユーザーが本当に全てのレコードの変更を意図している場合に限り 'overrideRecordLimit' をtrueにして %[5]s を再度呼び出してください。",
+	"The rest of %d records can't be read by the offset, because the offset can't exceed 10,000. Please narrow down the records by the query, such as by the record ID, to read the rest.":                                                                                       "オフセットは10,000を超えられないため、残りの%s件のレコードはオフセットで読み込めません。残りを読み込むには、レコードIDなどのクエリでレコードを絞り込んでください。",
+	"Too many apps: %d. Up to %d apps can be checked at once.": "アプリが多すぎます: %s。一度に確認できるのは%s個までです。",
+	"The confirmation token is invalid or expired, or the settings of the app have been changed after the token was issued. Please call %s again without 'confirmationToken' to get a new one.": "確認トークンが無効か期限切れ、またはトークンの発行後にアプリの設定が変更されました。新しいトークンを取得するには、'confirmationToken'を付けずに%sを再度呼び出してください。",
+	"Failed to deploy the settings of app ID %s: the status is %s.": "アプリID %s の設定の反映に失敗しました: ステータスは%sです。",
+	"Unknown profile: %s. Available profiles are: %s":               "不明なプロファイルです: %s。利用できるプロファイル: %s",
+	"Unknown profile: %s":  "不明なプロファイルです: %s",
+	"Invalid path: %s: %v": "不正なパスです: %s: %s",
+	"Path %s is inaccessible because it is not in the directories listed in the KINTONE_ALLOWED_PATHS environment variable. Please use a file in the allowed directories, or check the MCP server settings.": "パス %s は環境変数 KINTONE_ALLOWED_PATHS に含まれるディレクトリの外にあるため、アクセスできません。許可されたディレクトリ内のファイルを使うか、MCPサーバーの設定を確認してください。",
+	"Path %s is inaccessible because it is not in the roots provided by the client. Please use a file in the roots.":                                                                                         "パス %s はクライアントが提供したルートの外にあるため、アクセスできません。ルート内のファイルを使ってください。",
+	"Text extraction is not supported for this file type: %s (%s). Please use 'downloadAttachmentFile' tool instead.":                                                                                        "このファイル形式からはテキストを読み取れません: %s (%s)。代わりに 'downloadAttachmentFile' ツールを使ってください。",
+	"The file is too large to extract text. The maximum size is %d MB. Please use 'downloadAttachmentFile' tool instead.":                                                                                    "ファイルが大きすぎるためテキストを読み取れません。最大サイズは%s MBです。代わりに 'downloadAttachmentFile' ツールを使ってください。",
+	"The file is too large to upload. The maximum size is %s.":                                                                                                                                               "ファイルが大きすぎるためアップロードできません。最大サイズは%sです。",
+	"Failed to extract text from %s: %v":                    "%s からテキストを読み取れませんでした: %s",
+	"Failed to read attachment file: %v":                    "添付ファイルを読み込めませんでした: %s",
+	"Failed to download attachment file: %s: %v":            "添付ファイルをダウンロードできませんでした: %s: %s",
+	"Failed to create download directory: %v":               "ダウンロード先のディレクトリを作成できませんでした: %s",
+	"Failed to create file for attachment: %v":              "添付ファイルの保存先を作成できませんでした: %s",
+	"Failed to save attachment file: %v":                    "添付ファイルを保存できませんでした: %s",
+	"Failed to save attachment file: %s: %v":                "添付ファイルを保存できませんでした: %s: %s",
+	"Failed to create zip file: %v":                         "zipファイルを作成できませんでした: %s",
+	"Failed to write zip file: %v":                          "zipファイルに書き込めませんでした: %s",
+	"Failed to save zip file: %s: %v":                       "zipファイルを保存できませんでした: %s: %s",
+	"Failed to open file: %v":                               "ファイルを開けませんでした: %s",
+	"Failed to read file information: %v":                   "ファイルの情報を読み込めませんでした: %s",
+	"Failed to read file content: %v":                       "ファイルの内容を読み込めませんでした: %s",
+	"Failed to prepare request: %v":                         "リクエストを準備できませんでした: %s",
+	"Failed to finalize request: %v":                        "リクエストを完成できませんでした: %s",
+	"Failed to create HTTP request: %v":                     "HTTPリクエストを作成できませんでした: %s",
+	"Failed to parse kintone server's response: %v":         "kintoneサーバーのレスポンスを解析できませんでした: %s",
+	"Failed to prepare request body for kintone server: %v": "kintoneサーバーへのリクエストボディを準備できませんでした: %s",
+	"Per-session credentials are not allowed. Please set KINTONE_SESSION_CREDENTIALS to enable it.":                    "セッションごとの認証情報は許可されていません。有効にするには KINTONE_SESSION_CREDENTIALS を設定してください。",
+	"kintone credentials are not provided. Please provide them via the initialize request or the X-Kintone-* headers.": "kintoneの認証情報がありません。initializeリクエストか X-Kintone-* ヘッダーで指定してください。",
+}
+
+// catalogFormat is a message format in a catalog that is compiled to match the generated messages.
+type catalogFormat struct {
+	pattern     *regexp.Regexp
+	translation string
+}
+
+var (
+	formatVerbPattern = regexp.MustCompile(`%[sdvq]`)
+	catalogFormats    = map[string][]catalogFormat{}
+)
+
+func init() {
+	for lang, catalog := range messageCatalogs {
+		keys := slices.Collect(maps.Keys(catalog))
+		// Longer formats are more specific, such as "Unknown profile: %s. Available profiles are: %s" and "Unknown profile: %s".
+		slices.SortFunc(keys, func(a, b string) int {
+			return cmp.Or(len(b)-len(a), strings.Compare(a, b))
+		})
+
+		for _, key := range keys {
+			if !formatVerbPattern.MatchString(key) {
+				continue
+			}
+			literals := formatVerbPattern.Split(key, -1)
+			for i, l := range literals {
+				literals[i] = regexp.QuoteMeta(l)
+			}
+			catalogFormats[lang] = append(catalogFormats[lang], catalogFormat{
+				pattern:     regexp.MustCompile(`(?s)^` + strings.Join(literals, `(.*?)`) + `$`),
+				translation: catalog[key],
+			})
+		}
+	}
+}
+
+// localize translates the message into the language.
+// The message is returned as is if the language is English or the message has no translation.
+func localize(lang, s string) string {
+	if t, ok := messageCatalogs[lang][s]; ok {
+		return t
+	}
+	for _, f := range catalogFormats[lang] {
+		if m := f.pattern.FindStringSubmatch(s); m != nil {
+			args := make([]any, len(m)-1)
+			for i, v := range m[1:] {
+				args[i] = v
+			}
+			return fmt.Sprintf(f.translation, args...)
+		}
+	}
+	return s
+}
+
+// tr translates the message into the language of KINTONE_LANG.
+func (h *KintoneHandlers) tr(s string) string {
+	return localize(h.Lang, s)
+}
+
+// localizeError translates the message of JSON-RPC errors, such as validation errors of tool arguments.
+func (h *KintoneHandlers) localizeError(err error) error {
+	var e jsonrpc2.Error
+	if err == nil || !errors.As(err, &e) {
+		return err
+	}
+	e.Message = h.tr(e.Message)
+	return e
+}
+
+// localizeTool translates the description, title, and the descriptions in the input schema of the tool.
+func (h *KintoneHandlers) localizeTool(t ToolInfo) ToolInfo {
+	if _, ok := messageCatalogs[h.Lang]; !ok {
+		return t
+	}
+
+	t.Description = h.tr(t.Description)
+	if title, ok := t.Annotations["title"].(string); ok {
+		t.Annotations = maps.Clone(t.Annotations)
+		t.Annotations["title"] = h.tr(title)
+	}
+	t.InputSchema, _ = h.localizeSchema(t.InputSchema).(JsonMap)
+	return t
+}
+
+// localizeSchema returns a copy of the JSON schema with the descriptions translated.
+func (h *KintoneHandlers) localizeSchema(v any) any {
+	switch v := v.(type) {
+	case JsonMap:
+		m := make(JsonMap, len(v))
+		for k, x := range v {
+			if s, ok := x.(string); ok && k == "description" {
+				m[k] = h.tr(s)
+			} else {
+				m[k] = h.localizeSchema(x)
+			}
+		}
+		return m
+	case []JsonMap:
+		l := make([]JsonMap, len(v))
+		for i, x := range v {
+			l[i], _ = h.localizeSchema(x).(JsonMap)
+		}
+		return l
+	default:
+		return v
+	}
+}
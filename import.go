@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+const (
+	// importBatchSize is the maximum number of records that kintone creates at once.
+	importBatchSize = 100
+
+	// maxImportErrors is the maximum number of errors in the result of importRecords, to keep the response small.
+	maxImportErrors = 50
+)
+
+// multiValueFieldTypes is the field types that have multiple values in a cell of CSV, separated by newlines as the CSV files of kintone.
+var multiValueFieldTypes = []string{"CHECK_BOX", "MULTI_SELECT", "USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT"}
+
+type ImportRecordsParams struct {
+	AppID      string            `json:"appID" required:"true" description:"The app ID to import records into."`
+	Path       string            `json:"path" required:"true" description:"The path of the CSV or JSON Lines file to import. The CSV file has to have a header row. Each line of JSON Lines file is a record in the simple format such as {\"field1\": \"value1\"}."`
+	FileFormat string            `json:"fileFormat" enum:"csv,jsonl" description:"The format of the file. Default is determined by the extension of the file."`
+	Mapping    map[string]string `json:"mapping" description:"The map from the column names in the file to the field codes. Default is to match the column names with the field codes or the field labels of the app."`
+	DryRun     bool              `json:"dryRun" description:"If true, only validates the rows without creating records. Default is false."`
+
+	OverrideRecordLimit bool `json:"overrideRecordLimit" description:"If true, the records are changed even if the number exceeds the limit of the server settings. Only use it if the user explicitly asks to change that many records. Default is false."`
+}
+
+// importRow is a row in the file to import.
+type importRow struct {
+	Row    int
+	Record RecordParam
+}
+
+// importError is an error of a row, that is reported in the result of importRecords.
+type importError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+func (h *KintoneHandlers) ImportRecords(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req ImportRecordsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" || req.Path == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Arguments 'appID' and 'path' are required",
+		}
+	}
+
+	if req.FileFormat == "" {
+		switch strings.ToLower(filepath.Ext(req.Path)) {
+		case ".csv":
+			req.FileFormat = "csv"
+		case ".jsonl", ".ndjson":
+			req.FileFormat = "jsonl"
+		default:
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: "Failed to determine the format of the file from the extension. Please specify 'fileFormat' argument.",
+			}
+		}
+	}
+	if req.FileFormat != "csv" && req.FileFormat != "jsonl" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "FileFormat must be 'csv' or 'jsonl'",
+		}
+	}
+
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkPathAccess(ctx, req.Path); err != nil {
+		return nil, err
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(req.Path)
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to open file: %v", err),
+		}
+	}
+	defer f.Close()
+
+	columns := importColumns{fields: fields, mapping: req.Mapping}
+	var rows []importRow
+	var errs []importError
+	if req.FileFormat == "csv" {
+		rows, errs, err = columns.readCSV(f)
+	} else {
+		rows, errs, err = columns.readJSONLines(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := JsonMap{
+		"rows": len(rows) + len(errs),
+	}
+
+	// Validate all rows before creating any records, so that the mistakes in the file can be fixed at once.
+	var valid []importRow
+	var coerced []string
+	for _, row := range rows {
+		record, err := h.expandRecord(ctx, req.AppID, row.Record)
+		if err == nil {
+			err = h.fieldFilter(req.AppID).checkRecord(req.AppID, map[string]any(record))
+		}
+		if err != nil {
+			errs = append(errs, importError{Row: row.Row, Error: importErrorMessage(err)})
+			continue
+		}
+		for _, note := range h.coerceRecord(ctx, req.AppID, record) {
+			coerced = append(coerced, fmt.Sprintf("row %d: %s", row.Row, note))
+		}
+		valid = append(valid, importRow{Row: row.Row, Record: record})
+	}
+
+	if err := h.checkAffectedRecords(ctx, "importRecords", req.AppID, len(valid), req.OverrideRecordLimit); err != nil {
+		return nil, err
+	}
+
+	var recordIDs []string
+	if req.DryRun {
+		result["valid"] = len(valid)
+	} else {
+		for start := 0; start < len(valid); start += importBatchSize {
+			batch := valid[start:min(start+importBatchSize, len(valid))]
+			ids, batchErrs := h.createImportBatch(ctx, req.AppID, batch)
+			recordIDs = append(recordIDs, ids...)
+			errs = append(errs, batchErrs...)
+			ReportProgress(ctx, float64(start+len(batch)), float64(len(valid)), fmt.Sprintf("Imported %d of %d records", start+len(batch), len(valid)))
+		}
+		result["created"] = len(recordIDs)
+		result["recordIDs"] = recordIDs
+	}
+
+	slices.SortStableFunc(errs, func(a, b importError) int { return a.Row - b.Row })
+	result["failed"] = len(errs)
+	if len(errs) > maxImportErrors {
+		result["errors"] = errs[:maxImportErrors]
+		result["note"] = fmt.Sprintf("Only the first %d errors are shown.", maxImportErrors)
+	} else if len(errs) > 0 {
+		result["errors"] = errs
+	}
+	if len(coerced) > 0 {
+		result["coerced"] = coerced
+	}
+
+	return JSONContent(result)
+}
+
+// createImportBatch creates the records at once.
+// kintone doesn't create any records in the request if one of them is invalid, so the records are created one by one after a failure, to report which rows are wrong.
+func (h *KintoneHandlers) createImportBatch(ctx context.Context, appID string, batch []importRow) ([]string, []importError) {
+	records := make([]RecordParam, len(batch))
+	for i, row := range batch {
+		records[i] = row.Record
+	}
+
+	var res struct {
+		IDs []string `json:"ids"`
+	}
+	err := h.FetchHTTPWithJSON(ctx, "POST", "/k/v1/records.json", nil, JsonMap{"app": appID, "records": records}, &res)
+	if err == nil {
+		return res.IDs, nil
+	}
+	if len(batch) == 1 {
+		return nil, []importError{{Row: batch[0].Row, Error: importErrorMessage(err)}}
+	}
+	serverLog.DebugContext(ctx, "Failed to create records at once, retrying one by one", "appID", appID, "error", err)
+
+	var ids []string
+	var errs []importError
+	for _, row := range batch {
+		var res struct {
+			ID string `json:"id"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "POST", "/k/v1/record.json", nil, JsonMap{"app": appID, "record": row.Record}, &res); err != nil {
+			errs = append(errs, importError{Row: row.Row, Error: importErrorMessage(err)})
+		} else {
+			ids = append(ids, res.ID)
+		}
+	}
+	return ids, errs
+}
+
+// importErrorMessage returns the message of the error for the result, without the data that is only useful for the whole request.
+func importErrorMessage(err error) string {
+	var rpcErr jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Message
+	}
+	return err.Error()
+}
+
+// importColumns maps the columns in the file to the fields of the app.
+type importColumns struct {
+	fields  map[string]formField
+	mapping map[string]string
+}
+
+// fieldCode returns the field code for the column.
+// The explicit mapping takes precedence, then the field code, then the field label if only one field has it.
+func (c importColumns) fieldCode(column string) (string, error) {
+	if code, ok := c.mapping[column]; ok {
+		if _, ok := c.fields[code]; !ok {
+			return "", fmt.Errorf("unknown field code %q in the mapping of column %q", code, column)
+		}
+		return code, nil
+	}
+	if _, ok := c.fields[column]; ok {
+		return column, nil
+	}
+
+	var matched []string
+	for code, f := range c.fields {
+		if f.Label == column {
+			matched = append(matched, code)
+		}
+	}
+	switch len(matched) {
+	case 1:
+		return matched[0], nil
+	case 0:
+		return "", fmt.Errorf("column %q doesn't match any field code or label. Please specify 'mapping' argument", column)
+	default:
+		return "", fmt.Errorf("column %q matches multiple fields by label. Please specify 'mapping' argument", column)
+	}
+}
+
+// readCSV reads the rows of a CSV file with a header row.
+// Empty cells are omitted, so that the default values of the fields are used.
+func (c importColumns) readCSV(r io.Reader) ([]importRow, []importError, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xEF\xBB\xBF")) {
+		br.Discard(3)
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Failed to read the header row of the CSV file: %v", err),
+		}
+	}
+
+	codes := make([]string, len(header))
+	for i, column := range header {
+		code, err := c.fieldCode(column)
+		if err != nil {
+			return nil, nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Failed to map the columns of the CSV file: %v", err),
+			}
+		}
+		if t := c.fields[code].Type; t == "SUBTABLE" || t == "FILE" {
+			return nil, nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Field %s of column %q can't be imported from CSV because it is %s. Please use JSON Lines instead.", code, column, t),
+			}
+		}
+		codes[i] = code
+	}
+
+	var rows []importRow
+	var errs []importError
+	for n := 1; ; n++ {
+		cells, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, jsonrpc2.Error{
+					Code:    jsonrpc2.InternalErrorCode,
+					Message: fmt.Sprintf("Failed to read the CSV file: %v", err),
+				}
+			}
+			errs = append(errs, importError{Row: n, Error: err.Error()})
+			continue
+		}
+
+		record := make(RecordParam)
+		for i, cell := range cells {
+			if i >= len(codes) || cell == "" {
+				continue
+			}
+			if slices.Contains(multiValueFieldTypes, c.fields[codes[i]].Type) {
+				var values []any
+				for _, v := range strings.Split(cell, "\n") {
+					if v = strings.TrimSpace(v); v != "" {
+						values = append(values, v)
+					}
+				}
+				record[codes[i]] = values
+			} else {
+				record[codes[i]] = cell
+			}
+		}
+		rows = append(rows, importRow{Row: n, Record: record})
+	}
+
+	return rows, errs, nil
+}
+
+// readJSONLines reads the records in the simple format from a JSON Lines file.
+// Empty lines are skipped, but counted as rows so that the row numbers match the line numbers.
+func (c importColumns) readJSONLines(r io.Reader) ([]importRow, []importError, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16*1024*1024)
+
+	var rows []importRow
+	var errs []importError
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var values map[string]any
+		if err := dec.Decode(&values); err != nil {
+			errs = append(errs, importError{Row: n, Error: fmt.Sprintf("invalid JSON: %v", err)})
+			continue
+		}
+
+		record := make(RecordParam, len(values))
+		var mapErr error
+		for column, v := range values {
+			code, err := c.fieldCode(column)
+			if err != nil {
+				mapErr = err
+				break
+			}
+			record[code] = v
+		}
+		if mapErr != nil {
+			errs = append(errs, importError{Row: n, Error: mapErr.Error()})
+			continue
+		}
+		rows = append(rows, importRow{Row: n, Record: record})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to read the JSON Lines file: %v", err),
+		}
+	}
+
+	return rows, errs, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const defaultInstructions = "kintone is a database service to store and manage enterprise data. You can use this server to interact with kintone."
+
+// maxInstructionFields is the maximum number of fields listed for each app in the generated instructions.
+const maxInstructionFields = 30
+
+// maxInstructionApps is the maximum number of apps described in the generated instructions.
+const maxInstructionApps = 50
+
+// systemFieldTypes are field types that kintone adds to every app automatically.
+var systemFieldTypes = []string{"RECORD_NUMBER", "__ID__", "__REVISION__", "CREATOR", "CREATED_TIME", "MODIFIER", "UPDATED_TIME", "STATUS", "STATUS_ASSIGNEE", "CATEGORY"}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// stripHTML converts kintone's rich text, such as app descriptions, into plain text.
+func stripHTML(s string) string {
+	s = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</div>", "\n", "</p>", "\n").Replace(s)
+	s = html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+	return strings.TrimSpace(s)
+}
+
+// buildInstructions makes the instructions for the initialize response.
+func (h *KintoneHandlers) buildInstructions(ctx context.Context) string {
+	instructions := h.tr(defaultInstructions)
+	switch {
+	case h.Instructions != "" && h.InstructionsMode == "replace":
+		instructions = h.Instructions
+	case h.Instructions != "":
+		instructions += "\n\n" + h.Instructions
+	}
+
+	if h.InstructionsApps && !h.policy().Allow.IsEmpty() {
+		if guide := h.buildAppGuide(ctx); guide != "" {
+			instructions += "\n\n" + guide
+		}
+	}
+
+	return instructions
+}
+
+// buildAppGuide describes the apps listed in KINTONE_ALLOW_APPS, so that the model knows which app to use without calling tools.
+func (h *KintoneHandlers) buildAppGuide(ctx context.Context) string {
+	var buf strings.Builder
+	buf.WriteString(h.tr("The following kintone apps are available:\n"))
+
+	allow := h.policy().Allow
+	appIDs := allow.IDs()
+	if !allow.IsExact() {
+		apps, err := h.listPermittedApps(ctx)
+		if err != nil {
+			LogContext(ctx, LogLevelWarning, "instructions", fmt.Sprintf("Failed to list apps for instructions: %v", err))
+			return ""
+		}
+		appIDs = nil
+		for _, app := range apps {
+			appIDs = append(appIDs, app.AppID)
+		}
+	}
+
+	count := 0
+	for _, appID := range appIDs {
+		if count >= maxInstructionApps {
+			buf.WriteString(h.tr("\n...and more apps. Use 'listApps' tool to see all apps.\n"))
+			break
+		}
+		if h.checkPermissions(appID) != nil {
+			continue
+		}
+
+		guide, err := cached(ctx, &h.cache, h.appCacheKey(ctx, appID, "guide"), h.CacheTTL, func(ctx context.Context) (string, error) {
+			return h.describeApp(ctx, appID)
+		})
+		if err != nil {
+			LogContext(ctx, LogLevelWarning, "instructions", fmt.Sprintf("Failed to read app %s for instructions: %v", appID, err))
+			continue
+		}
+
+		buf.WriteString(guide)
+		count++
+	}
+
+	if count == 0 {
+		return ""
+	}
+	return buf.String()
+}
+
+func (h *KintoneHandlers) describeApp(ctx context.Context, appID string) (string, error) {
+	var app KintoneAppDetail
+	if err := h.fetchAppSchema(ctx, "/k/v1/app.json", appID, &app); err != nil {
+		return "", err
+	}
+
+	var fields struct {
+		Properties map[string]struct {
+			Type  string `json:"type"`
+			Label string `json:"label"`
+		} `json:"properties"`
+	}
+	if err := h.fetchAppSchema(ctx, "/k/v1/app/form/fields.json", appID, &fields); err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "\n## %s (appID: %s)\n", app.Name, appID)
+	if desc := stripHTML(app.Description); desc != "" {
+		if r := []rune(desc); len(r) > 500 {
+			desc = string(r[:500]) + "..."
+		}
+		fmt.Fprintf(&buf, "%s\n", desc)
+	}
+
+	filter := h.fieldFilter(appID)
+	var codes []string
+	for code, f := range fields.Properties {
+		if !slices.Contains(systemFieldTypes, f.Type) && filter.allowed("", code) {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+
+	if len(codes) > 0 {
+		buf.WriteString(h.tr("Fields:\n"))
+		for i, code := range codes {
+			if i >= maxInstructionFields {
+				buf.WriteString(h.tr(fmt.Sprintf("- ...and %d more fields. Use 'readAppInfo' tool to see all fields.\n", len(codes)-i)))
+				break
+			}
+			f := fields.Properties[code]
+			fmt.Fprintf(&buf, "- %s (%s): %s\n", code, f.Type, f.Label)
+		}
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// requestEndpoint is an endpoint that kintoneRequest tool can call, such as "GET /k/v1/app/acl.json".
+type requestEndpoint struct {
+	// Method is the HTTP method, or an empty string to allow all methods.
+	Method string
+
+	// Path is the pattern of the path in the syntax of path.Match, such as "/k/v1/app/*.json".
+	Path string
+}
+
+func (e requestEndpoint) String() string {
+	if e.Method == "" {
+		return e.Path
+	}
+	return e.Method + " " + e.Path
+}
+
+// allows reports whether the endpoint allows the request.
+func (e requestEndpoint) allows(method, p string) bool {
+	ok, _ := path.Match(e.Path, p)
+	return ok && (e.Method == "" || e.Method == method)
+}
+
+// deleteRecordsEndpoints is the endpoints that can delete records, which are guarded by KINTONE_CONFIRM_DELETE in the other tools.
+var deleteRecordsEndpoints = []requestEndpoint{
+	{Method: "DELETE", Path: "/k/v1/records.json"},
+	{Method: "POST", Path: "/k/v1/bulkRequest.json"},
+}
+
+// bulkRecordsEndpoints is the endpoints that can change many records at once, which are guarded by KINTONE_MAX_AFFECTED_RECORDS in the other tools.
+var bulkRecordsEndpoints = []requestEndpoint{
+	{Method: "POST", Path: "/k/v1/records.json"},
+	{Method: "PUT", Path: "/k/v1/records.json"},
+	{Method: "DELETE", Path: "/k/v1/records.json"},
+	{Method: "POST", Path: "/k/v1/bulkRequest.json"},
+}
+
+// bypassedGuardrail returns an endpoint that KINTONE_REQUEST_ENDPOINTS allows although it bypasses the guardrail of the other tools, and the name of the guardrail.
+func (p *Policy) bypassedGuardrail() (requestEndpoint, string, bool) {
+	limited := p.MaxAffectedRecords > 0
+	for _, app := range p.Config.Apps {
+		limited = limited || app.MaxAffectedRecords > 0
+	}
+
+	check := func(guarded []requestEndpoint) (requestEndpoint, bool) {
+		for _, g := range guarded {
+			for _, e := range p.RequestEndpoints {
+				if e.allows(g.Method, g.Path) {
+					return g, true
+				}
+			}
+		}
+		return requestEndpoint{}, false
+	}
+	if p.ConfirmDeletes {
+		if e, ok := check(deleteRecordsEndpoints); ok {
+			return e, "KINTONE_CONFIRM_DELETE", true
+		}
+	}
+	if limited {
+		if e, ok := check(bulkRecordsEndpoints); ok {
+			return e, "KINTONE_MAX_AFFECTED_RECORDS", true
+		}
+	}
+	return requestEndpoint{}, "", false
+}
+
+// parseRequestEndpoints parses the endpoints in KINTONE_REQUEST_ENDPOINTS, such as "GET /k/v1/app/acl.json" or "/k/v1/app/*.json".
+func parseRequestEndpoints(ss []string) ([]requestEndpoint, error) {
+	var endpoints []requestEndpoint
+	for _, s := range ss {
+		var e requestEndpoint
+		if method, p, ok := strings.Cut(strings.TrimSpace(s), " "); ok {
+			e = requestEndpoint{Method: strings.ToUpper(method), Path: strings.TrimSpace(p)}
+		} else {
+			e = requestEndpoint{Path: strings.TrimSpace(s)}
+		}
+
+		switch e.Method {
+		case "", "GET", "POST", "PUT", "DELETE":
+		default:
+			return nil, fmt.Errorf("invalid method: %q: the method must be one of GET, POST, PUT, or DELETE", s)
+		}
+		if !strings.HasPrefix(e.Path, "/k/v1/") {
+			return nil, fmt.Errorf("invalid path: %q: the path must start with /k/v1/", s)
+		}
+		if _, err := path.Match(e.Path, ""); err != nil {
+			return nil, fmt.Errorf("invalid path: %q: %w", s, err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, nil
+}
+
+// requestAppIDs returns the app IDs in the parameters of a kintone API.
+// Most of the APIs take "app", but the APIs about apps themselves take "id" or "ids".
+func requestAppIDs(p string, body JsonMap) []string {
+	format := func(v any) string {
+		switch v := v.(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	var ids []string
+	if id := format(body["app"]); id != "" {
+		ids = append(ids, id)
+	}
+	if p == "/k/v1/app.json" || p == "/k/v1/preview/app.json" {
+		if id := format(body["id"]); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if p == "/k/v1/apps.json" {
+		list, _ := body["ids"].([]any)
+		for _, v := range list {
+			if id := format(v); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+type KintoneRequestParams struct {
+	Method string  `json:"method" required:"true" enum:"GET,POST,PUT,DELETE" description:"The HTTP method to call the API."`
+	Path   string  `json:"path" required:"true" description:"The path of the kintone REST API, such as '/k/v1/app/acl.json'."`
+	Body   JsonMap `json:"body" description:"The parameters of the API. They are sent as the JSON body, also for GET requests. For example, {\"app\": \"1\"}."`
+}
+
+func (h *KintoneHandlers) KintoneRequest(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req KintoneRequestParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	req.Method = strings.ToUpper(req.Method)
+	if req.Method == "" || req.Path == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Arguments 'method' and 'path' are required",
+		}
+	}
+
+	var operation string
+	switch req.Method {
+	case "GET":
+		operation = "read"
+	case "POST", "PUT":
+		operation = "write"
+	case "DELETE":
+		operation = "delete"
+	default:
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Method must be 'GET', 'POST', 'PUT', or 'DELETE'",
+		}
+	}
+	if !strings.HasPrefix(req.Path, "/k/v1/") || path.Clean(req.Path) != req.Path || strings.ContainsAny(req.Path, "?#") {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Invalid path: %s. The path must start with /k/v1/, such as '/k/v1/app/acl.json'.", req.Path),
+		}
+	}
+
+	p := h.policy()
+	if !slices.ContainsFunc(p.RequestEndpoints, func(e requestEndpoint) bool { return e.allows(req.Method, req.Path) }) {
+		names := make([]string, len(p.RequestEndpoints))
+		for i, e := range p.RequestEndpoints {
+			names[i] = e.String()
+		}
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("%s %s is not allowed by the server settings. The allowed endpoints are: %s", req.Method, req.Path, strings.Join(names, ", ")),
+		}
+	}
+
+	if p.ReadOnly && req.Method != "GET" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("%s requests are disabled because the server is in read-only mode", req.Method),
+		}
+	}
+	ids := requestAppIDs(req.Path, req.Body)
+	if len(ids) == 0 && (!p.Allow.IsEmpty() || !p.Deny.IsEmpty() || len(p.Config.Apps) > 0) {
+		// The APIs without an app ID, such as file.json, could access any app, so they can't be checked against the restrictions.
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("%s %s can't be called because the accessible apps are restricted by the server settings and the request doesn't specify an app ID", req.Method, req.Path),
+		}
+	}
+	for _, id := range ids {
+		if err := h.checkOperation(id, operation); err != nil {
+			return nil, err
+		}
+		// The responses of arbitrary APIs can't be filtered, so the apps that hide some fields are rejected.
+		if h.fieldFilter(id).active() || h.FieldACL {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("App ID %s can't be used with kintoneRequest because some fields of the app are restricted by the server settings", id),
+			}
+		}
+	}
+
+	var body any
+	if req.Body != nil {
+		body = req.Body
+	}
+	var res any
+	if err := h.FetchHTTPWithJSON(ctx, req.Method, req.Path, nil, body, &res); err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = JsonMap{}
+	}
+	return JSONContent(res)
+}
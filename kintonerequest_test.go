@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// callTestToolError calls the tool and returns the error message, or fails if the call succeeds.
+func callTestToolError(t *testing.T, h *KintoneHandlers, name string, args any) string {
+	t.Helper()
+
+	bs, _ := json.Marshal(args)
+	res, err := h.ToolsCall(context.Background(), ToolsCallRequest{Name: name, Arguments: bs})
+	if err != nil {
+		return err.Error()
+	}
+	if !res.IsError {
+		t.Fatalf("expected %s to fail but got %s", name, res.Content[0].Text)
+	}
+	return res.Content[0].Text
+}
+
+func TestKintoneRequestAudit(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	h := newTestHandlers(t, map[string]string{
+		"KINTONE_REQUEST_ENDPOINTS": "/k/v1/record.json",
+		"KINTONE_AUDIT_LOG":         auditPath,
+	})
+
+	callTestTool(t, h, "kintoneRequest", JsonMap{"method": "GET", "path": "/k/v1/record.json", "body": JsonMap{"app": "1", "id": "1"}})
+	callTestTool(t, h, "kintoneRequest", JsonMap{"method": "PUT", "path": "/k/v1/record.json", "body": JsonMap{"app": "1", "id": "1", "record": JsonMap{"note": JsonMap{"value": "updated"}}}})
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("failed to read the audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only the PUT request to be audited but got %d entries: %s", len(lines), data)
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("failed to parse the audit log: %v", err)
+	}
+	if entry.Tool != "kintoneRequest" || entry.AppID != "1" || entry.Outcome != "success" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestKintoneRequestBeforeWriteHook(t *testing.T) {
+	config := writeTestConfig(t, `
+hooks:
+  beforeWrite:
+    - command: [sh, -c, 'cat >/dev/null; echo "{\"allow\": false, \"message\": \"denied by the hook\"}"']
+`)
+	h := newTestHandlers(t, map[string]string{
+		"KINTONE_CONFIG_FILE":       config,
+		"KINTONE_REQUEST_ENDPOINTS": "/k/v1/record.json",
+	})
+
+	callTestTool(t, h, "kintoneRequest", JsonMap{"method": "GET", "path": "/k/v1/record.json", "body": JsonMap{"app": "1", "id": "1"}})
+
+	msg := callTestToolError(t, h, "kintoneRequest", JsonMap{"method": "PUT", "path": "/k/v1/record.json", "body": JsonMap{"app": "1", "id": "1", "record": JsonMap{}}})
+	if !strings.Contains(msg, "denied by the hook") {
+		t.Errorf("expected the PUT request to be rejected by the hook but got %s", msg)
+	}
+}
+
+func TestKintoneRequestDisabledByMasking(t *testing.T) {
+	config := writeTestConfig(t, "masking:\n  - preset: email\n")
+	h := newTestHandlers(t, map[string]string{
+		"KINTONE_CONFIG_FILE":       config,
+		"KINTONE_REQUEST_ENDPOINTS": "/k/v1/records.json",
+	})
+
+	msg := callTestToolError(t, h, "kintoneRequest", JsonMap{"method": "GET", "path": "/k/v1/records.json", "body": JsonMap{"app": "1"}})
+	if !strings.Contains(msg, "masking rules") {
+		t.Errorf("expected kintoneRequest to be disabled by the masking rules but got %s", msg)
+	}
+}
+
+func TestKintoneRequestWithoutAppID(t *testing.T) {
+	h := newTestHandlers(t, map[string]string{
+		"KINTONE_REQUEST_ENDPOINTS": "/k/v1/*.json",
+		"KINTONE_ALLOW_APPS":        "1",
+	})
+
+	msg := callTestToolError(t, h, "kintoneRequest", JsonMap{"method": "GET", "path": "/k/v1/file.json", "body": JsonMap{"fileKey": "mock-file-1"}})
+	if !strings.Contains(msg, "doesn't specify an app ID") {
+		t.Errorf("expected the request without an app ID to be rejected but got %s", msg)
+	}
+
+	callTestTool(t, h, "kintoneRequest", JsonMap{"method": "GET", "path": "/k/v1/app.json", "body": JsonMap{"id": "1"}})
+	msg = callTestToolError(t, h, "kintoneRequest", JsonMap{"method": "GET", "path": "/k/v1/app.json", "body": JsonMap{"id": "2"}})
+	if !strings.Contains(msg, "KINTONE_ALLOW_APPS") {
+		t.Errorf("expected the app that is not allowed to be rejected but got %s", msg)
+	}
+}
+
+func TestKintoneRequestReadOnly(t *testing.T) {
+	h := newTestHandlers(t, map[string]string{
+		"KINTONE_REQUEST_ENDPOINTS": "PUT /k/v1/record.json",
+		"KINTONE_READ_ONLY":         "true",
+	})
+	if err := h.checkToolEnabled("kintoneRequest"); err == nil {
+		t.Error("expected kintoneRequest to be disabled in read-only mode without GET endpoints")
+	}
+
+	h = newTestHandlers(t, map[string]string{
+		"KINTONE_REQUEST_ENDPOINTS": "/k/v1/record.json",
+		"KINTONE_READ_ONLY":         "true",
+	})
+	if err := h.checkToolEnabled("kintoneRequest"); err != nil {
+		t.Errorf("expected kintoneRequest to be enabled for GET requests in read-only mode: %v", err)
+	}
+}
+
+func TestKintoneRequestGuardrails(t *testing.T) {
+	for _, tt := range []struct {
+		env     map[string]string
+		enabled bool
+	}{
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "/k/v1/records.json"}, true},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "/k/v1/records.json", "KINTONE_CONFIRM_DELETE": "true"}, false},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "/k/v1/*.json", "KINTONE_CONFIRM_DELETE": "true"}, false},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "POST /k/v1/bulkRequest.json", "KINTONE_CONFIRM_DELETE": "true"}, false},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "GET /k/v1/records.json, PUT /k/v1/records.json", "KINTONE_CONFIRM_DELETE": "true"}, true},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "PUT /k/v1/records.json", "KINTONE_MAX_AFFECTED_RECORDS": "10"}, false},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "DELETE /k/v1/records.json", "KINTONE_MAX_AFFECTED_RECORDS": "10"}, false},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "GET /k/v1/records.json, PUT /k/v1/record.json", "KINTONE_MAX_AFFECTED_RECORDS": "10"}, true},
+		{map[string]string{"KINTONE_REQUEST_ENDPOINTS": "/k/v1/records.json", "KINTONE_MAX_AFFECTED_RECORDS": "0"}, true},
+	} {
+		// The environment variables are reset for each case by the subtest.
+		t.Run(fmt.Sprint(tt.env), func(t *testing.T) {
+			h := newTestHandlers(t, tt.env)
+			if err := h.checkToolEnabled("kintoneRequest"); tt.enabled && err != nil {
+				t.Errorf("expected kintoneRequest to be enabled: %v", err)
+			} else if !tt.enabled && err == nil {
+				t.Error("expected kintoneRequest to be disabled")
+			}
+		})
+	}
+
+	// maxAffectedRecords for an app in the configuration file is a guardrail too.
+	config := writeTestConfig(t, "apps:\n  \"1\":\n    permissions: {read: true, write: true, delete: true}\n    maxAffectedRecords: 5\n")
+	h := newTestHandlers(t, map[string]string{"KINTONE_REQUEST_ENDPOINTS": "/k/v1/records.json", "KINTONE_CONFIG_FILE": config})
+	msg := callTestToolError(t, h, "kintoneRequest", JsonMap{"method": "DELETE", "path": "/k/v1/records.json", "body": JsonMap{"app": "1", "ids": []string{"1", "2"}}})
+	if !strings.Contains(msg, "KINTONE_MAX_AFFECTED_RECORDS") {
+		t.Errorf("expected the bulk delete to be rejected but got %s", msg)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// Listen opens a listener for the address such as "unix:///run/kintone.sock" or "tcp://127.0.0.1:9000".
+// If tlsConfig is not nil, TCP connections are encrypted with TLS.
+func Listen(addr string, tlsConfig *tls.Config) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		// Remove the socket file left by the previous process that did not exit cleanly.
+		if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("address is already in use: %s", addr)
+			}
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+
+	if hostport, ok := strings.CutPrefix(addr, "tcp://"); ok {
+		if tlsConfig != nil {
+			return tls.Listen("tcp", hostport, tlsConfig)
+		}
+		return net.Listen("tcp", hostport)
+	}
+
+	return nil, fmt.Errorf("unsupported listen address: %s: the address should start with unix:// or tcp://", addr)
+}
+
+// checkListenAuth refuses a TCP address that other hosts can reach without KINTONE_MCP_AUTH_TOKENS, because the clients can use the credentials of the server.
+// TLS doesn't matter here, because it encrypts the connections but doesn't authenticate the clients.
+func checkListenAuth(addr string, authTokens []authToken) error {
+	hostport, ok := strings.CutPrefix(addr, "tcp://")
+	if !ok || len(authTokens) > 0 {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return fmt.Errorf("invalid listen address: %s: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("KINTONE_MCP_AUTH_TOKENS is required to listen on %s, because anyone who can reach it can access your kintone data: use a loopback address or a unix socket, or set the tokens", addr)
+}
+
+// LoadTLSConfig loads the certificate and the private key for TLS.
+// It returns nil if both of them are empty.
+func LoadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both KINTONE_MCP_TLS_CERT and KINTONE_MCP_TLS_KEY are required to enable TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+const (
+	// authHandshakeTimeout is the time limit for the client to send the token after connecting.
+	authHandshakeTimeout = 10 * time.Second
+
+	// maxAuthHandshakeSize is the maximum length of the line to send the token.
+	maxAuthHandshakeSize = 4096
+)
+
+// readAuthHandshake reads the first line of the connection, "Authorization: Bearer <token>", and returns the matched token.
+func readAuthHandshake(r *bufio.Reader, tokens []authToken) (authToken, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return authToken{}, errors.New("the authorization line is too long")
+	} else if err != nil {
+		return authToken{}, fmt.Errorf("failed to read the authorization line: %w", err)
+	}
+
+	name, value, _ := strings.Cut(strings.TrimRight(string(line), "\r\n"), ":")
+	given, ok := strings.CutPrefix(strings.TrimSpace(value), "Bearer ")
+	if !strings.EqualFold(name, "Authorization") || !ok {
+		return authToken{}, errors.New("the first line is not an Authorization header")
+	}
+	token, ok := matchAuthToken(tokens, given)
+	if !ok {
+		return authToken{}, errors.New("invalid token")
+	}
+	return token, nil
+}
+
+// ServeListener accepts connections from ln, and serves newline-delimited JSON-RPC on each of them.
+// If authTokens is not empty, clients have to send "Authorization: Bearer <token>" as the first line before the messages.
+// When ctx is canceled, it stops accepting new connections and waits for in-flight requests to complete.
+func ServeListener(ctx context.Context, server *jsonrpc2.Server, ln net.Listener, authTokens []authToken) error {
+	serverLog.Info("kintone server is running", "url", fmt.Sprintf("%s://%s", ln.Addr().Network(), ln.Addr()))
+
+	var mu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+	var wg sync.WaitGroup
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+
+		// Stop reading new messages. Session.Serve waits for in-flight requests before returning.
+		mu.Lock()
+		for conn := range conns {
+			conn.SetReadDeadline(time.Now())
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = struct{}{}
+		if ctx.Err() != nil {
+			conn.SetReadDeadline(time.Now())
+		}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+				conn.Close()
+			}()
+
+			s := NewSession(server, conn)
+			defer s.Close()
+
+			var r io.Reader = conn
+			if len(authTokens) > 0 {
+				br := bufio.NewReaderSize(conn, maxAuthHandshakeSize)
+				conn.SetReadDeadline(time.Now().Add(authHandshakeTimeout))
+				token, err := readAuthHandshake(br, authTokens)
+				if err != nil {
+					serverLog.Warn("Rejected the connection", "remoteAddr", conn.RemoteAddr().String(), "error", err)
+					json.NewEncoder(conn).Encode(rpcResponse{
+						Jsonrpc: "2.0",
+						Error:   &jsonrpc2.Error{Code: jsonrpc2.InvalidRequestCode, Message: "Unauthorized"},
+						ID:      jsonrpc2.NullID(),
+					})
+					return
+				}
+
+				// The deadline is kept if the server is shutting down, because it is used to stop reading.
+				mu.Lock()
+				if ctx.Err() == nil {
+					conn.SetReadDeadline(time.Time{})
+				}
+				mu.Unlock()
+
+				s.user = token.User
+				r = br
+			}
+
+			if err := s.Serve(context.Background(), r); err != nil {
+				serverLog.Warn("Connection closed", "remoteAddr", conn.RemoteAddr().String(), "error", err)
+			}
+		}()
+	}
+
+	serverLog.Info("Shutting down kintone server, waiting for in-flight requests")
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(shutdownTimeout):
+		return errors.New("failed to shutdown gracefully: timed out waiting for in-flight requests")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// startTestListener serves a JSON-RPC server that responds the user of the session to "whoami", on a loopback TCP address.
+func startTestListener(t *testing.T, tokens []authToken) string {
+	t.Helper()
+
+	server := jsonrpc2.NewServer()
+	server.On("whoami", jsonrpc2.Call(func(ctx context.Context, params any) (string, error) {
+		s := SessionFromContext(ctx)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.user, nil
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ServeListener(ctx, server, ln, tokens)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return ln.Addr().String()
+}
+
+// dialTestListener sends the lines to the listener, and returns the first response.
+func dialTestListener(t *testing.T, addr string, lines ...string) rpcResponse {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	for _, line := range lines {
+		fmt.Fprintln(conn, line)
+	}
+
+	var res rpcResponse
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	if err := json.Unmarshal(line, &res); err != nil {
+		t.Fatalf("failed to parse the response: %v: %s", err, line)
+	}
+	return res
+}
+
+func TestServeListenerAuthTokens(t *testing.T) {
+	addr := startTestListener(t, parseAuthTokens([]string{"shared-token", "alice:alice-token"}))
+	const request = `{"jsonrpc": "2.0", "id": 1, "method": "whoami"}`
+
+	for _, tt := range []struct {
+		name  string
+		lines []string
+		user  string
+		ok    bool
+	}{
+		{"without token", []string{request}, "", false},
+		{"wrong token", []string{"Authorization: Bearer wrong-token", request}, "", false},
+		{"shared token", []string{"Authorization: Bearer shared-token", request}, "", true},
+		{"token of user", []string{"Authorization: Bearer alice-token", request}, "alice", true},
+	} {
+		res := dialTestListener(t, addr, tt.lines...)
+		if !tt.ok {
+			if res.Error == nil || res.Error.Message != "Unauthorized" {
+				t.Errorf("%s: expected to be rejected but got %+v", tt.name, res)
+			}
+			continue
+		}
+		if res.Error != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, res.Error)
+			continue
+		}
+		var user string
+		json.Unmarshal(res.Result, &user)
+		if user != tt.user {
+			t.Errorf("%s: expected the user %q but got %q", tt.name, tt.user, user)
+		}
+	}
+}
+
+func TestServeListenerWithoutAuthTokens(t *testing.T) {
+	addr := startTestListener(t, nil)
+
+	res := dialTestListener(t, addr, `{"jsonrpc": "2.0", "id": 1, "method": "whoami"}`)
+	if res.Error != nil {
+		t.Errorf("unexpected error: %v", res.Error)
+	}
+}
+
+func TestCheckListenAuth(t *testing.T) {
+	tokens := parseAuthTokens([]string{"secret-token"})
+
+	for _, tt := range []struct {
+		addr   string
+		tokens []authToken
+		ok     bool
+	}{
+		{"unix:///run/kintone.sock", nil, true},
+		{"tcp://127.0.0.1:9000", nil, true},
+		{"tcp://[::1]:9000", nil, true},
+		{"tcp://localhost:9000", nil, true},
+		{"tcp://0.0.0.0:9000", nil, false},
+		{"tcp://:9000", nil, false},
+		{"tcp://192.0.2.1:9000", nil, false},
+		{"tcp://kintone.example.com:9000", nil, false},
+		{"tcp://0.0.0.0:9000", tokens, true},
+	} {
+		err := checkListenAuth(tt.addr, tt.tokens)
+		if tt.ok && err != nil {
+			t.Errorf("%s with %d tokens: unexpected error: %v", tt.addr, len(tt.tokens), err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s with %d tokens: expected an error", tt.addr, len(tt.tokens))
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// LogLevel is a severity of log messages, defined in the MCP specification as same as syslog.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelNotice
+	LogLevelWarning
+	LogLevelError
+	LogLevelCritical
+	LogLevelAlert
+	LogLevelEmergency
+)
+
+// defaultLogLevel is the minimum level of log messages to send before the client sets the level. It can be changed by KINTONE_LOG_LEVEL.
+var defaultLogLevel = LogLevelWarning
+
+var logLevelNames = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+func (l LogLevel) String() string {
+	if l < 0 || int(l) >= len(logLevelNames) {
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+func ParseLogLevel(s string) (LogLevel, bool) {
+	for i, name := range logLevelNames {
+		if name == s {
+			return LogLevel(i), true
+		}
+	}
+	return 0, false
+}
+
+type SetLogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (h *KintoneHandlers) SetLogLevel(ctx context.Context, params SetLogLevelRequest) (struct{}, error) {
+	level, ok := ParseLogLevel(params.Level)
+	if !ok {
+		return struct{}{}, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Unknown log level: %s", params.Level),
+		}
+	}
+
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		s.logLevel = level
+		s.mu.Unlock()
+	}
+
+	return struct{}{}, nil
+}
+
+// Log sends a log message to the client if the level is equal or higher than the level that the client requested.
+func (s *Session) Log(ctx context.Context, level LogLevel, logger string, data any) {
+	s.mu.Lock()
+	minLevel := s.logLevel
+	s.mu.Unlock()
+
+	if level < minLevel {
+		return
+	}
+
+	s.Notify(ctx, "notifications/message", JsonMap{
+		"level":  level.String(),
+		"logger": logger,
+		"data":   data,
+	})
+}
+
+// LogContext sends a log message to the client of the session associated with the context.
+// It does nothing if there is no session.
+func LogContext(ctx context.Context, level LogLevel, logger string, data any) {
+	if s := SessionFromContext(ctx); s != nil {
+		s.Log(ctx, level, logger, data)
+	}
+}
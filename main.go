@@ -3,24 +3,38 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	_ "embed"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
-	"text/template"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/macrat/go-jsonrpc2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -32,11 +46,28 @@ type JsonMap map[string]any
 
 type ServerInfo struct {
 	Name    string `json:"name"`
+	Title   string `json:"title,omitempty"`
 	Version string `json:"version"`
 }
 
 type InitializeRequest struct {
-	ProtocolVersion string `json:"protocolVersion"`
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      ClientInfo         `json:"clientInfo"`
+	Meta            struct {
+		Kintone *KintoneCredentials `json:"kintone"`
+	} `json:"_meta"`
+}
+
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type ClientCapabilities struct {
+	Roots *struct {
+		ListChanged bool `json:"listChanged"`
+	} `json:"roots,omitempty"`
 }
 
 type InitializeResult struct {
@@ -63,8 +94,10 @@ func JSONContent(v any) ([]Content, error) {
 
 type ToolInfo struct {
 	Name        string  `json:"name"`
+	Title       string  `json:"title,omitempty"`
 	Description string  `json:"description,omitempty"`
 	InputSchema JsonMap `json:"inputSchema"`
+	Annotations JsonMap `json:"annotations,omitempty"`
 }
 
 type ToolsListResult struct {
@@ -74,6 +107,13 @@ type ToolsListResult struct {
 type ToolsCallRequest struct {
 	Name      string          `json:"name"`
 	Arguments json.RawMessage `json:"arguments"`
+	Meta      struct {
+		ProgressToken any `json:"progressToken"`
+
+		// TraceParent and TraceState are the W3C trace context of the client, to continue the trace of the client.
+		TraceParent string `json:"traceparent"`
+		TraceState  string `json:"tracestate"`
+	} `json:"_meta"`
 }
 
 type ToolsCallResult struct {
@@ -106,42 +146,358 @@ type KintoneAppDetail struct {
 	CreatedAt         string            `json:"createdAt"`
 	ModifiedAt        string            `json:"modifiedAt"`
 	ProcessManagement ProcessManagement `json:"processManagement,omitempty"`
+	Views             JsonMap           `json:"views,omitempty"`
+	Settings          JsonMap           `json:"settings,omitempty"`
 }
 
 type KintoneHandlers struct {
-	URL   *url.URL
-	Auth  string
-	Token string
-	Allow []string
-	Deny  []string
+	URL           *url.URL
+	Username      string
+	Auth          string
+	BasicAuth     string
+	AllowedPaths  []string
+	DownloadDir   string
+	MaxUploadSize int64
+
+	// MaxResponseSize is the maximum size of records in the result of readRecords in bytes. 0 means no limit.
+	MaxResponseSize int
+
+	Instructions     string
+	InstructionsMode string
+	InstructionsApps bool
+
+	// Lang is the language of tool descriptions, instructions, and error messages, set by KINTONE_LANG.
+	Lang string
+
+	// Timeout is the time limit of a tool call, or a request to kintone outside of tool calls. 0 means no limit.
+	Timeout time.Duration
+
+	// CacheTTL is how long the app list and the app schemas are cached. 0 disables caching.
+	CacheTTL time.Duration
+
+	// Timezone is the timezone of date and time values, set by KINTONE_TIMEZONE. If nil, the timezone of the kintone user is used.
+	Timezone *time.Location
+
+	// ToolTimeouts overrides Timeout for each tool.
+	ToolTimeouts map[string]time.Duration
+
+	// Concurrency is the number of sub-requests that a tool runs in parallel, such as downloading multiple files.
+	Concurrency int
+
+	// Retry is the settings to retry requests that failed by transient problems.
+	Retry RetryPolicy
+
+	// AuditLog records write operations if KINTONE_AUDIT_LOG is set.
+	AuditLog *AuditLog
+
+	// SessionCredentials allows clients to provide their own credentials for each session.
+	SessionCredentials bool
+
+	// FieldACL applies the field permissions of kintone to the records that are read by API tokens.
+	FieldACL bool
+
+	// GzipRequests compresses large JSON request bodies, for kintone or proxies that accept Content-Encoding: gzip.
+	GzipRequests bool
+
+	// Debug writes the requests and responses of kintone to the server log, to diagnose errors of kintone.
+	Debug bool
+
+	// SecureAccess is true if a client certificate for cybozu.com Secure Access is configured.
+	SecureAccess bool
+
+	// Mock is true if KINTONE_MOCK is set, to serve the tools by the in-memory kintone instead of a real domain.
+	Mock bool
+
+	// CustomDomain allows the base URLs that are not a domain of kintone, such as a reverse proxy.
+	CustomDomain bool
+
+	// WebhookAddr is the address to receive the webhooks of kintone, that are forwarded to the clients as notifications.
+	WebhookAddr string
+
+	// webhookSecret is the secret that the webhooks have to provide.
+	webhookSecret string
+
+	client  *http.Client
+	limiter rateLimiter
+
+	// hookClient is the HTTP client to call the hooks, and afterHooks is the after hooks in background that the shutdown waits for.
+	hookClient *http.Client
+	afterHooks sync.WaitGroup
+
+	cache   ttlCache
+	masking maskingAudit
+
+	confirmations confirmationStore
+
+	// cursors is the cursors of kintone that are in use, which are deleted at shutdown.
+	cursors openCursors
+
+	// current is the settings that can be reloaded without restarting.
+	current  atomic.Pointer[Policy]
+	reloadMu sync.Mutex
+
+	// configPath is the path to KINTONE_CONFIG_FILE, which is watched to reload the settings.
+	configPath string
+
+	// readiness is the cached result of /readyz.
+	readiness readinessCache
 }
 
 func NewKintoneHandlersFromEnv() (*KintoneHandlers, error) {
 	var handlers KintoneHandlers
 	errs := []error{errors.New("Error:")}
 
+	// The configuration file has to be loaded first, because it provides default values of the environment variables.
+	// The settings are applied even without the file, to clear the settings of the previous file.
+	var config Configuration
+	if path := Getenv("KINTONE_CONFIG_FILE", ""); path != "" {
+		handlers.configPath = path
+		var err error
+		if config, err = LoadConfiguration(path); err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to load KINTONE_CONFIG_FILE: %s", err))
+		}
+	}
+	config.Apply()
+
+	errs = append(errs, configureServerLog()...)
+
+	handlers.SessionCredentials = GetenvBool("KINTONE_SESSION_CREDENTIALS")
+	handlers.CustomDomain = GetenvBool("KINTONE_CUSTOM_DOMAIN")
+	handlers.FieldACL = GetenvBool("KINTONE_FIELD_ACL")
+	handlers.GzipRequests = GetenvBool("KINTONE_GZIP_REQUESTS")
+	handlers.Debug = GetenvBool("KINTONE_DEBUG")
+
 	username := Getenv("KINTONE_USERNAME", "")
-	password := Getenv("KINTONE_PASSWORD", "")
-	tokens := Getenv("KINTONE_API_TOKEN", "")
-	if (username == "" || password == "") && tokens == "" {
-		errs = append(errs, errors.New("- Either KINTONE_USERNAME/KINTONE_PASSWORD or KINTONE_API_TOKEN must be provided"))
+	password, err := GetenvSecret("KINTONE_PASSWORD")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("- %s", err))
+	}
+	if GetenvBool("KINTONE_KEYRING") && password == "" && username != "" {
+		service := Getenv("KINTONE_KEYRING_SERVICE", defaultKeyringService)
+		if password, err = lookupKeyring(service, username); err != nil {
+			errs = append(errs, fmt.Errorf("- %s", err))
+		}
+	}
+
+	policy, policyErrs := loadPolicy(config)
+	errs = append(errs, policyErrs...)
+	handlers.current.Store(policy)
+
+	// The mock kintone doesn't need credentials, and the login user is the user in the fixture.
+	var mock *mockKintone
+	if handlers.Mock = GetenvBool("KINTONE_MOCK"); handlers.Mock {
+		serverLog.Warn("KINTONE_MOCK is set. The tools use the in-memory kintone, and the changes are lost when the server stops.")
+		if mock, err = loadMockKintone(Getenv("KINTONE_MOCK_FIXTURE", "")); err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to load KINTONE_MOCK_FIXTURE: %s", err))
+		} else {
+			username, password = mock.user, "mock-password"
+		}
+	}
+
+	if (username == "" || password == "") && policy.Token == "" && len(policy.AppTokens) == 0 && !handlers.SessionCredentials && !handlers.Mock {
+		errs = append(errs, errors.New("- Either KINTONE_USERNAME/KINTONE_PASSWORD, KINTONE_API_TOKEN, or KINTONE_API_TOKEN_<appID> must be provided"))
 	}
 	if username != "" && password != "" {
-		handlers.Auth = base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "%s:%s", username, password))
+		handlers.Username = username
+		handlers.Auth = basicAuth(username, password)
+	}
+
+	basicUsername := Getenv("KINTONE_BASIC_AUTH_USERNAME", "")
+	basicPassword := Getenv("KINTONE_BASIC_AUTH_PASSWORD", "")
+	if basicUsername != "" || basicPassword != "" {
+		handlers.BasicAuth = basicAuth(basicUsername, basicPassword)
 	}
-	handlers.Token = tokens
 
 	baseURL := Getenv("KINTONE_BASE_URL", "")
+	if baseURL == "" && handlers.Mock {
+		baseURL = mockBaseURL
+	}
 	if baseURL == "" {
-		errs = append(errs, errors.New("- KINTONE_BASE_URL must be provided"))
-	} else if u, err := url.Parse(baseURL); err != nil {
-		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_BASE_URL: %s", err))
+		if !handlers.SessionCredentials {
+			errs = append(errs, errors.New("- KINTONE_BASE_URL must be provided"))
+		}
+	} else if u, err := parseBaseURL(baseURL, handlers.CustomDomain || handlers.Mock); err != nil {
+		errs = append(errs, fmt.Errorf("- Invalid KINTONE_BASE_URL: %s", err))
 	} else {
 		handlers.URL = u
 	}
 
-	handlers.Allow = GetenvList("KINTONE_ALLOW_APPS")
-	handlers.Deny = GetenvList("KINTONE_DENY_APPS")
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile := Getenv("KINTONE_CLIENT_CERT_FILE", ""); certFile != "" {
+		cert, err := loadClientCertificate(certFile, Getenv("KINTONE_CLIENT_CERT_PASSWORD", ""))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to load KINTONE_CLIENT_CERT_FILE: %s", err))
+		} else {
+			tlsConfig.Certificates = []tls.Certificate{cert}
+			handlers.SecureAccess = true
+			if handlers.URL != nil {
+				handlers.URL = secureAccessURL(handlers.URL)
+			}
+		}
+	}
+
+	if caFile := Getenv("KINTONE_CA_FILE", ""); caFile != "" {
+		if pool, err := loadCertPool(caFile); err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to load KINTONE_CA_FILE: %s", err))
+		} else {
+			tlsConfig.RootCAs = pool
+		}
+	}
+
+	if v, ok := tlsVersions[Getenv("KINTONE_TLS_MIN_VERSION", "1.2")]; !ok {
+		errs = append(errs, errors.New("- KINTONE_TLS_MIN_VERSION must be one of 1.0, 1.1, 1.2, or 1.3"))
+	} else {
+		tlsConfig.MinVersion = v
+	}
+
+	if GetenvBool("KINTONE_TLS_INSECURE_SKIP_VERIFY") {
+		serverLog.Warn("KINTONE_TLS_INSECURE_SKIP_VERIFY is set. The certificate of kintone server is not verified. Never use this in production.")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	connectTimeout, err := parseTimeout(Getenv("KINTONE_CONNECT_TIMEOUT", defaultConnectTimeout.String()))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_CONNECT_TIMEOUT: %s", err))
+	}
+	proxy, err := parseProxy(Getenv("KINTONE_PROXY", ""))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_PROXY: %s", err))
+	}
+
+	if handlers.Timeout, err = parseTimeout(Getenv("KINTONE_TIMEOUT", defaultTimeout.String())); err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_TIMEOUT: %s", err))
+	}
+	if handlers.CacheTTL, err = parseTimeout(Getenv("KINTONE_CACHE_TTL", defaultCacheTTL.String())); err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_CACHE_TTL: %s", err))
+	}
+	if handlers.Concurrency, err = parseConcurrency(); err != nil {
+		errs = append(errs, err)
+	}
+	if handlers.Timezone, err = parseTimezone(); err != nil {
+		errs = append(errs, err)
+	}
+	if handlers.ToolTimeouts, err = parseToolTimeouts(GetenvList("KINTONE_TOOL_TIMEOUTS")); err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_TOOL_TIMEOUTS: %s", err))
+	}
+	var retryErrs []error
+	handlers.Retry, retryErrs = parseRetryPolicy()
+	errs = append(errs, retryErrs...)
+	errs = append(errs, parseRateLimit(&handlers.limiter)...)
+	handlers.client = newHTTPClient(httpClientOptions{
+		TLS:             tlsConfig,
+		ConnectTimeout:  connectTimeout,
+		Proxy:           proxy,
+		MaxConnsPerHost: handlers.limiter.MaxConcurrent,
+	})
+	handlers.hookClient = newHookHTTPClient(tlsConfig, connectTimeout, proxy)
+	if mock != nil {
+		handlers.client = &http.Client{Transport: mock}
+	}
+
+	recordDir, replayDir := Getenv("KINTONE_RECORD_FIXTURES", ""), Getenv("KINTONE_REPLAY_FIXTURES", "")
+	switch {
+	case recordDir != "" && replayDir != "":
+		errs = append(errs, errors.New("- KINTONE_RECORD_FIXTURES and KINTONE_REPLAY_FIXTURES can't be used together"))
+	case recordDir != "":
+		if recorder, err := newFixtureRecorder(recordDir, handlers.client.Transport); err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to prepare KINTONE_RECORD_FIXTURES: %s", err))
+		} else {
+			handlers.client = &http.Client{Transport: recorder}
+		}
+	case replayDir != "":
+		if replayer, err := loadFixtureReplayer(replayDir); err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to load KINTONE_REPLAY_FIXTURES: %s", err))
+		} else {
+			handlers.client = &http.Client{Transport: replayer}
+		}
+	}
+
+	if handlers.WebhookAddr = Getenv("KINTONE_WEBHOOK_ADDR", ""); handlers.WebhookAddr != "" {
+		if handlers.webhookSecret, err = GetenvSecret("KINTONE_WEBHOOK_SECRET"); err != nil {
+			errs = append(errs, fmt.Errorf("- %s", err))
+		} else if handlers.webhookSecret == "" {
+			errs = append(errs, errors.New("- KINTONE_WEBHOOK_SECRET must be provided to use KINTONE_WEBHOOK_ADDR"))
+		}
+	}
+
+	for name := range handlers.ToolTimeouts {
+		isAppTool := slices.ContainsFunc(config.appTools(), func(t appTool) bool { return t.Name == name })
+		_, isCommandTool := config.findCommandTool(name)
+		if _, ok := findTool(name); !ok && !isAppTool && !isCommandTool {
+			errs = append(errs, fmt.Errorf("- Unknown tool name in KINTONE_TOOL_TIMEOUTS: %s", name))
+		}
+	}
+
+	if path := Getenv("KINTONE_AUDIT_LOG", ""); path != "" {
+		maxSize, err := parseSize(Getenv("KINTONE_AUDIT_LOG_MAX_SIZE", "10MB"))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_AUDIT_LOG_MAX_SIZE: %s", err))
+		}
+		maxFiles, err := strconv.Atoi(Getenv("KINTONE_AUDIT_LOG_MAX_FILES", "5"))
+		if err != nil || maxFiles < 0 {
+			errs = append(errs, fmt.Errorf("- KINTONE_AUDIT_LOG_MAX_FILES must be a non-negative integer: %s", lookupSetting("KINTONE_AUDIT_LOG_MAX_FILES")))
+		}
+		if auditLog, err := OpenAuditLog(path, maxSize, maxFiles); err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to open KINTONE_AUDIT_LOG: %s", err))
+		} else {
+			handlers.AuditLog = auditLog
+		}
+	}
+
+	for _, p := range GetenvList("KINTONE_ALLOWED_PATHS") {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_ALLOWED_PATHS: %s: %s", p, err))
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		handlers.AllowedPaths = append(handlers.AllowedPaths, abs)
+	}
+
+	if dir := Getenv("KINTONE_DOWNLOAD_DIR", ""); dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_DOWNLOAD_DIR: %s: %s", dir, err))
+		} else {
+			handlers.DownloadDir = abs
+		}
+	}
+
+	if level := Getenv("KINTONE_LOG_LEVEL", ""); level != "" {
+		if l, ok := ParseLogLevel(level); ok {
+			defaultLogLevel = l
+		} else {
+			errs = append(errs, fmt.Errorf("- Unknown log level in KINTONE_LOG_LEVEL: %s", level))
+		}
+	}
+
+	if size, err := parseSize(Getenv("KINTONE_MAX_UPLOAD_SIZE", "1GB")); err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_MAX_UPLOAD_SIZE: %s", err))
+	} else {
+		handlers.MaxUploadSize = size
+	}
+	if s := Getenv("KINTONE_MAX_RESPONSE_SIZE", formatSize(defaultMaxResponseSize)); s == "0" {
+		handlers.MaxResponseSize = 0
+	} else if size, err := parseSize(s); err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_MAX_RESPONSE_SIZE: %s", err))
+	} else {
+		handlers.MaxResponseSize = int(size)
+	}
+
+	handlers.Instructions = Getenv("KINTONE_INSTRUCTIONS", "")
+	handlers.InstructionsMode = Getenv("KINTONE_INSTRUCTIONS_MODE", "append")
+	if handlers.InstructionsMode != "append" && handlers.InstructionsMode != "replace" {
+		errs = append(errs, errors.New("- KINTONE_INSTRUCTIONS_MODE must be 'append' or 'replace'"))
+	}
+	handlers.InstructionsApps = GetenvBool("KINTONE_INSTRUCTIONS_APPS")
+
+	handlers.Lang = Getenv("KINTONE_LANG", defaultLang)
+	if !slices.Contains(supportedLangs, handlers.Lang) {
+		errs = append(errs, fmt.Errorf("- KINTONE_LANG must be one of %s", strings.Join(supportedLangs, ", ")))
+	}
 
 	if len(errs) > 1 {
 		return nil, errors.Join(errs...)
@@ -161,7 +517,16 @@ func (q Query) Encode() string {
 }
 
 func (h *KintoneHandlers) SendHTTP(ctx context.Context, method, path string, query Query, body io.Reader, contentType string) (*http.Response, error) {
-	endpoint := h.URL.JoinPath(path)
+	if appID := requestAppID(path, query, nil); appID != "" {
+		ctx = withAppID(ctx, appID)
+	}
+
+	auth, err := h.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := auth.URL.JoinPath(path)
 	endpoint.RawQuery = query.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
@@ -172,33 +537,109 @@ func (h *KintoneHandlers) SendHTTP(ctx context.Context, method, path string, que
 		}
 	}
 
-	if h.Auth != "" {
-		req.Header.Set("X-Cybozu-Authorization", h.Auth)
-	}
-	if h.Token != "" {
-		req.Header.Set("X-Cybozu-API-Token", h.Token)
-	}
 	if body != nil {
 		req.Header.Set("Content-Type", contentType)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	return h.SendRequest(req)
+}
+
+// SendRequest sends the HTTP request to kintone server with the credentials.
+// It returns an error if the server doesn't respond with a successful status.
+func (h *KintoneHandlers) SendRequest(req *http.Request) (*http.Response, error) {
+	auth, err := h.auth(req.Context())
 	if err != nil {
+		return nil, err
+	}
+	addLogSecrets(auth.secrets()...)
+
+	// Tool calls have their own deadline. The other requests, such as resources/read, are limited by KINTONE_TIMEOUT.
+	cancel := context.CancelFunc(func() {})
+	if _, ok := req.Context().Deadline(); !ok && h.Timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), h.Timeout)
+		req = req.WithContext(ctx)
+	}
+	if auth.Auth != "" {
+		req.Header.Set("X-Cybozu-Authorization", auth.Auth)
+	}
+	if auth.Token != "" {
+		req.Header.Set("X-Cybozu-API-Token", auth.Token)
+	}
+	if auth.BasicAuth != "" {
+		req.Header.Set("Authorization", "Basic "+auth.BasicAuth)
+	}
+	if h.Lang != defaultLang {
+		// kintone also responds error messages in the language.
+		req.Header.Set("Accept-Language", h.Lang)
+	}
+	if id := requestIDFromContext(req.Context()); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+	req, span := startRequestSpan(req)
+	h.traceRequest(req)
+	if err := h.gzipRequestBody(req); err != nil {
+		cancel()
+		endSpan(span, err)
 		return nil, jsonrpc2.Error{
 			Code:    jsonrpc2.InternalErrorCode,
-			Message: fmt.Sprintf("Failed to send HTTP request to kintone server: %v", err),
+			Message: fmt.Sprintf("Failed to compress request body: %v", err),
 		}
 	}
 
-	if res.StatusCode != http.StatusOK {
+	start := time.Now()
+	res, err := h.doWithRetry(req)
+	if err != nil {
+		cancel()
+		LogContext(req.Context(), LogLevelError, "http", JsonMap{
+			"method":     req.Method,
+			"path":       req.URL.Path,
+			"error":      auth.redact(err.Error()),
+			"durationMs": time.Since(start).Milliseconds(),
+		})
+		endSpan(span, err)
+		countUsage(req.Context(), func(s *usageStats) { s.countRequest(true) })
+		serverLog.ErrorContext(req.Context(), "kintone request failed", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "durationMs", time.Since(start).Milliseconds(), "error", err)
+		if isTimeout(err) {
+			return nil, newTimeoutError(0)
+		}
+		msg := auth.redact(fmt.Sprintf("Failed to send HTTP request to kintone server: %v", err))
+		var unknownAuthority x509.UnknownAuthorityError
+		if errors.As(err, &unknownAuthority) {
+			msg += "\nThe certificate of the server is signed by an unknown authority. If you use a proxy with a private CA, please set KINTONE_CA_FILE."
+		}
+		return nil, &KintoneAPIError{
+			Message: msg,
+		}
+	}
+	h.traceResponse(req, res, start)
+
+	level := LogLevelInfo
+	if res.StatusCode >= 400 {
+		level = LogLevelError
+	}
+	LogContext(req.Context(), level, "http", JsonMap{
+		"method":     req.Method,
+		"path":       req.URL.Path,
+		"status":     res.StatusCode,
+		"durationMs": time.Since(start).Milliseconds(),
+	})
+	serverLog.DebugContext(req.Context(), "kintone request", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "status", res.StatusCode, "durationMs", time.Since(start).Milliseconds())
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	countUsage(req.Context(), func(s *usageStats) { s.countRequest(res.StatusCode >= 400) })
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
 		msg, _ := io.ReadAll(res.Body)
 		res.Body.Close()
-		return nil, jsonrpc2.Error{
-			Code:    jsonrpc2.InternalErrorCode,
-			Message: fmt.Sprintf("kintone server returned an error: %s\n%s", res.Status, msg),
-		}
+		cancel()
+		// The body could contain a dump of the request, such as an error page of a proxy.
+		apiErr := newKintoneAPIError(res.StatusCode, res.Status, []byte(auth.redact(string(msg))))
+		endSpan(span, apiErr)
+		return nil, apiErr
 	}
+	endSpan(span, nil)
 
+	res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancel}
 	return res, nil
 }
 
@@ -221,103 +662,264 @@ func (h *KintoneHandlers) FetchHTTPWithReader(ctx context.Context, method, path
 	return nil
 }
 
-func (h *KintoneHandlers) FetchHTTPWithJSON(ctx context.Context, method, path string, query Query, body, result any) error {
-	var reqBody io.Reader
-	if body != nil {
-		bs, err := json.Marshal(body)
-		if err != nil {
-			return jsonrpc2.Error{
-				Code:    jsonrpc2.InternalErrorCode,
-				Message: fmt.Sprintf("Failed to prepare request body for kintone server: %v", err),
-			}
+// jsonBody encodes the request body for kintone. It returns nil if body is nil.
+func jsonBody(body any) (io.Reader, error) {
+	if body == nil {
+		return nil, nil
+	}
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to prepare request body for kintone server: %v", err),
 		}
-		reqBody = bytes.NewReader(bs)
+	}
+	return bytes.NewReader(bs), nil
+}
+
+func (h *KintoneHandlers) FetchHTTPWithJSON(ctx context.Context, method, path string, query Query, body, result any) error {
+	reqBody, err := jsonBody(body)
+	if err != nil {
+		return err
 	}
 
+	ctx = withAppID(ctx, requestAppID(path, query, body))
+
 	return h.FetchHTTPWithReader(ctx, method, path, query, reqBody, "application/json", result)
 }
 
+// SupportedProtocolVersions is the list of MCP versions that the server supports, in ascending order.
+var SupportedProtocolVersions = []string{
+	"2024-11-05",
+	"2025-03-26",
+	"2025-06-18",
+}
+
+// LatestProtocolVersion is the newest MCP version that the server supports.
+var LatestProtocolVersion = SupportedProtocolVersions[len(SupportedProtocolVersions)-1]
+
+// negotiateProtocolVersion decides the protocol version to use.
+// As the MCP specification says, the server responds with the requested version if it supports, otherwise the latest version it supports.
+func negotiateProtocolVersion(requested string) string {
+	if slices.Contains(SupportedProtocolVersions, requested) {
+		return requested
+	}
+	return LatestProtocolVersion
+}
+
 func (h *KintoneHandlers) InitializeHandler(ctx context.Context, params InitializeRequest) (InitializeResult, error) {
-	version := "2025-03-26"
-	if params.ProtocolVersion < version {
-		version = params.ProtocolVersion
+	version := negotiateProtocolVersion(params.ProtocolVersion)
+
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		creds := s.credentials
+		s.mu.Unlock()
+		if params.Meta.Kintone != nil {
+			creds = *params.Meta.Kintone
+		}
+
+		var auth *kintoneAuth
+		if !creds.IsZero() {
+			var err error
+			if auth, err = h.resolveCredentials(creds); err != nil {
+				return InitializeResult{}, err
+			}
+		}
+
+		s.mu.Lock()
+		s.protocolVersion = version
+		s.clientCapabilities = params.Capabilities
+		s.clientInfo = params.ClientInfo
+		s.auth = auth
+		s.mu.Unlock()
+	}
+
+	info := ServerInfo{
+		Name:    "Kintone Server",
+		Version: fmt.Sprintf("%s (%s)", Version, Commit),
+	}
+	if version >= "2025-06-18" {
+		info.Title = "kintone"
+	}
+
+	capabilities := JsonMap{
+		"tools":     JsonMap{"listChanged": true},
+		"resources": JsonMap{},
+		"logging":   JsonMap{},
+	}
+	if h.WebhookAddr != "" {
+		// The changes of records are notified only by the webhooks.
+		capabilities["resources"] = JsonMap{"subscribe": true}
+	}
+	if version >= "2025-03-26" {
+		capabilities["completions"] = JsonMap{}
 	}
 
 	return InitializeResult{
 		ProtocolVersion: version,
-		Capabilities: JsonMap{
-			"tools": JsonMap{},
-		},
-		ServerInfo: ServerInfo{
-			Name:    "Kintone Server",
-			Version: fmt.Sprintf("%s (%s)", Version, Commit),
-		},
-		Instructions: fmt.Sprintf("kintone is a database service to store and manage enterprise data. You can use this server to interact with kintone."),
+		Capabilities:    capabilities,
+		ServerInfo:      info,
+		Instructions:    h.buildInstructions(ctx),
 	}, nil
 }
 
-//go:embed tools_list.json
-var toolsListTmplStr string
-
-var toolsList ToolsListResult
+func (h *KintoneHandlers) ToolsList(ctx context.Context, params any) (ToolsListResult, error) {
+	version := ProtocolVersionFromContext(ctx)
 
-func init() {
-	tmpl, err := template.New("tools_list").Parse(toolsListTmplStr)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to parse tools list template: %v", err))
+	var profiles []string
+	if len(h.policy().Profiles) > 0 {
+		profiles = h.profileNames()
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, nil); err != nil {
-		panic(fmt.Sprintf("Failed to render tools list template: %v", err))
+	candidates := slices.Clone(toolsList.Tools)
+	for _, at := range h.policy().Config.appTools() {
+		if h.checkToolEnabled(at.Name) != nil {
+			continue
+		}
+		info, err := h.appToolInfo(ctx, at)
+		if err != nil {
+			LogContext(ctx, LogLevelWarning, "tools", fmt.Sprintf("Failed to generate tool %s from app ID %s: %v", at.Name, at.AppID, err))
+			continue
+		}
+		candidates = append(candidates, info)
+	}
+	for _, ct := range h.policy().Config.Tools {
+		candidates = append(candidates, ct.info())
 	}
 
-	if err := json.Unmarshal(buf.Bytes(), &toolsList); err != nil {
-		panic(fmt.Sprintf("Failed to parse tools list JSON: %v", err))
+	tools := make([]ToolInfo, 0, len(candidates))
+	for _, t := range candidates {
+		if h.checkToolEnabled(t.Name) != nil {
+			continue
+		}
+		if profiles != nil && t.Name != "listProfiles" {
+			t.InputSchema = withProfileProperty(t.InputSchema, profiles)
+		}
+		t = h.localizeTool(t)
+		if t.Name == "deleteRecord" && h.policy().ConfirmDeletes {
+			t.Description += h.tr(" This server requires confirmation to delete: the first call returns a preview of the record and a confirmationToken, and the record is deleted only when called again with the token.")
+		}
+		if t.Name == "createRecordFromTemplate" {
+			var names []string
+			for _, tmpl := range h.recordTemplates("") {
+				names = append(names, fmt.Sprintf("%s (app ID %s)", tmpl["template"], tmpl["appID"]))
+			}
+			t.Description += h.tr(fmt.Sprintf(" The available templates are: %s.", strings.Join(names, ", ")))
+		}
+		if op, ok := appScopedTools[t.Name]; ok {
+			if ids, all := h.appsWithPermission(op); !all {
+				t.Description += h.tr(fmt.Sprintf(" This tool can only be used for the following app IDs: %s.", strings.Join(ids, ", ")))
+			}
+		}
+		if version < "2025-03-26" {
+			// Tool annotations are introduced in 2025-03-26.
+			t.Annotations = nil
+		}
+		if version >= "2025-06-18" {
+			if title, ok := t.Annotations["title"].(string); ok {
+				t.Title = title
+			}
+		}
+		tools = append(tools, t)
 	}
-}
 
-func (h *KintoneHandlers) ToolsList(ctx context.Context, params any) (ToolsListResult, error) {
-	return toolsList, nil
+	return ToolsListResult{Tools: tools}, nil
 }
 
 func (h *KintoneHandlers) ToolsCall(ctx context.Context, params ToolsCallRequest) (ToolsCallResult, error) {
+	ctx = withRequestID(ctx, newRequestID())
+	ctx, span := startToolSpan(ctx, params)
+	span.SetAttributes(attribute.String("mcp.request_id", requestIDFromContext(ctx)))
+	start := time.Now()
+	result, err := h.callTool(ctx, params)
+
+	attrs := []any{"tool", params.Name, "durationMs", time.Since(start).Milliseconds()}
+	if profile := profileFromContext(ctx); profile != "" {
+		attrs = append(attrs, "profile", profile)
+	}
+	// Unknown names are counted together, so that a client can't grow the statistics without limit.
+	name := params.Name
+	if _, ok := findTool(name); !ok {
+		_, isAppTool := h.findAppTool(name)
+		_, isCommandTool := h.findCommandTool(name)
+		if !isAppTool && !isCommandTool {
+			name = "(unknown)"
+		}
+	}
+	countUsage(ctx, func(s *usageStats) { s.countToolCall(name, err != nil || result.IsError) })
+	switch {
+	case err != nil:
+		serverLog.WarnContext(ctx, "Tool call failed", append(attrs, "error", err)...)
+		endSpan(span, err)
+	case result.IsError:
+		serverLog.WarnContext(ctx, "Tool call returned an error", attrs...)
+		span.SetStatus(codes.Error, "tool returned an error")
+		span.End()
+	default:
+		serverLog.InfoContext(ctx, "Tool call succeeded", attrs...)
+		span.End()
+	}
+
+	return result, withErrorIDs(ctx, h.localizeError(err))
+}
+
+func (h *KintoneHandlers) callTool(ctx context.Context, params ToolsCallRequest) (ToolsCallResult, error) {
 	var content []Content
 	var err error
 
-	switch params.Name {
-	case "listApps":
-		content, err = h.ListApps(ctx, params.Arguments)
-	case "readAppInfo":
-		content, err = h.ReadAppInfo(ctx, params.Arguments)
-	case "createRecord":
-		content, err = h.CreateRecord(ctx, params.Arguments)
-	case "readRecords":
-		content, err = h.ReadRecords(ctx, params.Arguments)
-	case "updateRecord":
-		content, err = h.UpdateRecord(ctx, params.Arguments)
-	case "deleteRecord":
-		content, err = h.DeleteRecord(ctx, params.Arguments)
-	case "downloadAttachmentFile":
-		content, err = h.DownloadAttachmentFile(ctx, params.Arguments)
-	case "uploadAttachmentFile":
-		content, err = h.UploadAttachmentFile(ctx, params.Arguments)
-	case "readRecordComments":
-		content, err = h.ReadRecordComments(ctx, params.Arguments)
-	case "createRecordComment":
-		content, err = h.CreateRecordComment(ctx, params.Arguments)
-	case "updateProcessManagementAssignee":
-		content, err = h.UpdateProcessManagementAssignee(ctx, params.Arguments)
-	case "executeProcessManagementAction":
-		content, err = h.ExecuteProcessManagementAction(ctx, params.Arguments)
-	default:
+	ctx = withProgressToken(ctx, params.Meta.ProgressToken)
+
+	if err := h.checkToolEnabled(params.Name); err != nil {
+		return ToolsCallResult{}, err
+	}
+
+	ctx, err = h.selectProfile(ctx, params.Arguments)
+	if err != nil {
+		return ToolsCallResult{}, err
+	}
+
+	timeout := h.toolTimeout(params.Name)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if h.isWriteCall(params) {
+		var args json.RawMessage
+		if args, err = h.runBeforeWriteHooks(ctx, params); err == nil {
+			params.Arguments = args
+		}
+	}
+
+	if err != nil {
+		// The call is rejected by a hook, but it is still audited.
+	} else if t, ok := findTool(params.Name); ok {
+		content, err = t.Handler(h, ctx, params.Arguments)
+	} else if t, ok := h.findAppTool(params.Name); ok {
+		content, err = h.CallAppTool(ctx, t, params.Arguments)
+	} else if t, ok := h.findCommandTool(params.Name); ok {
+		content, err = h.CallCommandTool(ctx, t, params.Arguments)
+	} else {
 		return ToolsCallResult{}, jsonrpc2.Error{
 			Code:    jsonrpc2.InvalidParamsCode,
 			Message: fmt.Sprintf("Unknown tool name: %s", params.Name),
 		}
 	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		// The error could be caused while reading the response, such as "failed to parse kintone server's response".
+		err = newTimeoutError(timeout)
+	}
 
-	if err != nil {
+	h.auditToolCall(ctx, params, content, err)
+	h.runAfterHooks(ctx, params, content, err)
+
+	var apiErr *KintoneAPIError
+	if errors.As(err, &apiErr) {
+		apiErr.RequestID = requestIDFromContext(ctx)
+		apiErr.TraceID = traceID(ctx)
+		return apiErr.ToolResult(), nil
+	} else if err != nil {
 		return ToolsCallResult{}, err
 	}
 
@@ -326,14 +928,152 @@ func (h *KintoneHandlers) ToolsCall(ctx context.Context, params ToolsCallRequest
 	}, nil
 }
 
+// writeTools is the set of tools that modify data in kintone.
+var writeTools = map[string]bool{
+	"createRecord":                    true,
+	"importRecords":                   true,
+	"createRecordFromTemplate":        true,
+	"restoreAppData":                  true,
+	"updateRecord":                    true,
+	"deleteRecord":                    true,
+	"uploadAttachmentFile":            true,
+	"createRecordComment":             true,
+	"createRecordComments":            true,
+	"updateProcessManagementAssignee": true,
+	"executeProcessManagementAction":  true,
+	"updateAppAcl":                    true,
+	"updateRecordAcl":                 true,
+	"updateFieldAcl":                  true,
+	"updateGeneralNotifications":      true,
+	"updatePerRecordNotifications":    true,
+	"deployAppSettings":               true,
+}
+
+// isWriteTool reports whether the tool modifies data in kintone, including the tools generated from apps and the command tools that are not read-only.
+func (h *KintoneHandlers) isWriteTool(name string) bool {
+	_, ok := h.findAppTool(name)
+	if t, isCommandTool := h.findCommandTool(name); isCommandTool && !t.ReadOnly {
+		return true
+	}
+	return writeTools[name] || ok
+}
+
+// isWriteCall reports whether the tool call modifies data in kintone.
+// kintoneRequest is a write unless the method is GET, so that the hooks and the audit log can't be bypassed by calling the APIs directly.
+func (h *KintoneHandlers) isWriteCall(params ToolsCallRequest) bool {
+	if params.Name == "kintoneRequest" {
+		var args struct {
+			Method string `json:"method"`
+		}
+		json.Unmarshal(params.Arguments, &args)
+		return !strings.EqualFold(args.Method, "GET")
+	}
+	return h.isWriteTool(params.Name)
+}
+
+// checkToolEnabled checks if the tool can be used with the current settings.
+func (h *KintoneHandlers) checkToolEnabled(name string) error {
+	p := h.policy()
+	if p.ReadOnly && h.isWriteTool(name) {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled because the server is in read-only mode", name),
+		}
+	}
+	if slices.Contains(p.DisabledTools, name) {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled by the server settings", name),
+		}
+	}
+	if t, ok := h.findAppTool(name); ok {
+		if err := h.checkWritePermission(t.AppID); err != nil {
+			return jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Tool %s is disabled because the write permission for app ID %s is not granted", name, t.AppID),
+			}
+		}
+	}
+	if name == "createRecordFromTemplate" && !p.Config.hasRecordTemplates() {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled because no record templates are configured", name),
+		}
+	}
+	if name == "kintoneRequest" && len(p.RequestEndpoints) == 0 {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled because no endpoints are allowed by KINTONE_REQUEST_ENDPOINTS", name),
+		}
+	}
+	if name == "kintoneRequest" && p.ReadOnly && !slices.ContainsFunc(p.RequestEndpoints, func(e requestEndpoint) bool { return e.Method == "" || e.Method == "GET" }) {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled because the server is in read-only mode and no GET endpoints are allowed by KINTONE_REQUEST_ENDPOINTS", name),
+		}
+	}
+	if name == "kintoneRequest" && len(p.Config.Masking) > 0 {
+		// The responses of arbitrary APIs can't be masked, because it is unknown which values are record fields.
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled because masking rules are configured", name),
+		}
+	}
+	if e, guardrail, ok := p.bypassedGuardrail(); name == "kintoneRequest" && ok {
+		// The passthrough would delete or change the records without the confirmation or the limit that the other tools apply.
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled because KINTONE_REQUEST_ENDPOINTS allows %s, that bypasses %s", name, e, guardrail),
+		}
+	}
+	if name == "listProfiles" && len(p.Profiles) == 0 {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Tool %s is disabled because no profiles are configured", name),
+		}
+	}
+	if op, ok := appScopedTools[name]; ok {
+		if ids, all := h.appsWithPermission(op); !all && len(ids) == 0 {
+			return jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Tool %s is disabled because no app has the %s permission in the configuration file", name, op),
+			}
+		}
+	}
+	return nil
+}
+
+// checkPermissions checks if the app can be read.
 func (h *KintoneHandlers) checkPermissions(id string) error {
-	if slices.Contains(h.Deny, id) {
+	return h.checkOperation(id, "read")
+}
+
+// checkWritePermission checks if records in the app can be created or updated.
+func (h *KintoneHandlers) checkWritePermission(id string) error {
+	return h.checkOperation(id, "write")
+}
+
+// checkDeletePermission checks if records in the app can be deleted.
+func (h *KintoneHandlers) checkDeletePermission(id string) error {
+	return h.checkOperation(id, "delete")
+}
+
+// checkAllowed checks if the app is allowed by KINTONE_ALLOW_APPS and KINTONE_DENY_APPS.
+func (h *KintoneHandlers) checkAllowed(id string) error {
+	p := h.policy()
+	if !p.Deny.Resolved() {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("App ID %s is inaccessible because the app names for KINTONE_DENY_APPS could not be fetched. Please check the MCP server settings.", id),
+		}
+	}
+	if p.Deny.Match(id) {
 		return jsonrpc2.Error{
 			Code:    jsonrpc2.InvalidParamsCode,
 			Message: fmt.Sprintf("App ID %s is inaccessible because it is listed in the KINTONE_DENY_APPS environment variable. Please check the MCP server settings.", id),
 		}
 	}
-	if len(h.Allow) > 0 && !slices.Contains(h.Allow, id) {
+	if !p.Allow.IsEmpty() && !p.Allow.Match(id) {
 		return jsonrpc2.Error{
 			Code:    jsonrpc2.InvalidParamsCode,
 			Message: fmt.Sprintf("App ID %s is inaccessible because it is not listed in the KINTONE_ALLOW_APPS environment variable. Please check the MCP server settings.", id),
@@ -343,12 +1083,14 @@ func (h *KintoneHandlers) checkPermissions(id string) error {
 	return nil
 }
 
+type ListAppsParams struct {
+	Offset int     `json:"offset" description:"The offset of apps to read. Default is 0."`
+	Limit  *int    `json:"limit" description:"The maximum number of apps to read. Default is 100, maximum is 100. The result might be different from the limit because of the permission. Use nextOffset in the result to read the next page."`
+	Name   *string `json:"name" description:"The name or a part of name of the apps to search. Highly recommended to use this parameter to find the app you want to use."`
+}
+
 func (h *KintoneHandlers) ListApps(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		Offset int     `json:"offset"`
-		Limit  *int    `json:"limit"`
-		Name   *string `json:"name"`
-	}
+	var req ListAppsParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -368,16 +1110,40 @@ func (h *KintoneHandlers) ListApps(ctx context.Context, params json.RawMessage)
 		}
 	}
 
-	type Res struct {
+	// Read one more app to know if there is the next page without another request.
+	// kintone returns at most 100 apps, so a full page of 100 is checked by another request below.
+	limit := *req.Limit
+	fetch := req
+	fetchLimit := min(limit+1, 100)
+	fetch.Limit = &fetchLimit
+
+	var httpRes struct {
 		Apps []KintoneAppDetail `json:"apps"`
 	}
-
-	var httpRes Res
-	err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apps.json", nil, req, &httpRes)
+	err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apps.json", nil, fetch, &httpRes)
 	if err != nil {
 		return nil, err
 	}
 
+	hasNext := len(httpRes.Apps) > limit
+	if !hasNext && len(httpRes.Apps) == 100 {
+		probe := req
+		probe.Offset = req.Offset + 100
+		probeLimit := 1
+		probe.Limit = &probeLimit
+
+		var next struct {
+			Apps []KintoneAppDetail `json:"apps"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apps.json", nil, probe, &next); err != nil {
+			return nil, err
+		}
+		hasNext = len(next.Apps) > 0
+	}
+	if len(httpRes.Apps) > limit {
+		httpRes.Apps = httpRes.Apps[:limit]
+	}
+
 	apps := make([]KintoneAppDetail, 0, len(httpRes.Apps))
 	for _, app := range httpRes.Apps {
 		if err := h.checkPermissions(app.AppID); err == nil {
@@ -385,23 +1151,24 @@ func (h *KintoneHandlers) ListApps(ctx context.Context, params json.RawMessage)
 		}
 	}
 
-	hasNext := false
-	var httpRes2 Res
-	err = h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apps.json", nil, JsonMap{"offset": req.Offset + len(httpRes.Apps), "limit": 1}, &httpRes2)
-	if err == nil {
-		hasNext = len(httpRes2.Apps) > 0
-	}
-
-	return JSONContent(JsonMap{
+	result := JsonMap{
 		"apps":    apps,
 		"hasNext": hasNext,
-	})
+	}
+	if hasNext {
+		result["nextOffset"] = req.Offset + len(httpRes.Apps)
+	}
+	return JSONContent(result)
+}
+
+type ReadAppInfoParams struct {
+	AppID           string `json:"appID" required:"true" description:"The app ID to get information from."`
+	IncludeViews    bool   `json:"includeViews" description:"If true, the views of the app are included, such as the filter conditions and the displayed fields. Default is false."`
+	IncludeSettings bool   `json:"includeSettings" description:"If true, the general settings of the app are included, such as the icon, the theme, and the title field. Default is false."`
 }
 
 func (h *KintoneHandlers) ReadAppInfo(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID string `json:"appID"`
-	}
+	var req ReadAppInfoParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -416,37 +1183,95 @@ func (h *KintoneHandlers) ReadAppInfo(ctx context.Context, params json.RawMessag
 		return nil, err
 	}
 
-	var app KintoneAppDetail
-	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/app.json", Query{"id": req.AppID}, nil, &app); err != nil {
+	app, err := h.fetchAppDetail(ctx, req.AppID, appDetailOptions{Views: req.IncludeViews, Settings: req.IncludeSettings})
+	if err != nil {
 		return nil, err
 	}
 
-	var fields struct {
-		Properties JsonMap `json:"properties"`
+	return JSONContent(app)
+}
+
+// appDetailOptions is the optional parts of the app information to fetch.
+type appDetailOptions struct {
+	Views    bool
+	Settings bool
+}
+
+// fetchAppDetail fetches the app information, the fields, and the process management settings of the app.
+// The requests are sent in parallel.
+func (h *KintoneHandlers) fetchAppDetail(ctx context.Context, appID string, opts appDetailOptions) (KintoneAppDetail, error) {
+	var (
+		app    KintoneAppDetail
+		fields struct {
+			Properties JsonMap `json:"properties"`
+		}
+		process ProcessManagement
+		views   struct {
+			Views JsonMap `json:"views"`
+		}
+		settings JsonMap
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return h.fetchAppSchema(gctx, "/k/v1/app.json", appID, &app)
+	})
+	g.Go(func() error {
+		return h.fetchAppSchema(gctx, "/k/v1/app/form/fields.json", appID, &fields)
+	})
+	g.Go(func() error {
+		return h.fetchAppSchema(gctx, "/k/v1/app/status.json", appID, &process)
+	})
+	if opts.Views {
+		g.Go(func() error {
+			return h.fetchAppSchema(gctx, "/k/v1/app/views.json", appID, &views)
+		})
 	}
-	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/app/form/fields.json", Query{"app": req.AppID}, nil, &fields); err != nil {
-		return nil, err
+	if opts.Settings {
+		g.Go(func() error {
+			return h.fetchAppSchema(gctx, "/k/v1/app/settings.json", appID, &settings)
+		})
 	}
+	if err := g.Wait(); err != nil {
+		return KintoneAppDetail{}, err
+	}
+
+	filter := h.fieldFilter(appID)
+	filter.filterProperties(fields.Properties)
 	app.Properties = fields.Properties
 
-	var process ProcessManagement
-	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/app/status.json", Query{"app": req.AppID}, nil, &process); err != nil {
-		return nil, err
-	}
 	if !process.Enable {
 		process.States = nil
 		process.Actions = nil
 	}
 	app.ProcessManagement = process
 
-	return JSONContent(app)
+	if opts.Views {
+		if filter.active() {
+			tables, err := h.fieldTables(ctx, appID)
+			if err != nil {
+				return KintoneAppDetail{}, err
+			}
+			filter.filterViews(views.Views, tables)
+		}
+		app.Views = views.Views
+	}
+	if opts.Settings {
+		delete(settings, "revision")
+		app.Settings = settings
+	}
+
+	return app, nil
+}
+
+type CreateRecordParams struct {
+	AppID  string      `json:"appID" required:"true" description:"The app ID to create a record in."`
+	Record RecordParam `json:"record" required:"true" description:"The record data to create. Record data format is the same as kintone's record data format. For example, {\"field1\": {\"value\": \"value1\"}, \"field2\": {\"value\": \"value2\"}, \"field3\": {\"value\": \"value3\"}}."`
+	Format string      `json:"format" enum:"kintone,simple" description:"The format of the record data. 'kintone' is the format of kintone REST API, and 'simple' is the plain values without {\"value\": ...} such as {\"field1\": \"value1\", \"table1\": [{\"cell1\": \"value\"}]}. Default is 'kintone'."`
 }
 
 func (h *KintoneHandlers) CreateRecord(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID  string  `json:"appID"`
-		Record JsonMap `json:"record"`
-	}
+	var req CreateRecordParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -457,9 +1282,23 @@ func (h *KintoneHandlers) CreateRecord(ctx context.Context, params json.RawMessa
 		}
 	}
 
-	if err := h.checkPermissions(req.AppID); err != nil {
+	if err := checkRecordFormat(req.Format); err != nil {
+		return nil, err
+	}
+
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+	if req.Format == recordFormatSimple {
+		var err error
+		if req.Record, err = h.expandRecord(ctx, req.AppID, req.Record); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.fieldFilter(req.AppID).checkRecord(req.AppID, map[string]any(req.Record)); err != nil {
 		return nil, err
 	}
+	coerced := h.coerceRecord(ctx, req.AppID, req.Record)
 
 	httpReq := JsonMap{
 		"app":    req.AppID,
@@ -472,20 +1311,28 @@ func (h *KintoneHandlers) CreateRecord(ctx context.Context, params json.RawMessa
 		return nil, err
 	}
 
-	return JSONContent(JsonMap{
+	result := JsonMap{
 		"success":  true,
 		"recordID": record.ID,
-	})
+	}
+	if len(coerced) > 0 {
+		result["coerced"] = coerced
+	}
+	return JSONContent(result)
+}
+
+type ReadRecordsParams struct {
+	AppID        string   `json:"appID" required:"true" description:"The app ID to read records from."`
+	Query        string   `json:"query" description:"The query to filter records. Query format is the same as kintone's query format. For example, 'field1 = \"value1\" and (field2 like \"value2\"' or field3 not in (\"value3.1\",\"value3.2\")) and date > \"2006-01-02\"'."`
+	Limit        *int     `json:"limit" description:"The maximum number of records to read. Default is 10 or the value set by the server for the app, maximum is 500."`
+	Fields       []string `json:"fields" description:"The field codes to include in the response. Default is all fields, or the fields set by the server for the app."`
+	Offset       int      `json:"offset" description:"The offset of records to read. Default is 0, maximum is 10,000. Use nextOffset in the result to read the next page."`
+	Format       string   `json:"format" enum:"kintone,simple" description:"The format of the records. 'kintone' is the format of kintone REST API that includes the type of each field, and 'simple' is the plain values such as {\"field1\": \"value1\"} that saves tokens. Default is 'kintone'."`
+	ResolveNames bool     `json:"resolveNames" description:"If true, fills in the display names of users, organizations, and groups that have only their codes. It requires password authentication. Default is false."`
 }
 
 func (h *KintoneHandlers) ReadRecords(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID  string   `json:"appID"`
-		Query  string   `json:"query"`
-		Limit  *int     `json:"limit"`
-		Fields []string `json:"fields"`
-		Offset int      `json:"offset"`
-	}
+	var req ReadRecordsParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -496,8 +1343,23 @@ func (h *KintoneHandlers) ReadRecords(ctx context.Context, params json.RawMessag
 		}
 	}
 
+	defaults := h.recordDefaults(req.AppID)
+
+	// kintone reads the limit and the offset only from the query. They are taken from the query of the AI as the arguments, to tell the next page correctly.
+	query, limit, offset := takeQueryPaging(req.Query)
+	req.Query = query
+	if limit >= 0 && req.Limit == nil {
+		req.Limit = &limit
+	}
+	if offset >= 0 && req.Offset == 0 {
+		req.Offset = offset
+	}
+
 	if req.Limit == nil {
 		limit := 10
+		if defaults.Limit > 0 {
+			limit = defaults.Limit
+		}
 		req.Limit = &limit
 	} else if *req.Limit < 1 || *req.Limit > 500 {
 		return nil, jsonrpc2.Error{
@@ -512,34 +1374,102 @@ func (h *KintoneHandlers) ReadRecords(ctx context.Context, params json.RawMessag
 			Message: "Offset must be between 0 and 10000",
 		}
 	}
+	if err := checkRecordFormat(req.Format); err != nil {
+		return nil, err
+	}
 
 	if err := h.checkPermissions(req.AppID); err != nil {
 		return nil, err
 	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+	if len(req.Fields) == 0 {
+		req.Fields = defaults.Fields
+	}
 
 	httpReq := JsonMap{
 		"app":        req.AppID,
-		"query":      req.Query,
-		"limit":      *req.Limit,
-		"offset":     req.Offset,
+		"query":      strings.TrimSpace(fmt.Sprintf("%s limit %d offset %d", defaults.mergeQuery(req.Query), *req.Limit, req.Offset)),
 		"fields":     req.Fields,
 		"totalCount": true,
 	}
 
-	var records JsonMap
-	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/records.json", nil, httpReq, &records); err != nil {
+	// The records are decoded one by one, and the records that exceed the size limit are not kept in memory.
+	acl, err := h.fieldACL(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	loc := h.timezone(ctx)
+	filter := h.fieldFilter(req.AppID)
+	config := h.policy().Config
+	counts := make(maskCounts)
+	directory := h.newDirectoryLookup(ctx)
+	rs := []any{}
+	read, size := 0, 0
+	records, err := h.fetchRecords(ctx, "GET", "/k/v1/records.json", nil, httpReq, func(record map[string]any) error {
+		read++
+		if h.MaxResponseSize > 0 && size > h.MaxResponseSize {
+			return nil
+		}
+		acl.filterRecord(record)
+		filter.filterRecord(record)
+		if req.ResolveNames {
+			directory.resolveNames(record)
+		}
+		config.maskRecord(record, counts)
+		localizeRecordTimes(record, loc)
+		if req.Format == recordFormatSimple {
+			record = simplifyRecord(record)
+		}
+		rs = append(rs, record)
+		size += jsonSize(record)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	records["records"] = rs
+	records["timezone"] = loc.String()
+	h.masking.record(ctx, fmt.Sprintf("records of app %s", req.AppID), counts)
+
+	if note := limitRecordsSize(records, h.MaxResponseSize, read); note != "" {
+		records["truncated"] = true
+		records["note"] = note
+	}
+
+	// The paging is told explicitly, so that the AI doesn't have to calculate the next offset by itself.
+	rs, _ = records["records"].([]any)
+	records["returnedCount"] = len(rs)
+	records["hasMore"] = false
+	if total, err := strconv.Atoi(fmt.Sprint(records["totalCount"])); err == nil {
+		records["totalCount"] = total
+		if next := req.Offset + len(rs); next < total {
+			records["hasMore"] = true
+			if next <= 10000 {
+				records["nextOffset"] = next
+			} else {
+				note := fmt.Sprintf("The rest of %d records can't be read by the offset, because the offset can't exceed 10,000. Please narrow down the records by the query, such as by the record ID, to read the rest.", total-next)
+				if prev, ok := records["note"].(string); ok {
+					note = prev + " " + note
+				}
+				records["note"] = note
+			}
+		}
+	}
 
 	return JSONContent(records)
 }
 
+type UpdateRecordParams struct {
+	AppID    string      `json:"appID" required:"true" description:"The app ID to update a record in."`
+	RecordID string      `json:"recordID" required:"true" description:"The record ID to update."`
+	Record   RecordParam `json:"record" required:"true" description:"The record data to update. Record data format is the same as kintone's record data format. For example, {\"field1\": {\"value\": \"value1\"}, \"field2\": {\"value\": \"value2\"}, \"field3\": {\"value\": \"value3\"}}. Omits the field that you don't want to update."`
+	Format   string      `json:"format" enum:"kintone,simple" description:"The format of the record data. 'kintone' is the format of kintone REST API, and 'simple' is the plain values without {\"value\": ...} such as {\"field1\": \"value1\", \"table1\": [{\"$id\": \"row ID\", \"cell1\": \"value\"}]}. Default is 'kintone'."`
+}
+
 func (h *KintoneHandlers) UpdateRecord(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID    string `json:"appID"`
-		RecordID string `json:"recordID"`
-		Record   any    `json:"record"`
-	}
+	var req UpdateRecordParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -550,9 +1480,23 @@ func (h *KintoneHandlers) UpdateRecord(ctx context.Context, params json.RawMessa
 		}
 	}
 
-	if err := h.checkPermissions(req.AppID); err != nil {
+	if err := checkRecordFormat(req.Format); err != nil {
+		return nil, err
+	}
+
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+	if req.Format == recordFormatSimple {
+		var err error
+		if req.Record, err = h.expandRecord(ctx, req.AppID, req.Record); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.fieldFilter(req.AppID).checkRecord(req.AppID, map[string]any(req.Record)); err != nil {
 		return nil, err
 	}
+	coerced := h.coerceRecord(ctx, req.AppID, req.Record)
 
 	httpReq := JsonMap{
 		"app":    req.AppID,
@@ -566,26 +1510,43 @@ func (h *KintoneHandlers) UpdateRecord(ctx context.Context, params json.RawMessa
 		return nil, err
 	}
 
-	return JSONContent(JsonMap{
+	response := JsonMap{
 		"success":  true,
 		"revision": result.Revision,
-	})
+	}
+	if len(coerced) > 0 {
+		response["coerced"] = coerced
+	}
+	return JSONContent(response)
 }
 
 func (h *KintoneHandlers) readSingleRecord(ctx context.Context, appID, recordID string) (JsonMap, error) {
 	var result struct {
 		Record JsonMap `json:"record"`
 	}
-	err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/record.json", Query{"app": appID, "id": recordID}, nil, &result)
+	acl, err := h.fieldACL(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/record.json", Query{"app": appID, "id": recordID}, nil, &result)
+	acl.filterRecord(result.Record)
+	h.fieldFilter(appID).filterRecord(result.Record)
+	counts := make(maskCounts)
+	h.policy().Config.maskRecord(result.Record, counts)
+	h.masking.record(ctx, fmt.Sprintf("record %s of app %s", recordID, appID), counts)
+	localizeRecordTimes(result.Record, h.timezone(ctx))
 
 	return result.Record, err
 }
 
+type DeleteRecordParams struct {
+	AppID             string `json:"appID" required:"true" description:"The app ID to delete a record from."`
+	RecordID          string `json:"recordID" required:"true" description:"The record ID to delete."`
+	ConfirmationToken string `json:"confirmationToken" description:"The confirmation token that is returned by the previous call of this tool. Only required if the server asks for confirmation."`
+}
+
 func (h *KintoneHandlers) DeleteRecord(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID    string `json:"appID"`
-		RecordID string `json:"recordID"`
-	}
+	var req DeleteRecordParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -596,15 +1557,35 @@ func (h *KintoneHandlers) DeleteRecord(ctx context.Context, params json.RawMessa
 		}
 	}
 
-	if err := h.checkPermissions(req.AppID); err != nil {
+	if err := h.checkDeletePermission(req.AppID); err != nil {
 		return nil, err
 	}
 
+	confirm := h.policy().ConfirmDeletes
+	target := h.cacheScope(ctx) + req.AppID + ":" + req.RecordID
+	if confirm && req.ConfirmationToken != "" && !h.confirmations.consume(req.ConfirmationToken, target) {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "The confirmation token is invalid or expired. Please call deleteRecord again without 'confirmationToken' to get a new one.",
+		}
+	}
+
 	deletedRecord, err := h.readSingleRecord(ctx, req.AppID, req.RecordID)
 	if err != nil {
 		return nil, err
 	}
 
+	if confirm && req.ConfirmationToken == "" {
+		token, expires := h.confirmations.issue(target)
+		return JSONContent(JsonMap{
+			"confirmationRequired": true,
+			"confirmationToken":    token,
+			"expiresAt":            expires.Format(time.RFC3339),
+			"record":               deletedRecord,
+			"message":              "The record is not deleted yet. Please make sure that this is the record to delete, and call deleteRecord again with the same appID, recordID, and this confirmationToken.",
+		})
+	}
+
 	if err := h.FetchHTTPWithJSON(ctx, "DELETE", "/k/v1/records.json", Query{"app": req.AppID, "ids[0]": req.RecordID}, nil, nil); err != nil {
 		return nil, err
 	}
@@ -639,8 +1620,128 @@ func getDownloadDirectory() string {
 	return dir
 }
 
-func getDownloadFilePath(fileName string) string {
+// downloadDirectory returns the directory to save downloaded files, after checking that it is allowed to write.
+// If the default download directory is not allowed, the first allowed root directory is used instead.
+func (h *KintoneHandlers) downloadDirectory(ctx context.Context) (string, error) {
+	if h.DownloadDir != "" {
+		if err := h.checkPathAccess(ctx, h.DownloadDir); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(h.DownloadDir, 0755); err != nil {
+			return "", jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to create download directory: %v", err),
+			}
+		}
+		return h.DownloadDir, nil
+	}
+
 	dir := getDownloadDirectory()
+	err := h.checkPathAccess(ctx, dir)
+	if err == nil {
+		return dir, nil
+	}
+
+	for _, root := range h.allowedRoots(ctx) {
+		if h.checkPathAccess(ctx, root) == nil {
+			return root, nil
+		}
+	}
+
+	return "", err
+}
+
+// allowedRoots returns the directories that the server can access.
+// If the client provides roots, they are used. Otherwise, the paths in KINTONE_ALLOWED_PATHS are used.
+// It returns nil if there is no restriction.
+func (h *KintoneHandlers) allowedRoots(ctx context.Context) []string {
+	if roots := clientRoots(ctx); len(roots) > 0 {
+		return roots
+	}
+	return h.AllowedPaths
+}
+
+// checkPathAccess checks if the path is in the roots that the client provides and in the directories listed in KINTONE_ALLOWED_PATHS.
+// Symbolic links are resolved before checking, so that links can't be used to escape from the allowed directories.
+func (h *KintoneHandlers) checkPathAccess(ctx context.Context, p string) error {
+	roots := clientRoots(ctx)
+	if len(h.AllowedPaths) == 0 && len(roots) == 0 {
+		return nil
+	}
+
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Invalid path: %s: %v", p, err),
+		}
+	}
+	if abs, err = resolveSymlinks(abs); err != nil {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Invalid path: %s: %v", p, err),
+		}
+	}
+
+	if len(h.AllowedPaths) > 0 && !slices.ContainsFunc(h.AllowedPaths, func(root string) bool { return isSubPath(root, abs) }) {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Path %s is inaccessible because it is not in the directories listed in the KINTONE_ALLOWED_PATHS environment variable. Please use a file in the allowed directories, or check the MCP server settings.", p),
+			Data:    JsonMap{"allowedPaths": h.AllowedPaths},
+		}
+	}
+
+	if len(roots) > 0 && !slices.ContainsFunc(roots, func(root string) bool { return isSubPath(root, abs) }) {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Path %s is inaccessible because it is not in the roots provided by the client. Please use a file in the roots.", p),
+			Data:    JsonMap{"roots": roots},
+		}
+	}
+
+	return nil
+}
+
+// resolveSymlinks resolves the symbolic links in the absolute path.
+// If the path doesn't exist yet, such as a file to download, the nearest existing parent is resolved and the rest is appended.
+func resolveSymlinks(p string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if _, lerr := os.Lstat(p); lerr == nil {
+		// A broken symbolic link would create the file at the target of the link.
+		return "", err
+	}
+
+	parent := filepath.Dir(p)
+	if parent == p {
+		return "", err
+	}
+	resolved, err = resolveSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, filepath.Base(p)), nil
+}
+
+// isSubPath reports whether p is root itself or is inside root.
+func isSubPath(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+func getDownloadFilePath(dir, fileName string) string {
+	fileName = sanitizeFileName(fileName)
+	if fileName == "" {
+		fileName = "file"
+	}
 
 	p := filepath.Join(dir, fileName)
 	if _, err := os.Stat(p); err != nil {
@@ -660,19 +1761,232 @@ func getDownloadFilePath(fileName string) string {
 		}
 	}
 
-	for {
-		p = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, num, ext))
-		if _, err := os.Stat(p); err != nil {
-			return p
-		}
-		num++
-	}
+	for {
+		p = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, num, ext))
+		if _, err := os.Stat(p); err != nil {
+			return p
+		}
+		num++
+	}
+}
+
+// parseContentDispositionFileName extracts the file name from the Content-Disposition header.
+// It supports the RFC 5987 style filename* parameter and the RFC 2047 style encoded words in addition to the plain filename parameter.
+func parseContentDispositionFileName(header string) string {
+	var fileName string
+
+	if _, ps, err := mime.ParseMediaType(header); err == nil && utf8.ValidString(ps["filename"]) {
+		// mime.ParseMediaType decodes filename* and prefers it over filename.
+		// It doesn't validate the decoded bytes, so the names that are not UTF-8 are parsed again below to use filename instead.
+		fileName = ps["filename"]
+	} else {
+		// Fall back to lenient parsing, because some servers send raw UTF-8 or malformed parameters.
+		var plain, extended string
+		for _, part := range strings.Split(header, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(k)) {
+			case "filename":
+				plain = strings.Trim(strings.TrimSpace(v), `"`)
+			case "filename*":
+				extended = decodeRFC5987(strings.Trim(strings.TrimSpace(v), `"`))
+			}
+		}
+		fileName = plain
+		if extended != "" {
+			fileName = extended
+		}
+	}
+
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(fileName); err != nil {
+		serverLog.Warn("Failed to decode filename", "filename", fileName, "error", err)
+	} else {
+		fileName = decoded
+	}
+
+	// Some servers send percent-encoded UTF-8 in the plain filename parameter.
+	if strings.Contains(fileName, "%") {
+		if unescaped, err := url.PathUnescape(fileName); err == nil && utf8.ValidString(unescaped) {
+			fileName = unescaped
+		}
+	}
+
+	return fileName
+}
+
+// decodeRFC5987 decodes an extended parameter value such as "UTF-8'ja'%E3%81%82.txt".
+// It returns an empty string if the value can not be decoded.
+func decodeRFC5987(v string) string {
+	charset, rest, ok := strings.Cut(v, "'")
+	if !ok {
+		return ""
+	}
+	_, encoded, ok := strings.Cut(rest, "'")
+	if !ok {
+		return ""
+	}
+
+	decoded, err := url.PathUnescape(encoded)
+	if err != nil {
+		return ""
+	}
+
+	charset = strings.ToLower(charset)
+	if (charset != "utf-8" && charset != "us-ascii" && charset != "") || !utf8.ValidString(decoded) {
+		return ""
+	}
+	return decoded
+}
+
+// sanitizeFileName makes the file name safe to use as a single path element.
+// Directory components are removed, and characters that can not be used in file names on common platforms are replaced.
+func sanitizeFileName(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.TrimSpace(name)
+	if strings.Trim(name, ".") == "" {
+		return ""
+	}
+	return name
+}
+
+// attachmentFileInfo returns the content type and the file name of the attachment file in the response.
+func attachmentFileInfo(httpRes *http.Response, fileKey string) (contentType, fileName string) {
+	contentType = httpRes.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	fileName = sanitizeFileName(parseContentDispositionFileName(httpRes.Header.Get("Content-Disposition")))
+
+	if fileName == "" {
+		fileName = fileKey
+
+		ext, err := mime.ExtensionsByType(contentType)
+		if err == nil && len(ext) > 0 {
+			fileName += ext[0]
+		}
+	}
+
+	return contentType, fileName
+}
+
+// findFileByHash looks for a regular file in dir that has the given size and SHA-256 digest.
+func findFileByHash(dir string, size int64, digest string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".kintone-download-") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Size() != size {
+			continue
+		}
+
+		p := filepath.Join(dir, e.Name())
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err == nil && hex.EncodeToString(hash.Sum(nil)) == digest {
+			return p, true
+		}
+	}
+
+	return "", false
+}
+
+type savedFile struct {
+	Path    string
+	Size    int64
+	SHA256  string
+	Skipped bool
+}
+
+// saveFileMu serializes choosing the name of a downloaded file and renaming to it.
+var saveFileMu sync.Mutex
+
+// saveDownloadedFile writes the content of r into the directory dir.
+// The file is written to a temporary file first, and then renamed to the final name.
+// If skipIfExists is true and the same content already exists in dir, the existing file is used instead.
+func saveDownloadedFile(dir string, r io.Reader, fileName string, skipIfExists bool) (savedFile, error) {
+	tmpFile, err := os.CreateTemp(dir, ".kintone-download-*")
+	if err != nil {
+		return savedFile{}, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to create file for attachment: %v", err),
+			Data:    JsonMap{"directory": dir},
+		}
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+	defer tmpFile.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), r)
+	if err != nil {
+		return savedFile{}, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to save attachment file: %v", err),
+		}
+	}
+	if err := tmpFile.Close(); err != nil {
+		return savedFile{}, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to save attachment file: %v", err),
+		}
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	if skipIfExists {
+		if p, ok := findFileByHash(dir, size, digest); ok {
+			return savedFile{Path: p, Size: size, SHA256: digest, Skipped: true}, nil
+		}
+	}
+
+	// Lock to avoid choosing the same name for files that are downloaded in parallel.
+	saveFileMu.Lock()
+	defer saveFileMu.Unlock()
+
+	outPath := getDownloadFilePath(dir, fileName)
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return savedFile{}, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to save attachment file: %s: %v", outPath, err),
+		}
+	}
+
+	return savedFile{Path: outPath, Size: size, SHA256: digest}, nil
+}
+
+type DownloadAttachmentFileParams struct {
+	FileKey      string `json:"fileKey" required:"true" description:"The file key to download."`
+	SkipIfExists bool   `json:"skipIfExists" description:"If true, the file is not saved again when a file with the same content already exists in the download directory. The path of the existing file is returned instead. Default is false."`
 }
 
 func (h *KintoneHandlers) DownloadAttachmentFile(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		FileKey string `json:"fileKey"`
-	}
+	var req DownloadAttachmentFileParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -683,97 +1997,189 @@ func (h *KintoneHandlers) DownloadAttachmentFile(ctx context.Context, params jso
 		}
 	}
 
-	httpRes, err := h.SendHTTP(ctx, "GET", "/k/v1/file.json", Query{"fileKey": req.FileKey}, nil, "")
+	dir, err := h.downloadDirectory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRes, err := h.downloadFile(ctx, req.FileKey)
 	if err != nil {
 		return nil, err
 	}
 	defer httpRes.Body.Close()
 
-	contentType := httpRes.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream"
+	contentType, fileName := attachmentFileInfo(httpRes, req.FileKey)
+
+	var r io.Reader = newProgressReader(ctx, httpRes.Body, httpRes.ContentLength, fmt.Sprintf("Downloading %s", fileName))
+	var buf *bytes.Buffer
+	if strings.HasPrefix(contentType, "text/") || strings.HasPrefix(contentType, "image/") {
+		buf = new(bytes.Buffer)
+		r = io.TeeReader(r, buf)
 	}
 
-	var fileName string
+	saved, err := saveDownloadedFile(dir, r, fileName, req.SkipIfExists)
+	if err != nil {
+		return nil, err
+	}
 
-	_, ps, err := mime.ParseMediaType(httpRes.Header.Get("Content-Disposition"))
-	if err == nil {
-		fileName = ps["filename"]
+	res, err := JSONContent(JsonMap{
+		"success":     true,
+		"filePath":    saved.Path,
+		"fileName":    fileName,
+		"contentType": contentType,
+		"size":        saved.Size,
+		"sha256":      saved.SHA256,
+		"skipped":     saved.Skipped,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(contentType, "text/") {
+		res = append(res, Content{Type: "text", Text: buf.String()})
+	} else if strings.HasPrefix(contentType, "image/") {
+		b64 := base64.StdEncoding.EncodeToString(buf.Bytes())
+		res = append(res, Content{
+			Type:     "image",
+			Data:     b64,
+			MimeType: contentType,
+		})
+	}
+
+	return res, nil
+}
+
+const maxExtractFileSize = 50 * 1024 * 1024
+
+type ExtractAttachmentTextParams struct {
+	FileKey   string `json:"fileKey" required:"true" description:"The file key to read."`
+	MaxLength *int   `json:"maxLength" description:"The maximum number of characters to return. Default is 20000, maximum is 200000. The response tells you if the text was truncated."`
+}
+
+func (h *KintoneHandlers) ExtractAttachmentText(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req ExtractAttachmentTextParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.FileKey == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'fileKey' is required",
+		}
+	}
+	if req.MaxLength == nil {
+		maxLength := 20000
+		req.MaxLength = &maxLength
+	} else if *req.MaxLength < 1 || *req.MaxLength > 200000 {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "MaxLength must be between 1 and 200000",
+		}
 	}
 
-	fileName, err = new(mime.WordDecoder).DecodeHeader(fileName)
+	httpRes, err := h.downloadFile(ctx, req.FileKey)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to decode filename: %v\n", err)
-		fileName = ""
+		return nil, err
 	}
+	defer httpRes.Body.Close()
 
-	if fileName == "" {
-		fileName = req.FileKey
+	contentType, fileName := attachmentFileInfo(httpRes, req.FileKey)
 
-		ext, err := mime.ExtensionsByType(contentType)
-		if err == nil && len(ext) > 0 {
-			fileName += ext[0]
+	format := detectDocumentFormat(contentType, fileName)
+	if format == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Text extraction is not supported for this file type: %s (%s). Please use 'downloadAttachmentFile' tool instead.", fileName, contentType),
 		}
 	}
 
-	outPath := getDownloadFilePath(fileName)
-	outFile, err := os.Create(outPath)
+	data, err := io.ReadAll(io.LimitReader(httpRes.Body, maxExtractFileSize+1))
 	if err != nil {
 		return nil, jsonrpc2.Error{
 			Code:    jsonrpc2.InternalErrorCode,
-			Message: fmt.Sprintf("Failed to create file for attachment: %v", err),
-			Data:    JsonMap{"filePath": outPath},
+			Message: fmt.Sprintf("Failed to read attachment file: %v", err),
 		}
 	}
-	defer outFile.Close()
-
-	var w io.Writer = outFile
-	var buf *bytes.Buffer
-	if strings.HasPrefix(contentType, "text/") || strings.HasPrefix(contentType, "image/") {
-		buf = new(bytes.Buffer)
-		w = io.MultiWriter(outFile, buf)
+	if len(data) > maxExtractFileSize {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("The file is too large to extract text. The maximum size is %d MB. Please use 'downloadAttachmentFile' tool instead.", maxExtractFileSize/1024/1024),
+		}
 	}
 
-	size, err := io.Copy(w, httpRes.Body)
+	text, err := extractText(data, format)
 	if err != nil {
-		outFile.Close()
-		os.Remove(outPath)
 		return nil, jsonrpc2.Error{
 			Code:    jsonrpc2.InternalErrorCode,
-			Message: fmt.Sprintf("Failed to save attachment file: %s: %v", outPath, err),
+			Message: fmt.Sprintf("Failed to extract text from %s: %v", fileName, err),
 		}
 	}
 
+	length := utf8.RuneCountInString(text)
+	truncated := false
+	if length > *req.MaxLength {
+		text = string([]rune(text)[:*req.MaxLength])
+		truncated = true
+	}
+
 	res, err := JSONContent(JsonMap{
-		"success":  true,
-		"filePath": outPath,
-		"size":     size,
+		"fileName":    fileName,
+		"contentType": contentType,
+		"format":      format,
+		"length":      length,
+		"truncated":   truncated,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	if strings.HasPrefix(contentType, "text/") {
-		res = append(res, Content{Type: "text", Text: buf.String()})
-	} else if strings.HasPrefix(contentType, "image/") {
-		b64 := base64.StdEncoding.EncodeToString(buf.Bytes())
-		res = append(res, Content{
-			Type:     "image",
-			Data:     b64,
-			MimeType: contentType,
-		})
+	return append(res, Content{Type: "text", Text: text}), nil
+}
+
+// writeMultipartFile writes a multipart body that contains a file read from src.
+// It fails if the content is larger than maxSize.
+func writeMultipartFile(mw *multipart.Writer, filename string, src io.Reader, maxSize int64) error {
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to prepare request: %v", err),
+		}
 	}
 
-	return res, nil
+	n, err := io.Copy(part, io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to read file content: %v", err),
+		}
+	}
+	if n > maxSize {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("The file is too large to upload. The maximum size is %s.", formatSize(maxSize)),
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to finalize request: %v", err),
+		}
+	}
+
+	return nil
+}
+
+type UploadAttachmentFileParams struct {
+	Path    *string `json:"path" description:"The path of the file to upload. Required if 'content' is not specified."`
+	Name    string  `json:"name" description:"The file name for the 'content'. This is only used when 'content' is specified."`
+	Content *string `json:"content" description:"The content of the file to upload. Required if 'path' is not specified."`
+	Base64  bool    `json:"base64" description:"The 'content' is base64 encoded or not. Default is false. This is only used when 'content' is specified."`
 }
 
 func (h *KintoneHandlers) UploadAttachmentFile(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		Path    *string `json:"path"`
-		Name    string  `json:"name"`
-		Content *string `json:"content"`
-		Base64  bool    `json:"base64"`
-	}
+	var req UploadAttachmentFileParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -806,77 +2212,122 @@ func (h *KintoneHandlers) UploadAttachmentFile(ctx context.Context, params json.
 		}
 	}
 
-	var body bytes.Buffer
-	mw := multipart.NewWriter(&body)
-	part, err := mw.CreateFormFile("file", filename)
-	if err != nil {
-		return nil, jsonrpc2.Error{
-			Code:    jsonrpc2.InternalErrorCode,
-			Message: fmt.Sprintf("Failed to prepare request: %v", err),
+	var src io.Reader
+	var size int64
+	if req.Path != nil {
+		if err := h.checkPathAccess(ctx, *req.Path); err != nil {
+			return nil, err
 		}
-	}
 
-	if req.Path != nil {
-		r, err := os.Open(*req.Path)
+		f, err := os.Open(*req.Path)
 		if err != nil {
 			return nil, jsonrpc2.Error{
 				Code:    jsonrpc2.InternalErrorCode,
 				Message: fmt.Sprintf("Failed to open file: %v", err),
 			}
 		}
-		defer r.Close()
+		defer f.Close()
 
-		if _, err := io.Copy(part, r); err != nil {
+		info, err := f.Stat()
+		if err != nil {
 			return nil, jsonrpc2.Error{
 				Code:    jsonrpc2.InternalErrorCode,
-				Message: fmt.Sprintf("Failed to read file content: %v", err),
+				Message: fmt.Sprintf("Failed to read file information: %v", err),
 			}
 		}
+		src = f
+		size = info.Size()
 	} else if req.Base64 {
-		r := base64.NewDecoder(base64.StdEncoding, strings.NewReader(*req.Content))
-		if _, err := io.Copy(part, r); err != nil {
-			return nil, jsonrpc2.Error{
-				Code:    jsonrpc2.InternalErrorCode,
-				Message: fmt.Sprintf("Failed to read file content: %v", err),
-			}
-		}
+		src = base64.NewDecoder(base64.StdEncoding, strings.NewReader(*req.Content))
+		size = int64(base64.StdEncoding.DecodedLen(len(strings.TrimRight(*req.Content, "="))))
 	} else {
-		if _, err := part.Write([]byte(*req.Content)); err != nil {
-			return nil, jsonrpc2.Error{
-				Code:    jsonrpc2.InternalErrorCode,
-				Message: fmt.Sprintf("Failed to read file content: %v", err),
-			}
-		}
+		src = strings.NewReader(*req.Content)
+		size = int64(len(*req.Content))
 	}
 
-	if err := mw.Close(); err != nil {
+	if size > h.MaxUploadSize {
 		return nil, jsonrpc2.Error{
-			Code:    jsonrpc2.InternalErrorCode,
-			Message: fmt.Sprintf("Failed to finalize request: %v", err),
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("The file is too large to upload. The maximum size is %s.", formatSize(h.MaxUploadSize)),
+			Data:    JsonMap{"size": size, "maxSize": h.MaxUploadSize},
 		}
 	}
 
-	var res struct {
-		FileKey string `json:"fileKey"`
-	}
-	if err := h.FetchHTTPWithReader(ctx, "POST", "/k/v1/file.json", nil, &body, mw.FormDataContentType(), &res); err != nil {
+	fileKey, err := h.uploadFile(ctx, filename, src, size)
+	if err != nil {
 		return nil, err
 	}
 
 	return JSONContent(JsonMap{
 		"success": true,
-		"fileKey": res.FileKey,
+		"fileKey": fileKey,
 	})
 }
 
-func (h *KintoneHandlers) ReadRecordComments(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID    string `json:"appID"`
-		RecordID string `json:"recordID"`
-		Order    string `json:"order"`
-		Offset   int    `json:"offset"`
-		Limit    *int   `json:"limit"`
+// uploadFile uploads the content of src to kintone, and returns the file key.
+// size is used only to report the progress.
+func (h *KintoneHandlers) uploadFile(ctx context.Context, filename string, src io.Reader, size int64) (string, error) {
+	// Stream the multipart body through a pipe, so that large files don't have to be loaded into memory.
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeMultipartFile(mw, filename, newProgressReader(ctx, src, size, fmt.Sprintf("Uploading %s", filename)), h.MaxUploadSize)
+		pw.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	var res struct {
+		FileKey string `json:"fileKey"`
+	}
+	err := h.FetchHTTPWithReader(ctx, "POST", "/k/v1/file.json", nil, pr, mw.FormDataContentType(), &res)
+	pr.Close()
+
+	// Report the size limit error rather than the broken request caused by it.
+	werr := <-writeErr
+	var rpcErr jsonrpc2.Error
+	if errors.As(werr, &rpcErr) && rpcErr.Code == jsonrpc2.InvalidParamsCode {
+		return "", werr
+	}
+	if err != nil {
+		return "", err
+	}
+	if werr != nil {
+		return "", werr
+	}
+
+	return res.FileKey, nil
+}
+
+type ReadRecordCommentsParams struct {
+	AppID    string `json:"appID" required:"true" description:"The app ID to read comments from."`
+	RecordID string `json:"recordID" required:"true" description:"The record ID to read comments from."`
+	Order    string `json:"order" description:"The order of comments. Default is 'desc'."`
+	Offset   int    `json:"offset" description:"The offset of comments to read. Default is 0."`
+	Limit    *int   `json:"limit" description:"The maximum number of comments to read. Default is 10, maximum is 10."`
+	All      bool   `json:"all" description:"If true, all comments from the offset are read by paging through the thread, up to 500 comments. The 'limit' is ignored. Default is false."`
+	Compact  bool   `json:"compact" description:"If true, each comment includes only the ID, the author, the timestamp, and the text, to keep long threads readable. Default is false."`
+}
+
+// maxAllComments is the maximum number of comments that readRecordComments tool reads with 'all'.
+const maxAllComments = 500
+
+// compactComment returns the comment with only the ID, the author, the timestamp, and the text.
+func compactComment(c JsonMap) JsonMap {
+	author := ""
+	if creator, ok := c["creator"].(map[string]any); ok {
+		author = entityLabel(creator)
 	}
+	return JsonMap{
+		"id":        c["id"],
+		"author":    author,
+		"createdAt": c["createdAt"],
+		"text":      c["text"],
+	}
+}
+
+func (h *KintoneHandlers) ReadRecordComments(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req ReadRecordCommentsParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -918,41 +2369,85 @@ func (h *KintoneHandlers) ReadRecordComments(ctx context.Context, params json.Ra
 		return nil, err
 	}
 
-	httpReq := JsonMap{
-		"app":    req.AppID,
-		"record": req.RecordID,
-		"order":  req.Order,
-		"offset": req.Offset,
-		"limit":  *req.Limit,
+	var comments []JsonMap
+	var older, newer bool
+	offset := req.Offset
+	for {
+		httpReq := JsonMap{
+			"app":    req.AppID,
+			"record": req.RecordID,
+			"order":  req.Order,
+			"offset": offset,
+			"limit":  *req.Limit,
+		}
+		if req.All {
+			httpReq["limit"] = 10
+		}
+		var httpRes struct {
+			Comments []JsonMap `json:"comments"`
+			Older    bool      `json:"older"`
+			Newer    bool      `json:"newer"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/record/comments.json", nil, httpReq, &httpRes); err != nil {
+			return nil, err
+		}
+		// The flag behind the offset comes from the first page, and the flag ahead comes from the last page.
+		if offset == req.Offset {
+			older, newer = httpRes.Older, httpRes.Newer
+		} else if req.Order == "desc" {
+			older = httpRes.Older
+		} else {
+			newer = httpRes.Newer
+		}
+		comments = append(comments, httpRes.Comments...)
+		offset += len(httpRes.Comments)
+
+		more := (req.Order == "desc" && older) || (req.Order == "asc" && newer)
+		if !req.All || !more || len(httpRes.Comments) == 0 || len(comments) >= maxAllComments {
+			break
+		}
+		ReportProgress(ctx, float64(len(comments)), 0, fmt.Sprintf("Read %d comments", len(comments)))
 	}
-	var httpRes struct {
-		Comments []JsonMap `json:"comments"`
-		Older    bool      `json:"older"`
-		Newer    bool      `json:"newer"`
+
+	counts := make(maskCounts)
+	for i, c := range comments {
+		h.policy().Config.maskComment(c, counts)
+		if req.Compact {
+			comments[i] = compactComment(c)
+		}
 	}
-	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/record/comments.json", nil, httpReq, &httpRes); err != nil {
-		return nil, err
+	h.masking.record(ctx, fmt.Sprintf("comments on record %s of app %s", req.RecordID, req.AppID), counts)
+
+	if comments == nil {
+		comments = []JsonMap{}
+	}
+	res := JsonMap{
+		"comments":            comments,
+		"existsOlderComments": older,
+		"existsNewerComments": newer,
 	}
+	if req.All && len(comments) >= maxAllComments && ((req.Order == "desc" && older) || (req.Order == "asc" && newer)) {
+		res["truncated"] = true
+		res["nextOffset"] = offset
+		res["note"] = fmt.Sprintf("Only the first %d comments are read. Use 'offset' to read the rest.", maxAllComments)
+	}
+	return JSONContent(res)
+}
 
-	return JSONContent(JsonMap{
-		"comments":            httpRes.Comments,
-		"existsOlderComments": httpRes.Older,
-		"existsNewerComments": httpRes.Newer,
-	})
+type CreateRecordCommentParams struct {
+	AppID    string `json:"appID" required:"true" description:"The app ID to create a comment in."`
+	RecordID string `json:"recordID" required:"true" description:"The record ID to create a comment on."`
+	Comment  struct {
+		Text     string `json:"text" required:"true" description:"The text of the comment."`
+		Mentions []struct {
+			Code string `json:"code" required:"true" description:"The code of the mention target. You can get the code by other records or comments."`
+			Type string `json:"type" enum:"USER,GROUP,ORGANIZATION" description:"The type of the mention target. Default is 'USER'."`
+		} `json:"mentions" description:"The mention targets of the comment. The target can be a user, a group, or a organization."`
+	} `json:"comment" required:"true"`
 }
 
 func (h *KintoneHandlers) CreateRecordComment(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID    string `json:"appID"`
-		RecordID string `json:"recordID"`
-		Comment  struct {
-			Text     string `json:"text"`
-			Mentions []struct {
-				Code string `json:"code"`
-				Type string `json:"type"`
-			} `json:"mentions"`
-		} `json:"comment"`
-	}
+	var req CreateRecordCommentParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -981,7 +2476,7 @@ func (h *KintoneHandlers) CreateRecordComment(ctx context.Context, params json.R
 		}
 	}
 
-	if err := h.checkPermissions(req.AppID); err != nil {
+	if err := h.checkWritePermission(req.AppID); err != nil {
 		return nil, err
 	}
 
@@ -999,12 +2494,14 @@ func (h *KintoneHandlers) CreateRecordComment(ctx context.Context, params json.R
 	})
 }
 
+type UpdateProcessManagementAssigneeParams struct {
+	AppID     string   `json:"appID" required:"true" description:"The app ID to update the assignee."`
+	RecordID  string   `json:"recordID" required:"true" description:"The record ID to update the assignee."`
+	Assignees []string `json:"assignees" required:"true" description:"The codes of the assignee users of the record."`
+}
+
 func (h *KintoneHandlers) UpdateProcessManagementAssignee(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID     string   `json:"appID"`
-		RecordID  string   `json:"recordID"`
-		Assignees []string `json:"assignees"`
-	}
+	var req UpdateProcessManagementAssigneeParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -1015,6 +2512,10 @@ func (h *KintoneHandlers) UpdateProcessManagementAssignee(ctx context.Context, p
 		}
 	}
 
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+
 	httpReq := JsonMap{
 		"app":       req.AppID,
 		"id":        req.RecordID,
@@ -1029,13 +2530,15 @@ func (h *KintoneHandlers) UpdateProcessManagementAssignee(ctx context.Context, p
 	})
 }
 
+type ExecuteProcessManagementActionParams struct {
+	AppID    string  `json:"appID" required:"true" description:"The app ID to execute the action."`
+	RecordID string  `json:"recordID" required:"true" description:"The record ID to execute the action."`
+	Action   string  `json:"action" required:"true" description:"The action to execute."`
+	Assignee *string `json:"assignee" description:"The next assignee of the record."`
+}
+
 func (h *KintoneHandlers) ExecuteProcessManagementAction(ctx context.Context, params json.RawMessage) ([]Content, error) {
-	var req struct {
-		AppID    string  `json:"appID"`
-		RecordID string  `json:"recordID"`
-		Action   string  `json:"action"`
-		Assignee *string `json:"assignee"`
-	}
+	var req ExecuteProcessManagementActionParams
 	if err := UnmarshalParams(params, &req); err != nil {
 		return nil, err
 	}
@@ -1046,6 +2549,10 @@ func (h *KintoneHandlers) ExecuteProcessManagementAction(ctx context.Context, pa
 		}
 	}
 
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+
 	httpReq := JsonMap{
 		"app":    req.AppID,
 		"id":     req.RecordID,
@@ -1063,15 +2570,68 @@ func (h *KintoneHandlers) ExecuteProcessManagementAction(ctx context.Context, pa
 	})
 }
 
+// Getenv returns the setting by the environment variable or the configuration file, or defaultValue if it is not set.
 func Getenv(key, defaultValue string) string {
-	if v := os.Getenv(key); v != "" {
+	if v := lookupSetting(key); v != "" {
 		return v
 	}
 	return defaultValue
 }
 
+// GetenvBool reports whether the environment variable is set to a truthy value such as "1" or "true".
+func GetenvBool(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(lookupSetting(key))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"B", 1},
+}
+
+// parseSize parses a size string like "100MB" or "1024".
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, errors.New("size must be greater than 0")
+	}
+	return n * unit, nil
+}
+
+// formatSize formats a size in bytes into a human readable string.
+func formatSize(n int64) string {
+	for _, u := range sizeUnits {
+		if n >= u.size && n%u.size == 0 {
+			return fmt.Sprintf("%d%s", n/u.size, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
 func GetenvList(key string) []string {
-	if v := os.Getenv(key); v != "" {
+	if v := lookupSetting(key); v != "" {
 		raw := strings.Split(v, ",")
 		ss := make([]string, 0, len(raw))
 		for _, s := range raw {
@@ -1084,38 +2644,122 @@ func GetenvList(key string) []string {
 	return nil
 }
 
-type MergedReadWriter struct {
-	r io.Reader
-	w io.Writer
-}
-
-func (rw *MergedReadWriter) Read(p []byte) (int, error) {
-	return rw.r.Read(p)
-}
+func main() {
+	cmd, args, err := parseCommandLine(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
 
-func (rw *MergedReadWriter) Write(p []byte) (int, error) {
-	return rw.w.Write(p)
+	if err := cmd.Run(context.Background(), args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 }
 
-func main() {
+// runServe starts the MCP server on stdio, or the address in KINTONE_MCP_HTTP_ADDR or KINTONE_MCP_LISTEN.
+func runServe(ctx context.Context, args []string) error {
 	handlers, err := NewKintoneHandlersFromEnv()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
+		return err
+	}
+
+	shutdownTracing, err := configureTracing(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to configure OpenTelemetry: %w", err)
+	}
+	defer shutdownTracing()
+
+	// The addresses are read after loading the configuration file, which can also set them.
+	httpAddr := Getenv("KINTONE_MCP_HTTP_ADDR", "")
+	listenAddr := Getenv("KINTONE_MCP_LISTEN", "")
+	if httpAddr != "" && listenAddr != "" {
+		return errors.New("--http and --listen can't be used together")
+	}
+
+	tlsConfig, err := LoadTLSConfig(Getenv("KINTONE_MCP_TLS_CERT", ""), Getenv("KINTONE_MCP_TLS_KEY", ""))
+	if err != nil {
+		return err
 	}
 
+	// At the first signal, the server stops accepting new requests and waits for the in-flight requests.
+	// The second signal terminates the server immediately, because the signal handler is restored.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		handlers.Shutdown(ctx)
+	}()
+
+	handlers.WatchAppNames(ctx)
+	handlers.WatchConfig(ctx)
+	handlers.ReloadOnSignal(ctx)
+
 	server := jsonrpc2.NewServer()
 	server.On("initialize", jsonrpc2.Call(handlers.InitializeHandler))
 	server.On("notifications/initialized", jsonrpc2.Notify(func(ctx context.Context, params any) error {
 		return nil
 	}))
+	server.On("notifications/roots/list_changed", jsonrpc2.Notify(handlers.RootsListChanged))
 	server.On("ping", jsonrpc2.Call(func(ctx context.Context, params any) (struct{}, error) {
 		return struct{}{}, nil
 	}))
 	server.On("tools/list", jsonrpc2.Call(handlers.ToolsList))
 	server.On("tools/call", jsonrpc2.Call(handlers.ToolsCall))
+	server.On("resources/list", jsonrpc2.Call(handlers.ResourcesList))
+	server.On("resources/read", jsonrpc2.Call(handlers.ResourcesRead))
+	server.On("resources/templates/list", jsonrpc2.Call(handlers.ResourceTemplatesList))
+	server.On("resources/subscribe", jsonrpc2.Call(handlers.ResourcesSubscribe))
+	server.On("resources/unsubscribe", jsonrpc2.Call(handlers.ResourcesUnsubscribe))
+	server.On("logging/setLevel", jsonrpc2.Call(handlers.SetLogLevel))
+	server.On("completion/complete", jsonrpc2.Call(handlers.Complete))
+
+	if handlers.WebhookAddr != "" {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			if err := ServeWebhook(ctx, handlers.WebhookAddr, tlsConfig, handlers.webhookHandler()); err != nil {
+				serverLog.Error("Failed to receive webhooks", "error", err)
+			}
+		}()
+	}
+
+	switch {
+	case httpAddr != "":
+		handlers.reportStartup("HTTP on " + httpAddr)
+	case listenAddr != "":
+		handlers.reportStartup("connections on " + listenAddr)
+	default:
+		handlers.reportStartup("stdio")
+	}
+
+	authTokens := parseAuthTokens(GetenvList("KINTONE_MCP_AUTH_TOKENS"))
+
+	if httpAddr != "" {
+		return ServeHTTP(ctx, server, httpAddr, tlsConfig, authTokens, handlers.healthHandler())
+	}
+
+	if listenAddr != "" {
+		if err := checkListenAuth(listenAddr, authTokens); err != nil {
+			return err
+		}
+		ln, err := Listen(listenAddr, tlsConfig)
+		if err != nil {
+			return err
+		}
+		return ServeListener(ctx, server, ln, authTokens)
+	}
+
+	serverLog.Info("kintone server is running on stdio")
 
-	fmt.Fprintf(os.Stderr, "kintone server is running on stdio!\n")
+	// The stdout is only for the messages. The stray writes to os.Stdout are routed to stderr, so that they don't break the messages.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
 
-	server.ServeForOne(&MergedReadWriter{r: os.Stdin, w: os.Stdout})
+	session := NewSession(server, stdout)
+	return session.Serve(ctx, os.Stdin)
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseContentDispositionFileName(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"RFC 5987 UTF-8", `attachment; filename*=UTF-8''%E8%A6%8B%E7%A9%8D.pdf`, "見積.pdf"},
+		{"lowercase charset", `attachment; filename*=utf-8''%E8%A6%8B%E7%A9%8D.pdf`, "見積.pdf"},
+		{"filename* after filename", `attachment; filename="quote.pdf"; filename*=UTF-8''%E8%A6%8B%E7%A9%8D.pdf`, "見積.pdf"},
+		{"filename* before filename", `attachment; filename*=UTF-8''%E8%A6%8B%E7%A9%8D.pdf; filename="quote.pdf"`, "見積.pdf"},
+		{"Shift_JIS filename*", `attachment; filename="quote.pdf"; filename*=Shift_JIS''%8C%A9%90%CF.pdf`, "quote.pdf"},
+		{"Shift_JIS bytes labeled as UTF-8", `attachment; filename="quote.pdf"; filename*=UTF-8''%8C%A9%90%CF.pdf`, "quote.pdf"},
+		{"invalid percent-encoding", `attachment; filename="quote.pdf"; filename*=UTF-8''%ZZ.pdf`, "quote.pdf"},
+		{"raw UTF-8 in quoted filename", `attachment; filename="見積.pdf"`, "見積.pdf"},
+		{"raw UTF-8 in unquoted filename", `attachment; filename=見積 2024.pdf`, "見積 2024.pdf"},
+		{"percent-encoded filename", `attachment; filename="%E8%A6%8B%E7%A9%8D.pdf"`, "見積.pdf"},
+		{"percent sign in filename", `attachment; filename="100%.pdf"`, "100%.pdf"},
+		{"RFC 2047 encoded word", `attachment; filename="=?UTF-8?B?6KaL56mNLnBkZg==?="`, "見積.pdf"},
+		{"no filename", `attachment`, ""},
+		{"empty header", ``, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseContentDispositionFileName(tt.header); got != tt.want {
+				t.Errorf("parseContentDispositionFileName(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRFC5987(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`UTF-8''%E8%A6%8B%E7%A9%8D.pdf`, "見積.pdf"},
+		{`UTF-8'ja'%E8%A6%8B%E7%A9%8D.pdf`, "見積.pdf"},
+		{`us-ascii''quote.pdf`, "quote.pdf"},
+		{`''quote.pdf`, "quote.pdf"},
+		{`Shift_JIS''%8C%A9%90%CF.pdf`, ""},
+		{`UTF-8''%8C%A9%90%CF.pdf`, ""},
+		{`UTF-8''%ZZ.pdf`, ""},
+		{`UTF-8''%E8%A6`, ""},
+		{`quote.pdf`, ""},
+		{`UTF-8'quote.pdf`, ""},
+	}
+	for _, tt := range tests {
+		if got := decodeRFC5987(tt.input); got != tt.want {
+			t.Errorf("decodeRFC5987(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"見積.pdf", "見積.pdf"},
+		{"../見積.pdf", "見積.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{"/etc/passwd", "passwd"},
+		{`C:\Users\alice\見積.pdf`, "見積.pdf"},
+		{`..\..\見積.pdf`, "見積.pdf"},
+		{`dir/sub\見積.pdf`, "見積.pdf"},
+		{"a<b>:c|d?e*f\".pdf", "a_b__c_d_e_f_.pdf"},
+		{"\x00見\n積\x7f.pdf", "見積.pdf"},
+		{"  見積.pdf  ", "見積.pdf"},
+		{"..", ""},
+		{".", ""},
+		{"../", ""},
+		{`C:\`, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFileName(tt.input); got != tt.want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAttachmentFileInfo(t *testing.T) {
+	tests := []struct {
+		disposition string
+		contentType string
+		want        string
+	}{
+		{`attachment; filename*=UTF-8''..%2F..%2F%E8%A6%8B%E7%A9%8D.pdf`, "application/pdf", "見積.pdf"},
+		{`attachment; filename="..\..\見積.pdf"`, "application/pdf", "見積.pdf"},
+		{`attachment; filename="/"`, "application/pdf", "filekey.pdf"},
+	}
+	for _, tt := range tests {
+		res := &http.Response{Header: http.Header{}}
+		res.Header.Set("Content-Disposition", tt.disposition)
+		res.Header.Set("Content-Type", tt.contentType)
+		if _, got := attachmentFileInfo(res, "filekey"); got != tt.want {
+			t.Errorf("attachmentFileInfo(%q, %q) = %q, want %q", tt.disposition, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPathAccessSymlinks(t *testing.T) {
+	resolve := func(p string) string {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resolved
+	}
+	allowed := resolve(t.TempDir())
+	outside := resolve(t.TempDir())
+
+	if err := os.Mkdir(filepath.Join(allowed, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"outside": outside,
+		"broken":  filepath.Join(outside, "missing.txt"),
+		"inside":  filepath.Join(allowed, "sub"),
+	} {
+		if err := os.Symlink(target, filepath.Join(allowed, link)); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+	}
+
+	h := &KintoneHandlers{AllowedPaths: []string{allowed}}
+	for _, tt := range []struct {
+		path string
+		ok   bool
+	}{
+		{filepath.Join(allowed, "new.txt"), true},
+		{filepath.Join(allowed, "sub", "new", "deep.txt"), true},
+		{filepath.Join(allowed, "inside", "new.txt"), true},
+		{filepath.Join(allowed, "outside", "new.txt"), false},
+		{filepath.Join(allowed, "outside", "new", "deep.txt"), false},
+		{filepath.Join(allowed, "broken"), false},
+		{filepath.Join(outside, "new.txt"), false},
+	} {
+		err := h.checkPathAccess(context.Background(), tt.path)
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.path, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s: expected to be rejected", tt.path)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// defaultMaskReplacement is the text to replace masked values with.
+const defaultMaskReplacement = "[MASKED]"
+
+// maskingPresets is the built-in patterns for MaskingRule.Preset.
+var maskingPresets = map[string]string{
+	"email":      `[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`,
+	"phone":      `(?:\+\d{1,3}[\s\-]?|\b)(?:\(\d{1,4}\)[\s\-]?|\d{1,4}[\s\-])\d{1,4}[\s\-]\d{3,4}\b`,
+	"creditCard": `\b\d{4}[\s\-]?\d{4}[\s\-]?\d{4}[\s\-]?\d{1,4}\b`,
+}
+
+// unmaskableFieldTypes is the types of fields that hold IDs of kintone, which are never masked by patterns.
+var unmaskableFieldTypes = []string{"__ID__", "__REVISION__", "RECORD_NUMBER"}
+
+// MaskingRule is a rule to hide personal information in records and comments before they are returned to the client.
+// Rules are applied in the order of the configuration file.
+type MaskingRule struct {
+	// Name is the name of the rule for the audit log. The preset name or the pattern is used if empty.
+	Name string `json:"name"`
+
+	// Preset is the name of a built-in pattern: "email", "phone", or "creditCard".
+	Preset string `json:"preset"`
+
+	// Pattern is a regular expression to mask.
+	Pattern string `json:"pattern"`
+
+	// FieldTypes limits the rule to the fields of the types, such as "LINK".
+	// If neither Preset nor Pattern is set, the whole value of the fields is masked.
+	FieldTypes []string `json:"fieldTypes"`
+
+	// Replacement is the text to replace masked values with. The default is "[MASKED]".
+	Replacement string `json:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// compile validates the rule and fills default values.
+func (r *MaskingRule) compile() error {
+	if r.Preset != "" && r.Pattern != "" {
+		return fmt.Errorf("preset and pattern can't be used together")
+	}
+	if r.Preset != "" {
+		pattern, ok := maskingPresets[r.Preset]
+		if !ok {
+			return fmt.Errorf("unknown preset: %q: the preset must be one of %s", r.Preset, strings.Join(slices.Sorted(maps.Keys(maskingPresets)), ", "))
+		}
+		r.re = regexp.MustCompile(pattern)
+	} else if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		r.re = re
+	} else if len(r.FieldTypes) == 0 {
+		return fmt.Errorf("either preset, pattern, or fieldTypes is required")
+	}
+
+	if r.Name == "" {
+		r.Name = r.Preset
+	}
+	if r.Name == "" {
+		r.Name = r.Pattern
+	}
+	if r.Name == "" {
+		r.Name = strings.Join(r.FieldTypes, ",")
+	}
+	if r.Replacement == "" {
+		r.Replacement = defaultMaskReplacement
+	}
+	return nil
+}
+
+// maskCounts is the number of masked values for each rule.
+type maskCounts map[string]int
+
+// maskString masks the text with the pattern rules that can be applied to the field type.
+// fieldType is empty for texts that are not record fields, such as comments.
+func (c Configuration) maskString(s, fieldType string, counts maskCounts) string {
+	for i := range c.Masking {
+		r := &c.Masking[i]
+		if r.re == nil || (len(r.FieldTypes) > 0 && !slices.Contains(r.FieldTypes, fieldType)) {
+			continue
+		}
+		s = r.re.ReplaceAllStringFunc(s, func(string) string {
+			counts[r.Name]++
+			return r.Replacement
+		})
+	}
+	return s
+}
+
+// maskValue masks all strings in the value, such as a text, a list of choices, or a list of users.
+func (c Configuration) maskValue(v any, fieldType string, counts maskCounts) any {
+	switch v := v.(type) {
+	case string:
+		return c.maskString(v, fieldType, counts)
+	case []any:
+		for i := range v {
+			v[i] = c.maskValue(v[i], fieldType, counts)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = c.maskValue(v[k], fieldType, counts)
+		}
+	}
+	return v
+}
+
+// maskRecord masks the values of the record, including fields in tables.
+func (c Configuration) maskRecord(record map[string]any, counts maskCounts) {
+	if len(c.Masking) == 0 {
+		return
+	}
+
+	for _, v := range record {
+		field, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		fieldType, _ := field["type"].(string)
+
+		if fieldType == "SUBTABLE" {
+			rows, _ := field["value"].([]any)
+			for _, row := range rows {
+				if row, ok := row.(map[string]any); ok {
+					if cells, ok := row["value"].(map[string]any); ok {
+						c.maskRecord(cells, counts)
+					}
+				}
+			}
+			continue
+		}
+		if slices.Contains(unmaskableFieldTypes, fieldType) {
+			continue
+		}
+
+		for i := range c.Masking {
+			r := &c.Masking[i]
+			if r.re == nil && slices.Contains(r.FieldTypes, fieldType) && field["value"] != nil {
+				field["value"] = r.Replacement
+				counts[r.Name]++
+			}
+		}
+		field["value"] = c.maskValue(field["value"], fieldType, counts)
+	}
+}
+
+// maskComment masks the text and the names in the comment.
+func (c Configuration) maskComment(comment map[string]any, counts maskCounts) {
+	if len(c.Masking) == 0 {
+		return
+	}
+
+	for k, v := range comment {
+		if k != "id" && k != "createdAt" {
+			comment[k] = c.maskValue(v, "", counts)
+		}
+	}
+}
+
+// maskingAudit counts the masked values since the server started.
+type maskingAudit struct {
+	mu     sync.Mutex
+	totals maskCounts
+}
+
+// record adds the counts to the totals, and writes an audit log to stderr.
+func (a *maskingAudit) record(ctx context.Context, source string, counts maskCounts) {
+	if len(counts) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	if a.totals == nil {
+		a.totals = make(maskCounts)
+	}
+	for name, n := range counts {
+		a.totals[name] += n
+	}
+	totals := maps.Clone(a.totals)
+	a.mu.Unlock()
+
+	serverLog.InfoContext(ctx, "Masked values", "source", source, "counts", counts.String(), "totals", totals.String())
+}
+
+// String formats the counts such as "email=2, phone=1".
+func (c maskCounts) String() string {
+	parts := make([]string, 0, len(c))
+	for _, name := range slices.Sorted(maps.Keys(c)) {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, c[name]))
+	}
+	return strings.Join(parts, ", ")
+}
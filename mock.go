@@ -0,0 +1,1622 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockBaseURL is the base URL of kintone in the mock mode if KINTONE_BASE_URL is not set. It is never resolved.
+const mockBaseURL = "https://mock.kintone.invalid"
+
+// MockFixture is the initial data of the mock kintone, that is loaded from KINTONE_MOCK_FIXTURE.
+type MockFixture struct {
+	// User is the code of the login user. Default is the first user.
+	User string `json:"user"`
+
+	Apps          []MockAppFixture `json:"apps"`
+	Users         []map[string]any `json:"users"`
+	Organizations []map[string]any `json:"organizations"`
+	Groups        []map[string]any `json:"groups"`
+}
+
+// MockAppFixture is an app in MockFixture.
+type MockAppFixture struct {
+	AppID       string `json:"appID"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Fields is the field definitions in the format of app/form/fields.json.
+	// The code and the label default to the key, and the options can be a list of labels.
+	Fields map[string]map[string]any `json:"fields"`
+
+	// ProcessManagement is the settings of the process management in the format of app/status.json.
+	ProcessManagement map[string]any `json:"processManagement"`
+
+	// Views is the views in the format of app/views.json.
+	Views map[string]any `json:"views"`
+
+	// FieldACL is the field permissions in the format of the rights of field/acl.json.
+	FieldACL []map[string]any `json:"fieldACL"`
+
+	// Records is the records in the simple format, such as {"title": "Hello"}.
+	Records []map[string]any `json:"records"`
+}
+
+// mockKintone is an in-memory fake of kintone for KINTONE_MOCK.
+// It serves the REST API that the tools use, and keeps the changes until the server stops.
+type mockKintone struct {
+	mu sync.Mutex
+
+	user          string
+	location      *time.Location
+	apps          map[string]*mockApp
+	users         []map[string]any
+	organizations []map[string]any
+	groups        []map[string]any
+	files         map[string]mockFile
+	cursors       map[string]*mockCursor
+
+	// serial is the counter to issue IDs of files, cursors, rows, and errors.
+	serial int
+}
+
+type mockApp struct {
+	id          string
+	name        string
+	description string
+	createdAt   string
+	properties  map[string]map[string]any
+	fields      map[string]formField
+	process     map[string]any
+	views       map[string]any
+	fieldACL    []map[string]any
+	settings    map[string]map[string]any
+	preview     map[string]map[string]any
+	records     []map[string]any
+	lastID      int
+	comments    map[string][]map[string]any
+}
+
+type mockFile struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+type mockCursor struct {
+	records []map[string]any
+	size    int
+}
+
+// mockError is an error response of the mock kintone, in the same format as kintone.
+type mockError struct {
+	Status  int            `json:"-"`
+	Code    string         `json:"code"`
+	ID      string         `json:"id"`
+	Message string         `json:"message"`
+	Errors  map[string]any `json:"errors,omitempty"`
+}
+
+func (e *mockError) Error() string {
+	return e.Message
+}
+
+// invalidInput returns the error of kintone for invalid values, such as a missing required field.
+func invalidInput(errs map[string]any) *mockError {
+	return &mockError{Status: 400, Code: "CB_VA01", Message: "Missing or invalid input.", Errors: errs}
+}
+
+// loadMockKintone creates the mock kintone from the fixture file, or from the demo data if path is empty.
+func loadMockKintone(path string) (*mockKintone, error) {
+	data := []byte(mockDemoFixture)
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var fixture MockFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, err
+	}
+	return newMockKintone(fixture)
+}
+
+func newMockKintone(fixture MockFixture) (*mockKintone, error) {
+	m := &mockKintone{
+		user:          fixture.User,
+		location:      time.UTC,
+		apps:          make(map[string]*mockApp),
+		users:         fixture.Users,
+		organizations: fixture.Organizations,
+		groups:        fixture.Groups,
+		files:         make(map[string]mockFile),
+		cursors:       make(map[string]*mockCursor),
+	}
+
+	if len(m.users) == 0 {
+		m.users = []map[string]any{{"code": "Administrator", "name": "Administrator"}}
+	}
+	for i, u := range m.users {
+		if mockString(u["code"]) == "" {
+			return nil, fmt.Errorf("users[%d]: code is required", i)
+		}
+		u["id"] = strconv.Itoa(i + 1)
+		if u["name"] == nil {
+			u["name"] = u["code"]
+		}
+	}
+	for _, entities := range [][]map[string]any{m.organizations, m.groups} {
+		for i, e := range entities {
+			e["id"] = strconv.Itoa(i + 1)
+			if e["name"] == nil {
+				e["name"] = e["code"]
+			}
+		}
+	}
+
+	if m.user == "" {
+		m.user = mockString(m.users[0]["code"])
+	}
+	user, ok := m.findEntity("users", m.user)
+	if !ok {
+		return nil, fmt.Errorf("the user %s is not found in users", m.user)
+	}
+	if tz := mockString(user["timezone"]); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("the timezone of the user %s is invalid: %w", m.user, err)
+		}
+		m.location = loc
+	} else {
+		user["timezone"] = "UTC"
+	}
+
+	for i, f := range fixture.Apps {
+		app, err := m.newApp(f, i)
+		if err != nil {
+			return nil, fmt.Errorf("apps[%d]: %w", i, err)
+		}
+		if _, ok := m.apps[app.id]; ok {
+			return nil, fmt.Errorf("apps[%d]: the app ID %s is duplicated", i, app.id)
+		}
+		m.apps[app.id] = app
+
+		for j, simple := range f.Records {
+			input := make(map[string]any, len(simple))
+			for code, v := range simple {
+				input[code] = map[string]any{"value": app.fields[code].recordValue(v)}
+			}
+			// recordValue makes JsonMap, so convert into plain maps as the requests.
+			bs, _ := json.Marshal(input)
+			json.Unmarshal(bs, &input)
+
+			errs := make(map[string]any)
+			record := m.buildRecord(app, input, "record.", errs)
+			for code := range simple {
+				// The fixtures can set the fields that kintone calculates.
+				if field := app.fields[code]; field.Type == "CALC" || field.Type == "STATUS" || field.Type == "STATUS_ASSIGNEE" {
+					cell, _ := input[code].(map[string]any)
+					record[code] = mockField(field.Type, m.normalizeValue(field, cell["value"], "record."+code+".value", errs))
+				}
+			}
+			if len(errs) > 0 {
+				bs, _ := json.Marshal(errs)
+				return nil, fmt.Errorf("apps[%d].records[%d]: invalid values: %s", i, j, bs)
+			}
+			app.addRecord(record)
+		}
+	}
+
+	return m, nil
+}
+
+// newApp creates an app from the fixture, adding the system fields that every kintone app has.
+func (m *mockKintone) newApp(f MockAppFixture, index int) (*mockApp, error) {
+	app := &mockApp{
+		id:          cmp.Or(f.AppID, strconv.Itoa(index+1)),
+		name:        cmp.Or(f.Name, fmt.Sprintf("App %d", index+1)),
+		description: f.Description,
+		createdAt:   time.Now().UTC().Truncate(time.Second).Format(time.RFC3339),
+		properties:  make(map[string]map[string]any),
+		process:     f.ProcessManagement,
+		views:       f.Views,
+		fieldACL:    f.FieldACL,
+		comments:    make(map[string][]map[string]any),
+	}
+	if _, err := strconv.ParseUint(app.id, 10, 64); err != nil {
+		return nil, fmt.Errorf("the app ID must be a number: %s", app.id)
+	}
+	if app.views == nil {
+		app.views = map[string]any{}
+	}
+
+	for code, p := range f.Fields {
+		if err := normalizeMockProperty(code, p); err != nil {
+			return nil, err
+		}
+		app.properties[code] = p
+	}
+
+	systemFields := []struct{ Code, Type, Label string }{
+		{"Record_number", "RECORD_NUMBER", "Record number"},
+		{"Created_by", "CREATOR", "Created by"},
+		{"Created_datetime", "CREATED_TIME", "Created datetime"},
+		{"Updated_by", "MODIFIER", "Updated by"},
+		{"Updated_datetime", "UPDATED_TIME", "Updated datetime"},
+	}
+	if enable, _ := app.process["enable"].(bool); enable {
+		systemFields = append(systemFields,
+			struct{ Code, Type, Label string }{"Status", "STATUS", "Status"},
+			struct{ Code, Type, Label string }{"Assignee", "STATUS_ASSIGNEE", "Assignee"},
+		)
+	}
+	for _, s := range systemFields {
+		exists := slices.ContainsFunc(slices.Collect(maps.Values(app.properties)), func(p map[string]any) bool { return p["type"] == s.Type })
+		if _, ok := app.properties[s.Code]; !ok && !exists {
+			app.properties[s.Code] = map[string]any{"type": s.Type, "code": s.Code, "label": s.Label, "noLabel": false}
+		}
+	}
+
+	bs, err := json.Marshal(app.properties)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bs, &app.fields); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// normalizeMockProperty fills the code and the label of the field, and converts the options in a list into the format of kintone.
+func normalizeMockProperty(code string, p map[string]any) error {
+	if mockString(p["type"]) == "" {
+		return fmt.Errorf("the type of the field %s is required", code)
+	}
+	p["code"] = code
+	if p["label"] == nil {
+		p["label"] = code
+	}
+	if p["noLabel"] == nil {
+		p["noLabel"] = false
+	}
+	if p["required"] == nil {
+		switch p["type"] {
+		case "SINGLE_LINE_TEXT", "MULTI_LINE_TEXT", "RICH_TEXT", "NUMBER", "DATE", "TIME", "DATETIME", "LINK",
+			"CHECK_BOX", "RADIO_BUTTON", "DROP_DOWN", "MULTI_SELECT", "FILE",
+			"USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT":
+			p["required"] = false
+		}
+	}
+	if options, ok := p["options"].([]any); ok {
+		converted := make(map[string]any, len(options))
+		for i, o := range options {
+			label := mockString(o)
+			converted[label] = map[string]any{"label": label, "index": strconv.Itoa(i)}
+		}
+		p["options"] = converted
+	}
+	if fields, ok := p["fields"].(map[string]any); ok {
+		for c, sub := range fields {
+			sub, ok := sub.(map[string]any)
+			if !ok {
+				return fmt.Errorf("the field %s in the table %s is invalid", c, code)
+			}
+			if err := normalizeMockProperty(c, sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldType returns the type of the field, including the fields in tables.
+func (app *mockApp) fieldType(code string) (string, bool) {
+	switch code {
+	case "$id":
+		return "__ID__", true
+	case "$revision":
+		return "__REVISION__", true
+	}
+	if f, ok := app.fields[code]; ok {
+		return f.Type, true
+	}
+	for _, f := range app.fields {
+		if sub, ok := f.Fields[code]; ok && f.Type == "SUBTABLE" {
+			return sub.Type, true
+		}
+	}
+	return "", false
+}
+
+// initialStatus returns the first status of the process management, or an empty string if it is disabled.
+func (app *mockApp) initialStatus() string {
+	states, _ := app.process["states"].(map[string]any)
+	first, index := "", -1
+	for name, s := range states {
+		s, _ := s.(map[string]any)
+		i, _ := strconv.Atoi(mockString(s["index"]))
+		if index < 0 || i < index || (i == index && name < first) {
+			first, index = name, i
+		}
+	}
+	return first
+}
+
+// findRecord returns the record of the ID, or an error of kintone if it doesn't exist.
+func (app *mockApp) findRecord(id string) (map[string]any, error) {
+	for _, r := range app.records {
+		if mockFieldValues(r, "$id")[0] == id {
+			return r, nil
+		}
+	}
+	return nil, &mockError{Status: 404, Code: "GAIA_RE01", Message: fmt.Sprintf("The specified record (ID: %s) is not found.", id)}
+}
+
+// addRecord assigns an ID to the record that is made by buildRecord, and stores it.
+func (app *mockApp) addRecord(record map[string]any) string {
+	app.lastID++
+	id := strconv.Itoa(app.lastID)
+	record["$id"] = mockField("__ID__", id)
+	record["$revision"] = mockField("__REVISION__", "1")
+	for code, f := range app.fields {
+		if f.Type == "RECORD_NUMBER" {
+			record[code] = mockField(f.Type, id)
+		}
+	}
+	app.records = append(app.records, record)
+	return id
+}
+
+// buildRecord makes a new record from the values in the format of kintone. The problems of the values are added to errs.
+func (m *mockKintone) buildRecord(app *mockApp, input map[string]any, prefix string, errs map[string]any) map[string]any {
+	now := time.Now().UTC().Truncate(time.Minute).Format(time.RFC3339)
+	me, _ := m.entity("users", m.user)
+
+	record := make(map[string]any, len(app.fields)+2)
+	for code, f := range app.fields {
+		var v any
+		switch f.Type {
+		case "CREATOR", "MODIFIER":
+			v = me
+		case "CREATED_TIME", "UPDATED_TIME":
+			v = now
+		case "STATUS":
+			v = app.initialStatus()
+		default:
+			v = mockEmptyValue(f.Type)
+		}
+		record[code] = mockField(f.Type, v)
+	}
+
+	m.setValues(app, record, input, true, prefix, errs)
+
+	for code, f := range app.fields {
+		if f.Required && mockIsEmpty(mockFieldValues(record, code)[0]) {
+			errs[prefix+code+".value"] = map[string]any{"messages": []any{"Required."}}
+		}
+	}
+	return record
+}
+
+// setValues writes the values in the format of kintone into the record. The fields that kintone calculates are ignored.
+// The creator and the created time can be set only when creating a record, as kintone does.
+func (m *mockKintone) setValues(app *mockApp, record, input map[string]any, creating bool, prefix string, errs map[string]any) {
+	for _, code := range slices.Sorted(maps.Keys(input)) {
+		f, ok := app.fields[code]
+		if !ok {
+			errs[prefix+code] = map[string]any{"messages": []any{fmt.Sprintf("The specified field (code: %s) not found.", code)}}
+			continue
+		}
+		switch f.Type {
+		case "RECORD_NUMBER", "CALC", "STATUS", "STATUS_ASSIGNEE", "CATEGORY":
+			continue
+		case "CREATOR", "MODIFIER", "CREATED_TIME", "UPDATED_TIME":
+			if !creating {
+				continue
+			}
+		}
+		cell, _ := input[code].(map[string]any)
+		path := prefix + code + ".value"
+		v := m.normalizeValue(f, cell["value"], path, errs)
+		if f.Required && mockIsEmpty(v) {
+			errs[path] = map[string]any{"messages": []any{"Required."}}
+		}
+		record[code] = mockField(f.Type, v)
+	}
+}
+
+// normalizeValue converts a value in a request into the value that kintone returns, such as adding the names of users.
+func (m *mockKintone) normalizeValue(f formField, v any, path string, errs map[string]any) any {
+	fail := func(msg string) {
+		errs[path] = map[string]any{"messages": []any{msg}}
+	}
+
+	switch f.Type {
+	case "CHECK_BOX", "MULTI_SELECT", "CATEGORY":
+		items := []any{}
+		for _, s := range mockTexts(v) {
+			if _, ok := f.Options[s]; !ok && len(f.Options) > 0 {
+				fail(fmt.Sprintf("%s is not an option of the field.", s))
+			}
+			items = append(items, s)
+		}
+		return items
+	case "USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT", "STATUS_ASSIGNEE", "CREATOR", "MODIFIER":
+		entities := []any{}
+		for _, code := range mockTexts(v) {
+			e, ok := m.entity(entityKinds[f.Type], code)
+			if !ok {
+				fail(fmt.Sprintf("%s is not found.", code))
+			}
+			entities = append(entities, e)
+		}
+		if f.Type == "CREATOR" || f.Type == "MODIFIER" {
+			if len(entities) == 0 {
+				me, _ := m.entity("users", m.user)
+				return me
+			}
+			return entities[0]
+		}
+		return entities
+	case "FILE":
+		files := []any{}
+		list, _ := v.([]any)
+		for _, x := range list {
+			key := mockString(x)
+			if x, ok := x.(map[string]any); ok {
+				key = mockString(x["fileKey"])
+			}
+			file, ok := m.files[key]
+			if !ok {
+				fail(fmt.Sprintf("The file (fileKey: %s) is not found.", key))
+				continue
+			}
+			files = append(files, map[string]any{
+				"fileKey":     key,
+				"name":        file.name,
+				"contentType": file.contentType,
+				"size":        strconv.Itoa(len(file.data)),
+			})
+		}
+		return files
+	case "SUBTABLE":
+		rows := []any{}
+		list, _ := v.([]any)
+		for i, row := range list {
+			row, _ := row.(map[string]any)
+			cells, _ := row["value"].(map[string]any)
+			id := mockString(row["id"])
+			if id == "" {
+				m.serial++
+				id = strconv.Itoa(m.serial)
+			}
+			values := make(map[string]any, len(f.Fields))
+			for code, sub := range f.Fields {
+				cell, _ := cells[code].(map[string]any)
+				values[code] = mockField(sub.Type, m.normalizeValue(sub, cell["value"], fmt.Sprintf("%s[%d].value.%s.value", path, i, code), errs))
+			}
+			rows = append(rows, map[string]any{"id": id, "value": values})
+		}
+		return rows
+	}
+
+	s := mockString(v)
+	if s == "" {
+		return ""
+	}
+	switch f.Type {
+	case "NUMBER":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			fail("Only numbers are allowed.")
+		}
+	case "DATE":
+		if !queryDatePattern.MatchString(s) {
+			fail("The date must be in the format of YYYY-MM-DD.")
+		}
+	case "DATETIME", "CREATED_TIME", "UPDATED_TIME":
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			fail("The datetime must be in the format of ISO 8601, such as 2025-01-31T09:00:00Z.")
+			return s
+		}
+		return t.UTC().Truncate(time.Minute).Format(time.RFC3339)
+	case "DROP_DOWN", "RADIO_BUTTON":
+		if _, ok := f.Options[s]; !ok && len(f.Options) > 0 {
+			fail(fmt.Sprintf("%s is not an option of the field.", s))
+		}
+	}
+	return s
+}
+
+// findEntity returns the user, organization, or group of the code.
+func (m *mockKintone) findEntity(kind, code string) (map[string]any, bool) {
+	entities := map[string][]map[string]any{
+		"users":         m.users,
+		"organizations": m.organizations,
+		"groups":        m.groups,
+	}[kind]
+	for _, e := range entities {
+		if mockString(e["code"]) == code {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// entity returns the code and the name of the entity as the values of records.
+func (m *mockKintone) entity(kind, code string) (map[string]any, bool) {
+	e, ok := m.findEntity(kind, code)
+	if !ok {
+		return map[string]any{"code": code, "name": code}, false
+	}
+	return map[string]any{"code": code, "name": mockString(e["name"])}, true
+}
+
+// RoundTrip serves the request by the mock kintone in process, so that the HTTP client of the handlers can use it as the transport.
+func (m *mockKintone) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	res := rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+func (m *mockKintone) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var res any
+	var err error
+	route := r.Method + " " + r.URL.Path
+	switch route {
+	case "POST /k/v1/file.json":
+		res, err = m.uploadFile(r)
+	case "GET /k/v1/file.json":
+		if err = m.downloadFile(w, r); err == nil {
+			return
+		}
+	default:
+		var p map[string]any
+		if p, err = mockParams(r); err != nil {
+			break
+		}
+		handler, ok := m.routes()[route]
+		if !ok {
+			err = &mockError{Status: 404, Message: fmt.Sprintf("The API is not supported by the mock kintone: %s", route)}
+			break
+		}
+		res, err = handler(p)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err != nil {
+		var mockErr *mockError
+		if !errors.As(err, &mockErr) {
+			mockErr = &mockError{Status: 500, Code: "GAIA_UN01", Message: err.Error()}
+		}
+		m.serial++
+		mockErr.ID = fmt.Sprintf("mock-%d", m.serial)
+		w.WriteHeader(mockErr.Status)
+		json.NewEncoder(w).Encode(mockErr)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// routes returns the handlers of the APIs that take parameters in the query string or in the JSON body.
+func (m *mockKintone) routes() map[string]func(p map[string]any) (any, error) {
+	return map[string]func(p map[string]any) (any, error){
+		"GET /k/v1/apis.json":                                m.apis,
+		"GET /k/v1/apps.json":                                m.listApps,
+		"GET /k/v1/app.json":                                 m.getApp,
+		"GET /k/v1/app/form/fields.json":                     m.getFields,
+		"GET /k/v1/app/status.json":                          m.getStatus,
+		"GET /k/v1/app/views.json":                           m.getViews,
+		"GET /k/v1/app/settings.json":                        m.getSettings,
+		"GET /k/v1/field/acl.json":                           m.getFieldACL,
+		"GET /k/v1/preview/app/acl.json":                     m.getPreviewSettings("app/acl"),
+		"PUT /k/v1/preview/app/acl.json":                     m.updatePreviewSettings("app/acl"),
+		"GET /k/v1/preview/record/acl.json":                  m.getPreviewSettings("record/acl"),
+		"PUT /k/v1/preview/record/acl.json":                  m.updatePreviewSettings("record/acl"),
+		"GET /k/v1/preview/field/acl.json":                   m.getPreviewSettings("field/acl"),
+		"PUT /k/v1/preview/field/acl.json":                   m.updatePreviewSettings("field/acl"),
+		"GET /k/v1/preview/app/notifications/general.json":   m.getPreviewSettings("app/notifications/general"),
+		"PUT /k/v1/preview/app/notifications/general.json":   m.updatePreviewSettings("app/notifications/general"),
+		"GET /k/v1/preview/app/notifications/perRecord.json": m.getPreviewSettings("app/notifications/perRecord"),
+		"PUT /k/v1/preview/app/notifications/perRecord.json": m.updatePreviewSettings("app/notifications/perRecord"),
+		"POST /k/v1/preview/app/deploy.json":                 m.deploy,
+		"GET /k/v1/preview/app/deploy.json":                  m.deployStatus,
+		"GET /k/v1/record.json":                              m.getRecord,
+		"POST /k/v1/record.json":                             m.createRecord,
+		"PUT /k/v1/record.json":                              m.updateRecord,
+		"GET /k/v1/records.json":                             m.getRecords,
+		"GET /k/v1/records/acl/evaluate.json":                m.evaluateRecordACL,
+		"POST /k/v1/records.json":                            m.createRecords,
+		"DELETE /k/v1/records.json":                          m.deleteRecords,
+		"POST /k/v1/records/cursor.json":                     m.createCursor,
+		"GET /k/v1/records/cursor.json":                      m.readCursor,
+		"DELETE /k/v1/records/cursor.json":                   m.deleteCursor,
+		"GET /k/v1/record/comments.json":                     m.getComments,
+		"POST /k/v1/record/comment.json":                     m.createComment,
+		"PUT /k/v1/record/assignees.json":                    m.updateAssignees,
+		"PUT /k/v1/record/status.json":                       m.updateStatus,
+		"GET /v1/users.json":                                 m.directory("users"),
+		"GET /v1/organizations.json":                         m.directory("organizations"),
+		"GET /v1/groups.json":                                m.directory("groups"),
+	}
+}
+
+// mockParams reads the parameters from the query string and the JSON body, as kintone accepts both for GET requests.
+func mockParams(r *http.Request) (map[string]any, error) {
+	p := make(map[string]any)
+	for k, v := range r.URL.Query() {
+		p[k] = v[0]
+	}
+	if r.Body == nil {
+		return p, nil
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, &mockError{Status: 400, Code: "CB_IJ01", Message: fmt.Sprintf("Failed to decompress the request body: %s", err)}
+		}
+		defer gz.Close()
+		body = gz
+	}
+	d := json.NewDecoder(body)
+	d.UseNumber()
+	if err := d.Decode(&p); err != nil && err != io.EOF {
+		return nil, &mockError{Status: 400, Code: "CB_IJ01", Message: fmt.Sprintf("Failed to parse JSON: %s", err)}
+	}
+	return p, nil
+}
+
+// mockString converts a parameter or a value into a string.
+func mockString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// mockInt reads an integer parameter, or returns def if it is not set.
+func mockInt(p map[string]any, key string, def, limit int) (int, error) {
+	s := mockString(p[key])
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > limit {
+		return 0, invalidInput(map[string]any{key: map[string]any{"messages": []any{fmt.Sprintf("Enter an integer between 0 and %d.", limit)}}})
+	}
+	return n, nil
+}
+
+// mockList reads a list parameter that is either a JSON array or the query string such as ids[0]=1&ids[1]=2.
+func mockList(p map[string]any, key string) []string {
+	if list, ok := p[key].([]any); ok {
+		items := make([]string, 0, len(list))
+		for _, x := range list {
+			items = append(items, mockString(x))
+		}
+		return items
+	}
+	if s, ok := p[key].(string); ok {
+		return []string{s}
+	}
+	var items []string
+	for i := 0; ; i++ {
+		s, ok := p[fmt.Sprintf("%s[%d]", key, i)]
+		if !ok {
+			return items
+		}
+		items = append(items, mockString(s))
+	}
+}
+
+// mockField makes a field of a record.
+func mockField(typ string, value any) map[string]any {
+	return map[string]any{"type": typ, "value": value}
+}
+
+// mockEmptyValue returns the value of the field that has no value.
+func mockEmptyValue(typ string) any {
+	switch typ {
+	case "CHECK_BOX", "MULTI_SELECT", "CATEGORY", "USER_SELECT", "ORGANIZATION_SELECT", "GROUP_SELECT", "STATUS_ASSIGNEE", "FILE", "SUBTABLE":
+		return []any{}
+	case "CREATOR", "MODIFIER":
+		return map[string]any{"code": "", "name": ""}
+	default:
+		return ""
+	}
+}
+
+// mockIsEmpty reports whether the value of a field is empty.
+func mockIsEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return mockString(v["code"]) == ""
+	}
+	return false
+}
+
+func (m *mockKintone) app(p map[string]any, key string) (*mockApp, error) {
+	id := mockString(p[key])
+	if id == "" {
+		return nil, invalidInput(map[string]any{key: map[string]any{"messages": []any{"Required."}}})
+	}
+	app, ok := m.apps[id]
+	if !ok {
+		return nil, &mockError{Status: 404, Code: "GAIA_AP01", Message: fmt.Sprintf("The app (ID: %s) not found. The app may have been deleted.", id)}
+	}
+	return app, nil
+}
+
+func (m *mockKintone) apis(p map[string]any) (any, error) {
+	names := []string{
+		"app/get", "apps/get", "app/form/fields/get", "app/status/get", "app/views/get", "app/settings/get",
+		"record/get", "record/post", "record/put", "records/get", "records/post", "records/delete",
+		"records/cursor/post", "records/cursor/get", "records/cursor/delete",
+		"file/post", "file/get", "record/comments/get", "record/comment/post",
+		"record/assignees/put", "record/status/put",
+		"preview/app/acl/get", "preview/app/acl/put", "preview/record/acl/get", "preview/record/acl/put",
+		"records/acl/evaluate/get", "preview/field/acl/get", "preview/field/acl/put", "preview/app/deploy/post", "preview/app/deploy/get",
+		"preview/app/notifications/general/get", "preview/app/notifications/general/put",
+		"preview/app/notifications/perRecord/get", "preview/app/notifications/perRecord/put",
+	}
+	apis := make(map[string]any, len(names))
+	for _, name := range names {
+		apis[name] = map[string]any{"link": "apis/" + name + ".json"}
+	}
+	return map[string]any{"baseUrl": mockBaseURL + "/k/v1/", "apis": apis}, nil
+}
+
+// appInfo returns the app in the format of app.json.
+func (m *mockKintone) appInfo(app *mockApp) map[string]any {
+	me, _ := m.entity("users", m.user)
+	return map[string]any{
+		"appId":       app.id,
+		"code":        "",
+		"name":        app.name,
+		"description": app.description,
+		"spaceId":     nil,
+		"threadId":    nil,
+		"createdAt":   app.createdAt,
+		"creator":     me,
+		"modifiedAt":  app.createdAt,
+		"modifier":    me,
+	}
+}
+
+func (m *mockKintone) listApps(p map[string]any) (any, error) {
+	offset, err := mockInt(p, "offset", 0, 1<<31-1)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := mockInt(p, "limit", 100, 100)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(mockString(p["name"]))
+	ids := mockList(p, "ids")
+
+	apps := slices.SortedFunc(maps.Values(m.apps), func(a, b *mockApp) int {
+		x, _ := strconv.ParseUint(a.id, 10, 64)
+		y, _ := strconv.ParseUint(b.id, 10, 64)
+		return cmp.Compare(x, y)
+	})
+	result := []any{}
+	for _, app := range apps {
+		if !strings.Contains(strings.ToLower(app.name), name) || (len(ids) > 0 && !slices.Contains(ids, app.id)) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if len(result) >= limit {
+			break
+		}
+		result = append(result, m.appInfo(app))
+	}
+	return map[string]any{"apps": result}, nil
+}
+
+func (m *mockKintone) getApp(p map[string]any) (any, error) {
+	app, err := m.app(p, "id")
+	if err != nil {
+		return nil, err
+	}
+	return m.appInfo(app), nil
+}
+
+func (m *mockKintone) getFields(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"properties": app.properties, "revision": "1"}, nil
+}
+
+func (m *mockKintone) getStatus(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	enable, _ := app.process["enable"].(bool)
+	return map[string]any{
+		"enable":   enable,
+		"states":   app.process["states"],
+		"actions":  app.process["actions"],
+		"revision": "1",
+	}, nil
+}
+
+func (m *mockKintone) getViews(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"views": app.views, "revision": "1"}, nil
+}
+
+func (m *mockKintone) getFieldACL(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	rights := app.fieldACL
+	if rights == nil {
+		rights = []map[string]any{}
+	}
+	return map[string]any{"rights": rights, "revision": "1"}, nil
+}
+
+// deployedSettings returns the settings of the app in the format of the API, such as "app/acl" for app/acl.json.
+// The field permissions are kept in fieldACL, because they are also loaded from the fixture.
+func (a *mockApp) deployedSettings(name string) map[string]any {
+	if name == "field/acl" {
+		rights := make([]any, len(a.fieldACL))
+		for i, r := range a.fieldACL {
+			rights[i] = r
+		}
+		return map[string]any{"rights": rights}
+	}
+	if settings, ok := a.settings[name]; ok {
+		return settings
+	}
+	switch name {
+	case "app/notifications/general":
+		return map[string]any{"notifications": []any{}, "notifyToCommenter": false}
+	case "app/notifications/perRecord":
+		return map[string]any{"notifications": []any{}}
+	default:
+		return map[string]any{"rights": []any{}}
+	}
+}
+
+func (m *mockKintone) getPreviewSettings(name string) func(p map[string]any) (any, error) {
+	return func(p map[string]any) (any, error) {
+		app, err := m.app(p, "app")
+		if err != nil {
+			return nil, err
+		}
+		settings, ok := app.preview[name]
+		if !ok {
+			settings = app.deployedSettings(name)
+		}
+		res := maps.Clone(settings)
+		res["revision"] = "1"
+		return res, nil
+	}
+}
+
+func (m *mockKintone) updatePreviewSettings(name string) func(p map[string]any) (any, error) {
+	return func(p map[string]any) (any, error) {
+		app, err := m.app(p, "app")
+		if err != nil {
+			return nil, err
+		}
+		settings, ok := app.preview[name]
+		if !ok {
+			settings = app.deployedSettings(name)
+		}
+		settings = maps.Clone(settings)
+		for k, v := range p {
+			if _, known := settings[k]; known {
+				settings[k] = v
+			}
+		}
+		if app.preview == nil {
+			app.preview = make(map[string]map[string]any)
+		}
+		app.preview[name] = settings
+		return map[string]any{"revision": "1"}, nil
+	}
+}
+
+// deploy applies the settings in the preview environment immediately, or discards them if revert is true.
+func (m *mockKintone) deploy(p map[string]any) (any, error) {
+	apps, _ := p["apps"].([]any)
+	for _, a := range apps {
+		a, _ := a.(map[string]any)
+		app, err := m.app(a, "app")
+		if err != nil {
+			return nil, err
+		}
+		if revert, _ := p["revert"].(bool); !revert {
+			for name, settings := range app.preview {
+				if name == "field/acl" {
+					rights, _ := settings["rights"].([]any)
+					app.fieldACL = make([]map[string]any, 0, len(rights))
+					for _, r := range rights {
+						r, _ := r.(map[string]any)
+						app.fieldACL = append(app.fieldACL, r)
+					}
+					continue
+				}
+				if app.settings == nil {
+					app.settings = make(map[string]map[string]any)
+				}
+				app.settings[name] = settings
+			}
+		}
+		app.preview = nil
+	}
+	return map[string]any{}, nil
+}
+
+func (m *mockKintone) deployStatus(p map[string]any) (any, error) {
+	ids, _ := p["apps"].([]any)
+	statuses := make([]any, 0, len(ids))
+	for _, id := range ids {
+		if _, err := m.app(map[string]any{"app": id}, "app"); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, map[string]any{"app": mockString(id), "status": "SUCCESS"})
+	}
+	return map[string]any{"apps": statuses}, nil
+}
+
+// evaluateRecordACL evaluates the deployed record permissions for the login user.
+// Only the entities of the login user and "everyone" are matched, and the first right whose condition matches the record decides the permissions.
+func (m *mockKintone) evaluateRecordACL(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+
+	rights := []any{}
+	for _, id := range mockList(p, "ids") {
+		record, err := app.findRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		perm := map[string]any{"viewable": true, "editable": true, "deletable": true}
+		acl, _ := app.deployedSettings("record/acl")["rights"].([]any)
+		for _, r := range acl {
+			r, _ := r.(map[string]any)
+			q, err := m.compileQuery(app, mockString(r["filterCond"]), 1)
+			if err != nil {
+				return nil, err
+			}
+			if len(m.filter(q, []map[string]any{record})) == 0 {
+				continue
+			}
+			perm = map[string]any{"viewable": false, "editable": false, "deletable": false}
+			entities, _ := r["entities"].([]any)
+			for _, e := range entities {
+				e, _ := e.(map[string]any)
+				entity, _ := e["entity"].(map[string]any)
+				if code := mockString(entity["code"]); code == m.user || (entity["type"] == "GROUP" && code == "everyone") {
+					perm = map[string]any{"viewable": e["viewable"] == true, "editable": e["editable"] == true, "deletable": e["deletable"] == true}
+					break
+				}
+			}
+			break
+		}
+		rights = append(rights, map[string]any{"id": id, "record": perm, "fields": map[string]any{}})
+	}
+	return map[string]any{"rights": rights}, nil
+}
+
+func (m *mockKintone) getSettings(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"name":        app.name,
+		"description": app.description,
+		"icon":        map[string]any{"type": "PRESET", "key": "APP72"},
+		"theme":       "WHITE",
+		"revision":    "1",
+	}, nil
+}
+
+// projectRecord returns the record that has only the fields. All fields are returned if fields is empty.
+func projectRecord(record map[string]any, fields []string) map[string]any {
+	if len(fields) == 0 {
+		return record
+	}
+	projected := make(map[string]any, len(fields))
+	for _, code := range fields {
+		if v, ok := record[code]; ok {
+			projected[code] = v
+		}
+	}
+	return projected
+}
+
+func (m *mockKintone) getRecord(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	record, err := app.findRecord(mockString(p["id"]))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"record": record}, nil
+}
+
+func (m *mockKintone) getRecords(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	q, err := m.compileQuery(app, mockString(p["query"]), 100)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := m.filter(q, app.records)
+	fields := mockList(p, "fields")
+	records := []any{}
+	for _, r := range q.page(matched) {
+		records = append(records, projectRecord(r, fields))
+	}
+
+	var total any
+	if mockString(p["totalCount"]) == "true" {
+		total = strconv.Itoa(len(matched))
+	}
+	return map[string]any{"records": records, "totalCount": total}, nil
+}
+
+func (m *mockKintone) createRecord(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	input, _ := p["record"].(map[string]any)
+	errs := make(map[string]any)
+	record := m.buildRecord(app, input, "record.", errs)
+	if len(errs) > 0 {
+		return nil, invalidInput(errs)
+	}
+	return map[string]any{"id": app.addRecord(record), "revision": "1"}, nil
+}
+
+func (m *mockKintone) createRecords(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	inputs, _ := p["records"].([]any)
+	if len(inputs) > 100 {
+		return nil, invalidInput(map[string]any{"records": map[string]any{"messages": []any{"Only 100 records can be created at once."}}})
+	}
+
+	errs := make(map[string]any)
+	var records []map[string]any
+	for i, input := range inputs {
+		input, _ := input.(map[string]any)
+		records = append(records, m.buildRecord(app, input, fmt.Sprintf("records[%d].", i), errs))
+	}
+	if len(errs) > 0 {
+		return nil, invalidInput(errs)
+	}
+
+	ids, revisions := []any{}, []any{}
+	for _, r := range records {
+		ids = append(ids, app.addRecord(r))
+		revisions = append(revisions, "1")
+	}
+	return map[string]any{"ids": ids, "revisions": revisions}, nil
+}
+
+// checkRevision returns an error of kintone if the revision is specified and not the latest.
+func checkRevision(record map[string]any, revision any) error {
+	if r := mockString(revision); r != "" && r != "-1" && r != mockFieldValues(record, "$revision")[0] {
+		return &mockError{Status: 409, Code: "GAIA_CO02", Message: "The revision is not the latest. Someone may update a record."}
+	}
+	return nil
+}
+
+// touch increments the revision of the record, and sets the modifier and the updated time.
+func (m *mockKintone) touch(app *mockApp, record map[string]any, count int) string {
+	revision, _ := strconv.Atoi(mockFieldValues(record, "$revision")[0].(string))
+	revision += count
+	record["$revision"] = mockField("__REVISION__", strconv.Itoa(revision))
+
+	me, _ := m.entity("users", m.user)
+	for code, f := range app.fields {
+		switch f.Type {
+		case "MODIFIER":
+			record[code] = mockField(f.Type, me)
+		case "UPDATED_TIME":
+			record[code] = mockField(f.Type, time.Now().UTC().Truncate(time.Minute).Format(time.RFC3339))
+		}
+	}
+	return strconv.Itoa(revision)
+}
+
+func (m *mockKintone) updateRecord(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+
+	var record map[string]any
+	if key, ok := p["updateKey"].(map[string]any); ok {
+		code, value := mockString(key["field"]), mockString(key["value"])
+		for _, r := range app.records {
+			if v := mockFieldValues(r, code); len(v) > 0 && mockFieldType(r, code) != "" && mockString(v[0]) == value {
+				record = r
+				break
+			}
+		}
+		if record == nil {
+			return nil, &mockError{Status: 404, Code: "GAIA_RE01", Message: fmt.Sprintf("The specified record (%s: %s) is not found.", code, value)}
+		}
+	} else if record, err = app.findRecord(mockString(p["id"])); err != nil {
+		return nil, err
+	}
+	if err := checkRevision(record, p["revision"]); err != nil {
+		return nil, err
+	}
+
+	input, _ := p["record"].(map[string]any)
+	updated := maps.Clone(record)
+	errs := make(map[string]any)
+	m.setValues(app, updated, input, false, "record.", errs)
+	if len(errs) > 0 {
+		return nil, invalidInput(errs)
+	}
+	maps.Copy(record, updated)
+	return map[string]any{"revision": m.touch(app, record, 1)}, nil
+}
+
+func (m *mockKintone) deleteRecords(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	ids := mockList(p, "ids")
+	revisions := mockList(p, "revisions")
+	for i, id := range ids {
+		record, err := app.findRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(revisions) {
+			if err := checkRevision(record, revisions[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	app.records = slices.DeleteFunc(app.records, func(r map[string]any) bool {
+		return slices.Contains(ids, mockFieldValues(r, "$id")[0].(string))
+	})
+	for _, id := range ids {
+		delete(app.comments, id)
+	}
+	return map[string]any{}, nil
+}
+
+func (m *mockKintone) createCursor(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	size, err := mockInt(p, "size", 100, 500)
+	if err != nil {
+		return nil, err
+	}
+	q, err := m.compileQuery(app, mockString(p["query"]), 0)
+	if err != nil {
+		return nil, err
+	}
+	if q.parsed.Limit != "" || q.parsed.Offset != "" {
+		return nil, &mockError{Status: 400, Code: "GAIA_CU01", Message: "The query of a cursor can't have limit and offset."}
+	}
+
+	fields := mockList(p, "fields")
+	cursor := &mockCursor{size: max(size, 1)}
+	for _, r := range m.filter(q, app.records) {
+		cursor.records = append(cursor.records, projectRecord(r, fields))
+	}
+	m.serial++
+	id := fmt.Sprintf("mock-cursor-%d", m.serial)
+	m.cursors[id] = cursor
+	return map[string]any{"id": id, "totalCount": strconv.Itoa(len(cursor.records))}, nil
+}
+
+func (m *mockKintone) readCursor(p map[string]any) (any, error) {
+	id := mockString(p["id"])
+	cursor, ok := m.cursors[id]
+	if !ok {
+		return nil, &mockError{Status: 404, Code: "GAIA_CN01", Message: fmt.Sprintf("The cursor (ID: %s) is not found or expired.", id)}
+	}
+	n := min(cursor.size, len(cursor.records))
+	records := slices.Clone(cursor.records[:n])
+	cursor.records = cursor.records[n:]
+	next := len(cursor.records) > 0
+	if !next {
+		delete(m.cursors, id)
+	}
+	return map[string]any{"records": records, "next": next}, nil
+}
+
+func (m *mockKintone) deleteCursor(p map[string]any) (any, error) {
+	id := mockString(p["id"])
+	if _, ok := m.cursors[id]; !ok {
+		return nil, &mockError{Status: 404, Code: "GAIA_CN01", Message: fmt.Sprintf("The cursor (ID: %s) is not found or expired.", id)}
+	}
+	delete(m.cursors, id)
+	return map[string]any{}, nil
+}
+
+func (m *mockKintone) uploadFile(r *http.Request) (any, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, invalidInput(map[string]any{"file": map[string]any{"messages": []any{"Required."}}})
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, invalidInput(map[string]any{"file": map[string]any{"messages": []any{"Required."}}})
+		} else if err != nil {
+			return nil, &mockError{Status: 400, Code: "CB_VA01", Message: fmt.Sprintf("Failed to read the file: %s", err)}
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, &mockError{Status: 400, Code: "CB_VA01", Message: fmt.Sprintf("Failed to read the file: %s", err)}
+		}
+		m.serial++
+		key := fmt.Sprintf("mock-file-%d", m.serial)
+		m.files[key] = mockFile{
+			name:        part.FileName(),
+			contentType: cmp.Or(part.Header.Get("Content-Type"), "application/octet-stream"),
+			data:        data,
+		}
+		return map[string]any{"fileKey": key}, nil
+	}
+}
+
+func (m *mockKintone) downloadFile(w http.ResponseWriter, r *http.Request) error {
+	key := r.URL.Query().Get("fileKey")
+	file, ok := m.files[key]
+	if !ok {
+		return &mockError{Status: 404, Code: "GAIA_BL01", Message: fmt.Sprintf("The file (fileKey: %s) is not found.", key)}
+	}
+	w.Header().Set("Content-Type", file.contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.name}))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(file.data))
+	return nil
+}
+
+func (m *mockKintone) getComments(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	recordID := mockString(p["record"])
+	if _, err := app.findRecord(recordID); err != nil {
+		return nil, err
+	}
+	offset, err := mockInt(p, "offset", 0, 1<<31-1)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := mockInt(p, "limit", 10, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := slices.Clone(app.comments[recordID])
+	order := cmp.Or(mockString(p["order"]), "desc")
+	if order == "desc" {
+		slices.Reverse(comments)
+	}
+	page := comments[min(offset, len(comments)):min(offset+limit, len(comments))]
+	behind, ahead := offset > 0, offset+limit < len(comments)
+	if page == nil {
+		page = []map[string]any{}
+	}
+	if order == "desc" {
+		return map[string]any{"comments": page, "older": ahead, "newer": behind}, nil
+	}
+	return map[string]any{"comments": page, "older": behind, "newer": ahead}, nil
+}
+
+func (m *mockKintone) createComment(p map[string]any) (any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, err
+	}
+	recordID := mockString(p["record"])
+	if _, err := app.findRecord(recordID); err != nil {
+		return nil, err
+	}
+	comment, _ := p["comment"].(map[string]any)
+	text := mockString(comment["text"])
+	if text == "" {
+		return nil, invalidInput(map[string]any{"comment.text": map[string]any{"messages": []any{"Required."}}})
+	}
+
+	mentions := []any{}
+	list, _ := comment["mentions"].([]any)
+	for i, x := range list {
+		x, _ := x.(map[string]any)
+		typ := cmp.Or(mockString(x["type"]), "USER")
+		if _, ok := m.findEntity(mentionTypes[typ], mockString(x["code"])); !ok {
+			return nil, invalidInput(map[string]any{fmt.Sprintf("comment.mentions[%d].code", i): map[string]any{"messages": []any{fmt.Sprintf("%s is not found.", mockString(x["code"]))}}})
+		}
+		mentions = append(mentions, map[string]any{"code": mockString(x["code"]), "type": typ})
+	}
+
+	comments := app.comments[recordID]
+	id := "1"
+	if len(comments) > 0 {
+		last, _ := strconv.Atoi(mockString(comments[len(comments)-1]["id"]))
+		id = strconv.Itoa(last + 1)
+	}
+	me, _ := m.entity("users", m.user)
+	app.comments[recordID] = append(comments, map[string]any{
+		"id":        id,
+		"text":      text,
+		"createdAt": time.Now().UTC().Truncate(time.Second).Format(time.RFC3339),
+		"creator":   me,
+		"mentions":  mentions,
+	})
+	return map[string]any{"id": id}, nil
+}
+
+// processRecord returns the record of the app that uses the process management.
+func (m *mockKintone) processRecord(p map[string]any) (*mockApp, map[string]any, error) {
+	app, err := m.app(p, "app")
+	if err != nil {
+		return nil, nil, err
+	}
+	if enable, _ := app.process["enable"].(bool); !enable {
+		return nil, nil, &mockError{Status: 400, Code: "GAIA_ST01", Message: "The process management is not enabled in the app."}
+	}
+	record, err := app.findRecord(mockString(p["id"]))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkRevision(record, p["revision"]); err != nil {
+		return nil, nil, err
+	}
+	return app, record, nil
+}
+
+// setAssignees sets the assignees of the record by the user codes.
+func (m *mockKintone) setAssignees(app *mockApp, record map[string]any, codes []string) error {
+	assignees := []any{}
+	for _, code := range codes {
+		e, ok := m.entity("users", code)
+		if !ok {
+			return invalidInput(map[string]any{"assignees": map[string]any{"messages": []any{fmt.Sprintf("%s is not found.", code)}}})
+		}
+		assignees = append(assignees, e)
+	}
+	for code, f := range app.fields {
+		if f.Type == "STATUS_ASSIGNEE" {
+			record[code] = mockField(f.Type, assignees)
+		}
+	}
+	return nil
+}
+
+func (m *mockKintone) updateAssignees(p map[string]any) (any, error) {
+	app, record, err := m.processRecord(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.setAssignees(app, record, mockList(p, "assignees")); err != nil {
+		return nil, err
+	}
+	return map[string]any{"revision": m.touch(app, record, 1)}, nil
+}
+
+func (m *mockKintone) updateStatus(p map[string]any) (any, error) {
+	app, record, err := m.processRecord(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var statusCode, status string
+	for code, f := range app.fields {
+		if f.Type == "STATUS" {
+			statusCode, status = code, mockString(mockFieldValues(record, code)[0])
+		}
+	}
+	action := mockString(p["action"])
+	actions, _ := app.process["actions"].([]any)
+	for _, a := range actions {
+		a, _ := a.(map[string]any)
+		if mockString(a["name"]) != action || mockString(a["from"]) != status {
+			continue
+		}
+
+		var assignees []string
+		if assignee := mockString(p["assignee"]); assignee != "" {
+			assignees = []string{assignee}
+		}
+		if err := m.setAssignees(app, record, assignees); err != nil {
+			return nil, err
+		}
+		record[statusCode] = mockField("STATUS", mockString(a["to"]))
+		// kintone counts the action and the change of the status as two revisions.
+		return map[string]any{"revision": m.touch(app, record, 2)}, nil
+	}
+	return nil, &mockError{Status: 400, Code: "GAIA_ST02", Message: fmt.Sprintf("The action %s is not available for the status %s.", action, status)}
+}
+
+// directory returns the handler of the User API to list users, organizations, or groups.
+func (m *mockKintone) directory(kind string) func(p map[string]any) (any, error) {
+	return func(p map[string]any) (any, error) {
+		offset, err := mockInt(p, "offset", 0, 1<<31-1)
+		if err != nil {
+			return nil, err
+		}
+		size, err := mockInt(p, "size", 100, 100)
+		if err != nil {
+			return nil, err
+		}
+		codes := mockList(p, "codes")
+
+		entities := map[string][]map[string]any{
+			"users":         m.users,
+			"organizations": m.organizations,
+			"groups":        m.groups,
+		}[kind]
+		result := []any{}
+		for _, e := range entities {
+			if len(codes) > 0 && !slices.Contains(codes, mockString(e["code"])) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			if len(result) >= size {
+				break
+			}
+			result = append(result, e)
+		}
+		return map[string]any{kind: result}, nil
+	}
+}
+
+// mockDemoFixture is the demo data of the mock kintone that is used if KINTONE_MOCK_FIXTURE is not set.
+const mockDemoFixture = `{
+  "users": [
+    {"code": "alice", "name": "Alice Smith", "email": "alice@example.com", "timezone": "Asia/Tokyo"},
+    {"code": "bob", "name": "Bob Jones", "email": "bob@example.com", "timezone": "Asia/Tokyo"},
+    {"code": "carol", "name": "Carol White", "email": "carol@example.com", "timezone": "Asia/Tokyo"}
+  ],
+  "organizations": [
+    {"code": "sales", "name": "Sales"},
+    {"code": "support", "name": "Support"}
+  ],
+  "groups": [
+    {"code": "everyone", "name": "Everyone"}
+  ],
+  "apps": [
+    {
+      "appID": "1",
+      "name": "Customers",
+      "description": "The list of customers and their contact persons.",
+      "fields": {
+        "company": {"type": "SINGLE_LINE_TEXT", "label": "Company", "required": true, "unique": true},
+        "industry": {"type": "DROP_DOWN", "label": "Industry", "options": ["Manufacturing", "Retail", "IT", "Finance"]},
+        "employees": {"type": "NUMBER", "label": "Employees"},
+        "owner": {"type": "USER_SELECT", "label": "Account owner"},
+        "since": {"type": "DATE", "label": "Customer since"},
+        "note": {"type": "MULTI_LINE_TEXT", "label": "Note"}
+      },
+      "views": {
+        "All customers": {"type": "LIST", "name": "All customers", "id": "1", "index": "0", "fields": ["company", "industry", "owner"], "filterCond": "", "sort": "company asc"}
+      },
+      "records": [
+        {"company": "Acme Corporation", "industry": "Manufacturing", "employees": 1200, "owner": ["alice"], "since": "2019-04-01", "note": "The largest customer."},
+        {"company": "Globex", "industry": "IT", "employees": 350, "owner": ["bob"], "since": "2021-10-15"},
+        {"company": "Initech", "industry": "IT", "employees": 80, "owner": ["alice"], "since": "2023-01-20"},
+        {"company": "Umbrella Retail", "industry": "Retail", "employees": 5400, "owner": ["carol"], "since": "2020-07-07"},
+        {"company": "Stark Finance", "industry": "Finance", "employees": 230, "owner": ["bob"], "since": "2024-03-11", "note": "Interested in the premium plan."}
+      ]
+    },
+    {
+      "appID": "2",
+      "name": "Support tickets",
+      "description": "The inquiries from the customers.",
+      "fields": {
+        "title": {"type": "SINGLE_LINE_TEXT", "label": "Title", "required": true},
+        "customer": {"type": "SINGLE_LINE_TEXT", "label": "Customer"},
+        "priority": {"type": "RADIO_BUTTON", "label": "Priority", "options": ["High", "Medium", "Low"], "defaultValue": "Medium"},
+        "tags": {"type": "CHECK_BOX", "label": "Tags", "options": ["Bug", "Question", "Request"]},
+        "due": {"type": "DATE", "label": "Due date"},
+        "details": {"type": "MULTI_LINE_TEXT", "label": "Details"},
+        "attachments": {"type": "FILE", "label": "Attachments"},
+        "worklog": {
+          "type": "SUBTABLE",
+          "label": "Work log",
+          "fields": {
+            "date": {"type": "DATE", "label": "Date"},
+            "hours": {"type": "NUMBER", "label": "Hours"},
+            "memo": {"type": "SINGLE_LINE_TEXT", "label": "Memo"}
+          }
+        }
+      },
+      "processManagement": {
+        "enable": true,
+        "states": {
+          "Not started": {"name": "Not started", "index": "0"},
+          "In progress": {"name": "In progress", "index": "1"},
+          "Completed": {"name": "Completed", "index": "2"}
+        },
+        "actions": [
+          {"name": "Start", "from": "Not started", "to": "In progress", "filterCond": ""},
+          {"name": "Complete", "from": "In progress", "to": "Completed", "filterCond": ""},
+          {"name": "Reopen", "from": "Completed", "to": "In progress", "filterCond": ""}
+        ]
+      },
+      "records": [
+        {"title": "Cannot log in", "customer": "Acme Corporation", "priority": "High", "tags": ["Bug"], "due": "2025-06-10", "details": "The login page shows an error since this morning.", "Status": "Completed", "worklog": [{"date": "2025-06-09", "hours": 2, "memo": "Investigated"}, {"date": "2025-06-10", "hours": 1.5, "memo": "Fixed"}]},
+        {"title": "How to export records?", "customer": "Globex", "priority": "Low", "tags": ["Question"], "due": "2025-07-01", "Status": "Completed"},
+        {"title": "Add a CSV import", "customer": "Initech", "priority": "Medium", "tags": ["Request"], "due": "2025-09-30", "Status": "In progress", "Assignee": ["bob"], "worklog": [{"date": "2025-08-01", "hours": 4, "memo": "Designed"}]},
+        {"title": "Slow search", "customer": "Umbrella Retail", "priority": "High", "tags": ["Bug"], "due": "2025-08-20", "details": "Searching records takes more than 10 seconds.", "Status": "In progress", "Assignee": ["carol"]},
+        {"title": "Invoice address change", "customer": "Stark Finance", "priority": "Medium", "tags": ["Request", "Question"], "due": "2025-10-05"},
+        {"title": "Error on the mobile app", "customer": "Acme Corporation", "priority": "High", "tags": ["Bug"], "due": "2025-10-20"}
+      ]
+    }
+  ]
+}`
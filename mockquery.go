@@ -0,0 +1,423 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mockNumericTypes is the field types that the mock kintone compares as numbers.
+var mockNumericTypes = map[string]bool{
+	"__ID__":        true,
+	"__REVISION__":  true,
+	"RECORD_NUMBER": true,
+	"NUMBER":        true,
+	"CALC":          true,
+}
+
+// mockQuery is a query of records.json that is compiled for an app of the mock kintone.
+type mockQuery struct {
+	parsed parsedQuery
+	types  []string
+	limit  int
+	offset int
+}
+
+// compileQuery parses the query and checks the field codes against the app.
+// The default limit is used if the query has no "limit".
+func (m *mockKintone) compileQuery(app *mockApp, query string, defaultLimit int) (mockQuery, error) {
+	parsed, err := parseQuery(query)
+	if err != nil {
+		return mockQuery{}, &mockError{Status: 400, Code: "GAIA_IQ03", Message: fmt.Sprintf("Query syntax error: %s", err)}
+	}
+
+	q := mockQuery{parsed: parsed, limit: defaultLimit}
+	for _, c := range parsed.Conditions {
+		typ, ok := app.fieldType(c.Field)
+		if !ok {
+			return mockQuery{}, &mockError{Status: 400, Code: "GAIA_IQ11", Message: fmt.Sprintf("The specified field (code: %s) not found.", c.Field)}
+		}
+		if ops, ok := queryOperators[typ]; ok && !slices.Contains(ops, c.Operator) {
+			return mockQuery{}, &mockError{Status: 400, Code: "GAIA_IQ03", Message: fmt.Sprintf("The operator %s can't be used for the field %s (%s).", c.Operator, c.Field, typ)}
+		}
+		q.types = append(q.types, typ)
+	}
+	for _, s := range parsed.Sort {
+		typ, ok := app.fieldType(s.Field)
+		if !ok {
+			return mockQuery{}, &mockError{Status: 400, Code: "GAIA_IQ11", Message: fmt.Sprintf("The specified field (code: %s) not found.", s.Field)}
+		}
+		if unsortableFieldTypes[typ] {
+			return mockQuery{}, &mockError{Status: 400, Code: "GAIA_IQ03", Message: fmt.Sprintf("The field %s (%s) can't be used to sort records.", s.Field, typ)}
+		}
+	}
+	if len(q.parsed.Sort) == 0 {
+		q.parsed.Sort = []querySort{{Field: "$id", Order: "desc"}}
+	}
+
+	if parsed.Limit != "" {
+		if q.limit, err = strconv.Atoi(parsed.Limit); err != nil || q.limit < 0 || q.limit > 500 {
+			return mockQuery{}, &mockError{Status: 400, Code: "GAIA_QU01", Message: "The limit must be between 0 and 500."}
+		}
+	}
+	if parsed.Offset != "" {
+		if q.offset, err = strconv.Atoi(parsed.Offset); err != nil || q.offset < 0 || q.offset > 10000 {
+			return mockQuery{}, &mockError{Status: 400, Code: "GAIA_QU02", Message: "The offset must be between 0 and 10000."}
+		}
+	}
+	return q, nil
+}
+
+// filter returns the records that match the conditions in the order of the query, ignoring the limit and the offset.
+func (m *mockKintone) filter(q mockQuery, records []map[string]any) []map[string]any {
+	var matched []map[string]any
+	for _, r := range records {
+		if q.parsed.Logic == "" || m.evalLogic(q, r, strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(q.parsed.Logic))) {
+			matched = append(matched, r)
+		}
+	}
+
+	slices.SortStableFunc(matched, func(a, b map[string]any) int {
+		for _, s := range q.parsed.Sort {
+			c := compareMockValues(mockFieldType(a, s.Field), mockSortKey(a, s.Field), mockSortKey(b, s.Field))
+			if s.Order == "desc" {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return matched
+}
+
+// page applies the limit and the offset of the query.
+func (q mockQuery) page(records []map[string]any) []map[string]any {
+	if q.offset >= len(records) {
+		return nil
+	}
+	records = records[q.offset:]
+	return records[:min(q.limit, len(records))]
+}
+
+// evalLogic evaluates the tokens of parsedQuery.Logic, such as ["#1", "and", "(", "#2", "or", "#3", ")"].
+// "and" binds tighter than "or".
+func (m *mockKintone) evalLogic(q mockQuery, record map[string]any, tokens []string) bool {
+	pos := 0
+	var expr, term, factor func() bool
+	expr = func() bool {
+		result := term()
+		for pos < len(tokens) && tokens[pos] == "or" {
+			pos++
+			// Evaluate the right side anyway to consume the tokens.
+			right := term()
+			result = result || right
+		}
+		return result
+	}
+	term = func() bool {
+		result := factor()
+		for pos < len(tokens) && tokens[pos] == "and" {
+			pos++
+			right := factor()
+			result = result && right
+		}
+		return result
+	}
+	factor = func() bool {
+		if pos >= len(tokens) {
+			return false
+		}
+		t := tokens[pos]
+		pos++
+		if t == "(" {
+			result := expr()
+			pos++ // ")"
+			return result
+		}
+		n, _ := strconv.Atoi(strings.TrimPrefix(t, "#"))
+		return m.matchCondition(q.parsed.Conditions[n-1], q.types[n-1], record)
+	}
+	return expr()
+}
+
+// matchCondition reports whether the record matches the condition.
+// The condition for a field in tables matches if any row matches.
+func (m *mockKintone) matchCondition(c queryCondition, typ string, record map[string]any) bool {
+	values := mockFieldValues(record, c.Field)
+	if len(values) == 0 {
+		// A table without rows.
+		values = []any{mockEmptyValue(typ)}
+	}
+
+	negative := c.Operator == "!=" || c.Operator == "not in" || c.Operator == "not like" || c.Operator == "is not empty"
+	matched := slices.ContainsFunc(values, func(v any) bool { return m.matchValue(c, typ, v) })
+	return matched != negative
+}
+
+// matchValue reports whether the value matches the condition. The negative operators are evaluated as their positive ones.
+func (m *mockKintone) matchValue(c queryCondition, typ string, v any) bool {
+	switch c.Operator {
+	case "is empty", "is not empty":
+		return len(mockTexts(v)) == 0
+	case "in", "not in":
+		texts := mockTexts(v)
+		for _, raw := range c.Values {
+			want := m.queryValue(raw)
+			if want == "" && len(texts) == 0 {
+				return true
+			}
+			if mockNumericTypes[typ] {
+				if slices.ContainsFunc(texts, func(s string) bool { return compareMockValues(typ, s, want) == 0 }) {
+					return true
+				}
+			} else if slices.Contains(texts, want) {
+				return true
+			}
+		}
+		return false
+	case "like", "not like":
+		want := strings.ToLower(m.queryValue(c.Values[0]))
+		return strings.Contains(strings.ToLower(strings.Join(mockTexts(v), "\n")), want)
+	}
+
+	op := c.Operator
+	if op == "!=" {
+		op = "="
+	}
+	got := strings.Join(mockTexts(v), "")
+	switch typ {
+	case "DATE", "DATETIME", "CREATED_TIME", "UPDATED_TIME":
+		start, end, ok := m.timeRange(c.Values[0])
+		t, err := m.parseTime(got)
+		if !ok || err != nil {
+			return false
+		}
+		switch op {
+		case "=":
+			return !t.Before(start) && t.Before(end)
+		case ">":
+			return !t.Before(end)
+		case ">=":
+			return !t.Before(start)
+		case "<":
+			return t.Before(start)
+		case "<=":
+			return t.Before(end)
+		}
+		return false
+	}
+
+	want := m.queryValue(c.Values[0])
+	if got == "" && op != "=" {
+		return false
+	}
+	d := compareMockValues(typ, got, want)
+	switch op {
+	case "=":
+		return d == 0
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	}
+	return false
+}
+
+// queryValue resolves a value in a query into a string. LOGINUSER() and PRIMARY_ORGANIZATION() are resolved into codes, and the dates into "2006-01-02".
+func (m *mockKintone) queryValue(raw string) string {
+	if s, err := strconv.Unquote(raw); err == nil {
+		return s
+	}
+	name, _, ok := strings.Cut(raw, "(")
+	if !ok {
+		return raw
+	}
+	switch strings.ToUpper(name) {
+	case "LOGINUSER":
+		return m.user
+	case "PRIMARY_ORGANIZATION":
+		if len(m.organizations) > 0 {
+			return mockString(m.organizations[0]["code"])
+		}
+		return ""
+	}
+	if start, _, ok := m.timeRange(raw); ok {
+		return start.Format(time.DateOnly)
+	}
+	return raw
+}
+
+// timeRange resolves a value of date or time into the range that the value means, such as the whole day of "2025-01-31" or the month of THIS_MONTH().
+func (m *mockKintone) timeRange(raw string) (start, end time.Time, ok bool) {
+	now := time.Now().In(m.location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.location)
+
+	name, args, isFunc := strings.Cut(raw, "(")
+	if !isFunc {
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			s = raw
+		}
+		if t, err := time.ParseInLocation(time.DateOnly, s, m.location); err == nil {
+			return t, t.AddDate(0, 0, 1), true
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, t.Add(time.Second), true
+		}
+		return time.Time{}, time.Time{}, false
+	}
+
+	week := today.AddDate(0, 0, -int(today.Weekday()))
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, m.location)
+	year := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, m.location)
+	switch strings.ToUpper(name) {
+	case "NOW":
+		return now.Truncate(time.Minute), now.Truncate(time.Minute).Add(time.Minute), true
+	case "TODAY":
+		return today, today.AddDate(0, 0, 1), true
+	case "YESTERDAY":
+		return today.AddDate(0, 0, -1), today, true
+	case "TOMORROW":
+		return today.AddDate(0, 0, 1), today.AddDate(0, 0, 2), true
+	case "FROM_TODAY":
+		parts := strings.Split(strings.TrimSuffix(args, ")"), ",")
+		n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		unit := "DAYS"
+		if len(parts) > 1 {
+			unit = strings.ToUpper(strings.TrimSpace(parts[1]))
+		}
+		var day time.Time
+		switch unit {
+		case "DAYS":
+			day = today.AddDate(0, 0, n)
+		case "WEEKS":
+			day = today.AddDate(0, 0, 7*n)
+		case "MONTHS":
+			day = today.AddDate(0, n, 0)
+		case "YEARS":
+			day = today.AddDate(n, 0, 0)
+		default:
+			return time.Time{}, time.Time{}, false
+		}
+		return day, day.AddDate(0, 0, 1), true
+	case "THIS_WEEK":
+		return week, week.AddDate(0, 0, 7), true
+	case "LAST_WEEK":
+		return week.AddDate(0, 0, -7), week, true
+	case "NEXT_WEEK":
+		return week.AddDate(0, 0, 7), week.AddDate(0, 0, 14), true
+	case "THIS_MONTH":
+		return month, month.AddDate(0, 1, 0), true
+	case "LAST_MONTH":
+		return month.AddDate(0, -1, 0), month, true
+	case "NEXT_MONTH":
+		return month.AddDate(0, 1, 0), month.AddDate(0, 2, 0), true
+	case "THIS_YEAR":
+		return year, year.AddDate(1, 0, 0), true
+	case "LAST_YEAR":
+		return year.AddDate(-1, 0, 0), year, true
+	case "NEXT_YEAR":
+		return year.AddDate(1, 0, 0), year.AddDate(2, 0, 0), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// parseTime parses a value of a date or datetime field.
+func (m *mockKintone) parseTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, m.location); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// mockFieldType returns the type of the field in the record, or an empty string for the fields in tables.
+func mockFieldType(record map[string]any, code string) string {
+	field, _ := record[code].(map[string]any)
+	typ, _ := field["type"].(string)
+	return typ
+}
+
+// mockFieldValues returns the value of the field in the record. The field in tables has the values of all rows.
+func mockFieldValues(record map[string]any, code string) []any {
+	if field, ok := record[code].(map[string]any); ok {
+		return []any{field["value"]}
+	}
+	var values []any
+	for _, field := range record {
+		field, _ := field.(map[string]any)
+		if field["type"] != "SUBTABLE" {
+			continue
+		}
+		rows, _ := field["value"].([]any)
+		for _, row := range rows {
+			row, _ := row.(map[string]any)
+			cells, _ := row["value"].(map[string]any)
+			if cell, ok := cells[code].(map[string]any); ok {
+				values = append(values, cell["value"])
+			}
+		}
+	}
+	return values
+}
+
+// mockSortKey returns the value of the field to sort the records.
+func mockSortKey(record map[string]any, code string) string {
+	values := mockFieldValues(record, code)
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.Join(mockTexts(values[0]), ",")
+}
+
+// mockTexts returns the texts that a value has: the items of lists, the codes of users, and the names of files.
+func mockTexts(v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case map[string]any:
+		if code, ok := v["code"].(string); ok {
+			return []string{code}
+		}
+		if name, ok := v["name"].(string); ok {
+			return []string{name}
+		}
+		return nil
+	case []any:
+		var texts []string
+		for _, x := range v {
+			texts = append(texts, mockTexts(x)...)
+		}
+		return texts
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// compareMockValues compares the values of the field as numbers or strings.
+func compareMockValues(typ, a, b string) int {
+	if mockNumericTypes[typ] {
+		x, errX := strconv.ParseFloat(a, 64)
+		y, errY := strconv.ParseFloat(b, 64)
+		if errX == nil && errY == nil {
+			return cmp.Compare(x, y)
+		}
+	}
+	return strings.Compare(a, b)
+}
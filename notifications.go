@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type GeneralNotification struct {
+	Entity       aclEntity `json:"entity" required:"true"`
+	IncludeSubs  bool      `json:"includeSubs" description:"If true, the members of the child organizations are also notified. Only for ORGANIZATION."`
+	RecordAdded  bool      `json:"recordAdded" description:"Notify when a record is added."`
+	RecordEdited bool      `json:"recordEdited" description:"Notify when a record is edited."`
+	CommentAdded bool      `json:"commentAdded" description:"Notify when a comment is posted."`
+	FileImported bool      `json:"fileImported" description:"Notify when records are imported from a file."`
+}
+
+type PerRecordNotification struct {
+	FilterCond string                        `json:"filterCond" required:"true" description:"The query to select the records to notify about, such as 'priority in (\"High\")'."`
+	Title      string                        `json:"title" description:"The title of the notification."`
+	Targets    []PerRecordNotificationTarget `json:"targets" required:"true" description:"The entities to notify."`
+}
+
+type PerRecordNotificationTarget struct {
+	Entity      aclEntity `json:"entity" required:"true"`
+	IncludeSubs bool      `json:"includeSubs" description:"If true, the members of the child organizations are also notified. Only for ORGANIZATION."`
+}
+
+type UpdateGeneralNotificationsParams struct {
+	AppID             string                `json:"appID" required:"true" description:"The app ID to change the notifications."`
+	Notifications     []GeneralNotification `json:"notifications" required:"true" description:"All general notifications of the app. They replace the current notifications, so include the current ones to keep."`
+	NotifyToCommenter *bool                 `json:"notifyToCommenter" description:"If true, the users who commented on a record are notified of new comments on the record. Default is not to change the current setting."`
+	ConfirmationToken string                `json:"confirmationToken" description:"The confirmation token that is returned by the previous call of this tool. The first call returns the current notifications and the changes with a token, and nothing is changed."`
+}
+
+type UpdatePerRecordNotificationsParams struct {
+	AppID             string                  `json:"appID" required:"true" description:"The app ID to change the notifications."`
+	Notifications     []PerRecordNotification `json:"notifications" required:"true" description:"All per-record notifications of the app. They replace the current notifications, so include the current ones to keep."`
+	ConfirmationToken string                  `json:"confirmationToken" description:"The confirmation token that is returned by the previous call of this tool. The first call returns the current notifications and the changes with a token, and nothing is changed."`
+}
+
+func (h *KintoneHandlers) UpdateGeneralNotifications(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req UpdateGeneralNotificationsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	var options JsonMap
+	if req.NotifyToCommenter != nil {
+		options = JsonMap{"notifyToCommenter": *req.NotifyToCommenter}
+	}
+	return h.updatePreviewSettings(ctx, previewSettingsUpdate{
+		Tool:              "updateGeneralNotifications",
+		Path:              "/k/v1/preview/app/notifications/general.json",
+		AppID:             req.AppID,
+		What:              "notifications",
+		Key:               "notifications",
+		Items:             req.Notifications,
+		Options:           options,
+		ConfirmationToken: req.ConfirmationToken,
+	})
+}
+
+func (h *KintoneHandlers) UpdatePerRecordNotifications(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req UpdatePerRecordNotificationsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	return h.updatePreviewSettings(ctx, previewSettingsUpdate{
+		Tool:              "updatePerRecordNotifications",
+		Path:              "/k/v1/preview/app/notifications/perRecord.json",
+		AppID:             req.AppID,
+		What:              "notifications",
+		Key:               "notifications",
+		Items:             req.Notifications,
+		ConfirmationToken: req.ConfirmationToken,
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// defaultProfile is the name of the kintone environment that is configured by KINTONE_BASE_URL and the other environment variables.
+const defaultProfile = "default"
+
+// ProfileConfiguration is the settings to connect to another kintone environment, such as a sandbox or a subsidiary.
+type ProfileConfiguration struct {
+	BaseURL      string              `json:"baseURL"`
+	Username     string              `json:"username"`
+	Password     string              `json:"password"`
+	PasswordFile string              `json:"passwordFile"`
+	APITokens    []string            `json:"apiTokens"`
+	AppTokens    map[string][]string `json:"appTokens"`
+	BasicAuth    BasicAuthSettings   `json:"basicAuth"`
+}
+
+// Profile is a kintone environment that tools can select by the profile argument.
+type Profile struct {
+	URL       *url.URL
+	User      string
+	Auth      string
+	Token     string
+	AppTokens map[string][]string
+	BasicAuth string
+}
+
+// resolve validates the settings and converts them into Profile.
+// If customDomain is false, the base URL has to be a domain of kintone.
+func (c ProfileConfiguration) resolve(customDomain bool) (*Profile, error) {
+	if c.BaseURL == "" {
+		return nil, errors.New("baseURL must be provided")
+	}
+	u, err := parseBaseURL(c.BaseURL, customDomain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseURL: %w", err)
+	}
+
+	password := c.Password
+	if password == "" && c.PasswordFile != "" {
+		bs, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passwordFile: %w", err)
+		}
+		password = strings.TrimSpace(string(bs))
+	}
+
+	p := &Profile{
+		URL:       u,
+		Token:     strings.Join(c.APITokens, ","),
+		AppTokens: c.AppTokens,
+	}
+	if c.Username != "" && password != "" {
+		p.User = c.Username
+		p.Auth = basicAuth(c.Username, password)
+	}
+	if p.Auth == "" && p.Token == "" && len(p.AppTokens) == 0 {
+		return nil, errors.New("either username/password, apiTokens, or appTokens must be provided")
+	}
+	if c.BasicAuth.Username != "" && c.BasicAuth.Password != "" {
+		p.BasicAuth = basicAuth(c.BasicAuth.Username, c.BasicAuth.Password)
+	}
+
+	return p, nil
+}
+
+// loadProfiles resolves the profiles in the configuration file.
+func loadProfiles(config Configuration) (map[string]*Profile, []error) {
+	var errs []error
+	profiles := make(map[string]*Profile, len(config.Profiles))
+	for _, name := range slices.Sorted(maps.Keys(config.Profiles)) {
+		if name == "" || name == defaultProfile {
+			errs = append(errs, fmt.Errorf("- Invalid profile name in the configuration file: %q is reserved", name))
+			continue
+		}
+		p, err := config.Profiles[name].resolve(GetenvBool("KINTONE_CUSTOM_DOMAIN"))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to load profile %q in the configuration file: %s", name, err))
+			continue
+		}
+		profiles[name] = p
+	}
+	return profiles, errs
+}
+
+// profileNames returns the names of the available profiles, starting with the default profile.
+func (h *KintoneHandlers) profileNames() []string {
+	return append([]string{defaultProfile}, slices.Sorted(maps.Keys(h.policy().Profiles))...)
+}
+
+type profileKey struct{}
+
+// withProfile returns a context for requests that are sent to the profile.
+func withProfile(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, profileKey{}, name)
+}
+
+// profileFromContext returns the name of the profile that the request is sent to, or an empty string for the default profile.
+func profileFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(profileKey{}).(string)
+	return name
+}
+
+// selectProfile reads the profile argument of the tool call, and returns a context to send requests to the profile.
+func (h *KintoneHandlers) selectProfile(ctx context.Context, arguments json.RawMessage) (context.Context, error) {
+	var args struct {
+		Profile string `json:"profile"`
+	}
+	json.Unmarshal(arguments, &args)
+
+	if args.Profile == "" || args.Profile == defaultProfile {
+		return ctx, nil
+	}
+	if _, ok := h.policy().Profiles[args.Profile]; !ok {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Unknown profile: %s. Available profiles are: %s", args.Profile, strings.Join(h.profileNames(), ", ")),
+		}
+	}
+	return withProfile(ctx, args.Profile), nil
+}
+
+// profileAuth returns the credentials of the profile that the context selects.
+// The second return value is false if the context uses the default profile.
+func (h *KintoneHandlers) profileAuth(ctx context.Context) (kintoneAuth, bool, error) {
+	name := profileFromContext(ctx)
+	if name == "" {
+		return kintoneAuth{}, false, nil
+	}
+
+	p, ok := h.policy().Profiles[name]
+	if !ok {
+		// The profile has been removed by reloading the settings.
+		return kintoneAuth{}, true, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Unknown profile: %s", name),
+		}
+	}
+	return kintoneAuth{
+		URL:       p.URL,
+		User:      p.User,
+		Auth:      p.Auth,
+		Token:     joinAPITokens(p.Token, p.AppTokens, appIDFromContext(ctx)),
+		BasicAuth: p.BasicAuth,
+	}, true, nil
+}
+
+// withProfileProperty returns a copy of the input schema of a tool, with the profile argument.
+func withProfileProperty(schema JsonMap, names []string) JsonMap {
+	schema = maps.Clone(schema)
+	props, _ := schema["properties"].(JsonMap)
+	props = maps.Clone(props)
+	if props == nil {
+		props = make(JsonMap)
+	}
+	props["profile"] = JsonMap{
+		"description": "The name of the kintone environment to use. Default is \"default\". Use 'listProfiles' tool to see the available environments.",
+		"type":        "string",
+		"enum":        names,
+	}
+	schema["properties"] = props
+	return schema
+}
+
+func (h *KintoneHandlers) ListProfiles(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	type profileInfo struct {
+		Name    string `json:"name"`
+		BaseURL string `json:"baseURL,omitempty"`
+		Default bool   `json:"default,omitempty"`
+	}
+
+	profiles := []profileInfo{{Name: defaultProfile, Default: true}}
+	if h.URL != nil {
+		profiles[0].BaseURL = h.URL.String()
+	}
+	p := h.policy()
+	for _, name := range slices.Sorted(maps.Keys(p.Profiles)) {
+		profiles = append(profiles, profileInfo{Name: name, BaseURL: p.Profiles[name].URL.String()})
+	}
+
+	return JSONContent(JsonMap{"profiles": profiles})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// progressInterval is the minimum interval between progress notifications, to avoid flooding the client.
+const progressInterval = 200 * time.Millisecond
+
+type progressKey struct{}
+
+type progressReporter struct {
+	session *Session
+	token   any
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// withProgressToken returns a context that reports progress of the request with the token.
+func withProgressToken(ctx context.Context, token any) context.Context {
+	s := SessionFromContext(ctx)
+	if s == nil || token == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &progressReporter{session: s, token: token})
+}
+
+// ReportProgress sends a progress notification if the client requested it.
+// total can be 0 if the total amount is unknown.
+func ReportProgress(ctx context.Context, progress, total float64, message string) {
+	p, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	if (total == 0 || progress < total) && now.Sub(p.last) < progressInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.last = now
+	p.mu.Unlock()
+
+	params := JsonMap{
+		"progressToken": p.token,
+		"progress":      progress,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	if message != "" && ProtocolVersionFromContext(ctx) >= "2025-03-26" {
+		params["message"] = message
+	}
+	p.session.Notify(ctx, "notifications/progress", params)
+}
+
+// progressReader is an io.Reader that reports the number of bytes read as progress.
+type progressReader struct {
+	ctx     context.Context
+	r       io.Reader
+	read    int64
+	total   int64
+	message string
+}
+
+func newProgressReader(ctx context.Context, r io.Reader, total int64, message string) io.Reader {
+	if _, ok := ctx.Value(progressKey{}).(*progressReporter); !ok {
+		return r
+	}
+	if total < 0 {
+		total = 0
+	}
+	return &progressReader{ctx: ctx, r: r, total: total, message: message}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if n > 0 {
+		ReportProgress(r.ctx, float64(r.read), float64(r.total), r.message)
+	}
+	return n, err
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRateLimit is the number of requests per second to each kintone domain.
+	defaultRateLimit = 10
+
+	// defaultMaxConcurrentRequests is the number of requests in progress to each kintone domain.
+	// kintone rejects more than 100 concurrent requests per domain, which are shared with the other users and integrations.
+	defaultMaxConcurrentRequests = 10
+)
+
+// rateLimiter limits requests to each kintone domain by a token bucket and the number of concurrent requests.
+// The zero value doesn't limit anything.
+type rateLimiter struct {
+	// Rate is the number of requests per second. The bucket can hold the same number of tokens for bursts. 0 means no limit.
+	Rate float64
+
+	// MaxConcurrent is the number of requests in progress. 0 means no limit.
+	MaxConcurrent int
+
+	mu      sync.Mutex
+	domains map[string]*domainLimiter
+}
+
+type domainLimiter struct {
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	slots       chan struct{}
+}
+
+// burst is the capacity of the token bucket.
+func (l *rateLimiter) burst() float64 {
+	return max(l.Rate, 1)
+}
+
+func (l *rateLimiter) domain(host string) *domainLimiter {
+	if l.domains == nil {
+		l.domains = make(map[string]*domainLimiter)
+	}
+	d, ok := l.domains[host]
+	if !ok {
+		d = &domainLimiter{tokens: l.burst(), last: time.Now()}
+		if l.MaxConcurrent > 0 {
+			d.slots = make(chan struct{}, l.MaxConcurrent)
+		}
+		l.domains[host] = d
+	}
+	return d
+}
+
+// reserve takes a token for the domain, and returns how long the caller has to wait before sending the request.
+func (l *rateLimiter) reserve(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := l.domain(host)
+	now := time.Now()
+
+	var wait time.Duration
+	if now.Before(d.pausedUntil) {
+		wait = d.pausedUntil.Sub(now)
+	}
+	if l.Rate > 0 {
+		d.tokens = min(l.burst(), d.tokens+now.Sub(d.last).Seconds()*l.Rate)
+		d.last = now
+		d.tokens--
+		if d.tokens < 0 {
+			wait = max(wait, time.Duration(-d.tokens/l.Rate*float64(time.Second)))
+		}
+	}
+	return wait
+}
+
+// Acquire waits until a request can be sent to the domain.
+// The returned function has to be called when the response is closed, to let the next request in.
+func (l *rateLimiter) Acquire(ctx context.Context, host string) (func(), error) {
+	if wait := l.reserve(host); wait > 0 {
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	l.mu.Lock()
+	slots := l.domain(host).slots
+	l.mu.Unlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case slots <- struct{}{}:
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-slots }) }, nil
+}
+
+// Pause holds all requests to the domain, such as when kintone responded 429 with Retry-After.
+func (l *rateLimiter) Pause(host string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dl := l.domain(host)
+	if until := time.Now().Add(d); until.After(dl.pausedUntil) {
+		dl.pausedUntil = until
+	}
+}
+
+// parseRateLimit reads KINTONE_RATE_LIMIT and KINTONE_MAX_CONCURRENT_REQUESTS.
+func parseRateLimit(l *rateLimiter) []error {
+	var errs []error
+
+	l.Rate = defaultRateLimit
+	if s := Getenv("KINTONE_RATE_LIMIT", ""); s != "" {
+		if f, err := strconv.ParseFloat(s, 64); err != nil || f < 0 {
+			errs = append(errs, fmt.Errorf("- KINTONE_RATE_LIMIT must be a non-negative number: %s", s))
+		} else {
+			l.Rate = f
+		}
+	}
+
+	l.MaxConcurrent = defaultMaxConcurrentRequests
+	if s := Getenv("KINTONE_MAX_CONCURRENT_REQUESTS", ""); s != "" {
+		if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("- KINTONE_MAX_CONCURRENT_REQUESTS must be a non-negative integer: %s", s))
+		} else {
+			l.MaxConcurrent = n
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+)
+
+// maxEvaluateRecords is the maximum number of records that records/acl/evaluate.json accepts at once.
+const maxEvaluateRecords = 100
+
+// recordRights is the permissions of a record in the response of records/acl/evaluate.json.
+// Only the view permission is read, because it is only used to post comments, which needs the view permission of the records.
+type recordRights struct {
+	Viewable bool `json:"viewable"`
+}
+
+// evaluateRecordRights asks kintone the permissions of the credentials for the records, including the record permissions of the app.
+// It is used by createRecordComments to skip the records that can't be commented on, instead of failing in the middle of the operation.
+func (h *KintoneHandlers) evaluateRecordRights(ctx context.Context, appID string, ids []string) (map[string]recordRights, error) {
+	rights := make(map[string]recordRights, len(ids))
+	for start := 0; start < len(ids); start += maxEvaluateRecords {
+		chunk := ids[start:min(start+maxEvaluateRecords, len(ids))]
+
+		var res struct {
+			Rights []struct {
+				ID     string       `json:"id"`
+				Record recordRights `json:"record"`
+			} `json:"rights"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/records/acl/evaluate.json", nil, JsonMap{"app": appID, "ids": chunk}, &res); err != nil {
+			return nil, err
+		}
+		for _, r := range res.Rights {
+			rights[r.ID] = r.Record
+		}
+	}
+	return rights, nil
+}
+
+// partitionViewable splits the record IDs into the ones that the credentials can view and the reasons of the others.
+func (h *KintoneHandlers) partitionViewable(ctx context.Context, appID string, ids []string) (allowed map[string]bool, skipped []JsonMap, err error) {
+	rights, err := h.evaluateRecordRights(ctx, appID, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allowed = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		r, ok := rights[id]
+		switch {
+		case !ok:
+			skipped = append(skipped, JsonMap{"recordID": id, "reason": "The permissions of the record could not be evaluated."})
+		case !r.Viewable:
+			skipped = append(skipped, JsonMap{"recordID": id, "reason": "The credentials don't have the permission to view the record in kintone."})
+		default:
+			allowed[id] = true
+		}
+	}
+	return allowed, skipped, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// The values of the format argument of the record tools.
+const (
+	// recordFormatKintone is the format of kintone REST API, such as {"field": {"type": "SINGLE_LINE_TEXT", "value": "text"}}.
+	recordFormatKintone = "kintone"
+
+	// recordFormatSimple is the format without the envelope of fields, such as {"field": "text"}.
+	recordFormatSimple = "simple"
+)
+
+// rowIDKey is the key of the row ID in the rows of tables in the simple format.
+const rowIDKey = "$id"
+
+// checkRecordFormat validates the format argument.
+func checkRecordFormat(format string) error {
+	if format != "" && format != recordFormatKintone && format != recordFormatSimple {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Format must be %q or %q", recordFormatKintone, recordFormatSimple),
+		}
+	}
+	return nil
+}
+
+// simplifyRecord converts a record in the format of kintone into the simple format.
+// Each field becomes its value, and each row of tables becomes an object of the cells with the row ID as "$id".
+func simplifyRecord(record map[string]any) map[string]any {
+	simple := make(map[string]any, len(record))
+	for code, v := range record {
+		field, ok := v.(map[string]any)
+		if !ok {
+			simple[code] = v
+			continue
+		}
+
+		if field["type"] != "SUBTABLE" {
+			simple[code] = field["value"]
+			continue
+		}
+
+		rows, _ := field["value"].([]any)
+		simpleRows := make([]any, 0, len(rows))
+		for _, row := range rows {
+			row, _ := row.(map[string]any)
+			cells, _ := row["value"].(map[string]any)
+			simpleRow := simplifyRecord(cells)
+			if id, ok := row["id"]; ok {
+				simpleRow[rowIDKey] = id
+			}
+			simpleRows = append(simpleRows, simpleRow)
+		}
+		simple[code] = simpleRows
+	}
+	return simple
+}
+
+// expandRecord converts a record in the simple format into the format of kintone, using the field definitions of the app.
+func (h *KintoneHandlers) expandRecord(ctx context.Context, appID string, record map[string]any) (RecordParam, error) {
+	fields, err := h.formFields(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := make(RecordParam, len(record))
+	for _, code := range slices.Sorted(maps.Keys(record)) {
+		f, ok := fields[code]
+		if !ok {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Unknown field code: %s. Please check the field codes by using 'readAppInfo' tool.", code),
+			}
+		}
+		expanded[code] = JsonMap{"value": f.recordValue(record[code])}
+	}
+
+	// Convert into the plain maps and slices as the other records, so that checkRecord and coerceRecord can inspect it.
+	bs, err := json.Marshal(expanded)
+	if err != nil {
+		return nil, err
+	}
+	var result RecordParam
+	if err := json.Unmarshal(bs, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// parseMaxAffectedRecords reads KINTONE_MAX_AFFECTED_RECORDS. 0 means no limit.
+func parseMaxAffectedRecords() (int, error) {
+	s := Getenv("KINTONE_MAX_AFFECTED_RECORDS", "")
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("- KINTONE_MAX_AFFECTED_RECORDS must be a non-negative integer: %s", s)
+	}
+	return n, nil
+}
+
+// maxAffectedRecords returns the maximum number of records that a write tool can change at once in the app, or 0 if unlimited.
+// The setting of the app in the configuration file has a higher priority than KINTONE_MAX_AFFECTED_RECORDS.
+func (h *KintoneHandlers) maxAffectedRecords(appID string) int {
+	p := h.policy()
+	if app, ok := p.Config.appConfiguration(appID); ok && app.MaxAffectedRecords > 0 {
+		return app.MaxAffectedRecords
+	}
+	return p.MaxAffectedRecords
+}
+
+// checkAffectedRecords fails if the tool is going to change more records than the limit, unless the AI overrides it explicitly.
+// It has to be called before changing any records, so that a too broad query doesn't change the records partially.
+func (h *KintoneHandlers) checkAffectedRecords(ctx context.Context, tool, appID string, n int, override bool) error {
+	limit := h.maxAffectedRecords(appID)
+	if limit == 0 || n <= limit {
+		return nil
+	}
+	if override {
+		serverLog.InfoContext(ctx, "The limit of affected records is overridden", "tool", tool, "app", appID, "records", n, "limit", limit)
+		return nil
+	}
+	return jsonrpc2.Error{
+		Code:    jsonrpc2.InvalidParamsCode,
+		Message: fmt.Sprintf("Tool %s is going to change %d records in app ID %s, but the server allows up to %d records at once. No records are changed. Please narrow down the records, or call %s again with 'overrideRecordLimit' set to true only if the user really intends to change all of them.", tool, n, appID, limit, tool),
+	}
+}
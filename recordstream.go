@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// cursorSize is the number of records that the cursor API returns at once, which is the maximum of kintone.
+const cursorSize = 500
+
+// decodeRecords reads a response of kintone that contains "records", and calls fn for each record without keeping all records in memory.
+// The other properties of the response, such as totalCount, are returned.
+func decodeRecords(r io.Reader, fn func(record map[string]any) error) (JsonMap, error) {
+	parseError := func(err error) error {
+		return jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to parse kintone server's response: %v", err),
+		}
+	}
+
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return nil, parseError(err)
+	} else if tok != json.Delim('{') {
+		return nil, parseError(fmt.Errorf("unexpected token: %v", tok))
+	}
+
+	rest := make(JsonMap)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, parseError(err)
+		}
+		key, _ := tok.(string)
+
+		if key != "records" {
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return nil, parseError(err)
+			}
+			rest[key] = v
+			continue
+		}
+
+		if tok, err := dec.Token(); err != nil {
+			return nil, parseError(err)
+		} else if tok != json.Delim('[') {
+			return nil, parseError(fmt.Errorf("unexpected token in records: %v", tok))
+		}
+		for dec.More() {
+			var record map[string]any
+			if err := dec.Decode(&record); err != nil {
+				return nil, parseError(err)
+			}
+			if err := fn(record); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, parseError(err)
+		}
+	}
+
+	return rest, nil
+}
+
+// fetchRecords sends a request that returns records such as /k/v1/records.json, and calls fn for each record while reading the response.
+// The other properties of the response are returned.
+func (h *KintoneHandlers) fetchRecords(ctx context.Context, method, path string, query Query, body any, fn func(record map[string]any) error) (JsonMap, error) {
+	reqBody, err := jsonBody(body)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withAppID(ctx, requestAppID(path, query, body))
+
+	res, err := h.SendHTTP(ctx, method, path, query, reqBody, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return decodeRecords(res.Body, fn)
+}
+
+// openCursors is the cursors of kintone that are not read to the end.
+// kintone limits the number of cursors, so the cursors that are left by the shutdown have to be deleted.
+type openCursors struct {
+	mu sync.Mutex
+
+	// m is the context to delete the cursor for each ID, which has the profile and the credentials to use.
+	m map[string]context.Context
+}
+
+func (c *openCursors) add(ctx context.Context, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = make(map[string]context.Context)
+	}
+	c.m[id] = ctx
+}
+
+// take removes the cursor, and reports whether the caller should delete it.
+func (c *openCursors) take(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.m[id]
+	delete(c.m, id)
+	return ok
+}
+
+// deleteCursor deletes the cursor in kintone.
+func (h *KintoneHandlers) deleteCursor(ctx context.Context, id string) error {
+	return h.FetchHTTPWithJSON(ctx, "DELETE", "/k/v1/records/cursor.json", nil, JsonMap{"id": id}, nil)
+}
+
+// closeCursors deletes all cursors that are in use, such as at shutdown.
+func (h *KintoneHandlers) closeCursors(ctx context.Context) {
+	h.cursors.mu.Lock()
+	cursors := h.cursors.m
+	h.cursors.m = nil
+	h.cursors.mu.Unlock()
+
+	for id, cursorCtx := range cursors {
+		// The cursor is deleted with the credentials of the request that created it, but within the time limit of ctx.
+		cursorCtx, cancel := context.WithCancel(cursorCtx)
+		stop := context.AfterFunc(ctx, cancel)
+		if err := h.deleteCursor(cursorCtx, id); err != nil {
+			serverLog.Warn("Failed to delete a cursor", "cursor", id, "error", err)
+		} else {
+			serverLog.Info("Deleted a cursor that was in use", "cursor", id)
+		}
+		stop()
+		cancel()
+	}
+}
+
+// eachRecordByCursor reads all records that match the query by the cursor API, and calls fn for each record.
+// The records are read in chunks and not kept in memory, so that any number of records can be processed in constant memory.
+// The fields that are not allowed are removed from the records, the masking rules are applied, and the date and time values are converted into the timezone.
+func (h *KintoneHandlers) eachRecordByCursor(ctx context.Context, appID, query string, fields []string, fn func(record map[string]any) error) error {
+	acl, err := h.fieldACL(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	body := JsonMap{
+		"app":   appID,
+		"query": query,
+		"size":  cursorSize,
+	}
+	if len(fields) > 0 {
+		body["fields"] = fields
+	}
+
+	var cursor struct {
+		ID string `json:"id"`
+	}
+	if err := h.FetchHTTPWithJSON(ctx, "POST", "/k/v1/records/cursor.json", nil, body, &cursor); err != nil {
+		return err
+	}
+	ctx = withAppID(ctx, appID)
+
+	// kintone deletes the cursor after the last chunk is read. Otherwise, it has to be deleted to free the limit of cursors.
+	// The cursor is also tracked until then, so that it is deleted at shutdown even if this request doesn't complete.
+	h.cursors.add(context.WithoutCancel(ctx), cursor.ID)
+	finished := false
+	defer func() {
+		if h.cursors.take(cursor.ID) && !finished {
+			h.deleteCursor(context.WithoutCancel(ctx), cursor.ID)
+		}
+	}()
+
+	filter := h.fieldFilter(appID)
+	config := h.policy().Config
+	loc := h.timezone(ctx)
+	counts := make(maskCounts)
+	defer h.masking.record(ctx, fmt.Sprintf("records of app %s", appID), counts)
+
+	for !finished {
+		rest, err := h.fetchRecords(ctx, "GET", "/k/v1/records/cursor.json", Query{"id": cursor.ID}, nil, func(record map[string]any) error {
+			acl.filterRecord(record)
+			filter.filterRecord(record)
+			config.maskRecord(record, counts)
+			localizeRecordTimes(record, loc)
+			return fn(record)
+		})
+		if err != nil {
+			return err
+		}
+		next, _ := rest["next"].(bool)
+		finished = !next
+	}
+
+	return nil
+}
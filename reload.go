@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// configWatchInterval is how often KINTONE_CONFIG_FILE is checked for changes.
+const configWatchInterval = 5 * time.Second
+
+// Policy is the settings that can be reloaded without restarting the server.
+type Policy struct {
+	Token     string
+	AppTokens map[string][]string
+	Allow     *AppPatterns
+	Deny      *AppPatterns
+
+	// ReadOnly disables all tools that modify kintone data.
+	ReadOnly bool
+
+	// DisabledTools is the list of tools that can't be used.
+	DisabledTools []string
+
+	// RequestEndpoints is the endpoints that kintoneRequest tool can call. The tool is disabled if empty.
+	RequestEndpoints []requestEndpoint
+
+	// ConfirmDeletes requires a confirmation token to delete records, which is returned with a preview of the records.
+	ConfirmDeletes bool
+
+	// MaxAffectedRecords is the maximum number of records that a write tool can change at once. 0 means no limit.
+	MaxAffectedRecords int
+
+	// Config is the configuration loaded from KINTONE_CONFIG_FILE.
+	Config Configuration
+
+	// Profiles is the other kintone environments in the configuration file.
+	Profiles map[string]*Profile
+}
+
+// loadPolicy reads the reloadable settings from the environment variables.
+// The errors are formatted as lines of the error message of NewKintoneHandlersFromEnv.
+func loadPolicy(config Configuration) (*Policy, []error) {
+	p := &Policy{Config: config}
+	var errs []error
+
+	tokens, err := GetenvSecret("KINTONE_API_TOKEN")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("- %s", err))
+	}
+	if GetenvBool("KINTONE_KEYRING") && tokens == "" {
+		if tokens, err = lookupKeyring(Getenv("KINTONE_KEYRING_SERVICE", defaultKeyringService), "api-token"); err != nil {
+			errs = append(errs, fmt.Errorf("- %s", err))
+		}
+	}
+	p.Token = tokens
+	p.AppTokens = loadAppTokens()
+
+	profiles, profileErrs := loadProfiles(config)
+	errs = append(errs, profileErrs...)
+	p.Profiles = profiles
+
+	for _, key := range []string{"KINTONE_ALLOW_APPS", "KINTONE_DENY_APPS"} {
+		patterns, err := ParseAppPatterns(GetenvList(key))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to parse %s: %s", key, err))
+			continue
+		}
+		if key == "KINTONE_ALLOW_APPS" {
+			p.Allow = patterns
+		} else {
+			p.Deny = patterns
+		}
+	}
+
+	p.ReadOnly = GetenvBool("KINTONE_READ_ONLY")
+	p.ConfirmDeletes = GetenvBool("KINTONE_CONFIRM_DELETE")
+	if p.MaxAffectedRecords, err = parseMaxAffectedRecords(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if p.RequestEndpoints, err = parseRequestEndpoints(GetenvList("KINTONE_REQUEST_ENDPOINTS")); err != nil {
+		errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_REQUEST_ENDPOINTS: %s", err))
+	}
+
+	p.DisabledTools = GetenvList("KINTONE_DISABLED_TOOLS")
+	for _, name := range p.DisabledTools {
+		isAppTool := slices.ContainsFunc(config.appTools(), func(t appTool) bool { return t.Name == name })
+		_, isCommandTool := config.findCommandTool(name)
+		if !isAppTool && !isCommandTool && !slices.ContainsFunc(toolsList.Tools, func(t ToolInfo) bool { return t.Name == name }) {
+			errs = append(errs, fmt.Errorf("- Unknown tool name in KINTONE_DISABLED_TOOLS: %s", name))
+		}
+	}
+
+	return p, errs
+}
+
+// policy returns the current settings.
+func (h *KintoneHandlers) policy() *Policy {
+	return h.current.Load()
+}
+
+// Reload reads KINTONE_CONFIG_FILE and the environment variables again, and applies the new settings.
+// If the new settings are invalid, the current settings are kept.
+// The clients are notified if the available tools are changed.
+func (h *KintoneHandlers) Reload(ctx context.Context) error {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	old := h.policy()
+
+	config := old.Config
+	if h.configPath != "" {
+		var err error
+		if config, err = LoadConfiguration(h.configPath); err != nil {
+			return fmt.Errorf("failed to load KINTONE_CONFIG_FILE: %w", err)
+		}
+	}
+
+	config.Apply()
+	p, errs := loadPolicy(config)
+	if len(errs) > 0 {
+		old.Config.Apply()
+		return errors.Join(append([]error{errors.New("invalid settings:")}, errs...)...)
+	}
+
+	// Resolve the app names before applying, otherwise KINTONE_DENY_APPS rejects all apps until the names are fetched.
+	if p.Allow.HasNamePatterns() || p.Deny.HasNamePatterns() {
+		if err := h.refreshAppNames(ctx, p); err != nil {
+			serverLog.Warn("Failed to fetch app names for KINTONE_ALLOW_APPS or KINTONE_DENY_APPS", "error", err)
+		}
+	}
+
+	before, _ := h.ToolsList(ctx, nil)
+	h.current.Store(p)
+	after, _ := h.ToolsList(ctx, nil)
+
+	if !reflect.DeepEqual(before, after) {
+		BroadcastNotification("notifications/tools/list_changed", JsonMap{})
+	}
+
+	return nil
+}
+
+// WatchConfig reloads the settings when KINTONE_CONFIG_FILE is modified, until ctx is canceled.
+// It does nothing if KINTONE_CONFIG_FILE is not set.
+func (h *KintoneHandlers) WatchConfig(ctx context.Context) {
+	if h.configPath == "" {
+		return
+	}
+
+	var modTime time.Time
+	if info, err := os.Stat(h.configPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	go func() {
+		ticker := time.NewTicker(configWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(h.configPath)
+				if err != nil || info.ModTime().Equal(modTime) {
+					continue
+				}
+				modTime = info.ModTime()
+				h.reloadAndReport(ctx, "KINTONE_CONFIG_FILE is modified")
+			}
+		}
+	}()
+}
+
+// ReloadOnSignal reloads the settings when the process receives SIGHUP, until ctx is canceled.
+func (h *KintoneHandlers) ReloadOnSignal(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				h.reloadAndReport(ctx, "SIGHUP is received")
+			}
+		}
+	}()
+}
+
+// reloadAndReport reloads the settings and writes the result to the server log.
+func (h *KintoneHandlers) reloadAndReport(ctx context.Context, reason string) {
+	if err := h.Reload(ctx); err != nil {
+		serverLog.Error("Failed to reload settings, keeping the current settings", "reason", reason, "error", err)
+	} else {
+		serverLog.Info("Settings reloaded", "reason", reason)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// requestIDHeader is the header to send the request ID to kintone.
+// kintone ignores it, but it appears in the logs of proxies between the server and kintone.
+const requestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// newRequestID generates a unique ID for a tool call.
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// withRequestID returns a context that has the request ID of the tool call.
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFromContext returns the request ID of the current tool call, or an empty string.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withErrorIDs adds the request ID and the trace ID to the data of the JSON-RPC error, so that the client can report them to find the logs and the trace of the error.
+func withErrorIDs(ctx context.Context, err error) error {
+	var e jsonrpc2.Error
+	if err == nil || !errors.As(err, &e) || e.Data != nil {
+		return err
+	}
+
+	data := JsonMap{}
+	if id := requestIDFromContext(ctx); id != "" {
+		data["requestID"] = id
+	}
+	if id := traceID(ctx); id != "" {
+		data["traceID"] = id
+	}
+	if len(data) > 0 {
+		e.Data = data
+	}
+	return e
+}
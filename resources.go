@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// ResourceNotFoundCode is the error code for unknown resources, defined in the MCP specification.
+const ResourceNotFoundCode jsonrpc2.ErrorCode = -32002
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourcesListRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+type ResourcesListResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceTemplatesListResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+type ResourcesReadRequest struct {
+	URI string `json:"uri"`
+}
+
+type ResourcesSubscribeRequest struct {
+	URI string `json:"uri"`
+}
+
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+type ResourcesReadResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+var (
+	appSchemaURIPattern = regexp.MustCompile(`^kintone://app/([0-9]+)/schema$`)
+	recordURIPattern    = regexp.MustCompile(`^kintone://app/([0-9]+)/record/([0-9]+)$`)
+	fieldURIPattern     = regexp.MustCompile(`^kintone://app/([0-9]+)/field/(.+)$`)
+)
+
+var resourceTemplates = []ResourceTemplate{
+	{
+		URITemplate: "kintone://app/{appID}/schema",
+		Name:        "App schema",
+		Description: "The description and the field definitions of the kintone app.",
+		MimeType:    "application/json",
+	},
+	{
+		URITemplate: "kintone://app/{appID}/field/{fieldCode}",
+		Name:        "Field definition",
+		Description: "The definition of a field in the kintone app, such as the type, the label, and the options.",
+		MimeType:    "application/json",
+	},
+	{
+		URITemplate: "kintone://app/{appID}/record/{recordID}",
+		Name:        "Record",
+		Description: "A record in the kintone app.",
+		MimeType:    "application/json",
+	},
+}
+
+func appSchemaURI(appID string) string {
+	return fmt.Sprintf("kintone://app/%s/schema", appID)
+}
+
+func recordURI(appID, recordID string) string {
+	return fmt.Sprintf("kintone://app/%s/record/%s", appID, recordID)
+}
+
+func (h *KintoneHandlers) ResourcesList(ctx context.Context, params ResourcesListRequest) (ResourcesListResult, error) {
+	offset := 0
+	if params.Cursor != "" {
+		n, err := strconv.Atoi(params.Cursor)
+		if err != nil || n < 0 {
+			return ResourcesListResult{}, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Invalid cursor: %s", params.Cursor),
+			}
+		}
+		offset = n
+	}
+
+	const limit = 100
+
+	var httpRes struct {
+		Apps []KintoneAppDetail `json:"apps"`
+	}
+	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apps.json", nil, JsonMap{"offset": offset, "limit": limit}, &httpRes); err != nil {
+		return ResourcesListResult{}, err
+	}
+
+	result := ResourcesListResult{
+		Resources: make([]Resource, 0, len(httpRes.Apps)),
+	}
+	for _, app := range httpRes.Apps {
+		if err := h.checkPermissions(app.AppID); err != nil {
+			continue
+		}
+		result.Resources = append(result.Resources, Resource{
+			URI:         appSchemaURI(app.AppID),
+			Name:        fmt.Sprintf("%s schema", app.Name),
+			Description: fmt.Sprintf("The description and the field definitions of kintone app %q (ID: %s).", app.Name, app.AppID),
+			MimeType:    "application/json",
+		})
+	}
+	if len(httpRes.Apps) == limit {
+		result.NextCursor = strconv.Itoa(offset + limit)
+	}
+
+	return result, nil
+}
+
+func (h *KintoneHandlers) ResourceTemplatesList(ctx context.Context, params any) (ResourceTemplatesListResult, error) {
+	return ResourceTemplatesListResult{
+		ResourceTemplates: resourceTemplates,
+	}, nil
+}
+
+func (h *KintoneHandlers) ResourcesRead(ctx context.Context, params ResourcesReadRequest) (ResourcesReadResult, error) {
+	var data any
+
+	if m := appSchemaURIPattern.FindStringSubmatch(params.URI); m != nil {
+		if err := h.checkPermissions(m[1]); err != nil {
+			return ResourcesReadResult{}, err
+		}
+
+		app, err := h.fetchAppDetail(ctx, m[1], appDetailOptions{})
+		if err != nil {
+			return ResourcesReadResult{}, err
+		}
+		data = app
+	} else if m := fieldURIPattern.FindStringSubmatch(params.URI); m != nil {
+		if err := h.checkPermissions(m[1]); err != nil {
+			return ResourcesReadResult{}, err
+		}
+
+		code, err := url.PathUnescape(m[2])
+		if err != nil {
+			code = m[2]
+		}
+
+		field, err := h.readFieldDefinition(ctx, m[1], code)
+		if err != nil {
+			return ResourcesReadResult{}, err
+		}
+		data = field
+	} else if m := recordURIPattern.FindStringSubmatch(params.URI); m != nil {
+		if err := h.checkPermissions(m[1]); err != nil {
+			return ResourcesReadResult{}, err
+		}
+
+		record, err := h.readSingleRecord(ctx, m[1], m[2])
+		if err != nil {
+			return ResourcesReadResult{}, err
+		}
+		data = record
+	} else {
+		return ResourcesReadResult{}, jsonrpc2.Error{
+			Code:    ResourceNotFoundCode,
+			Message: "Resource not found",
+			Data:    JsonMap{"uri": params.URI},
+		}
+	}
+
+	content, err := JSONContent(data)
+	if err != nil {
+		return ResourcesReadResult{}, err
+	}
+
+	return ResourcesReadResult{
+		Contents: []ResourceContents{{
+			URI:      params.URI,
+			MimeType: "application/json",
+			Text:     content[0].Text,
+		}},
+	}, nil
+}
+
+// readFieldDefinition returns the definition of the field in the app, including fields in tables.
+func (h *KintoneHandlers) readFieldDefinition(ctx context.Context, appID, code string) (JsonMap, error) {
+	var fields struct {
+		Properties map[string]JsonMap `json:"properties"`
+	}
+	if err := h.fetchAppSchema(ctx, "/k/v1/app/form/fields.json", appID, &fields); err != nil {
+		return nil, err
+	}
+
+	filter := h.fieldFilter(appID)
+	if f, ok := fields.Properties[code]; ok && filter.allowed("", code) {
+		filter.filterProperties(JsonMap{code: map[string]any(f)})
+		return f, nil
+	}
+	for table, f := range fields.Properties {
+		if sub, ok := f["fields"].(map[string]any); ok {
+			if field, ok := sub[code].(map[string]any); ok && filter.allowed(table, code) {
+				return field, nil
+			}
+		}
+	}
+
+	return nil, jsonrpc2.Error{
+		Code:    ResourceNotFoundCode,
+		Message: fmt.Sprintf("Field %s is not found in app %s", code, appID),
+		Data:    JsonMap{"uri": fieldURI(appID, code)},
+	}
+}
+
+func fieldURI(appID, fieldCode string) string {
+	return fmt.Sprintf("kintone://app/%s/field/%s", appID, url.PathEscape(fieldCode))
+}
+
+// ResourcesSubscribe handles resources/subscribe. The clients are notified of the changes of the records by the webhooks of kintone.
+func (h *KintoneHandlers) ResourcesSubscribe(ctx context.Context, params ResourcesSubscribeRequest) (struct{}, error) {
+	s := SessionFromContext(ctx)
+	if s == nil {
+		return struct{}{}, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidRequestCode,
+			Message: "Subscriptions are not available without a session",
+		}
+	}
+
+	m := recordURIPattern.FindStringSubmatch(params.URI)
+	if m == nil {
+		return struct{}{}, jsonrpc2.Error{
+			Code:    ResourceNotFoundCode,
+			Message: "Only records can be subscribed, such as kintone://app/1/record/2",
+			Data:    JsonMap{"uri": params.URI},
+		}
+	}
+	if err := h.checkPermissions(m[1]); err != nil {
+		return struct{}{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscriptions == nil {
+		s.subscriptions = make(map[string]struct{})
+	}
+	s.subscriptions[params.URI] = struct{}{}
+	return struct{}{}, nil
+}
+
+// ResourcesUnsubscribe handles resources/unsubscribe.
+func (h *KintoneHandlers) ResourcesUnsubscribe(ctx context.Context, params ResourcesSubscribeRequest) (struct{}, error) {
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		delete(s.subscriptions, params.URI)
+		s.mu.Unlock()
+	}
+	return struct{}{}, nil
+}
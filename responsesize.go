@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	// defaultMaxResponseSize is the maximum size of records in a tool result, to fit in the message limit of clients and save tokens.
+	defaultMaxResponseSize = 100 * 1024
+
+	// shortenedTextLength is the number of characters kept in long text fields, when a single record is larger than the limit.
+	shortenedTextLength = 200
+)
+
+// shortenableFieldTypes is the types of fields that can be shortened to fit the response in the limit.
+var shortenableFieldTypes = []string{"SINGLE_LINE_TEXT", "MULTI_LINE_TEXT", "RICH_TEXT"}
+
+// jsonSize returns the size of v in a tool result.
+func jsonSize(v any) int {
+	bs, _ := json.MarshalIndent(v, "", "  ")
+	return len(bs)
+}
+
+// limitRecordsSize shrinks the response of /k/v1/records.json to fit in maxSize bytes.
+// The records at the end are removed first, and then long text fields are shortened if the first record is still too large.
+// read is the number of records that kintone returned, which can be more than the records in the response if the caller already dropped some.
+// It returns a note for the client that explains what was omitted, or an empty string if nothing was changed.
+func limitRecordsSize(response JsonMap, maxSize, read int) string {
+	records, _ := response["records"].([]any)
+	if maxSize <= 0 || len(records) == 0 || (len(records) == read && jsonSize(response) <= maxSize) {
+		return ""
+	}
+
+	fits := func(n int) bool {
+		response["records"] = records[:n]
+		return jsonSize(response) <= maxSize
+	}
+
+	// Find the largest number of records that fits, keeping at least one record.
+	n := sort.Search(len(records), func(i int) bool { return !fits(i + 1) })
+	n = max(n, 1)
+	response["records"] = records[:n]
+
+	var note string
+	if n < read {
+		note = fmt.Sprintf("The response is too large, so only the first %d of %d records are returned. Use nextOffset to read the rest, or specify fewer fields.", n, read)
+	}
+
+	if jsonSize(response) > maxSize {
+		if r, ok := records[0].(map[string]any); ok {
+			shortenTextFields(r)
+		}
+		if note == "" {
+			note = "The response is too large, so long text fields are shortened."
+		}
+		note += fmt.Sprintf(" Text fields longer than %d characters are cut off. Read the record with only the necessary fields to get the full text.", shortenedTextLength)
+	}
+
+	return note
+}
+
+// shortenTextFields cuts off long text fields in the record, including fields in tables.
+func shortenTextFields(record map[string]any) {
+	for _, v := range record {
+		field, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		if field["type"] == "SUBTABLE" {
+			rows, _ := field["value"].([]any)
+			for _, row := range rows {
+				if row, ok := row.(map[string]any); ok {
+					if cells, ok := row["value"].(map[string]any); ok {
+						shortenTextFields(cells)
+					}
+				}
+			}
+			continue
+		}
+
+		if t, _ := field["type"].(string); !slices.Contains(shortenableFieldTypes, t) {
+			continue
+		}
+		if s, ok := field["value"].(string); ok {
+			if r := []rune(s); len(r) > shortenedTextLength {
+				field["value"] = fmt.Sprintf("%s... (%d more characters)", string(r[:shortenedTextLength]), len(r)-shortenedTextLength)
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryCount   = 2
+	defaultRetryBackoff = time.Second
+	defaultRetryJitter  = 0.2
+
+	// maxRetryBackoff is the upper limit of the wait between retries, including Retry-After of the server.
+	maxRetryBackoff = 30 * time.Second
+)
+
+// RetryPolicy is the settings to retry requests to kintone that failed by transient problems.
+type RetryPolicy struct {
+	// Count is the maximum number of retries. 0 disables retrying.
+	Count int
+
+	// Backoff is the wait before the first retry. It doubles for each retry.
+	Backoff time.Duration
+
+	// Jitter is the ratio of random variation of the wait, from 0 to 1.
+	Jitter float64
+}
+
+// retryableStatuses is the status codes that are caused by transient problems, such as a proxy or a maintenance.
+var retryableStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// retryableKintoneCodes is the error codes of kintone that mean the request was not processed and can be sent again.
+var retryableKintoneCodes = []string{
+	"GAIA_DA02", // Failed to lock the database.
+}
+
+// isIdempotentMethod reports whether the request can be sent twice without side effects.
+// kintone uses GET for all requests to read data.
+func isIdempotentMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isDialError reports whether the request failed before it reaches the server.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// shouldRetry reports whether the request should be sent again.
+// Requests that modify data are retried only if the server surely didn't process them.
+func shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		// The body is a stream such as an uploading file, which can't be sent again.
+		return false
+	}
+	idempotent := isIdempotentMethod(req.Method)
+
+	if err != nil {
+		if req.Context().Err() != nil {
+			return false
+		}
+		return idempotent || isDialError(err)
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if idempotent && slices.Contains(retryableStatuses, res.StatusCode) {
+		return true
+	}
+	if res.StatusCode >= 500 {
+		return slices.Contains(retryableKintoneCodes, peekErrorCode(res))
+	}
+	return false
+}
+
+// peekErrorCode reads the error code of kintone in the response, and keeps the body readable.
+func peekErrorCode(res *http.Response) string {
+	bs, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(bs))
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(bs, &body)
+	return body.Code
+}
+
+// delay returns the wait before the retry of the attempt, which starts from 0.
+func (p RetryPolicy) delay(attempt int, res *http.Response) time.Duration {
+	d := p.Backoff << attempt
+	if d < 0 || d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1))
+	}
+
+	if ra, ok := retryAfter(res); ok {
+		d = max(d, min(ra, maxRetryBackoff))
+	}
+	return d
+}
+
+// retryAfter returns the wait that the server requested by the Retry-After header.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	v := res.Header.Get("Retry-After")
+	if sec, err := strconv.Atoi(v); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil && time.Until(t) > 0 {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// doWithRetry sends the request, and retries it if it failed by a transient problem.
+func (h *KintoneHandlers) doWithRetry(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		release, err := h.limiter.Acquire(ctx, req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := h.httpClient().Do(req)
+		if err != nil {
+			release()
+		} else {
+			res.Body = cancelOnClose{ReadCloser: res.Body, cancel: release}
+			if res.StatusCode == http.StatusTooManyRequests {
+				if d, ok := retryAfter(res); ok {
+					h.limiter.Pause(req.URL.Host, d)
+				}
+			}
+		}
+
+		if attempt >= h.Retry.Count || !shouldRetry(req, res, err) {
+			return res, err
+		}
+
+		wait := h.Retry.delay(attempt, res)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// It can't succeed in time, so report the current result instead of a timeout.
+			return res, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = res.Status
+			res.Body.Close()
+		}
+		LogContext(ctx, LogLevelWarning, "http", JsonMap{
+			"method":  req.Method,
+			"path":    req.URL.Path,
+			"message": fmt.Sprintf("Retrying in %s: %s", wait.Round(time.Millisecond), reason),
+			"attempt": attempt + 1,
+		})
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// parseRetryPolicy reads KINTONE_RETRY_COUNT, KINTONE_RETRY_BACKOFF, and KINTONE_RETRY_JITTER.
+func parseRetryPolicy() (RetryPolicy, []error) {
+	var errs []error
+	p := RetryPolicy{Count: defaultRetryCount, Backoff: defaultRetryBackoff, Jitter: defaultRetryJitter}
+
+	if s := Getenv("KINTONE_RETRY_COUNT", ""); s != "" {
+		if n, err := strconv.Atoi(s); err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("- KINTONE_RETRY_COUNT must be a non-negative integer: %s", s))
+		} else {
+			p.Count = n
+		}
+	}
+	if s := Getenv("KINTONE_RETRY_BACKOFF", ""); s != "" {
+		if d, err := parseTimeout(s); err != nil {
+			errs = append(errs, fmt.Errorf("- Failed to parse KINTONE_RETRY_BACKOFF: %s", err))
+		} else {
+			p.Backoff = d
+		}
+	}
+	if s := Getenv("KINTONE_RETRY_JITTER", ""); s != "" {
+		if f, err := strconv.ParseFloat(s, 64); err != nil || f < 0 || f > 1 {
+			errs = append(errs, fmt.Errorf("- KINTONE_RETRY_JITTER must be a number between 0 and 1: %s", s))
+		} else {
+			p.Jitter = f
+		}
+	}
+
+	return p, errs
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// rootsTimeout is how long to wait for the roots/list response from the client.
+const rootsTimeout = 10 * time.Second
+
+// clientRoots returns the local directories that the client provides as roots.
+// It returns nil if the client doesn't support roots, or doesn't provide any file roots.
+func clientRoots(ctx context.Context) []string {
+	s := SessionFromContext(ctx)
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	supported := s.clientCapabilities.Roots != nil
+	roots, loaded := s.roots, s.rootsLoaded
+	s.mu.Unlock()
+
+	if !supported {
+		return nil
+	}
+	if loaded {
+		return roots
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rootsTimeout)
+	defer cancel()
+
+	var res struct {
+		Roots []struct {
+			URI  string `json:"uri"`
+			Name string `json:"name"`
+		} `json:"roots"`
+	}
+	if err := s.Call(ctx, "roots/list", JsonMap{}, &res); err != nil {
+		LogContext(ctx, LogLevelWarning, "roots", "Failed to get roots from the client: "+err.Error())
+		return nil
+	}
+
+	roots = make([]string, 0, len(res.Roots))
+	for _, r := range res.Roots {
+		if p, ok := fileURIToPath(r.URI); ok {
+			if resolved, err := filepath.EvalSymlinks(p); err == nil {
+				p = resolved
+			}
+			roots = append(roots, p)
+		}
+	}
+
+	s.mu.Lock()
+	s.roots = roots
+	s.rootsLoaded = true
+	s.mu.Unlock()
+
+	return roots
+}
+
+// fileURIToPath converts a file:// URI into a local path.
+func fileURIToPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///C:/path is parsed as "/C:/path".
+		p = strings.TrimPrefix(p, "/")
+		if u.Host != "" && u.Host != "localhost" {
+			p = `\\` + u.Host + `\` + p
+		}
+	}
+
+	return filepath.Clean(filepath.FromSlash(p)), true
+}
+
+// RootsListChanged handles notifications/roots/list_changed, to fetch roots again in the next time.
+func (h *KintoneHandlers) RootsListChanged(ctx context.Context, params any) error {
+	if s := SessionFromContext(ctx); s != nil {
+		s.mu.Lock()
+		s.roots = nil
+		s.rootsLoaded = false
+		s.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// defaultCacheTTL is how long the app list and the app schemas are cached.
+const defaultCacheTTL = 5 * time.Minute
+
+// appCacheKey returns the cache key for the data of the app, so that invalidateCache can remove all of them at once.
+func (h *KintoneHandlers) appCacheKey(ctx context.Context, appID, name string) string {
+	return h.cacheScope(ctx) + "app:" + appID + ":" + name
+}
+
+// fetchAppSchema fetches a setting of the app such as /k/v1/app/form/fields.json, and caches the response for KINTONE_CACHE_TTL.
+// The response is cached as is, so the field filter has to be applied by the caller.
+func (h *KintoneHandlers) fetchAppSchema(ctx context.Context, path, appID string, result any) error {
+	raw, err := cached(ctx, &h.cache, h.appCacheKey(ctx, appID, path), h.CacheTTL, func(ctx context.Context) (json.RawMessage, error) {
+		key := "app"
+		if path == "/k/v1/app.json" {
+			key = "id"
+		}
+		var raw json.RawMessage
+		err := h.FetchHTTPWithJSON(ctx, "GET", path, Query{key: appID}, nil, &raw)
+		return raw, err
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// invalidateCache removes the cached data of the app, or everything in the current scope if appID is empty.
+func (h *KintoneHandlers) invalidateCache(ctx context.Context, appID string) {
+	if appID == "" {
+		h.cache.DeletePrefix(h.cacheScope(ctx))
+	} else {
+		h.cache.DeletePrefix(h.cacheScope(ctx) + "app:" + appID + ":")
+	}
+}
+
+type RefreshCacheParams struct {
+	AppID string `json:"appID,omitempty" description:"The app ID to refresh. If omitted, the app list and the settings of all apps are refreshed."`
+}
+
+func (h *KintoneHandlers) RefreshCache(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req RefreshCacheParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	if req.AppID != "" {
+		if err := h.checkPermissions(req.AppID); err != nil {
+			return nil, err
+		}
+		h.invalidateCache(ctx, req.AppID)
+		return JSONContent(JsonMap{"success": true, "appID": req.AppID})
+	}
+
+	h.invalidateCache(ctx, "")
+	if p := h.policy(); p.Allow.HasNamePatterns() || p.Deny.HasNamePatterns() {
+		if err := h.refreshAppNames(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return JSONContent(JsonMap{"success": true})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+type GetApiTokenScopesParams struct {
+	AppIDs []string `json:"appIDs" description:"The app IDs to check. In default, the apps that the server allows explicitly, or the apps that have API tokens or settings. Up to 50 apps."`
+}
+
+// scopeProbe is the result of a request to kintone to check a permission.
+type scopeProbe struct {
+	Status string `json:"status"` // "allowed", "denied", or "error"
+	Detail string `json:"detail,omitempty"`
+}
+
+// appScope is the permissions of an app in the result of getApiTokenScopes tool.
+type appScope struct {
+	AppID string `json:"appID"`
+	Name  string `json:"name,omitempty"`
+
+	// Server is the operations that the settings of this server allow, and ServerDenied is the reason if the app is not allowed at all.
+	Server       []string `json:"allowedByServer"`
+	ServerDenied string   `json:"deniedByServer,omitempty"`
+
+	// Kintone is the permissions that kintone grants to the credentials.
+	Kintone map[string]scopeProbe `json:"allowedByKintone,omitempty"`
+}
+
+func (h *KintoneHandlers) GetApiTokenScopes(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req GetApiTokenScopesParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.AppIDs) > maxSelfTestApps {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Too many apps: %d. Up to %d apps can be checked at once.", len(req.AppIDs), maxSelfTestApps),
+		}
+	}
+
+	auth, err := h.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var apis struct {
+		APIs map[string]json.RawMessage `json:"apis"`
+	}
+	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apis.json", nil, nil, &apis); err != nil {
+		return nil, err
+	}
+
+	res := JsonMap{
+		"credentials": h.describeCredentials(auth),
+		"apis":        slices.Sorted(maps.Keys(apis.APIs)),
+	}
+
+	ids := req.AppIDs
+	if len(ids) == 0 {
+		var truncated bool
+		ids, truncated, err = h.selfTestApps(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			res["note"] = "All apps are allowed by the server, so no app is checked. Please specify 'appIDs' to check the permissions of the apps."
+			return JSONContent(res)
+		}
+		if truncated {
+			res["truncated"] = fmt.Sprintf("Only the first %d apps are checked.", maxSelfTestApps)
+		}
+	}
+
+	scopes := make([]appScope, len(ids))
+	err = h.forEachConcurrently(ctx, len(ids), func(ctx context.Context, i int) error {
+		scopes[i] = h.appScope(ctx, ids[i])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res["apps"] = scopes
+	res["note"] = "Adding, editing, and deleting records are not checked in kintone, because they can't be checked without changing the data. If a tool fails with a permission error even though the server allows the operation, the credentials probably lack the permission in kintone."
+
+	return JSONContent(res)
+}
+
+// appScope checks the operations that the server allows for the app, and probes the permissions in kintone by reading requests.
+// The apps that the server doesn't allow are not requested to kintone.
+func (h *KintoneHandlers) appScope(ctx context.Context, appID string) appScope {
+	s := appScope{AppID: appID, Server: []string{}}
+
+	if err := h.checkPermissions(appID); err != nil {
+		s.ServerDenied = err.Error()
+		return s
+	}
+	s.Server = append(s.Server, "read")
+	if h.checkWritePermission(appID) == nil {
+		s.Server = append(s.Server, "write")
+	}
+	if h.checkDeletePermission(appID) == nil {
+		s.Server = append(s.Server, "delete")
+	}
+	if h.checkOperation(appID, "manage") == nil {
+		s.Server = append(s.Server, "manage")
+	}
+
+	var app KintoneAppDetail
+	s.Kintone = map[string]scopeProbe{
+		"viewApp": probeScope(h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/app.json", Query{"id": appID}, nil, &app)),
+		"viewRecords": probeScope(h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/records.json", nil, JsonMap{
+			"app":    appID,
+			"query":  "limit 1",
+			"fields": []string{"$id"},
+		}, nil)),
+		// Reading the field permissions requires the permission to manage the app.
+		"manageApp": probeScope(h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/field/acl.json", Query{"app": appID}, nil, nil)),
+	}
+	s.Name = app.Name
+
+	return s
+}
+
+// probeScope converts the result of a request into a scopeProbe.
+// Only the errors that tell the lack of the permission are reported as denied, and the others such as a network error are reported as error.
+func probeScope(err error) scopeProbe {
+	if err == nil {
+		return scopeProbe{Status: "allowed"}
+	}
+	var apiErr *KintoneAPIError
+	if errors.As(err, &apiErr) && (apiErr.Status == 403 || apiErr.Code == "GAIA_NO01" || apiErr.Code == "CB_NO02") {
+		return scopeProbe{Status: "denied", Detail: apiErr.Message}
+	}
+	return scopeProbe{Status: "error", Detail: err.Error()}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// defaultKeyringService is the service name to look up secrets in the OS keychain.
+const defaultKeyringService = "mcp-server-kintone"
+
+// GetenvSecret returns the value of the environment variable key.
+// If it is not set, it reads the file that is specified by key+"_FILE" instead, and trims whitespace around the content.
+func GetenvSecret(key string) (string, error) {
+	if v := lookupSetting(key); v != "" {
+		return v, nil
+	}
+
+	file := lookupSetting(key + "_FILE")
+	if file == "" {
+		return "", nil
+	}
+
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+// lookupKeyring returns the secret for the account in the OS keychain, such as macOS Keychain, Windows Credential Manager, or Secret Service on Linux.
+// It returns an empty string if the secret is not found.
+func lookupKeyring(service, account string) (string, error) {
+	secret, err := keyring.Get(service, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read %s from keychain: %w", account, err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+)
+
+// maxSelfTestApps is the maximum number of apps that are checked by the self-test.
+const maxSelfTestApps = 50
+
+// SelfTestResult is the result of a check in the self-test.
+type SelfTestResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "ok", "warning", "error", or "skipped"
+	Detail string `json:"detail,omitempty"`
+}
+
+// selfTestApps returns the app IDs to check in the self-test, and whether the list is truncated.
+// If no app is allowed explicitly, the apps that have API tokens or settings are used.
+func (h *KintoneHandlers) selfTestApps(ctx context.Context) ([]string, bool, error) {
+	p := h.policy()
+
+	var ids []string
+	switch {
+	case !p.Allow.IsEmpty() && p.Allow.IsExact():
+		ids = p.Allow.IDs()
+	case !p.Allow.IsEmpty():
+		apps, err := h.listPermittedApps(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, app := range apps {
+			ids = append(ids, app.AppID)
+		}
+	default:
+		for id := range p.AppTokens {
+			ids = append(ids, id)
+		}
+		for id := range p.Config.Apps {
+			if id != "*" {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		ids = slices.Compact(ids)
+	}
+
+	if len(ids) > maxSelfTestApps {
+		return ids[:maxSelfTestApps], true, nil
+	}
+	return ids, false, nil
+}
+
+// runSelfTest verifies that the base URL resolves, the credentials work, and the apps are reachable.
+// The profile in the context is tested.
+func (h *KintoneHandlers) runSelfTest(ctx context.Context) []SelfTestResult {
+	auth, err := h.auth(ctx)
+	if err != nil {
+		return []SelfTestResult{{Name: "credentials", Status: "error", Detail: err.Error()}}
+	}
+
+	var results []SelfTestResult
+
+	host := auth.URL.Hostname()
+	if h.Mock {
+		results = append(results, SelfTestResult{Name: "dns", Status: "skipped", Detail: "KINTONE_MOCK is set, so the in-memory kintone is used"})
+	} else {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return append(results, SelfTestResult{Name: "dns", Status: "error", Detail: err.Error()})
+		}
+		results = append(results, SelfTestResult{Name: "dns", Status: "ok", Detail: fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", "))})
+	}
+
+	var apis struct {
+		APIs map[string]json.RawMessage `json:"apis"`
+	}
+	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/apis.json", nil, nil, &apis); err != nil {
+		return append(results, SelfTestResult{Name: "authentication", Status: "error", Detail: err.Error()})
+	}
+	results = append(results, SelfTestResult{Name: "authentication", Status: "ok", Detail: h.describeCredentials(auth)})
+	results = append(results, SelfTestResult{
+		Name:   "apis",
+		Status: "ok",
+		Detail: fmt.Sprintf("%d APIs are available: %s", len(apis.APIs), strings.Join(slices.Sorted(maps.Keys(apis.APIs)), ", ")),
+	})
+
+	ids, truncated, err := h.selfTestApps(ctx)
+	if err != nil {
+		return append(results, SelfTestResult{Name: "apps", Status: "error", Detail: err.Error()})
+	}
+	if len(ids) == 0 {
+		return append(results, SelfTestResult{Name: "apps", Status: "skipped", Detail: "All apps are allowed. Set KINTONE_ALLOW_APPS to check each app."})
+	}
+	for _, id := range ids {
+		results = append(results, h.selfTestApp(ctx, id))
+	}
+	if truncated {
+		results = append(results, SelfTestResult{Name: "apps", Status: "warning", Detail: fmt.Sprintf("Only the first %d apps are checked.", maxSelfTestApps)})
+	}
+
+	return results
+}
+
+// describeCredentials returns the kind of the credentials, without the secrets.
+func (h *KintoneHandlers) describeCredentials(auth kintoneAuth) string {
+	var kinds []string
+	if auth.Auth != "" {
+		kinds = append(kinds, fmt.Sprintf("password of %s", auth.User))
+	}
+	if auth.Token != "" {
+		kinds = append(kinds, fmt.Sprintf("%d API tokens", len(strings.Split(auth.Token, ","))))
+	}
+	if auth.BasicAuth != "" {
+		kinds = append(kinds, "Basic authentication")
+	}
+	if h.SecureAccess {
+		kinds = append(kinds, "client certificate")
+	}
+	return "Authenticated with " + strings.Join(kinds, ", ")
+}
+
+// selfTestApp checks that the app and its records can be read, and reports the operations that the server allows.
+func (h *KintoneHandlers) selfTestApp(ctx context.Context, appID string) SelfTestResult {
+	name := "app " + appID
+
+	if err := h.checkPermissions(appID); err != nil {
+		return SelfTestResult{Name: name, Status: "warning", Detail: err.Error()}
+	}
+
+	var app KintoneAppDetail
+	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/app.json", Query{"id": appID}, nil, &app); err != nil {
+		return SelfTestResult{Name: name, Status: "error", Detail: err.Error()}
+	}
+
+	var records struct {
+		Records []json.RawMessage `json:"records"`
+	}
+	if err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/records.json", nil, JsonMap{"app": appID, "query": "limit 1", "fields": []string{"$id"}}, &records); err != nil {
+		return SelfTestResult{Name: name, Status: "error", Detail: fmt.Sprintf("%s: failed to read records: %v", app.Name, err)}
+	}
+
+	ops := []string{"read"}
+	if h.checkWritePermission(appID) == nil {
+		ops = append(ops, "write")
+	}
+	if h.checkDeletePermission(appID) == nil {
+		ops = append(ops, "delete")
+	}
+	return SelfTestResult{Name: name, Status: "ok", Detail: fmt.Sprintf("%s: reachable, allowed operations by the server: %s", app.Name, strings.Join(ops, ", "))}
+}
+
+func (h *KintoneHandlers) SelfTest(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	return JSONContent(JsonMap{"results": h.runSelfTest(ctx)})
+}
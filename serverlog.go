@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// serverLog is the log of the server itself for the operators, written to stderr or KINTONE_SERVER_LOG.
+// It is different from LogContext, which sends log messages to the client.
+var serverLog = slog.New(newServerLogHandler(os.Stderr, "json", slog.LevelInfo))
+
+// serverLogLevels maps the values of KINTONE_SERVER_LOG_LEVEL to slog levels.
+var serverLogLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"info":    slog.LevelInfo,
+	"warn":    slog.LevelWarn,
+	"warning": slog.LevelWarn,
+	"error":   slog.LevelError,
+}
+
+// sensitiveLogKey matches the attribute keys whose values are never written to the log.
+var sensitiveLogKey = regexp.MustCompile(`(?i)password|secret|token|authorization|cookie`)
+
+// logSecrets is the credentials that are removed from the log, such as passwords and API tokens.
+var logSecrets struct {
+	mu     sync.RWMutex
+	values map[string]struct{}
+}
+
+// addLogSecrets registers the values to remove from the log.
+func addLogSecrets(secrets ...string) {
+	logSecrets.mu.RLock()
+	missing := false
+	for _, s := range secrets {
+		if _, ok := logSecrets.values[s]; !ok && s != "" {
+			missing = true
+			break
+		}
+	}
+	logSecrets.mu.RUnlock()
+	if !missing {
+		return
+	}
+
+	logSecrets.mu.Lock()
+	defer logSecrets.mu.Unlock()
+	if logSecrets.values == nil {
+		logSecrets.values = make(map[string]struct{})
+	}
+	for _, s := range secrets {
+		if s != "" {
+			logSecrets.values[s] = struct{}{}
+		}
+	}
+}
+
+// redactLog removes the credentials from the log message.
+func redactLog(s string) string {
+	s = credentialHeaderPattern.ReplaceAllString(s, "${1}"+redactedText)
+
+	logSecrets.mu.RLock()
+	defer logSecrets.mu.RUnlock()
+	for secret := range logSecrets.values {
+		s = strings.ReplaceAll(s, secret, redactedText)
+	}
+	return s
+}
+
+// redactLogAttr is the ReplaceAttr of the server log, to remove the credentials and to write errors as strings.
+func redactLogAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveLogKey.MatchString(a.Key) {
+		return slog.String(a.Key, redactedText)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactLog(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redactLog(err.Error()))
+		}
+	}
+	return a
+}
+
+func newServerLogHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactLogAttr}
+	if format == "text" {
+		return traceLogHandler{slog.NewTextHandler(w, opts)}
+	}
+	return traceLogHandler{slog.NewJSONHandler(w, opts)}
+}
+
+// configureServerLog applies KINTONE_SERVER_LOG, KINTONE_SERVER_LOG_FORMAT, KINTONE_SERVER_LOG_LEVEL, and KINTONE_DEBUG.
+func configureServerLog() []error {
+	var errs []error
+
+	format := strings.ToLower(Getenv("KINTONE_SERVER_LOG_FORMAT", "json"))
+	if format != "json" && format != "text" {
+		errs = append(errs, fmt.Errorf("- KINTONE_SERVER_LOG_FORMAT must be 'json' or 'text': %s", format))
+	}
+
+	level, ok := serverLogLevels[strings.ToLower(Getenv("KINTONE_SERVER_LOG_LEVEL", "info"))]
+	if !ok {
+		errs = append(errs, fmt.Errorf("- KINTONE_SERVER_LOG_LEVEL must be one of debug, info, warn, or error: %s", Getenv("KINTONE_SERVER_LOG_LEVEL", "")))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// The traces of KINTONE_DEBUG are written in the debug level.
+	if GetenvBool("KINTONE_DEBUG") {
+		level = slog.LevelDebug
+	}
+
+	var w io.Writer = os.Stderr
+	if path := Getenv("KINTONE_SERVER_LOG", ""); path != "" && path != "-" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return []error{fmt.Errorf("- Failed to open KINTONE_SERVER_LOG: %s", err)}
+		}
+		w = f
+	}
+
+	serverLog = slog.New(newServerLogHandler(w, format, level))
+	return nil
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// Session is a connection between the server and a MCP client.
+//
+// Unlike jsonrpc2.Server.ServeForOne, Session handles requests concurrently and can send notifications to the client while handling requests.
+type Session struct {
+	server *jsonrpc2.Server
+
+	wmu sync.Mutex
+	w   io.Writer
+
+	// framed reports whether the client sends messages with Content-Length header. The responses are framed in the same way.
+	framed atomic.Bool
+
+	nextID atomic.Int64
+
+	mu                 sync.Mutex
+	logLevel           LogLevel
+	protocolVersion    string
+	clientCapabilities ClientCapabilities
+	clientInfo         ClientInfo
+	pending            map[string]chan rpcMessage
+	roots              []string
+	rootsLoaded        bool
+
+	// subscriptions is the URIs of the resources that the client subscribes by resources/subscribe.
+	subscriptions map[string]struct{}
+
+	// watches is the watches of records by watchRecords tool, that are stopped when the session is closed.
+	watches map[string]*recordWatch
+
+	// credentials is provided by the transport, such as HTTP headers.
+	credentials KintoneCredentials
+	// auth is the credentials to access kintone that is resolved in initialize.
+	auth *kintoneAuth
+	// user is the kintone user who uses the session, whose field permissions are applied by KINTONE_FIELD_ACL.
+	user string
+
+	// usage is the statistics of this session, that is reported by serverStats tool.
+	usage usageStats
+}
+
+// NewSession creates a new Session that writes messages to w.
+// The session receives broadcast notifications until Close is called.
+func NewSession(server *jsonrpc2.Server, w io.Writer) *Session {
+	s := &Session{
+		server:   server,
+		w:        w,
+		logLevel: defaultLogLevel,
+		usage:    usageStats{started: time.Now()},
+	}
+
+	sessions.Lock()
+	sessions.m[s] = struct{}{}
+	sessions.Unlock()
+
+	return s
+}
+
+// Close stops sending broadcast notifications to the session, and stops the watches of records.
+func (s *Session) Close() {
+	sessions.Lock()
+	delete(sessions.m, s)
+	sessions.Unlock()
+
+	s.mu.Lock()
+	for _, w := range s.watches {
+		w.cancel()
+	}
+	s.watches = nil
+	s.mu.Unlock()
+}
+
+// sessions is the set of open sessions to send broadcast notifications.
+var sessions = struct {
+	sync.Mutex
+	m map[*Session]struct{}
+}{m: make(map[*Session]struct{})}
+
+// BroadcastNotification sends a notification to all initialized sessions.
+func BroadcastNotification(method string, params any) {
+	for _, s := range initializedSessions() {
+		s.Notify(context.Background(), method, params)
+	}
+}
+
+// initializedSessions returns the open sessions that have completed the initialize request.
+func initializedSessions() []*Session {
+	sessions.Lock()
+	targets := make([]*Session, 0, len(sessions.m))
+	for s := range sessions.m {
+		targets = append(targets, s)
+	}
+	sessions.Unlock()
+
+	initialized := make([]*Session, 0, len(targets))
+	for _, s := range targets {
+		s.mu.Lock()
+		if s.protocolVersion != "" {
+			initialized = append(initialized, s)
+		}
+		s.mu.Unlock()
+	}
+	return initialized
+}
+
+type sessionKey struct{}
+
+// SessionFromContext returns the Session that is handling the current request.
+// It returns nil if the context is not associated with a session.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// ProtocolVersionFromContext returns the MCP version negotiated in the session associated with the context.
+// It returns the latest version if the context is not associated with a session.
+func ProtocolVersionFromContext(ctx context.Context) string {
+	s := SessionFromContext(ctx)
+	if s == nil {
+		return LatestProtocolVersion
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.protocolVersion == "" {
+		return SupportedProtocolVersions[0]
+	}
+	return s.protocolVersion
+}
+
+type rpcMessage struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      *jsonrpc2.ID    `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpc2.Error `json:"error,omitempty"`
+}
+
+type rpcResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpc2.Error `json:"error,omitempty"`
+	ID      *jsonrpc2.ID    `json:"id"`
+}
+
+// Serve reads messages from r and handles them until r is closed or ctx is canceled.
+// When ctx is canceled, new requests are refused and the in-flight requests are waited for up to shutdownTimeout.
+func (s *Session) Serve(ctx context.Context, r io.Reader) error {
+	// The requests are not canceled by ctx, so that the in-flight requests can complete at shutdown.
+	reqCtx, cancel := context.WithCancel(s.context(context.WithoutCancel(ctx)))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var inflight atomic.Int64
+
+	// disconnected cancels the in-flight requests when the client is gone,
+	// so that long operations such as exporting records don't consume the API quota of kintone for nobody.
+	disconnected := func() {
+		if n := inflight.Load(); n > 0 {
+			serverLog.Info("Client disconnected, canceling in-flight requests", "requests", n)
+		}
+		cancel()
+		wg.Wait()
+	}
+
+	messages := make(chan readResult)
+	stop := make(chan struct{})
+	defer close(stop)
+	go readMessages(newMessageReader(r), messages, stop)
+
+	for {
+		var m readResult
+		select {
+		case <-ctx.Done():
+			return s.drain(&wg, messages, cancel)
+		case m = <-messages:
+		}
+
+		var ferr *framingError
+		if errors.Is(m.err, os.ErrDeadlineExceeded) {
+			// The read deadline is used to stop reading at shutdown. The in-flight requests are completed.
+			wg.Wait()
+			return nil
+		} else if errors.Is(m.err, io.EOF) {
+			disconnected()
+			return nil
+		} else if errors.As(m.err, &ferr) {
+			// The broken message is skipped, so that a stray output of the client doesn't stop the session.
+			serverLog.Warn("Failed to read a message", "offset", ferr.Offset, "error", ferr.Err)
+			s.write(rpcResponse{Jsonrpc: "2.0", Error: &jsonrpc2.ErrParseError, ID: jsonrpc2.NullID()})
+			if errors.Is(ferr.Err, io.ErrUnexpectedEOF) {
+				disconnected()
+				return nil
+			}
+			continue
+		} else if m.err != nil {
+			disconnected()
+			return fmt.Errorf("failed to read message: %w", m.err)
+		}
+		s.framed.Store(m.framed)
+
+		if isInitializeMessage(m.raw) {
+			if res := s.handleRaw(reqCtx, m.raw); res != nil {
+				s.write(res)
+			}
+			continue
+		}
+
+		wg.Add(1)
+		inflight.Add(1)
+		go func() {
+			defer wg.Done()
+			defer inflight.Add(-1)
+			if res := s.handleRaw(reqCtx, m.raw); res != nil {
+				s.write(res)
+			}
+		}()
+	}
+}
+
+// readResult is a message that is read by readMessages.
+type readResult struct {
+	raw    json.RawMessage
+	framed bool
+	err    error
+}
+
+// readMessages reads messages and sends them to out until an unrecoverable error occurs or stop is closed.
+// Reading is done in a separate goroutine, so that Session.Serve can stop without waiting for the next message.
+func readMessages(reader *messageReader, out chan<- readResult, stop <-chan struct{}) {
+	for {
+		raw, err := reader.Read()
+		select {
+		case out <- readResult{raw: raw, framed: reader.framed, err: err}:
+		case <-stop:
+			return
+		}
+
+		var ferr *framingError
+		if err != nil && (!errors.As(err, &ferr) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			return
+		}
+	}
+}
+
+// drain waits for the in-flight requests at shutdown, while refusing the new requests.
+// The in-flight requests are canceled if they don't complete in shutdownTimeout.
+func (s *Session) drain(wg *sync.WaitGroup, messages <-chan readResult, cancel context.CancelFunc) error {
+	serverLog.Info("Shutting down kintone server, waiting for in-flight requests")
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timeout := time.NewTimer(shutdownTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-timeout.C:
+			cancel()
+			return errors.New("failed to shutdown gracefully: timed out waiting for in-flight requests")
+		case m := <-messages:
+			if m.err == nil {
+				s.framed.Store(m.framed)
+				s.refuse(m.raw)
+			}
+		}
+	}
+}
+
+// refuse responds to the request with an error because the server is shutting down.
+// Notifications are ignored, because they can't be responded.
+func (s *Session) refuse(raw json.RawMessage) {
+	errShuttingDown := &jsonrpc2.Error{Code: jsonrpc2.InternalErrorCode, Message: "Server is shutting down"}
+
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		s.write(rpcResponse{Jsonrpc: "2.0", Error: errShuttingDown, ID: jsonrpc2.NullID()})
+		return
+	}
+
+	var msg struct {
+		ID *jsonrpc2.ID `json:"id"`
+	}
+	if json.Unmarshal(raw, &msg) == nil && msg.ID != nil {
+		s.write(rpcResponse{Jsonrpc: "2.0", Error: errShuttingDown, ID: msg.ID})
+	}
+}
+
+// isInitializeMessage reports whether the message is an initialize request.
+// The initialize request affects how to handle the following requests, so it should be completed before reading next message.
+func isInitializeMessage(raw json.RawMessage) bool {
+	var peek struct {
+		Method string `json:"method"`
+	}
+	return json.Unmarshal(raw, &peek) == nil && peek.Method == "initialize"
+}
+
+// context returns a context associated with the session.
+func (s *Session) context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// handleRaw handles a single message or a batch of messages, and returns the response.
+// It returns nil if there is nothing to respond.
+func (s *Session) handleRaw(ctx context.Context, raw json.RawMessage) any {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '[' {
+		if res := s.handle(ctx, raw); res != nil {
+			return res
+		}
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(raw, &batch); err != nil || len(batch) == 0 {
+		return rpcResponse{Jsonrpc: "2.0", Error: &jsonrpc2.ErrInvalidRequest, ID: jsonrpc2.NullID()}
+	}
+
+	results := make([]*rpcResponse, len(batch))
+	var wg sync.WaitGroup
+	for i, msg := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.handle(ctx, msg)
+		}()
+	}
+	wg.Wait()
+
+	responses := make([]*rpcResponse, 0, len(results))
+	for _, r := range results {
+		if r != nil {
+			responses = append(responses, r)
+		}
+	}
+	if len(responses) > 0 {
+		return responses
+	}
+	return nil
+}
+
+// handle handles a single message and returns the response.
+// It returns nil if the message doesn't need a response.
+func (s *Session) handle(ctx context.Context, raw json.RawMessage) *rpcResponse {
+	var msg rpcMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return &rpcResponse{Jsonrpc: "2.0", Error: &jsonrpc2.ErrInvalidRequest, ID: jsonrpc2.NullID()}
+	}
+
+	if msg.Method == "" {
+		// This is a response to a request from the server.
+		if msg.ID != nil {
+			s.mu.Lock()
+			ch, ok := s.pending[msg.ID.String()]
+			s.mu.Unlock()
+			if ok {
+				select {
+				case ch <- msg:
+				default:
+				}
+			}
+		}
+		return nil
+	}
+
+	params := msg.Params
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+
+	start := time.Now()
+	result, err := s.server.ServeJSONRPC2(ctx, jsonrpc2.RawRequest{
+		Jsonrpc: jsonrpc2.VersionValue,
+		Method:  msg.Method,
+		Params:  params,
+		ID:      msg.ID,
+	})
+	if msg.ID == nil {
+		serverLog.DebugContext(ctx, "Notification handled", "method", msg.Method, "durationMs", time.Since(start).Milliseconds())
+		return nil
+	}
+	serverLog.DebugContext(ctx, "Request handled", "method", msg.Method, "id", msg.ID.String(), "durationMs", time.Since(start).Milliseconds(), "error", err)
+
+	res := &rpcResponse{Jsonrpc: "2.0", ID: msg.ID}
+
+	var rpcErr jsonrpc2.Error
+	if errors.As(err, &rpcErr) {
+		res.Error = &rpcErr
+	} else if err != nil {
+		serverLog.Error("Failed to handle request", "method", msg.Method, "id", msg.ID.String(), "error", err)
+		res.Error = &jsonrpc2.ErrInternalError
+	} else if res.Result, err = json.Marshal(result); err != nil {
+		serverLog.Error("Failed to encode result", "method", msg.Method, "id", msg.ID.String(), "error", err)
+		res.Result = nil
+		res.Error = &jsonrpc2.ErrInternalError
+	}
+
+	return res
+}
+
+// write writes a message to the client.
+func (s *Session) write(v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.framed.Load() {
+		bs = append(fmt.Appendf(nil, "Content-Length: %d\r\n\r\n", len(bs)), bs...)
+	} else {
+		bs = append(bs, '\n')
+	}
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	_, err = s.w.Write(bs)
+	return err
+}
+
+// messageSink is a function to send messages that relate to a specific request, such as progress notifications.
+type messageSink func(v any) error
+
+type sinkKey struct{}
+
+// withMessageSink returns a context that sends messages via sink instead of the default writer of the session.
+func withMessageSink(ctx context.Context, sink messageSink) context.Context {
+	return context.WithValue(ctx, sinkKey{}, sink)
+}
+
+// writeContext writes a message to the client, using the message sink associated with the context if exists.
+func (s *Session) writeContext(ctx context.Context, v any) error {
+	if sink, ok := ctx.Value(sinkKey{}).(messageSink); ok {
+		return sink(v)
+	}
+	return s.write(v)
+}
+
+// Notify sends a notification to the client.
+func (s *Session) Notify(ctx context.Context, method string, params any) error {
+	return s.writeContext(ctx, rpcMessage{
+		Jsonrpc: "2.0",
+		Method:  method,
+		Params:  mustMarshal(params),
+	})
+}
+
+// Call sends a request to the client and waits for the response.
+func (s *Session) Call(ctx context.Context, method string, params, result any) error {
+	id := jsonrpc2.StringID(fmt.Sprintf("server-%d", s.nextID.Add(1)))
+	ch := make(chan rpcMessage, 1)
+
+	s.mu.Lock()
+	if s.pending == nil {
+		s.pending = make(map[string]chan rpcMessage)
+	}
+	s.pending[id.String()] = ch
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id.String())
+		s.mu.Unlock()
+	}()
+
+	err := s.writeContext(ctx, rpcMessage{
+		Jsonrpc: "2.0",
+		Method:  method,
+		Params:  mustMarshal(params),
+		ID:      id,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case msg := <-ch:
+		if msg.Error != nil {
+			return *msg.Error
+		}
+		if result != nil {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	}
+}
+
+func mustMarshal(v any) json.RawMessage {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("failed to marshal: %v", err))
+	}
+	return bs
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// shutdownTimeout is how long to wait for in-flight requests when the server is shutting down.
+const shutdownTimeout = 30 * time.Second
+
+// drainGroup tracks in-flight requests of a connection, and stops accepting new requests once it is closed.
+type drainGroup struct {
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// Go runs f in a new goroutine, and reports whether it is started.
+// It returns false without calling f if the group is already closed.
+func (d *drainGroup) Go(f func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closing {
+		return false
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		f()
+	}()
+	return true
+}
+
+// Close stops accepting new requests and waits for in-flight requests to complete.
+func (d *drainGroup) Close() {
+	d.mu.Lock()
+	d.closing = true
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// serverLifecycle is shared by all connections of the server to handle graceful shutdown.
+type serverLifecycle struct {
+	// done is closed when the server starts shutting down.
+	done chan struct{}
+
+	// conns tracks long-lived connections that http.Server doesn't wait for, such as WebSocket.
+	conns sync.WaitGroup
+}
+
+type lifecycleKey struct{}
+
+// shuttingDown returns a channel that is closed when the server that handles the request starts shutting down.
+// The channel is never closed if the context is not associated with a server.
+func shuttingDown(ctx context.Context) <-chan struct{} {
+	if l, ok := ctx.Value(lifecycleKey{}).(*serverLifecycle); ok {
+		return l.done
+	}
+	return nil
+}
+
+// trackConnection registers a long-lived connection to wait for at shutdown.
+// The returned function should be called when the connection is closed.
+func trackConnection(ctx context.Context) func() {
+	l, ok := ctx.Value(lifecycleKey{}).(*serverLifecycle)
+	if !ok {
+		return func() {}
+	}
+	l.conns.Add(1)
+	return l.conns.Done
+}
+
+// cleanupTimeout is how long to wait for the cleanup after the server stopped, such as deleting cursors in kintone.
+const cleanupTimeout = 10 * time.Second
+
+// Shutdown releases the resources that remain after the server stopped.
+// It deletes the cursors that are left in kintone, waits for the after hooks in background, and closes the audit log.
+func (h *KintoneHandlers) Shutdown(ctx context.Context) {
+	h.closeCursors(ctx)
+
+	hooksDone := make(chan struct{})
+	go func() {
+		h.afterHooks.Wait()
+		close(hooksDone)
+	}()
+	select {
+	case <-hooksDone:
+	case <-ctx.Done():
+		serverLog.Warn("Timed out waiting for the after hooks", "error", ctx.Err())
+	}
+
+	if h.AuditLog != nil {
+		if err := h.AuditLog.Close(); err != nil {
+			serverLog.Warn("Failed to close the audit log", "error", err)
+		}
+	}
+}
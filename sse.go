@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// sseKeepAliveInterval is the interval to send keep-alive comments to the SSE stream, to prevent proxies from closing idle connections.
+const sseKeepAliveInterval = 30 * time.Second
+
+// SSETransport is a http.Handler that implements the legacy HTTP+SSE transport of MCP, which is defined in the 2024-11-05 version of the specification.
+//
+// The client opens a stream by GET request to /sse, and sends messages by POST request to the endpoint that is notified via the stream.
+type SSETransport struct {
+	server *jsonrpc2.Server
+
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+}
+
+// NewSSETransport creates a new SSETransport that handles requests with server.
+func NewSSETransport(server *jsonrpc2.Server) *SSETransport {
+	return &SSETransport{
+		server:   server,
+		sessions: make(map[string]*sseSession),
+	}
+}
+
+// sseSession is a Session that is connected via the legacy SSE transport.
+type sseSession struct {
+	*Session
+
+	// ctx is the context of the stream, which is canceled when the client disconnects.
+	ctx      context.Context
+	inflight drainGroup
+}
+
+// HandleStream handles GET requests to open the stream.
+func (t *SSETransport) HandleStream(w http.ResponseWriter, r *http.Request) {
+	if !isAllowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sse := newSSEWriter(w)
+	s := &sseSession{
+		Session: NewSession(t.server, &sseStream{w: sse}),
+		ctx:     r.Context(),
+	}
+	s.credentials = credentialsFromHeader(r.Header)
+	s.user = authenticatedUser(r.Context())
+	id := newSessionID()
+
+	t.mu.Lock()
+	t.sessions[id] = s
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.sessions, id)
+		t.mu.Unlock()
+		s.inflight.Close()
+		s.Close()
+	}()
+
+	if err := sse.WriteEvent("endpoint", []byte("/messages?sessionId="+id)); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shuttingDown(r.Context()):
+			return
+		case <-ticker.C:
+			if err := sse.KeepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// HandleMessage handles POST requests that send messages to the server.
+// The responses are sent via the stream, not in the response of the POST request.
+func (t *SSETransport) HandleMessage(w http.ResponseWriter, r *http.Request) {
+	if !isAllowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	t.mu.Lock()
+	s, ok := t.sessions[r.URL.Query().Get("sessionId")]
+	t.mu.Unlock()
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPRequestSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	} else if len(body) > maxHTTPRequestSize {
+		http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ctx := s.context(s.ctx)
+
+	if isInitializeMessage(body) {
+		if res := s.handleRaw(ctx, body); res != nil {
+			s.write(res)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	started := s.inflight.Go(func() {
+		if res := s.handleRaw(ctx, body); res != nil {
+			s.write(res)
+		}
+	})
+	if !started {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// WriteEvent writes an event with the name.
+func (s *sseWriter) WriteEvent(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// KeepAlive writes a comment line that is ignored by clients, to keep the connection alive.
+func (s *sseWriter) KeepAlive() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := io.WriteString(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
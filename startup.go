@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// startupItem is a line of the summary of the settings that is shown when the server starts.
+type startupItem struct {
+	Key   string // The attribute key in the server log.
+	Label string // The label in the terminal.
+	Value string
+
+	// Warn tells that the setting is probably a mistake, such as missing credentials.
+	Warn bool
+}
+
+// startupSummary returns the effective settings, so that the operators can find misconfigurations before using the server.
+// transport is how the clients connect, such as "stdio".
+func (h *KintoneHandlers) startupSummary(transport string) []startupItem {
+	p := h.policy()
+	stdio := transport == "stdio"
+
+	var items []startupItem
+
+	baseURL := startupItem{Key: "baseURL", Label: "Base URL", Value: "not set"}
+	if h.URL != nil {
+		baseURL.Value = h.URL.String()
+	}
+	if h.Mock {
+		baseURL.Value += " (mock)"
+	}
+	// Without the settings, the clients have to give the base URL by the headers or the initialize request.
+	baseURL.Warn = h.URL == nil && stdio
+	items = append(items, baseURL)
+
+	var auths []string
+	if h.Auth != "" {
+		auths = append(auths, fmt.Sprintf("password of %s", h.Username))
+	}
+	if p.Token != "" {
+		auths = append(auths, fmt.Sprintf("%d API tokens", len(strings.Split(p.Token, ","))))
+	}
+	if len(p.AppTokens) > 0 {
+		auths = append(auths, fmt.Sprintf("API tokens for %d apps", len(p.AppTokens)))
+	}
+	if h.BasicAuth != "" {
+		auths = append(auths, "Basic authentication")
+	}
+	if h.SecureAccess {
+		auths = append(auths, "client certificate")
+	}
+	auth := startupItem{Key: "authentication", Label: "Authentication", Value: strings.Join(auths, ", ")}
+	if auth.Value == "" {
+		auth.Value = "none, the credentials are given by each client"
+		auth.Warn = stdio
+	}
+	items = append(items, auth)
+
+	allow := startupItem{Key: "allowApps", Label: "Allowed apps", Value: p.Allow.String()}
+	if allow.Value == "" {
+		allow.Value = "all apps"
+	}
+	deny := startupItem{Key: "denyApps", Label: "Denied apps", Value: p.Deny.String()}
+	if deny.Value == "" {
+		deny.Value = "none"
+	}
+	items = append(items, allow, deny)
+
+	configFile := startupItem{Key: "configFile", Label: "Configuration file", Value: Getenv("KINTONE_CONFIG_FILE", "")}
+	if configFile.Value == "" {
+		configFile.Value = "none"
+	}
+	items = append(items, configFile)
+	if len(p.Profiles) > 0 {
+		items = append(items, startupItem{Key: "profiles", Label: "Profiles", Value: strings.Join(h.profileNames(), ", ")})
+	}
+
+	var enabled, disabled []string
+	for _, t := range toolsList.Tools {
+		if h.checkToolEnabled(t.Name) == nil {
+			enabled = append(enabled, t.Name)
+		} else {
+			disabled = append(disabled, t.Name)
+		}
+	}
+	for _, t := range p.Config.appTools() {
+		if h.checkToolEnabled(t.Name) == nil {
+			enabled = append(enabled, t.Name)
+		}
+	}
+	for _, t := range p.Config.Tools {
+		if h.checkToolEnabled(t.Name) == nil {
+			enabled = append(enabled, t.Name)
+		}
+	}
+	items = append(items, startupItem{Key: "enabledTools", Label: "Enabled tools", Value: fmt.Sprintf("%d tools", len(enabled)), Warn: len(enabled) == 0})
+	if len(disabled) > 0 {
+		slices.Sort(disabled)
+		items = append(items, startupItem{Key: "disabledTools", Label: "Disabled tools", Value: strings.Join(disabled, ", ")})
+	}
+
+	items = append(items, startupItem{Key: "transport", Label: "Transport", Value: transport})
+	if h.WebhookAddr != "" {
+		items = append(items, startupItem{Key: "webhook", Label: "Webhook", Value: h.WebhookAddr})
+	}
+
+	onOff := func(b bool) string {
+		if b {
+			return "on"
+		}
+		return "off"
+	}
+	items = append(items,
+		startupItem{Key: "readOnly", Label: "Read-only", Value: onOff(p.ReadOnly)},
+		startupItem{Key: "confirmDeletes", Label: "Confirm deletes", Value: onOff(p.ConfirmDeletes)},
+	)
+	if p.MaxAffectedRecords > 0 {
+		items = append(items, startupItem{Key: "maxAffectedRecords", Label: "Max affected records", Value: strconv.Itoa(p.MaxAffectedRecords)})
+	}
+
+	return items
+}
+
+// isTerminal reports whether the file is a terminal, such as stderr that is not redirected.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// reportStartup shows the summary of the settings when the server starts.
+// If stderr is a terminal and the server log is written there, the summary is printed as lines with colors, which NO_COLOR disables.
+// Otherwise, it is written as an entry of the server log, and the suspicious settings are written as warnings.
+func (h *KintoneHandlers) reportStartup(transport string) {
+	items := h.startupSummary(transport)
+
+	if path := Getenv("KINTONE_SERVER_LOG", ""); (path == "" || path == "-") && isTerminal(os.Stderr) {
+		printStartupSummary(os.Stderr, items, os.Getenv("NO_COLOR") == "")
+		return
+	}
+
+	attrs := make([]any, 0, len(items)*2)
+	for _, item := range items {
+		attrs = append(attrs, item.Key, item.Value)
+	}
+	serverLog.Info("Effective settings", attrs...)
+	for _, item := range items {
+		if item.Warn {
+			serverLog.Warn("Suspicious setting", "setting", item.Label, "value", item.Value)
+		}
+	}
+}
+
+// printStartupSummary writes the summary as aligned lines for the terminal.
+func printStartupSummary(w io.Writer, items []startupItem, color bool) {
+	const (
+		bold   = "\033[1m"
+		cyan   = "\033[36m"
+		yellow = "\033[33m"
+		reset  = "\033[0m"
+	)
+	paint := func(style, s string) string {
+		if !color {
+			return s
+		}
+		return style + s + reset
+	}
+
+	width := 0
+	for _, item := range items {
+		width = max(width, len(item.Label))
+	}
+
+	fmt.Fprintln(w, paint(bold, fmt.Sprintf("mcp-server-kintone %s", Version)))
+	for _, item := range items {
+		value := item.Value
+		if item.Warn {
+			value = paint(yellow, "! "+value)
+		}
+		fmt.Fprintf(w, "  %s  %s\n", paint(cyan, fmt.Sprintf("%-*s", width, item.Label)), value)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// usageStats is the statistics of the tool calls and the requests to kintone, to see the cost of an agent or to find a runaway loop.
+type usageStats struct {
+	mu          sync.Mutex
+	started     time.Time
+	toolCalls   map[string]int
+	toolErrors  map[string]int
+	cacheHits   int
+	cacheMisses int
+	requests    int
+	failures    int
+}
+
+// serverUsage is the statistics since the server started.
+// The statistics of each session are kept in Session.
+var serverUsage = &usageStats{started: time.Now()}
+
+// countUsage updates the statistics of the server and the current session.
+func countUsage(ctx context.Context, fn func(s *usageStats)) {
+	update := func(s *usageStats) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		fn(s)
+	}
+
+	update(serverUsage)
+	if s := SessionFromContext(ctx); s != nil {
+		update(&s.usage)
+	}
+}
+
+func (s *usageStats) countToolCall(name string, failed bool) {
+	if s.toolCalls == nil {
+		s.toolCalls = make(map[string]int)
+		s.toolErrors = make(map[string]int)
+	}
+	s.toolCalls[name]++
+	if failed {
+		s.toolErrors[name]++
+	}
+}
+
+func (s *usageStats) countCache(hit bool) {
+	if hit {
+		s.cacheHits++
+	} else {
+		s.cacheMisses++
+	}
+}
+
+func (s *usageStats) countRequest(failed bool) {
+	s.requests++
+	if failed {
+		s.failures++
+	}
+}
+
+// report returns the statistics in the form of the result of serverStats.
+func (s *usageStats) report() JsonMap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type toolStats struct {
+		Calls  int `json:"calls"`
+		Errors int `json:"errors,omitempty"`
+	}
+	tools := make(map[string]toolStats, len(s.toolCalls))
+	totalCalls, totalErrors := 0, 0
+	for _, name := range slices.Sorted(maps.Keys(s.toolCalls)) {
+		tools[name] = toolStats{Calls: s.toolCalls[name], Errors: s.toolErrors[name]}
+		totalCalls += s.toolCalls[name]
+		totalErrors += s.toolErrors[name]
+	}
+
+	cache := JsonMap{
+		"hits":   s.cacheHits,
+		"misses": s.cacheMisses,
+	}
+	if total := s.cacheHits + s.cacheMisses; total > 0 {
+		cache["hitRate"] = math.Round(float64(s.cacheHits)/float64(total)*1000) / 1000
+	}
+
+	return JsonMap{
+		"startedAt":     s.started.Format(time.RFC3339),
+		"uptimeSeconds": int(time.Since(s.started).Seconds()),
+		"toolCalls":     totalCalls,
+		"toolErrors":    totalErrors,
+		"tools":         tools,
+		"cache":         cache,
+		"kintoneRequests": JsonMap{
+			"total":  s.requests,
+			"errors": s.failures,
+		},
+	}
+}
+
+func (h *KintoneHandlers) ServerStats(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	result := JsonMap{
+		"version": Version,
+		"server":  serverUsage.report(),
+	}
+	if s := SessionFromContext(ctx); s != nil {
+		result["session"] = s.usage.report()
+	}
+	return JSONContent(result)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of tool calls and kintone requests.
+// The spans are not recorded unless configureTracing enables the exporter.
+var tracer = otel.Tracer("github.com/macrat/mcp-server-kintone")
+
+// tracingShutdownTimeout is how long to wait for sending the remaining spans when the server stops.
+const tracingShutdownTimeout = 5 * time.Second
+
+// configureTracing enables OpenTelemetry tracing if OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set.
+// The exporter is configured by the standard OTEL_* environment variables.
+// The returned function flushes the remaining spans.
+func configureTracing(ctx context.Context) (func(), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func() {}, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default service name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "mcp-server-kintone"),
+			attribute.String("service.version", Version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		serverLog.Warn("Failed to export traces", "error", err)
+	}))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tracingShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			serverLog.Warn("Failed to flush traces", "error", err)
+		}
+	}, nil
+}
+
+// extractTraceHeaders continues the trace of the client, if the HTTP request has traceparent header.
+func extractTraceHeaders(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// traceID returns the trace ID of the current span, or an empty string if there is no trace.
+func traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// startToolSpan starts the span of a tool call.
+// The traceparent in _meta of the request takes precedence over the HTTP headers, because it is specific to the tool call.
+func startToolSpan(ctx context.Context, params ToolsCallRequest) (context.Context, trace.Span) {
+	if params.Meta.TraceParent != "" {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{
+			"traceparent": params.Meta.TraceParent,
+			"tracestate":  params.Meta.TraceState,
+		})
+	}
+	return tracer.Start(ctx, "tools/call "+params.Name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("mcp.tool.name", params.Name)),
+	)
+}
+
+// startRequestSpan starts the span of a request to kintone, and adds traceparent header to the request.
+func startRequestSpan(req *http.Request) (*http.Request, trace.Span) {
+	ctx, span := tracer.Start(req.Context(), "kintone "+req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		),
+	)
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, span
+}
+
+// endSpan records the result of the operation and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, redactLog(err.Error()))
+	}
+	span.End()
+}
+
+// traceLogHandler adds the request ID and the trace ID of the context to the server log.
+type traceLogHandler struct {
+	slog.Handler
+}
+
+func (h traceLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("requestID", id))
+	}
+	if id := traceID(ctx); id != "" {
+		r.AddAttrs(slog.String("traceID", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceLogHandler) WithGroup(name string) slog.Handler {
+	return traceLogHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// RecordTemplate is a preset of a record that createRecordFromTemplate tool creates, to file the same kind of records in the same way.
+type RecordTemplate struct {
+	// Description tells the AI when to use the template.
+	Description string `json:"description"`
+
+	// Values is the preset values of the fields in the simple format, such as {"category": "Expense"}.
+	Values map[string]any `json:"values"`
+
+	// Inputs is the field codes that the AI has to fill in.
+	Inputs []string `json:"inputs"`
+}
+
+// recordTemplates returns the templates of the apps that can be written, sorted by the app ID and the name.
+// If appID is empty, the templates of all apps listed in the configuration file are returned.
+func (h *KintoneHandlers) recordTemplates(appID string) []JsonMap {
+	config := h.policy().Config
+
+	ids := []string{appID}
+	if appID == "" {
+		ids = nil
+		for id := range config.Apps {
+			if id != "*" {
+				ids = append(ids, id)
+			}
+		}
+		slices.SortFunc(ids, func(a, b string) int {
+			x, _ := strconv.ParseUint(a, 10, 64)
+			y, _ := strconv.ParseUint(b, 10, 64)
+			return cmp.Compare(x, y)
+		})
+	}
+
+	var templates []JsonMap
+	for _, id := range ids {
+		if h.checkWritePermission(id) != nil {
+			continue
+		}
+		app, _ := config.appConfiguration(id)
+		for _, name := range slices.Sorted(maps.Keys(app.Templates)) {
+			t := app.Templates[name]
+			templates = append(templates, JsonMap{
+				"appID":       id,
+				"template":    name,
+				"description": t.Description,
+				"inputs":      t.Inputs,
+				"values":      t.Values,
+			})
+		}
+	}
+	return templates
+}
+
+// hasRecordTemplates reports whether any app has templates.
+func (c Configuration) hasRecordTemplates() bool {
+	for _, app := range c.Apps {
+		if len(app.Templates) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateRecordFromTemplateParams struct {
+	AppID    string      `json:"appID" description:"The app ID to create a record in. Required if 'template' is specified. If 'template' is omitted, only the templates of this app are listed."`
+	Template string      `json:"template" description:"The name of the template. If omitted, the available templates are listed instead of creating a record."`
+	Values   RecordParam `json:"values" description:"The values of the fields in the simple format, such as {\"field1\": \"value1\"}. The fields in 'inputs' of the template are required, and the other values overwrite the preset values of the template."`
+}
+
+func (h *KintoneHandlers) CreateRecordFromTemplate(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req CreateRecordFromTemplateParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Template == "" {
+		templates := h.recordTemplates(req.AppID)
+		if templates == nil {
+			templates = []JsonMap{}
+		}
+		return JSONContent(JsonMap{"templates": templates})
+	}
+
+	if req.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required to use a template",
+		}
+	}
+	if err := h.checkWritePermission(req.AppID); err != nil {
+		return nil, err
+	}
+
+	app, _ := h.policy().Config.appConfiguration(req.AppID)
+	t, ok := app.Templates[req.Template]
+	if !ok {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Unknown template %s for app ID %s. Please call this tool without 'template' to list the available templates.", req.Template, req.AppID),
+		}
+	}
+
+	var missing []string
+	for _, code := range t.Inputs {
+		if v, ok := req.Values[code]; !ok || v == nil || v == "" {
+			missing = append(missing, code)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Template %s requires the values of the following fields: %s", req.Template, strings.Join(missing, ", ")),
+			Data:    JsonMap{"inputs": t.Inputs, "missing": missing},
+		}
+	}
+
+	record := make(RecordParam, len(t.Values)+len(req.Values))
+	maps.Copy(record, t.Values)
+	maps.Copy(record, req.Values)
+
+	create, err := json.Marshal(CreateRecordParams{
+		AppID:  req.AppID,
+		Record: record,
+		Format: recordFormatSimple,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h.CreateRecord(ctx, create)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultConnectTimeout is the time limit to connect to kintone, including the TLS handshake.
+	defaultConnectTimeout = 10 * time.Second
+
+	// defaultTimeout is the time limit of a tool call, or a request to kintone outside of tool calls.
+	defaultTimeout = 2 * time.Minute
+)
+
+// defaultToolTimeouts is the time limits of the tools that transfer files or process many records, which take longer than the other tools.
+var defaultToolTimeouts = map[string]time.Duration{
+	"backupAppData":             time.Hour,
+	"chartData":                 10 * time.Minute,
+	"createRecordComments":      10 * time.Minute,
+	"downloadAttachmentFile":    10 * time.Minute,
+	"downloadRecordAttachments": 30 * time.Minute,
+	"exportRecordsXLSX":         30 * time.Minute,
+	"extractAttachmentText":     10 * time.Minute,
+	"fieldStats":                10 * time.Minute,
+	"importRecords":             30 * time.Minute,
+	"restoreAppData":            time.Hour,
+	"uploadAttachmentFile":      10 * time.Minute,
+}
+
+// parseTimeout parses a duration such as "30s" or "5m". "0" disables the timeout.
+func parseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration: %s", s)
+	}
+	return d, nil
+}
+
+// parseToolTimeouts parses the values of KINTONE_TOOL_TIMEOUTS, such as "downloadRecordAttachments=1h".
+func parseToolTimeouts(list []string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(list))
+	for _, s := range list {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid format: %s: expected <tool name>=<duration>", s)
+		}
+		d, err := parseTimeout(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %w", name, err)
+		}
+		timeouts[strings.TrimSpace(name)] = d
+	}
+	return timeouts, nil
+}
+
+// toolTimeout returns the time limit of the tool call.
+func (h *KintoneHandlers) toolTimeout(name string) time.Duration {
+	if d, ok := h.ToolTimeouts[name]; ok {
+		return d
+	}
+	if h.Timeout == 0 {
+		return 0
+	}
+	if d, ok := defaultToolTimeouts[name]; ok && d > h.Timeout {
+		return d
+	}
+	return h.Timeout
+}
+
+// isTimeout reports whether the error is caused by a timeout of a request.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// newTimeoutError makes an error that tells the model to retry the request.
+func newTimeoutError(timeout time.Duration) *KintoneAPIError {
+	msg := "The request to kintone server timed out."
+	if timeout > 0 {
+		msg = fmt.Sprintf("The request to kintone server timed out after %s.", timeout)
+	}
+	return &KintoneAPIError{
+		Message:   msg + " This may be a temporary problem, so please retry later. If it happens repeatedly, try a smaller request such as a lower limit.",
+		Retryable: true,
+	}
+}
+
+// cancelOnClose is a response body that calls cancel when it is closed, to release the context of the request or the slot of the rate limiter.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
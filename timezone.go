@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// parseTimezone reads KINTONE_TIMEZONE.
+// It returns nil if not set, to use the timezone of the kintone user.
+func parseTimezone() (*time.Location, error) {
+	name := Getenv("KINTONE_TIMEZONE", "")
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("- Failed to parse KINTONE_TIMEZONE: %s", err)
+	}
+	return loc, nil
+}
+
+// timezone returns the timezone to show and to interpret the date and time values.
+// It is KINTONE_TIMEZONE, or the timezone in the settings of the kintone user if the server uses password authentication, or the local timezone of the server.
+func (h *KintoneHandlers) timezone(ctx context.Context) *time.Location {
+	if h.Timezone != nil {
+		return h.Timezone
+	}
+
+	auth, err := h.auth(ctx)
+	if err != nil || auth.User == "" {
+		return time.Local
+	}
+
+	name, err := cached(ctx, &h.cache, h.cacheScope(ctx)+"timezone:"+auth.User, h.CacheTTL, func(ctx context.Context) (string, error) {
+		var res struct {
+			Users []struct {
+				Timezone string `json:"timezone"`
+			} `json:"users"`
+		}
+		if err := h.FetchHTTPWithJSON(ctx, "GET", "/v1/users.json", Query{"codes": auth.User}, nil, &res); err != nil {
+			return "", err
+		}
+		if len(res.Users) == 0 {
+			return "", fmt.Errorf("user %s is not found", auth.User)
+		}
+		return res.Users[0].Timezone, nil
+	})
+	if err != nil || name == "" {
+		serverLog.DebugContext(ctx, "Failed to get the timezone of the kintone user, using the local timezone", "error", err)
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// localizeRecordTimes converts the date and time values in the record from UTC into the timezone, such as "2025-01-02T09:00:00+09:00".
+// The record has to be in the format of kintone.
+func localizeRecordTimes(record map[string]any, loc *time.Location) {
+	for _, v := range record {
+		field, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		switch t, _ := field["type"].(string); t {
+		case "SUBTABLE":
+			rows, _ := field["value"].([]any)
+			for _, row := range rows {
+				row, _ := row.(map[string]any)
+				if cells, ok := row["value"].(map[string]any); ok {
+					localizeRecordTimes(cells, loc)
+				}
+			}
+		case "DATETIME", "CREATED_TIME", "UPDATED_TIME":
+			s, _ := field["value"].(string)
+			if parsed, err := time.Parse(time.RFC3339, s); err == nil {
+				field["value"] = parsed.In(loc).Format(time.RFC3339)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// appTokenEnvPrefix is the prefix of environment variables to set API tokens for each app, such as KINTONE_API_TOKEN_123.
+const appTokenEnvPrefix = "KINTONE_API_TOKEN_"
+
+// loadAppTokens reads API tokens for each app from the environment variables and the configuration file.
+func loadAppTokens() map[string][]string {
+	tokens := make(map[string][]string)
+	for _, key := range settingKeys(appTokenEnvPrefix) {
+		appID, _ := strings.CutPrefix(key, appTokenEnvPrefix)
+		value := lookupSetting(key)
+		if _, err := strconv.ParseUint(appID, 10, 64); err != nil {
+			continue
+		}
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens[appID] = append(tokens[appID], t)
+			}
+		}
+	}
+	return tokens
+}
+
+type appIDKey struct{}
+
+// withAppID returns a context for requests that target the app.
+func withAppID(ctx context.Context, appID string) context.Context {
+	if appID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, appIDKey{}, appID)
+}
+
+// appIDFromContext returns the app ID that the request targets, or an empty string if unknown.
+func appIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(appIDKey{}).(string)
+	return id
+}
+
+// requestAppID guesses the app ID that the kintone API request targets.
+func requestAppID(path string, query Query, body any) string {
+	if id := query["app"]; id != "" {
+		return id
+	}
+	if path == "/k/v1/app.json" && query["id"] != "" {
+		return query["id"]
+	}
+	if m, ok := body.(JsonMap); ok {
+		if id, ok := m["app"]; ok {
+			return fmt.Sprint(id)
+		}
+	}
+	return ""
+}
+
+// apiTokensFor returns the comma-joined API tokens to access the app.
+// The tokens for the app are used in addition to KINTONE_API_TOKEN.
+// If the app is unknown, tokens for all apps are used because kintone accepts multiple tokens at once.
+func (h *KintoneHandlers) apiTokensFor(appID string) string {
+	p := h.policy()
+	return joinAPITokens(p.Token, p.AppTokens, appID)
+}
+
+// joinAPITokens returns the comma-joined API tokens in common and the tokens for the app.
+func joinAPITokens(common string, appTokens map[string][]string, appID string) string {
+	var tokens []string
+	for _, t := range strings.Split(common, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+
+	if appID != "" {
+		tokens = append(tokens, appTokens[appID]...)
+	} else {
+		for _, id := range slices.Sorted(maps.Keys(appTokens)) {
+			tokens = append(tokens, appTokens[id]...)
+		}
+	}
+
+	slices.Sort(tokens)
+	return strings.Join(slices.Compact(tokens), ",")
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// toolDefinition is a tool that the server provides.
+// The input schema is generated from Params, so that it always matches the arguments that Handler accepts.
+type toolDefinition struct {
+	Name        string
+	Description string
+	Params      any
+	Annotations JsonMap
+	Handler     func(h *KintoneHandlers, ctx context.Context, params json.RawMessage) ([]Content, error)
+}
+
+// toolDefinitions is the list of the tools, in the order of tools/list.
+var toolDefinitions = []toolDefinition{
+	{
+		Name:        "listApps",
+		Description: "List all applications made on kintone. Response includes the app ID, name, and description.",
+		Params:      ListAppsParams{},
+		Annotations: JsonMap{
+			"title":         "List kintone apps",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ListApps,
+	},
+	{
+		Name:        "readAppInfo",
+		Description: "Get information about the specified app. Response includes the app ID, name, description, and schema.",
+		Params:      ReadAppInfoParams{},
+		Annotations: JsonMap{
+			"title":         "Read kintone app information",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ReadAppInfo,
+	},
+	{
+		Name:        "appStatistics",
+		Description: "Get the operational statistics of the specified apps at once: the number of records, the last time a record was updated, the number of views, the number of fields by type, and whether the process management is enabled. Use this tool to find which apps are actually in use.",
+		Params:      AppStatisticsParams{},
+		Annotations: JsonMap{
+			"title":         "Get statistics of kintone apps",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).AppStatistics,
+	},
+	{
+		Name:        "createRecord",
+		Description: "Create a new record in the specified app. Before use this tool, you better to know the schema of the app by using 'readAppInfo' tool.",
+		Params:      CreateRecordParams{},
+		Annotations: JsonMap{
+			"title":           "Create a kintone record",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).CreateRecord,
+	},
+	{
+		Name:        "createRecordFromTemplate",
+		Description: "Create a new record from a template that the server defines for the app, such as a ticket or an expense report. The template presets some fields and tells which fields to fill in. Call this tool without 'template' to list the available templates with their inputs.",
+		Params:      CreateRecordFromTemplateParams{},
+		Annotations: JsonMap{
+			"title":           "Create a kintone record from a template",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).CreateRecordFromTemplate,
+	},
+	{
+		Name:        "importRecords",
+		Description: "Create records in the specified app from a CSV or JSON Lines file. The columns are mapped to the fields by the field codes or the labels, and all rows are validated before creating records. The response includes the errors of each row. Use 'dryRun' to check the file before importing.",
+		Params:      ImportRecordsParams{},
+		Annotations: JsonMap{
+			"title":           "Import kintone records from a file",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).ImportRecords,
+	},
+	{
+		Name:        "backupAppData",
+		Description: "Back up the records and the attachment files of the specified app into a zip file or a directory. The backup can be restored by using 'restoreAppData' tool. The response includes the path of the backup.",
+		Params:      BackupAppDataParams{},
+		Annotations: JsonMap{
+			"title":           "Back up a kintone app",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).BackupAppData,
+	},
+	{
+		Name:        "restoreAppData",
+		Description: "Restore the records and the attachment files from a backup that 'backupAppData' tool created, by creating new records in the specified app. The attachment files are uploaded again. The record IDs and the record numbers are not restored.",
+		Params:      RestoreAppDataParams{},
+		Annotations: JsonMap{
+			"title":           "Restore a kintone app from a backup",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).RestoreAppData,
+	},
+	{
+		Name:        "readRecords",
+		Description: "Read records from the specified app. Response includes the record ID and record data, and 'hasMore' and 'nextOffset' to read the next page. Before search records using this tool, you better to know the schema of the app by using 'readAppInfo' tool.",
+		Params:      ReadRecordsParams{},
+		Annotations: JsonMap{
+			"title":         "Read kintone records",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ReadRecords,
+	},
+	{
+		Name:        "exportRecordsXLSX",
+		Description: "Export records in the specified app into an Excel workbook. All records that match the query are exported, with dates and numbers as typed cells. The response includes the path of the saved file. Use this tool when the user wants a spreadsheet, rather than reading many records by 'readRecords' tool.",
+		Params:      ExportRecordsXLSXParams{},
+		Annotations: JsonMap{
+			"title":         "Export kintone records to Excel",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ExportRecordsXLSX,
+	},
+	{
+		Name:        "fieldStats",
+		Description: "Compute the statistics of a number field over the records that match the query: the count, sum, minimum, maximum, average, and percentiles. All records are aggregated on the server, so use this tool instead of reading many records by 'readRecords' tool to answer questions such as the average deal size.",
+		Params:      FieldStatsParams{},
+		Annotations: JsonMap{
+			"title":         "Compute statistics of a kintone field",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).FieldStats,
+	},
+	{
+		Name:        "chartData",
+		Description: "Count records, or sum a number field, for each day, week, or month of a date field, optionally split into groups by another field such as a status. The response is a compact time series that is suitable to describe trends or to draw a chart, like the graphs of kintone.",
+		Params:      ChartDataParams{},
+		Annotations: JsonMap{
+			"title":         "Aggregate kintone records over time",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ChartData,
+	},
+	{
+		Name:        "explainQuery",
+		Description: "Explain what records the query matches in the specified app: each condition with the label and type of the field, the sort order, the number of matched records, and warnings such as an operator that can't be used for the field. Use this tool to check the query before updating or deleting the records it matches.",
+		Params:      ExplainQueryParams{},
+		Annotations: JsonMap{
+			"title":         "Explain a kintone query",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ExplainQuery,
+	},
+	{
+		Name:        "updateRecord",
+		Description: "Update the specified record in the specified app. Before use this tool, you better to know the schema of the app by using 'readAppInfo' tool and check which record to update by using 'readRecords' tool.",
+		Params:      UpdateRecordParams{},
+		Annotations: JsonMap{
+			"title":           "Update a kintone record",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UpdateRecord,
+	},
+	{
+		Name:        "deleteRecord",
+		Description: "Delete the specified record in the specified app. Before use this tool, you should check which record to delete by using 'readRecords' tool. This operation is unrecoverable, so make sure that the user really want to delete the record.",
+		Params:      DeleteRecordParams{},
+		Annotations: JsonMap{
+			"title":           "Delete a kintone records",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).DeleteRecord,
+	},
+	{
+		Name:        "downloadAttachmentFile",
+		Description: "Download the specified attachment file. Before use this tool, you should check file key by using 'readRecords' tool. Response includes the saved file path, the original file name, the content type, and the SHA-256 hash of the file.",
+		Params:      DownloadAttachmentFileParams{},
+		Annotations: JsonMap{
+			"title":           "Download a file from kintone",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).DownloadAttachmentFile,
+	},
+	{
+		Name:        "downloadRecordAttachments",
+		Description: "Download all attachment files on the records that match the query in the specified app. The files can be saved separately or bundled into a single zip file with a manifest.json that maps each file to its record and field. Response includes the list of downloaded files.",
+		Params:      DownloadRecordAttachmentsParams{},
+		Annotations: JsonMap{
+			"title":           "Download files on kintone records",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).DownloadRecordAttachments,
+	},
+	{
+		Name:        "extractAttachmentText",
+		Description: "Read the text content of the specified attachment file. Supported file types are PDF, Word (.docx), Excel (.xlsx), PowerPoint (.pptx), and plain text files such as .txt, .csv, or .md. Use this tool instead of 'downloadAttachmentFile' when you want to read the content of a document. Before use this tool, you should check file key by using 'readRecords' tool.",
+		Params:      ExtractAttachmentTextParams{},
+		Annotations: JsonMap{
+			"title":         "Read text in a file on kintone",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ExtractAttachmentText,
+	},
+	{
+		Name:        "uploadAttachmentFile",
+		Description: "Upload a new attachment file to the specified app. The response includes a file key that you can use for creating or updating records. You can specify the file by path or content.",
+		Params:      UploadAttachmentFileParams{},
+		Annotations: JsonMap{
+			"title":           "Upload a file to kintone",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UploadAttachmentFile,
+	},
+	{
+		Name:        "readRecordComments",
+		Description: "Read comments on the specified record in the specified app.",
+		Params:      ReadRecordCommentsParams{},
+		Annotations: JsonMap{
+			"title":         "Read kintone record's comments",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).ReadRecordComments,
+	},
+	{
+		Name:        "createRecordComment",
+		Description: "Create a new comment on the specified record in the specified app.",
+		Params:      CreateRecordCommentParams{},
+		Annotations: JsonMap{
+			"title":           "Post a comment to kintone record",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).CreateRecordComment,
+	},
+	{
+		Name:        "createRecordComments",
+		Description: "Post the same comment on every record that matches the query in the specified app, such as asking for a status update on all overdue tasks. The text can include the values of the fields of each record, and the mentions can refer a user field of each record. If the query matches more than 10 records, the first call returns a preview and a confirmationToken, and the comments are posted only when called again with the token.",
+		Params:      CreateRecordCommentsParams{},
+		Annotations: JsonMap{
+			"title":           "Post a comment to kintone records",
+			"readOnlyHint":    false,
+			"destructiveHint": false,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).CreateRecordComments,
+	},
+	{
+		Name:        "updateProcessManagementAssignee",
+		Description: "Update the assignee of process management of the specified record in the specified app.",
+		Params:      UpdateProcessManagementAssigneeParams{},
+		Annotations: JsonMap{
+			"title":           "Update kintone record's assignee",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UpdateProcessManagementAssignee,
+	},
+	{
+		Name:        "executeProcessManagementAction",
+		Description: "Execute the specified action of process management of the specified record in the specified app.",
+		Params:      ExecuteProcessManagementActionParams{},
+		Annotations: JsonMap{
+			"title":           "Execute kintone record's process management action",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).ExecuteProcessManagementAction,
+	},
+	{
+		Name:        "updateAppAcl",
+		Description: "Replace the app permissions of the specified app, such as who can manage the app or add, edit, and delete records. Include the current permissions in the result of the first call to keep them. The first call returns the current permissions and the changes with a confirmationToken, and the permissions are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.",
+		Params:      UpdateAppACLParams{},
+		Annotations: JsonMap{
+			"title":           "Update kintone app permissions",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UpdateAppACL,
+	},
+	{
+		Name:        "updateRecordAcl",
+		Description: "Replace the record permissions of the specified app, that restrict who can view, edit, and delete the records that match the conditions. Include the current permissions in the result of the first call to keep them. The first call returns the current permissions and the changes with a confirmationToken, and the permissions are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.",
+		Params:      UpdateRecordACLParams{},
+		Annotations: JsonMap{
+			"title":           "Update kintone record permissions",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UpdateRecordACL,
+	},
+	{
+		Name:        "updateFieldAcl",
+		Description: "Replace the field permissions of the specified app, that restrict who can view and edit each field. Include the current permissions in the result of the first call to keep them. The first call returns the current permissions and the changes with a confirmationToken, and the permissions are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.",
+		Params:      UpdateFieldACLParams{},
+		Annotations: JsonMap{
+			"title":           "Update kintone field permissions",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UpdateFieldACL,
+	},
+	{
+		Name:        "updateGeneralNotifications",
+		Description: "Replace the general notifications of the specified app, that notify the users when records are added or edited, comments are posted, or files are imported. Include the current notifications in the result of the first call to keep them. The first call returns the current notifications and the changes with a confirmationToken, and the notifications are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.",
+		Params:      UpdateGeneralNotificationsParams{},
+		Annotations: JsonMap{
+			"title":           "Update kintone general notifications",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UpdateGeneralNotifications,
+	},
+	{
+		Name:        "updatePerRecordNotifications",
+		Description: "Replace the per-record notifications of the specified app, that notify the users when a record matches the conditions, such as notifying the manager when the priority is High. Include the current notifications in the result of the first call to keep them. The first call returns the current notifications and the changes with a confirmationToken, and the notifications are changed only when called again with the token. The changes are made in the preview environment, and applied to the app by 'deployAppSettings' tool.",
+		Params:      UpdatePerRecordNotificationsParams{},
+		Annotations: JsonMap{
+			"title":           "Update kintone per-record notifications",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  true,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).UpdatePerRecordNotifications,
+	},
+	{
+		Name:        "deployAppSettings",
+		Description: "Apply the changed settings in the preview environment of the specified app, such as the permissions changed by 'updateAppAcl', 'updateRecordAcl', and 'updateFieldAcl' tools, and the notifications changed by 'updateGeneralNotifications' and 'updatePerRecordNotifications' tools. Other changes in the preview environment that are made in kintone are also applied. Set 'revert' to discard the changes instead.",
+		Params:      DeployAppSettingsParams{},
+		Annotations: JsonMap{
+			"title":           "Deploy kintone app settings",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).DeployAppSettings,
+	},
+	{
+		Name:        "watchRecords",
+		Description: "Start watching the records in the specified app, such as new inquiries, while this session lasts. The records are checked periodically, and the added and updated records are kept until 'getWatchUpdates' tool is called. Use this tool when the user asks to be told about new or updated records during the work.",
+		Params:      WatchRecordsParams{},
+		Annotations: JsonMap{
+			"title":          "Watch kintone records",
+			"readOnlyHint":   true,
+			"idempotentHint": false,
+			"openWorldHint":  true,
+		},
+		Handler: (*KintoneHandlers).WatchRecords,
+	},
+	{
+		Name:        "getWatchUpdates",
+		Description: "Get the records that are added or updated since the last call, for the watches started by 'watchRecords' tool. The returned changes are cleared. Set 'stop' to stop watching.",
+		Params:      GetWatchUpdatesParams{},
+		Annotations: JsonMap{
+			"title":          "Get updates of watched kintone records",
+			"readOnlyHint":   true,
+			"idempotentHint": false,
+			"openWorldHint":  false,
+		},
+		Handler: (*KintoneHandlers).GetWatchUpdates,
+	},
+	{
+		Name:        "kintoneRequest",
+		Description: "Call a kintone REST API that other tools don't cover, such as '/k/v1/app/acl.json'. Only the endpoints allowed by the server settings can be called. Use the other tools instead if they can do the same thing.",
+		Params:      KintoneRequestParams{},
+		Annotations: JsonMap{
+			"title":           "Call kintone REST API",
+			"readOnlyHint":    false,
+			"destructiveHint": true,
+			"idempotentHint":  false,
+			"openWorldHint":   true,
+		},
+		Handler: (*KintoneHandlers).KintoneRequest,
+	},
+	{
+		Name:        "listProfiles",
+		Description: "List the kintone environments that this server can access, such as production and sandbox. Each tool accepts the 'profile' argument to select the environment.",
+		Params:      struct{}{},
+		Annotations: JsonMap{
+			"title":         "List kintone environments",
+			"readOnlyHint":  true,
+			"openWorldHint": false,
+		},
+		Handler: (*KintoneHandlers).ListProfiles,
+	},
+	{
+		Name:        "refreshCache",
+		Description: "Clear the cached app list and app settings such as fields and process management, and fetch them again on the next use. Use this tool after the settings of an app are changed.",
+		Params:      RefreshCacheParams{},
+		Annotations: JsonMap{
+			"title":          "Refresh cached kintone app settings",
+			"readOnlyHint":   true,
+			"idempotentHint": true,
+			"openWorldHint":  false,
+		},
+		Handler: (*KintoneHandlers).RefreshCache,
+	},
+	{
+		Name:        "serverStats",
+		Description: "Report the statistics of this server and the current session: uptime, the number of calls and errors of each tool, the hit rate of the cache, and the number of requests to kintone. Use this tool to see how many API requests the previous operations cost.",
+		Params:      struct{}{},
+		Annotations: JsonMap{
+			"title":         "Show server statistics",
+			"readOnlyHint":  true,
+			"openWorldHint": false,
+		},
+		Handler: (*KintoneHandlers).ServerStats,
+	},
+	{
+		Name:        "selfTest",
+		Description: "Check the connection to kintone. It verifies that the base URL resolves, the credentials work, and the allowed apps are reachable. Use this tool when other tools fail with connection or permission errors.",
+		Params:      struct{}{},
+		Annotations: JsonMap{
+			"title":         "Check the connection to kintone",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).SelfTest,
+	},
+	{
+		Name:        "getApiTokenScopes",
+		Description: "Report the APIs and the apps that the credentials can access. For each app, it tells the operations that this server allows, and whether kintone allows the credentials to view the app, view the records, and manage the app. Use this tool when other tools fail with permission errors, to tell whether the API token lacks the permission or the server settings don't allow the app.",
+		Params:      GetApiTokenScopesParams{},
+		Annotations: JsonMap{
+			"title":         "Check the permissions of the credentials",
+			"readOnlyHint":  true,
+			"openWorldHint": true,
+		},
+		Handler: (*KintoneHandlers).GetApiTokenScopes,
+	},
+}
+
+// toolsList is the result of tools/list before filtering by the settings.
+var toolsList ToolsListResult
+
+func init() {
+	for _, t := range toolDefinitions {
+		toolsList.Tools = append(toolsList.Tools, ToolInfo{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: inputSchema(reflect.TypeOf(t.Params)),
+			Annotations: t.Annotations,
+		})
+	}
+}
+
+// findTool returns the definition of the tool that has the name.
+func findTool(name string) (toolDefinition, bool) {
+	for _, t := range toolDefinitions {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return toolDefinition{}, false
+}
+
+// schemaProvider is implemented by parameter types that need a custom JSON schema.
+type schemaProvider interface {
+	JSONSchema() JsonMap
+}
+
+var schemaProviderType = reflect.TypeFor[schemaProvider]()
+
+// inputSchema generates the JSON schema of the type.
+// The fields of structs use the struct tags: `json` for the property name, `description` for the description, `required:"true"` for required properties, and `enum` for comma-separated allowed values.
+func inputSchema(t reflect.Type) JsonMap {
+	if t.Implements(schemaProviderType) {
+		return reflect.Zero(t).Interface().(schemaProvider).JSONSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return inputSchema(t.Elem())
+	case reflect.String:
+		return JsonMap{"type": "string"}
+	case reflect.Bool:
+		return JsonMap{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return JsonMap{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return JsonMap{"type": "array", "items": inputSchema(t.Elem())}
+	case reflect.Map:
+		return JsonMap{"type": "object", "additionalProperties": inputSchema(t.Elem())}
+	case reflect.Struct:
+		props := JsonMap{}
+		var required []string
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "" || name == "-" {
+				continue
+			}
+
+			s := inputSchema(f.Type)
+			if desc := f.Tag.Get("description"); desc != "" {
+				s["description"] = desc
+			}
+			if enum := f.Tag.Get("enum"); enum != "" {
+				if items, ok := s["items"].(JsonMap); ok {
+					items["enum"] = strings.Split(enum, ",")
+				} else {
+					s["enum"] = strings.Split(enum, ",")
+				}
+			}
+			if f.Tag.Get("required") == "true" {
+				required = append(required, name)
+			}
+			props[name] = s
+		}
+
+		s := JsonMap{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return JsonMap{}
+	}
+}
+
+// RecordParam is a record in kintone's record data format, such as {"field1": {"value": "value1"}}, or in the simple format, such as {"field1": "value1"}.
+type RecordParam map[string]any
+
+// JSONSchema returns the schema of kintone records.
+// The fields can also be plain values for the simple format.
+func (RecordParam) JSONSchema() JsonMap {
+	field := JsonMap{
+		"type":     "object",
+		"required": []string{"value"},
+		"properties": JsonMap{
+			"value": JsonMap{
+				"anyOf": []JsonMap{
+					{
+						"description": "Usual values for text, number, etc.",
+						"type":        "string",
+					},
+					{
+						"description": "Values for checkbox.",
+						"type":        "array",
+						"items":       JsonMap{"type": "string"},
+					},
+					{
+						"description": "Values for file attachment.",
+						"type":        "array",
+						"items": JsonMap{
+							"type": "object",
+							"properties": JsonMap{
+								"contentType": JsonMap{"description": "The content type of the file.", "type": "string"},
+								"fileKey":     JsonMap{"description": "The file key. You can get the file key to upload a file by using 'uploadAttachmentFile' tool. The file can donwload by using 'downloadAttachmentFile' tool.", "type": "string"},
+								"name":        JsonMap{"description": "The file name.", "type": "string"},
+							},
+						},
+					},
+					{
+						"description": "Values for table.",
+						"type":        "object",
+						"required":    []string{"value"},
+						"properties": JsonMap{
+							"value": JsonMap{
+								"type": "array",
+								"items": JsonMap{
+									"type":     "object",
+									"required": []string{"value"},
+									"properties": JsonMap{
+										"value": JsonMap{
+											"type": "object",
+											"additionalProperties": JsonMap{
+												"type":       "object",
+												"required":   []string{"value"},
+												"properties": JsonMap{"value": JsonMap{}},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return JsonMap{
+		"type": "object",
+		"additionalProperties": JsonMap{
+			"anyOf": []JsonMap{
+				field,
+				{"description": "The plain value of the field, if the format is 'simple'."},
+			},
+		},
+	}
+}
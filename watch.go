@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+const (
+	// defaultWatchInterval is the interval of watchRecords tool if not specified.
+	defaultWatchInterval = time.Minute
+
+	// minWatchInterval is the shortest interval of watchRecords tool, to avoid using up the API limit of kintone.
+	minWatchInterval = 10 * time.Second
+
+	// maxWatches is the maximum number of watches in a session.
+	maxWatches = 10
+
+	// maxWatchChanges is the maximum number of changes that a watch keeps until getWatchUpdates tool is called.
+	maxWatchChanges = 500
+
+	// watchPageSize is the number of records that a watch reads at once, which is the maximum of kintone.
+	watchPageSize = 500
+)
+
+// recordWatch is a watch of records that is registered by watchRecords tool.
+// It checks the records periodically while the session lasts, and keeps the changes until getWatchUpdates tool is called.
+type recordWatch struct {
+	ID       string
+	AppID    string
+	Query    string
+	Interval time.Duration
+	Format   string
+
+	// updatedField is the code of the updated time field, that is "Updated_datetime" in default.
+	updatedField string
+
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+
+	// since is the updated time of the newest record that has been checked. The records updated at this time or later are checked in the next time.
+	since string
+	// maxID is the largest record ID that has been checked. The records with larger IDs are reported as added.
+	maxID int
+	// revisions is the revisions of the records that are updated at since, to skip them in the next check.
+	revisions map[string]string
+
+	changes     []JsonMap
+	truncated   bool
+	lastChecked time.Time
+	lastError   string
+}
+
+type WatchRecordsParams struct {
+	AppID    string `json:"appID" required:"true" description:"The app ID to watch records in."`
+	Query    string `json:"query" description:"The query to filter the records to watch, such as 'status in (\"New\")'. Query format is the same as kintone's query format, but 'order by', 'limit', and 'offset' are not allowed. Default is all records."`
+	Interval string `json:"interval" description:"How often to check the records, such as '30s' or '5m'. Default is '1m', minimum is '10s'."`
+	Format   string `json:"format" enum:"kintone,simple" description:"The format of the records in the updates. 'kintone' is the format of kintone REST API that includes the type of each field, and 'simple' is the plain values such as {\"field1\": \"value1\"} that saves tokens. Default is 'kintone'."`
+}
+
+func (h *KintoneHandlers) WatchRecords(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req WatchRecordsParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required",
+		}
+	}
+
+	s := SessionFromContext(ctx)
+	if s == nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Watches are not available without a session",
+		}
+	}
+
+	interval := defaultWatchInterval
+	if req.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(req.Interval); err != nil || interval < minWatchInterval {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Interval must be a duration of %s or longer, such as '30s' or '5m'", minWatchInterval),
+			}
+		}
+	}
+	if err := checkRecordFormat(req.Format); err != nil {
+		return nil, err
+	}
+
+	if err := h.checkPermissions(req.AppID); err != nil {
+		return nil, err
+	}
+	if cond, options := splitQuery(req.Query); options != "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "The query of watchRecords can't have 'order by', 'limit', or 'offset'",
+		}
+	} else if err := h.checkQuery(ctx, req.AppID, cond); err != nil {
+		return nil, err
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	w := &recordWatch{
+		ID:        newRequestID(),
+		AppID:     req.AppID,
+		Query:     strings.TrimSpace(req.Query),
+		Interval:  interval,
+		Format:    req.Format,
+		revisions: make(map[string]string),
+	}
+	for code, f := range fields {
+		if f.Type == "UPDATED_TIME" {
+			w.updatedField = code
+		}
+	}
+	if w.updatedField == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("App ID %s can't be watched because it has no updated time field", req.AppID),
+		}
+	}
+
+	// The watch starts from the newest records, so that only the records added or updated after this are reported.
+	// The updated time has no seconds, so all records that are updated at the same time as the newest one are observed.
+	for _, order := range []string{"$id", w.updatedField} {
+		var res struct {
+			Records []map[string]any `json:"records"`
+		}
+		err := h.FetchHTTPWithJSON(ctx, "GET", "/k/v1/records.json", nil, JsonMap{
+			"app":    req.AppID,
+			"query":  fmt.Sprintf("order by %s desc limit 100", order),
+			"fields": []string{"$id", "$revision", w.updatedField},
+		}, &res)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range res.Records {
+			if recordFieldValue(r, order) != recordFieldValue(res.Records[0], order) {
+				break
+			}
+			w.observe(r)
+		}
+	}
+
+	s.mu.Lock()
+	if len(s.watches) >= maxWatches {
+		s.mu.Unlock()
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Too many watches. A session can have up to %d watches. Please stop a watch by using 'getWatchUpdates' tool with 'stop'.", maxWatches),
+		}
+	}
+	if s.watches == nil {
+		s.watches = make(map[string]*recordWatch)
+	}
+	s.watches[w.ID] = w
+	s.mu.Unlock()
+
+	// The watch outlives the tool call, so it uses a new context that only keeps the session and the profile.
+	wctx := s.context(context.Background())
+	if profile := profileFromContext(ctx); profile != "" {
+		wctx = withProfile(wctx, profile)
+	}
+	wctx, w.cancel = context.WithCancel(wctx)
+	go h.runWatch(wctx, s, w)
+
+	serverLog.InfoContext(ctx, "Started watching records", "watchID", w.ID, "app", w.AppID, "interval", w.Interval.String())
+
+	return JSONContent(JsonMap{
+		"watchID":  w.ID,
+		"appID":    w.AppID,
+		"query":    w.Query,
+		"interval": w.Interval.String(),
+		"message":  fmt.Sprintf("The records are checked every %s while this session lasts. Call getWatchUpdates with this watchID to get the added and updated records.", w.Interval),
+	})
+}
+
+// observe records that the record has been checked, and reports whether it is added or updated after the last check.
+// It returns an empty string if the record has already been checked.
+func (w *recordWatch) observe(record map[string]any) string {
+	id, revision, updated := recordFieldValue(record, "$id"), recordFieldValue(record, "$revision"), recordFieldValue(record, w.updatedField)
+	n, _ := strconv.Atoi(id)
+
+	if updated == w.since && w.revisions[id] == revision {
+		return ""
+	}
+	if updated > w.since {
+		w.since = updated
+		clear(w.revisions)
+	}
+	if updated == w.since {
+		w.revisions[id] = revision
+	}
+
+	if n > w.maxID {
+		w.maxID = n
+		return "added"
+	}
+	return "updated"
+}
+
+// recordFieldValue returns the value of the field in the record of the kintone format, such as the record ID.
+func recordFieldValue(record map[string]any, code string) string {
+	field, _ := record[code].(map[string]any)
+	v, _ := field["value"].(string)
+	return v
+}
+
+// runWatch checks the records in every interval until ctx is canceled.
+func (h *KintoneHandlers) runWatch(ctx context.Context, s *Session, w *recordWatch) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		added, updated, err := h.checkWatch(ctx, w)
+		w.mu.Lock()
+		w.lastChecked = time.Now()
+		w.lastError = ""
+		if err != nil {
+			w.lastError = err.Error()
+		}
+		w.mu.Unlock()
+
+		if err != nil {
+			serverLog.Warn("Failed to check watched records", "watchID", w.ID, "app", w.AppID, "error", err)
+			continue
+		}
+		if added+updated > 0 {
+			s.Notify(context.Background(), kintoneEventNotification, JsonMap{
+				"type":    "WATCH_UPDATE",
+				"watchID": w.ID,
+				"appID":   w.AppID,
+				"added":   added,
+				"updated": updated,
+			})
+		}
+	}
+}
+
+// checkWatch reads the records that are added or updated since the last check, and keeps them as the changes of the watch.
+func (h *KintoneHandlers) checkWatch(ctx context.Context, w *recordWatch) (added, updated int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	if err := h.checkPermissions(w.AppID); err != nil {
+		return 0, 0, err
+	}
+	acl, err := h.fieldACL(ctx, w.AppID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	w.mu.Lock()
+	since := w.since
+	w.mu.Unlock()
+
+	var conds []string
+	if cond, _ := splitQuery(h.recordDefaults(w.AppID).mergeQuery(w.Query)); cond != "" {
+		conds = append(conds, "("+cond+")")
+	}
+	if since != "" {
+		conds = append(conds, fmt.Sprintf("%s >= %q", w.updatedField, since))
+	}
+
+	filter := h.fieldFilter(w.AppID)
+	config := h.policy().Config
+	loc := h.timezone(ctx)
+	counts := make(maskCounts)
+	defer h.masking.record(ctx, fmt.Sprintf("watched records of app %s", w.AppID), counts)
+
+	// The records are read in pages that continue from the last record of the previous page, instead of the offset.
+	// Otherwise, if a page is filled by the records that are updated at the same time, such as by a bulk import, the same page is read forever.
+	var lastUpdated, lastID string
+	for {
+		pageConds := conds
+		if lastID != "" {
+			pageConds = append(slices.Clip(conds), fmt.Sprintf("(%[1]s > %[2]q or (%[1]s = %[2]q and $id > %[3]s))", w.updatedField, lastUpdated, lastID))
+		}
+		query := strings.TrimSpace(fmt.Sprintf("%s order by %s asc, $id asc limit %d", strings.Join(pageConds, " and "), w.updatedField, watchPageSize))
+
+		read := 0
+		_, err = h.fetchRecords(ctx, "GET", "/k/v1/records.json", nil, JsonMap{"app": w.AppID, "query": query}, func(record map[string]any) error {
+			read++
+			lastUpdated, lastID = recordFieldValue(record, w.updatedField), recordFieldValue(record, "$id")
+
+			w.mu.Lock()
+			defer w.mu.Unlock()
+
+			change := w.observe(record)
+			if change == "" {
+				return nil
+			}
+			if change == "added" {
+				added++
+			} else {
+				updated++
+			}
+
+			id := recordFieldValue(record, "$id")
+			acl.filterRecord(record)
+			filter.filterRecord(record)
+			config.maskRecord(record, counts)
+			localizeRecordTimes(record, loc)
+			if w.Format == recordFormatSimple {
+				record = simplifyRecord(record)
+			}
+
+			// A record that is updated again replaces the previous change, but it is still reported as added if it was added.
+			if i := slices.IndexFunc(w.changes, func(c JsonMap) bool { return c["recordID"] == id }); i >= 0 {
+				if w.changes[i]["change"] == "added" {
+					change = "added"
+				}
+				w.changes = slices.Delete(w.changes, i, i+1)
+			}
+			w.changes = append(w.changes, JsonMap{"recordID": id, "change": change, "record": record})
+			if len(w.changes) > maxWatchChanges {
+				w.changes = w.changes[len(w.changes)-maxWatchChanges:]
+				w.truncated = true
+			}
+			return nil
+		})
+		if err != nil || read < watchPageSize || lastID == "" {
+			break
+		}
+	}
+	return added, updated, err
+}
+
+type GetWatchUpdatesParams struct {
+	WatchID string `json:"watchID" description:"The watch ID that is returned by 'watchRecords' tool. Default is all watches in this session."`
+	Stop    bool   `json:"stop" description:"If true, stops the watch after returning the updates. Default is false."`
+}
+
+func (h *KintoneHandlers) GetWatchUpdates(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req GetWatchUpdatesParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+
+	s := SessionFromContext(ctx)
+	var watches []*recordWatch
+	if s != nil {
+		s.mu.Lock()
+		for id, w := range s.watches {
+			if req.WatchID == "" || req.WatchID == id {
+				watches = append(watches, w)
+				if req.Stop {
+					w.cancel()
+					delete(s.watches, id)
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	if req.WatchID != "" && len(watches) == 0 {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: fmt.Sprintf("Unknown watch ID: %s. The watches are lost when the session ends.", req.WatchID),
+		}
+	}
+	slices.SortFunc(watches, func(a, b *recordWatch) int { return strings.Compare(a.ID, b.ID) })
+
+	results := make([]JsonMap, 0, len(watches))
+	for _, w := range watches {
+		w.mu.Lock()
+		result := JsonMap{
+			"watchID":  w.ID,
+			"appID":    w.AppID,
+			"query":    w.Query,
+			"interval": w.Interval.String(),
+			"changes":  w.changes,
+			"stopped":  req.Stop,
+		}
+		if w.changes == nil {
+			result["changes"] = []JsonMap{}
+		}
+		if !w.lastChecked.IsZero() {
+			result["lastCheckedAt"] = w.lastChecked.In(h.timezone(ctx)).Format(time.RFC3339)
+		}
+		if w.truncated {
+			result["truncated"] = true
+			result["message"] = fmt.Sprintf("Only the last %d changes are kept. Call getWatchUpdates more often, or use readRecords to read all records.", maxWatchChanges)
+		}
+		if w.lastError != "" {
+			result["error"] = w.lastError
+		}
+		w.changes, w.truncated = nil, false
+		w.mu.Unlock()
+		results = append(results, result)
+	}
+
+	return JSONContent(JsonMap{"watches": results})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCheckWatchBulkUpdate(t *testing.T) {
+	// More records than a page are updated at the same time, such as by a bulk import.
+	const total = watchPageSize*2 + 100
+	records := make([]map[string]any, total)
+	for i := range records {
+		records[i] = map[string]any{"title": fmt.Sprintf("record %d", i+1)}
+	}
+	mock, err := newMockKintone(MockFixture{Apps: []MockAppFixture{{
+		Fields:  map[string]map[string]any{"title": {"type": "SINGLE_LINE_TEXT"}},
+		Records: records,
+	}}})
+	if err != nil {
+		t.Fatalf("failed to create the mock kintone: %v", err)
+	}
+	t.Setenv("KINTONE_USERNAME", mock.user)
+	h := newTestHandlersWithServer(t, mock, nil)
+	ctx := context.Background()
+
+	w := &recordWatch{AppID: "1", updatedField: "Updated_datetime", revisions: make(map[string]string)}
+
+	added, updated, err := h.checkWatch(ctx, w)
+	if err != nil {
+		t.Fatalf("failed to check the watch: %v", err)
+	}
+	if added != total || updated != 0 {
+		t.Errorf("expected %d added records in the first check but got added=%d updated=%d", total, added, updated)
+	}
+
+	added, updated, err = h.checkWatch(ctx, w)
+	if err != nil {
+		t.Fatalf("failed to check the watch: %v", err)
+	}
+	if added != 0 || updated != 0 {
+		t.Errorf("expected no changes but got added=%d updated=%d", added, updated)
+	}
+
+	// The record is after the first page, which was read forever when the page was filled by the records at the same time.
+	err = h.FetchHTTPWithJSON(ctx, "PUT", "/k/v1/record.json", nil, JsonMap{
+		"app":    "1",
+		"id":     fmt.Sprint(total),
+		"record": JsonMap{"title": JsonMap{"value": "updated"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to update the record: %v", err)
+	}
+
+	added, updated, err = h.checkWatch(ctx, w)
+	if err != nil {
+		t.Fatalf("failed to check the watch: %v", err)
+	}
+	if added != 0 || updated != 1 {
+		t.Errorf("expected the updated record to be reported but got added=%d updated=%d", added, updated)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxWebhookSize is the maximum size of a webhook request of kintone.
+const maxWebhookSize = 10 << 20
+
+// kintoneEventNotification is the method of the notifications that tell the clients about the webhooks of kintone.
+const kintoneEventNotification = "notifications/kintone/event"
+
+// webhookPayload is a request of the webhook of kintone.
+// The fields depend on the type, such as "record" for ADD_RECORD and "comment" for ADD_RECORD_COMMENT.
+type webhookPayload struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	App  struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"app"`
+	Record   map[string]any `json:"record"`
+	RecordID string         `json:"recordId"`
+	Comment  map[string]any `json:"comment"`
+	URL      string         `json:"url"`
+
+	Action     struct{ Value string } `json:"action"`
+	Status     struct{ Value string } `json:"status"`
+	NextStatus struct{ Value string } `json:"nextStatus"`
+}
+
+// recordID returns the ID of the record that the webhook is about.
+func (p webhookPayload) recordID() string {
+	if p.RecordID != "" {
+		return p.RecordID
+	}
+	if id, ok := p.Record["$id"].(map[string]any); ok {
+		if v, ok := id["value"].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// webhookHandler receives the webhooks of kintone, and forwards them to the clients.
+// The request has to provide the secret by the "secret" query parameter, the X-Webhook-Secret header, or the password of Basic authentication,
+// because kintone can't sign the webhooks.
+func (h *KintoneHandlers) webhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONResponse(w, http.StatusMethodNotAllowed, JsonMap{"error": "method not allowed"})
+			return
+		}
+
+		secret := r.URL.Query().Get("secret")
+		if secret == "" {
+			secret = r.Header.Get("X-Webhook-Secret")
+		}
+		if _, password, ok := r.BasicAuth(); secret == "" && ok {
+			secret = password
+		}
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(h.webhookSecret)) != 1 {
+			serverLog.Warn("Rejected a webhook with an invalid secret", "remoteAddr", r.RemoteAddr)
+			writeJSONResponse(w, http.StatusUnauthorized, JsonMap{"error": "invalid secret"})
+			return
+		}
+
+		var payload webhookPayload
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookSize)).Decode(&payload); err != nil || payload.Type == "" || payload.App.ID == "" {
+			writeJSONResponse(w, http.StatusBadRequest, JsonMap{"error": "invalid webhook payload"})
+			return
+		}
+
+		// The webhooks of the inaccessible apps are accepted but not forwarded, so that kintone doesn't report errors for them.
+		if err := h.checkPermissions(payload.App.ID); err != nil {
+			serverLog.Debug("Ignored a webhook of an inaccessible app", "type", payload.Type, "app", payload.App.ID)
+			writeJSONResponse(w, http.StatusOK, JsonMap{"status": "ignored"})
+			return
+		}
+
+		n := h.forwardWebhook(r.Context(), payload)
+		serverLog.Info("Received a webhook", "type", payload.Type, "app", payload.App.ID, "record", payload.recordID(), "sessions", n)
+		writeJSONResponse(w, http.StatusOK, JsonMap{"status": "ok"})
+	})
+}
+
+// forwardWebhook notifies the sessions of the webhook, and returns the number of the notified sessions.
+// The sessions with their own credentials are skipped, because they may not be able to access the app.
+func (h *KintoneHandlers) forwardWebhook(ctx context.Context, payload webhookPayload) int {
+	config := h.policy().Config
+	counts := make(maskCounts)
+
+	event := JsonMap{
+		"id":      payload.ID,
+		"type":    payload.Type,
+		"appID":   payload.App.ID,
+		"appName": payload.App.Name,
+	}
+	recordID := payload.recordID()
+	if recordID != "" {
+		event["recordID"] = recordID
+		event["uri"] = recordURI(payload.App.ID, recordID)
+	}
+	if payload.URL != "" {
+		event["url"] = payload.URL
+	}
+	// The record is shared by all sessions, so it is omitted if the field permissions differ for each user.
+	if payload.Record != nil && !h.FieldACL {
+		h.fieldFilter(payload.App.ID).filterRecord(payload.Record)
+		config.maskRecord(payload.Record, counts)
+		event["record"] = payload.Record
+	}
+	if payload.Comment != nil {
+		config.maskComment(payload.Comment, counts)
+		event["comment"] = payload.Comment
+	}
+	if payload.Action.Value != "" {
+		event["action"] = payload.Action.Value
+	}
+	if payload.Status.Value != "" {
+		event["status"] = payload.Status.Value
+		event["nextStatus"] = payload.NextStatus.Value
+	}
+	h.masking.record(ctx, fmt.Sprintf("webhook of app %s", payload.App.ID), counts)
+
+	notified := 0
+	for _, s := range initializedSessions() {
+		s.mu.Lock()
+		ownCredentials := s.auth != nil
+		_, subscribed := s.subscriptions[recordURI(payload.App.ID, recordID)]
+		s.mu.Unlock()
+		if ownCredentials {
+			continue
+		}
+
+		s.Notify(ctx, kintoneEventNotification, event)
+		if recordID != "" && subscribed {
+			s.Notify(ctx, "notifications/resources/updated", JsonMap{"uri": recordURI(payload.App.ID, recordID)})
+		}
+		notified++
+	}
+	return notified
+}
+
+// ServeWebhook receives the webhooks of kintone on addr until ctx is canceled.
+func ServeWebhook(ctx context.Context, addr string, tlsConfig *tls.Config, handler http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", handler)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	serverLog.Info("Webhook receiver is running", "url", fmt.Sprintf("%s://%s/webhook", scheme, addr))
+
+	errCh := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			errCh <- srv.ListenAndServeTLS("", "")
+		} else {
+			errCh <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// WebSocketTransport is a http.Handler that serves MCP over WebSocket.
+// Each message is sent as a text frame that contains a JSON-RPC message.
+type WebSocketTransport struct {
+	server *jsonrpc2.Server
+}
+
+// NewWebSocketTransport creates a new WebSocketTransport that handles requests with server.
+func NewWebSocketTransport(server *jsonrpc2.Server) *WebSocketTransport {
+	return &WebSocketTransport{server: server}
+}
+
+// wsWriter is an io.Writer that sends each written message as a text frame.
+type wsWriter struct {
+	ctx  context.Context
+	conn *websocket.Conn
+}
+
+func (w wsWriter) Write(p []byte) (int, error) {
+	if err := w.conn.Write(w.ctx, websocket.MessageText, bytes.TrimSpace(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *WebSocketTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isAllowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols: []string{"mcp"},
+	})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+
+	conn.SetReadLimit(maxHTTPRequestSize)
+
+	defer trackConnection(r.Context())()
+
+	var inflight drainGroup
+	defer inflight.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	s := NewSession(t.server, wsWriter{ctx: ctx, conn: conn})
+	defer s.Close()
+	s.credentials = credentialsFromHeader(r.Header)
+	s.user = authenticatedUser(r.Context())
+	ctx = s.context(ctx)
+
+	// At shutdown, wait for in-flight requests and then close the connection.
+	// Closing the connection also stops the loop below.
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-shuttingDown(ctx):
+			inflight.Close()
+			conn.Close(websocket.StatusGoingAway, "Server is shutting down")
+		}
+	}()
+
+	for {
+		typ, msg, err := conn.Read(ctx)
+		if err != nil {
+			status := websocket.CloseStatus(err)
+			if status != websocket.StatusNormalClosure && status != websocket.StatusGoingAway && !errors.Is(err, context.Canceled) {
+				serverLog.Warn("Failed to read WebSocket message", "error", err)
+			}
+			return
+		}
+		if typ != websocket.MessageText {
+			conn.Close(websocket.StatusUnsupportedData, "Only text messages are supported")
+			return
+		}
+
+		if isInitializeMessage(msg) {
+			if res := s.handleRaw(ctx, msg); res != nil {
+				s.write(res)
+			}
+			continue
+		}
+
+		inflight.Go(func() {
+			if res := s.handleRaw(ctx, msg); res != nil {
+				s.write(res)
+			}
+		})
+	}
+}
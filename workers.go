@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// defaultConcurrency is the number of sub-requests that a tool runs in parallel, such as downloading multiple files.
+// The requests are still limited by KINTONE_RATE_LIMIT and KINTONE_MAX_CONCURRENT_REQUESTS.
+const defaultConcurrency = 5
+
+// forEachConcurrently calls fn for each index from 0 to n-1, with at most KINTONE_CONCURRENCY calls at the same time.
+// After the first error, the context of the other calls is canceled and no more calls are started.
+func (h *KintoneHandlers) forEachConcurrently(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	indexes := make(chan int)
+	for range max(1, min(h.Concurrency, n)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range n {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// parseConcurrency reads KINTONE_CONCURRENCY.
+func parseConcurrency() (int, error) {
+	s := Getenv("KINTONE_CONCURRENCY", "")
+	if s == "" {
+		return defaultConcurrency, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return defaultConcurrency, fmt.Errorf("- KINTONE_CONCURRENCY must be a positive integer: %s", s)
+	}
+	return n, nil
+}
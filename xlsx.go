@@ -0,0 +1,467 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/macrat/go-jsonrpc2"
+)
+
+// The styles of cells in the workbook, which are the indexes of cellXfs in xlsxStyles.
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleDate
+	xlsxStyleDateTime
+	xlsxStyleTime
+)
+
+// xlsxStyles is the styles of the workbook, that show dates and times in the ISO style.
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="3"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm"/><numFmt numFmtId="166" formatCode="hh:mm"/></numFmts>
+<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/><xf numFmtId="166" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>
+</styleSheet>`
+
+// xlsxEpoch is the day zero of the serial numbers of dates in Excel.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxMaxSheetName is the maximum length of sheet names in Excel.
+const xlsxMaxSheetName = 31
+
+// xlsxCell is a typed value of a cell.
+// If Number is true, Value is a number, and Style decides how it is shown.
+type xlsxCell struct {
+	Value  string
+	Number bool
+	Style  int
+}
+
+// xlsxSheet is a worksheet that is written to a temporary file, so that the workbook can have many rows without keeping them in memory.
+type xlsxSheet struct {
+	Name string
+	Rows int
+
+	f *os.File
+	w *bufio.Writer
+}
+
+func newXLSXSheet(name string) (*xlsxSheet, error) {
+	f, err := os.CreateTemp("", "kintone-sheet-*.xml")
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxSheet{Name: name, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// WriteRow appends a row to the sheet.
+func (s *xlsxSheet) WriteRow(cells []xlsxCell) error {
+	s.Rows++
+	fmt.Fprintf(s.w, `<row r="%d">`, s.Rows)
+	for _, c := range cells {
+		switch {
+		case c.Number:
+			fmt.Fprintf(s.w, `<c s="%d"><v>%s</v></c>`, c.Style, c.Value)
+		case c.Value == "":
+			s.w.WriteString(`<c/>`)
+		default:
+			s.w.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(s.w, []byte(c.Value))
+			s.w.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := s.w.WriteString(`</row>`)
+	return err
+}
+
+// Close removes the temporary file.
+func (s *xlsxSheet) Close() error {
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
+
+// writeXLSX writes a workbook that has the sheets.
+func writeXLSX(w io.Writer, sheets []*xlsxSheet) error {
+	zw := zip.NewWriter(w)
+
+	var types, workbook, rels strings.Builder
+	types.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+	for i, s := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlAttr(s.Name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`</Relationships>`)
+
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", types.String()},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+
+	for i, s := range sheets {
+		if err := s.w.Flush(); err != nil {
+			return err
+		}
+		if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		fw, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		// Freeze the header row, so that the field codes are always visible.
+		io.WriteString(fw, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`)
+		if _, err := io.Copy(fw, s.f); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, `</sheetData></worksheet>`); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// xmlAttr escapes the string for an attribute value of XML.
+func xmlAttr(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// xlsxSheetName makes a valid sheet name, which is up to 31 characters without some symbols.
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > xlsxMaxSheetName {
+		name = string(r[:xlsxMaxSheetName])
+	}
+
+	base := []rune(name)
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		name = string(base[:min(len(base), xlsxMaxSheetName-len(suffix))]) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// xlsxSerial converts the wall clock of the time into the serial number of Excel.
+func xlsxSerial(t time.Time) string {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return strconv.FormatFloat(wall.Sub(xlsxEpoch).Hours()/24, 'f', -1, 64)
+}
+
+// xlsxFieldCell converts a field value of kintone into a cell.
+// Dates and numbers become typed cells, and the values that Excel can't keep as numbers, such as numbers with leading zeros, are kept as strings.
+func xlsxFieldCell(fieldType string, value any) xlsxCell {
+	s, isString := value.(string)
+
+	switch fieldType {
+	case "NUMBER", "CALC", "__ID__", "__REVISION__", "RECORD_NUMBER":
+		if isString && isExactNumber(s) {
+			return xlsxCell{Value: s, Number: true}
+		}
+	case "DATE":
+		if t, err := time.Parse(kintoneDateLayout, s); err == nil {
+			return xlsxCell{Value: xlsxSerial(t), Number: true, Style: xlsxStyleDate}
+		}
+	case "TIME":
+		if t, err := time.Parse(kintoneTimeLayout, s); err == nil {
+			// Times are the fractions of a day.
+			return xlsxCell{Value: xlsxSerial(xlsxEpoch.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)), Number: true, Style: xlsxStyleTime}
+		}
+	case "DATETIME", "CREATED_TIME", "UPDATED_TIME":
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return xlsxCell{Value: xlsxSerial(t), Number: true, Style: xlsxStyleDateTime}
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return xlsxCell{}
+	case string:
+		return xlsxCell{Value: v}
+	case map[string]any:
+		// CREATOR and MODIFIER.
+		return xlsxCell{Value: entityLabel(v)}
+	case []any:
+		// kintone puts multiple values in a cell with newlines in CSV files, and Excel shows them in lines.
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				values = append(values, item)
+			case map[string]any:
+				values = append(values, entityLabel(item))
+			}
+		}
+		return xlsxCell{Value: strings.Join(values, "\n")}
+	default:
+		bs, _ := json.Marshal(v)
+		return xlsxCell{Value: string(bs)}
+	}
+}
+
+// entityLabel returns the name of a user, an organization, a group, or a file.
+func entityLabel(v map[string]any) string {
+	for _, key := range []string{"name", "code", "fileKey"} {
+		if s, ok := v[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// isExactNumber reports whether s is a number that Excel can keep without losing precision.
+// Numbers with leading zeros such as "007" are not, because they look like codes rather than numbers.
+func isExactNumber(s string) bool {
+	if _, err := strconv.ParseFloat(s, 64); err != nil || strings.ContainsAny(s, "+_eEiInNxXpP") {
+		return false
+	}
+	digits := strings.TrimPrefix(s, "-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return false
+	}
+	return len(strings.TrimLeft(strings.Replace(digits, ".", "", 1), "0")) <= 15
+}
+
+type ExportRecordsXLSXParams struct {
+	AppID     string   `json:"appID" required:"true" description:"The app ID to export records from."`
+	Query     string   `json:"query" description:"The query to filter records. Query format is the same as kintone's query format, but 'limit' and 'offset' are not allowed. Default is all records."`
+	Fields    []string `json:"fields" description:"The field codes to export, in the order of the columns. Default is all fields, or the fields set by the server for the app."`
+	Subtables bool     `json:"subtables" description:"If true, the rows of each table are exported into a separate sheet with the record ID. Otherwise, the tables are exported as JSON in a cell. Default is false."`
+}
+
+func (h *KintoneHandlers) ExportRecordsXLSX(ctx context.Context, params json.RawMessage) ([]Content, error) {
+	var req ExportRecordsXLSXParams
+	if err := UnmarshalParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.AppID == "" {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InvalidParamsCode,
+			Message: "Argument 'appID' is required",
+		}
+	}
+
+	if err := h.checkPermissions(req.AppID); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuery(ctx, req.AppID, req.Query); err != nil {
+		return nil, err
+	}
+	defaults := h.recordDefaults(req.AppID)
+	if len(req.Fields) == 0 {
+		req.Fields = defaults.Fields
+	}
+
+	fields, err := h.formFields(ctx, req.AppID)
+	if err != nil {
+		return nil, err
+	}
+	filter := h.fieldFilter(req.AppID)
+
+	codes := req.Fields
+	if len(codes) == 0 {
+		for _, code := range slices.Sorted(maps.Keys(fields)) {
+			if t := fields[code].Type; t != "GROUP" && t != "REFERENCE_TABLE" && filter.allowed("", code) {
+				codes = append(codes, code)
+			}
+		}
+	}
+	for _, code := range codes {
+		if _, ok := fields[code]; !ok {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InvalidParamsCode,
+				Message: fmt.Sprintf("Unknown field code: %s. Please check the field codes by using 'readAppInfo' tool.", code),
+			}
+		}
+		if !filter.allowed("", code) {
+			return nil, fieldDeniedError(req.AppID, code)
+		}
+	}
+
+	dir, err := h.downloadDirectory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	records, err := newXLSXSheet(xlsxSheetName("records", used))
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to create a temporary file: %v", err),
+		}
+	}
+	sheets := []*xlsxSheet{records}
+	defer func() {
+		for _, s := range sheets {
+			s.Close()
+		}
+	}()
+
+	header := []xlsxCell{{Value: "$id"}}
+	tables := make(map[string]*xlsxSheet)
+	for _, code := range codes {
+		f := fields[code]
+		if f.Type != "SUBTABLE" || !req.Subtables {
+			header = append(header, xlsxCell{Value: code})
+			continue
+		}
+
+		sheet, err := newXLSXSheet(xlsxSheetName(code, used))
+		if err != nil {
+			return nil, jsonrpc2.Error{
+				Code:    jsonrpc2.InternalErrorCode,
+				Message: fmt.Sprintf("Failed to create a temporary file: %v", err),
+			}
+		}
+		sheets = append(sheets, sheet)
+		tables[code] = sheet
+
+		tableHeader := []xlsxCell{{Value: "$id"}, {Value: "rowID"}}
+		for _, cell := range slices.Sorted(maps.Keys(f.Fields)) {
+			tableHeader = append(tableHeader, xlsxCell{Value: cell})
+		}
+		sheet.WriteRow(tableHeader)
+	}
+	records.WriteRow(header)
+
+	count := 0
+	err = h.eachRecordByCursor(ctx, req.AppID, defaults.mergeQuery(req.Query), append([]string{"$id"}, codes...), func(record map[string]any) error {
+		id, _ := record["$id"].(map[string]any)
+		idCell := xlsxFieldCell("__ID__", id["value"])
+
+		row := []xlsxCell{idCell}
+		for _, code := range codes {
+			field, _ := record[code].(map[string]any)
+			fieldType, _ := field["type"].(string)
+			if fieldType != "SUBTABLE" {
+				row = append(row, xlsxFieldCell(fieldType, field["value"]))
+				continue
+			}
+
+			sheet, ok := tables[code]
+			if !ok {
+				bs, _ := json.Marshal(simplifyRecord(map[string]any{code: field})[code])
+				row = append(row, xlsxCell{Value: string(bs)})
+				continue
+			}
+
+			rows, _ := field["value"].([]any)
+			for _, r := range rows {
+				r, _ := r.(map[string]any)
+				cells, _ := r["value"].(map[string]any)
+				rowID, _ := r["id"].(string)
+				tableRow := []xlsxCell{idCell, xlsxFieldCell("__ID__", rowID)}
+				for _, cellCode := range slices.Sorted(maps.Keys(fields[code].Fields)) {
+					cell, _ := cells[cellCode].(map[string]any)
+					cellType, _ := cell["type"].(string)
+					tableRow = append(tableRow, xlsxFieldCell(cellType, cell["value"]))
+				}
+				if err := sheet.WriteRow(tableRow); err != nil {
+					return err
+				}
+			}
+		}
+
+		count++
+		if count%cursorSize == 0 {
+			ReportProgress(ctx, float64(count), 0, fmt.Sprintf("Exported %d records", count))
+		}
+		return records.WriteRow(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tmpFile, err := os.CreateTemp(dir, ".kintone-export-*")
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to create the file to export: %v", err),
+			Data:    JsonMap{"directory": dir},
+		}
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+	defer tmpFile.Close()
+
+	if err := writeXLSX(tmpFile, sheets); err == nil {
+		err = tmpFile.Close()
+	}
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to write the workbook: %v", err),
+		}
+	}
+
+	saveFileMu.Lock()
+	outPath := getDownloadFilePath(dir, fmt.Sprintf("app%s-records.xlsx", req.AppID))
+	err = os.Rename(tmpPath, outPath)
+	saveFileMu.Unlock()
+	if err != nil {
+		return nil, jsonrpc2.Error{
+			Code:    jsonrpc2.InternalErrorCode,
+			Message: fmt.Sprintf("Failed to save the workbook: %s: %v", outPath, err),
+		}
+	}
+
+	sheetNames := make([]string, len(sheets))
+	for i, s := range sheets {
+		sheetNames[i] = s.Name
+	}
+	return JSONContent(JsonMap{
+		"success":  true,
+		"filePath": outPath,
+		"records":  count,
+		"sheets":   sheetNames,
+		"fileName": filepath.Base(outPath),
+		"timezone": h.timezone(ctx).String(),
+	})
+}